@@ -220,6 +220,8 @@ func runCompact(
 		level.Info(logger).Log(
 			"msg", "vertical compaction is enabled", "compact.enable-vertical-compaction", fmt.Sprintf("%v", conf.enableVerticalCompaction),
 		)
+	} else if conf.mergeOverlaps {
+		level.Info(logger).Log("msg", "merging of overlapping blocks of the same stream is enabled")
 	}
 
 	compactorView := ui.NewBucketUI(
@@ -289,6 +291,7 @@ func runCompact(
 	var (
 		compactDir      = path.Join(conf.dataDir, "compact")
 		downsamplingDir = path.Join(conf.dataDir, "downsample")
+		deletionDir     = path.Join(conf.dataDir, "deletion")
 	)
 
 	if err := os.MkdirAll(compactDir, os.ModePerm); err != nil {
@@ -304,11 +307,47 @@ func runCompact(
 		bkt,
 		conf.acceptMalformedIndex,
 		enableVerticalCompaction,
+		conf.mergeOverlaps,
+		conf.enableBloomFilters,
 		reg,
 		blocksMarked.WithLabelValues(metadata.DeletionMarkFilename),
 		garbageCollectedBlocks,
 		metadata.HashFunc(conf.hashFunc),
 	)
+	downsamplingLevels, err := conf.downsampling.parse()
+	if err != nil {
+		return err
+	}
+	for _, l := range downsamplingLevels[1:] {
+		if l.MinSourceRange > levels[len(levels)-1] {
+			level.Warn(logger).Log("msg", "downsampling level requires blocks bigger than the maximum compaction range; it will never be produced", "level", l.String())
+		}
+	}
+
+	// Progress estimates the backlog with the planner without the index size filter, as it uploads no-compact marks.
+	progressLevels := downsamplingLevels
+	if conf.disableDownsampling {
+		progressLevels = nil
+	}
+	progress := compact.NewProgress(reg, compact.NewPlanner(logger, levels, noCompactMarkerFilter), progressLevels)
+
+	api.SetProgress(progress)
+
+	var deletionRequestsProcessor *compact.DeletionRequestsProcessor
+	if conf.enableDeletionRequests {
+		deletionRequestsProcessor = compact.NewDeletionRequestsProcessor(
+			logger,
+			reg,
+			bkt,
+			deletionDir,
+			ignoreDeletionMarkFilter,
+			noCompactMarkerFilter,
+			metadata.HashFunc(conf.hashFunc),
+			blocksMarked.WithLabelValues(metadata.DeletionMarkFilename),
+		)
+		api.SetDeletionRequests(compact.NewDeletionRequests(logger, bkt))
+	}
+
 	blocksCleaner := compact.NewBlocksCleaner(logger, bkt, ignoreDeletionMarkFilter, deleteDelay, blocksCleaned, blockCleanupFailures)
 	compactor, err := compact.NewBucketCompactor(
 		logger,
@@ -324,25 +363,27 @@ func runCompact(
 		compactDir,
 		bkt,
 		conf.compactionConcurrency,
+		progress,
 	)
 	if err != nil {
 		return errors.Wrap(err, "create bucket compactor")
 	}
 
-	retentionByResolution := map[compact.ResolutionLevel]time.Duration{
-		compact.ResolutionLevelRaw: time.Duration(conf.retentionRaw),
-		compact.ResolutionLevel5m:  time.Duration(conf.retentionFiveMin),
-		compact.ResolutionLevel1h:  time.Duration(conf.retentionOneHr),
-	}
-
-	if retentionByResolution[compact.ResolutionLevelRaw].Seconds() != 0 {
-		level.Info(logger).Log("msg", "retention policy of raw samples is enabled", "duration", retentionByResolution[compact.ResolutionLevelRaw])
+	retentionByResolution, err := conf.retention.byResolution(downsamplingLevels)
+	if err != nil {
+		return err
 	}
-	if retentionByResolution[compact.ResolutionLevel5m].Seconds() != 0 {
-		level.Info(logger).Log("msg", "retention policy of 5 min aggregated samples is enabled", "duration", retentionByResolution[compact.ResolutionLevel5m])
+	for _, r := range downsamplingLevels.Resolutions() {
+		if retentionByResolution[compact.ResolutionLevel(r)].Seconds() != 0 {
+			level.Info(logger).Log("msg", "retention policy is enabled", "resolution", time.Duration(r)*time.Millisecond, "duration", retentionByResolution[compact.ResolutionLevel(r)])
+		}
 	}
-	if retentionByResolution[compact.ResolutionLevel1h].Seconds() != 0 {
-		level.Info(logger).Log("msg", "retention policy of 1 hour aggregated samples is enabled", "duration", retentionByResolution[compact.ResolutionLevel1h])
+
+	var bucketIndexUpdater *block.BucketIndexUpdater
+	if conf.updateBucketIndex {
+		// The index has to contain all blocks, so the fetcher has no filters.
+		f := baseMetaFetcher.NewMetaFetcher(extprom.WrapRegistererWithPrefix("thanos_bucket_index_", reg), nil, nil, "component", "bucketIndex")
+		bucketIndexUpdater = block.NewBucketIndexUpdater(logger, reg, bkt, f, conf.blockMetaFetchConcurrency)
 	}
 
 	var cleanMtx sync.Mutex
@@ -362,38 +403,44 @@ func runCompact(
 		}
 		cleanups.Inc()
 
+		if bucketIndexUpdater != nil {
+			// Failed update does not stop the compactor; readers fall back to iterating the bucket once the index is stale.
+			if _, err := bucketIndexUpdater.Update(ctx); err != nil {
+				level.Warn(logger).Log("msg", "failed to update bucket index", "err", err)
+			}
+		}
 		return nil
 	}
 
 	compactMainFn := func() error {
+		progress.StartIteration()
+		defer progress.FinishIteration()
+
 		if err := compactor.Compact(ctx); err != nil {
 			return errors.Wrap(err, "compaction")
 		}
 
 		if !conf.disableDownsampling {
 			// After all compactions are done, work down the downsampling backlog.
-			// We run two passes of this to ensure that the 1h downsampling is generated
-			// for 5m downsamplings created in the first run.
-			level.Info(logger).Log("msg", "start first pass of downsampling")
-			if err := sy.SyncMetas(ctx); err != nil {
-				return errors.Wrap(err, "sync before first pass of downsampling")
-			}
-
-			for _, meta := range sy.Metas() {
-				groupKey := compact.DefaultGroupKey(meta.Thanos)
-				downsampleMetrics.downsamples.WithLabelValues(groupKey)
-				downsampleMetrics.downsampleFailures.WithLabelValues(groupKey)
-			}
-			if err := downsampleBucket(ctx, logger, downsampleMetrics, bkt, sy.Metas(), downsamplingDir, metadata.HashFunc(conf.hashFunc)); err != nil {
-				return errors.Wrap(err, "first pass of downsampling failed")
-			}
+			// We run one pass per downsampling level to ensure that every level is generated
+			// for downsampled blocks created in previous passes.
+			for pass := 1; pass < len(downsamplingLevels); pass++ {
+				level.Info(logger).Log("msg", "start pass of downsampling", "pass", pass)
+				if err := sy.SyncMetas(ctx); err != nil {
+					return errors.Wrapf(err, "sync before pass %d of downsampling", pass)
+				}
 
-			level.Info(logger).Log("msg", "start second pass of downsampling")
-			if err := sy.SyncMetas(ctx); err != nil {
-				return errors.Wrap(err, "sync before second pass of downsampling")
-			}
-			if err := downsampleBucket(ctx, logger, downsampleMetrics, bkt, sy.Metas(), downsamplingDir, metadata.HashFunc(conf.hashFunc)); err != nil {
-				return errors.Wrap(err, "second pass of downsampling failed")
+				if pass == 1 {
+					for _, meta := range sy.Metas() {
+						groupKey := compact.DefaultGroupKey(meta.Thanos)
+						downsampleMetrics.downsamples.WithLabelValues(groupKey)
+						downsampleMetrics.downsampleFailures.WithLabelValues(groupKey)
+					}
+				}
+				progress.StartDownsampling(sy.Metas())
+				if err := downsampleBucket(ctx, logger, downsampleMetrics, bkt, sy.Metas(), downsamplingDir, downsamplingLevels, metadata.HashFunc(conf.hashFunc), conf.enableBloomFilters, progress); err != nil {
+					return errors.Wrapf(err, "pass %d of downsampling failed", pass)
+				}
 			}
 			level.Info(logger).Log("msg", "downsampling iterations done")
 		} else {
@@ -405,10 +452,17 @@ func runCompact(
 			return errors.Wrap(err, "sync before first pass of downsampling")
 		}
 
+		progress.StartRetention()
 		if err := compact.ApplyRetentionPolicyByResolution(ctx, logger, bkt, sy.Metas(), retentionByResolution, blocksMarked.WithLabelValues(metadata.DeletionMarkFilename)); err != nil {
 			return errors.Wrap(err, "retention failed")
 		}
 
+		if deletionRequestsProcessor != nil {
+			if err := deletionRequestsProcessor.Process(ctx, sy.Metas()); err != nil {
+				return errors.Wrap(err, "deletion requests failed")
+			}
+		}
+
 		return cleanPartialMarked()
 	}
 
@@ -519,30 +573,35 @@ func runCompact(
 }
 
 type compactConfig struct {
-	haltOnError                                    bool
-	acceptMalformedIndex                           bool
-	maxCompactionLevel                             int
-	http                                           httpConfig
-	dataDir                                        string
-	objStore                                       extflag.PathOrContent
-	consistencyDelay                               time.Duration
-	retentionRaw, retentionFiveMin, retentionOneHr model.Duration
-	wait                                           bool
-	waitInterval                                   time.Duration
-	disableDownsampling                            bool
-	blockSyncConcurrency                           int
-	blockMetaFetchConcurrency                      int
-	blockViewerSyncBlockInterval                   time.Duration
-	cleanupBlocksInterval                          time.Duration
-	compactionConcurrency                          int
-	deleteDelay                                    model.Duration
-	dedupReplicaLabels                             []string
-	selectorRelabelConf                            extflag.PathOrContent
-	webConf                                        webConfig
-	label                                          string
-	maxBlockIndexSize                              units.Base2Bytes
-	hashFunc                                       string
-	enableVerticalCompaction                       bool
+	haltOnError                  bool
+	acceptMalformedIndex         bool
+	maxCompactionLevel           int
+	http                         httpConfig
+	dataDir                      string
+	objStore                     extflag.PathOrContent
+	consistencyDelay             time.Duration
+	retention                    retentionConfig
+	downsampling                 downsamplingConfig
+	wait                         bool
+	waitInterval                 time.Duration
+	disableDownsampling          bool
+	blockSyncConcurrency         int
+	blockMetaFetchConcurrency    int
+	blockViewerSyncBlockInterval time.Duration
+	cleanupBlocksInterval        time.Duration
+	compactionConcurrency        int
+	deleteDelay                  model.Duration
+	dedupReplicaLabels           []string
+	selectorRelabelConf          extflag.PathOrContent
+	webConf                      webConfig
+	label                        string
+	updateBucketIndex            bool
+	maxBlockIndexSize            units.Base2Bytes
+	hashFunc                     string
+	enableVerticalCompaction     bool
+	mergeOverlaps                bool
+	enableDeletionRequests       bool
+	enableBloomFilters           bool
 }
 
 func (cc *compactConfig) registerFlag(cmd extkingpin.FlagClause) {
@@ -564,13 +623,7 @@ func (cc *compactConfig) registerFlag(cmd extkingpin.FlagClause) {
 	cmd.Flag("consistency-delay", fmt.Sprintf("Minimum age of fresh (non-compacted) blocks before they are being processed. Malformed blocks older than the maximum of consistency-delay and %v will be removed.", compact.PartialUploadThresholdAge)).
 		Default("30m").DurationVar(&cc.consistencyDelay)
 
-	cmd.Flag("retention.resolution-raw",
-		"How long to retain raw samples in bucket. Setting this to 0d will retain samples of this resolution forever").
-		Default("0d").SetValue(&cc.retentionRaw)
-	cmd.Flag("retention.resolution-5m", "How long to retain samples of resolution 1 (5 minutes) in bucket. Setting this to 0d will retain samples of this resolution forever").
-		Default("0d").SetValue(&cc.retentionFiveMin)
-	cmd.Flag("retention.resolution-1h", "How long to retain samples of resolution 2 (1 hour) in bucket. Setting this to 0d will retain samples of this resolution forever").
-		Default("0d").SetValue(&cc.retentionOneHr)
+	cc.retention.registerFlag(cmd)
 
 	// TODO(kakkoyun, pgough): https://github.com/thanos-io/thanos/issues/2266.
 	cmd.Flag("wait", "Do not exit after all compactions have been processed and wait for new work.").
@@ -582,6 +635,8 @@ func (cc *compactConfig) registerFlag(cmd extkingpin.FlagClause) {
 		"as querying long time ranges without non-downsampled data is not efficient and useful e.g it is not possible to render all samples for a human eye anyway").
 		Default("false").BoolVar(&cc.disableDownsampling)
 
+	cc.downsampling.registerFlag(cmd)
+
 	cmd.Flag("block-sync-concurrency", "Number of goroutines to use when syncing block metadata from object storage.").
 		Default("20").IntVar(&cc.blockSyncConcurrency)
 	cmd.Flag("block-meta-fetch-concurrency", "Number of goroutines to use when fetching block metadata from object storage.").
@@ -591,6 +646,10 @@ func (cc *compactConfig) registerFlag(cmd extkingpin.FlagClause) {
 	cmd.Flag("compact.cleanup-interval", "How often we should clean up partially uploaded blocks and blocks with deletion mark in the background when --wait has been enabled. Setting it to \"0s\" disables it - the cleaning will only happen at the end of an iteration.").
 		Default("5m").DurationVar(&cc.cleanupBlocksInterval)
 
+	cmd.Flag("bucket-index.update", "If true, compactor maintains the bucket index with metadata and markers of all blocks in the bucket. "+
+		"The index is updated after each cleanup of blocks and allows other components to discover blocks without iterating the bucket (see --bucket-index.enabled on store).").
+		Default("false").BoolVar(&cc.updateBucketIndex)
+
 	cmd.Flag("compact.concurrency", "Number of goroutines to use when compacting groups.").
 		Default("1").IntVar(&cc.compactionConcurrency)
 
@@ -606,6 +665,19 @@ func (cc *compactConfig) registerFlag(cmd extkingpin.FlagClause) {
 		"NOTE: This flag is ignored and (enabled) when --deduplication.replica-label flag is set.").
 		Hidden().Default("false").BoolVar(&cc.enableVerticalCompaction)
 
+	cmd.Flag("compact.merge-overlapping-blocks", "When set to true, compactor merges overlapping blocks with the same external labels instead of halting, "+
+		"e.g. blocks uploaded again by Receive after a crash. Unlike vertical compaction, only the overlapping blocks are merged and any other overlap of the compacted block still halts the compactor. "+
+		"Merged blocks are recorded in the overlap_merges field of meta.json. Ignored when vertical compaction is enabled.").
+		Default("false").BoolVar(&cc.mergeOverlaps)
+
+	cmd.Flag("compact.enable-deletion-requests", "When set to true, compactor accepts series deletion requests on /api/v1/deletion_requests endpoint and applies them by rewriting the affected blocks "+
+		"at the end of each iteration. Requests are persisted in the deletion-requests directory of the bucket. This process is irreversible.").
+		Default("false").BoolVar(&cc.enableDeletionRequests)
+
+	cmd.Flag("compact.enable-bloom-filters", "When set to true, compactor writes a bloom filter of the label pairs of each compacted and downsampled block into the "+
+		"bloom-filter file of the block. Store gateways use it to skip blocks that cannot match equality matchers of a query, without reading their index.").
+		Default("false").BoolVar(&cc.enableBloomFilters)
+
 	cmd.Flag("deduplication.replica-label", "Label to treat as a replica indicator of blocks that can be deduplicated (repeated flag). This will merge multiple replica blocks into one. This process is irreversible."+
 		"Experimental. When it is set to true, compactor will ignore the given labels so that vertical compaction can merge the blocks."+
 		"Please note that this uses a NAIVE algorithm for merging (no smart replica deduplication, just chaining samples together)."+
@@ -1,15 +1,21 @@
 // Copyright (c) The Thanos Authors.
 // Licensed under the Apache License 2.0.
 
-//nolint:unparam
 // TODO(kakkoyun): Fix linter issues - The pattern we use makes linter unhappy (returning unused config pointers).
+//
+//nolint:unparam
 package main
 
 import (
 	"net/url"
+	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/compact"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
 	"github.com/thanos-io/thanos/pkg/extkingpin"
 )
 
@@ -161,3 +167,139 @@ func (wc *webConfig) registerFlag(cmd extkingpin.FlagClause) *webConfig {
 	cmd.Flag("web.disable-cors", "Whether to disable CORS headers to be set by Thanos. By default Thanos sets CORS headers to be allowed by all.").Default("false").BoolVar(&wc.disableCORS)
 	return wc
 }
+
+type downsamplingConfig struct {
+	levels []string
+}
+
+func (dc *downsamplingConfig) registerFlag(cmd extkingpin.FlagClause) *downsamplingConfig {
+	cmd.Flag("downsampling.level",
+		"Downsampling level in the <resolution>:<min-source-range> form (repeated flag). Blocks of the previous level (raw data for the first one) are downsampled "+
+			"into this resolution once they span at least the min source range. Raw resolution is always implied. "+
+			"All components working with downsampled blocks should be configured with the same levels.").
+		Default("5m:40h", "1h:10d").PlaceHolder("<resolution>:<min-source-range>").StringsVar(&dc.levels)
+	return dc
+}
+
+func (dc *downsamplingConfig) parse() (downsample.Levels, error) {
+	levels, err := downsample.ParseLevels(dc.levels)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse downsampling levels")
+	}
+	return levels, nil
+}
+
+type retentionConfig struct {
+	raw, fiveMin, oneHr model.Duration
+	resolutions         []string
+}
+
+func (rc *retentionConfig) registerFlag(cmd extkingpin.FlagClause) *retentionConfig {
+	cmd.Flag("retention.resolution-raw",
+		"How long to retain raw samples in bucket. Setting this to 0d will retain samples of this resolution forever").
+		Default("0d").SetValue(&rc.raw)
+	cmd.Flag("retention.resolution-5m", "How long to retain samples of resolution 1 (5 minutes) in bucket. Setting this to 0d will retain samples of this resolution forever").
+		Default("0d").SetValue(&rc.fiveMin)
+	cmd.Flag("retention.resolution-1h", "How long to retain samples of resolution 2 (1 hour) in bucket. Setting this to 0d will retain samples of this resolution forever").
+		Default("0d").SetValue(&rc.oneHr)
+	cmd.Flag("retention.resolution", "How long to retain samples of the given resolution in bucket, in the <resolution>=<duration> form (repeated flag). "+
+		"It allows to set retention for custom downsampling levels and takes precedence over the resolution specific retention flags. Setting the duration to 0d will retain samples of this resolution forever").
+		PlaceHolder("<resolution>=<duration>").StringsVar(&rc.resolutions)
+	return rc
+}
+
+// byResolution returns retention durations for all given downsampling levels.
+func (rc *retentionConfig) byResolution(levels downsample.Levels) (map[compact.ResolutionLevel]time.Duration, error) {
+	retention := map[compact.ResolutionLevel]time.Duration{
+		compact.ResolutionLevelRaw: time.Duration(rc.raw),
+	}
+	for res, d := range map[compact.ResolutionLevel]model.Duration{
+		compact.ResolutionLevel5m: rc.fiveMin,
+		compact.ResolutionLevel1h: rc.oneHr,
+	} {
+		if d == 0 {
+			continue
+		}
+		if !levels.Contains(int64(res)) {
+			return nil, errors.Errorf("retention set for resolution %v which is not a configured downsampling level", time.Duration(res)*time.Millisecond)
+		}
+		retention[res] = time.Duration(d)
+	}
+
+	for _, r := range rc.resolutions {
+		parts := strings.Split(r, "=")
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid retention %q, expected <resolution>=<duration>", r)
+		}
+		res, err := model.ParseDuration(parts[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse resolution of retention %q", r)
+		}
+		d, err := model.ParseDuration(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse duration of retention %q", r)
+		}
+		if !levels.Contains(time.Duration(res).Milliseconds()) {
+			return nil, errors.Errorf("retention set for resolution %v which is not a configured downsampling level", res)
+		}
+		retention[compact.ResolutionLevel(time.Duration(res).Milliseconds())] = time.Duration(d)
+	}
+	return retention, nil
+}
+
+type bucketIndexConfig struct {
+	enabled      bool
+	maxStaleness time.Duration
+}
+
+func (bc *bucketIndexConfig) registerFlag(cmd extkingpin.FlagClause) *bucketIndexConfig {
+	cmd.Flag("bucket-index.enabled",
+		"If true, block metadata and markers are read from the bucket index maintained by the compactor (see --bucket-index.update on compactor) instead of iterating the bucket. "+
+			"Iterating the bucket is still used if the index does not exist or is stale.").
+		Default("false").BoolVar(&bc.enabled)
+	cmd.Flag("bucket-index.max-stale-period", "Maximum age of the bucket index. Older index is ignored and the bucket is iterated instead. 0s means the index is never considered stale.").
+		Default("1h").DurationVar(&bc.maxStaleness)
+	return bc
+}
+
+type warmupConfig struct {
+	enabled       bool
+	labelNames    []string
+	maxLabelPairs int
+}
+
+func (wc *warmupConfig) registerFlag(cmd extkingpin.FlagClause) *warmupConfig {
+	cmd.Flag("store.warmup.enabled", "If true, new blocks are warmed up before they are queryable: their index-header is loaded and the postings of the label pairs "+
+		"most frequently used by past queries are fetched into the index cache. Readiness passes only after the blocks of the initial sync are warmed up.").
+		Default("false").BoolVar(&wc.enabled)
+	cmd.Flag("store.warmup.label-names", "Names of labels whose equality matchers in Series requests are recorded in the warm up query log (repeated).").
+		Default("__name__", "job").StringsVar(&wc.labelNames)
+	cmd.Flag("store.warmup.max-label-pairs", "Maximum number of the most frequently used label pairs whose postings are fetched when warming up a block.").
+		Default("1000").IntVar(&wc.maxLabelPairs)
+	return wc
+}
+
+type shardingConfig struct {
+	shardIndex        uint64
+	shardTotal        uint64
+	replicationFactor uint64
+}
+
+func (sc *shardingConfig) registerFlag(cmd extkingpin.FlagClause) *shardingConfig {
+	cmd.Flag("store.shard-total", "Total number of shards blocks are split into by the hash of their ULID. "+
+		"Each shard serves only its blocks and advertises their label sets and time range. 0 disables sharding.").
+		Default("0").Uint64Var(&sc.shardTotal)
+	cmd.Flag("store.shard-index", "Index of the shard served, between 0 and --store.shard-total - 1.").
+		Default("0").Uint64Var(&sc.shardIndex)
+	cmd.Flag("store.shard-replication-factor", "Number of consecutive shards each block is assigned to, starting from the shard selected by the hash of its ULID.").
+		Default("1").Uint64Var(&sc.replicationFactor)
+	return sc
+}
+
+// filter returns the filter of blocks not assigned to the shard or nil if sharding is disabled.
+func (sc *shardingConfig) filter() (block.MetadataFilter, error) {
+	if sc.shardTotal == 0 {
+		return nil, nil
+	}
+	return block.NewHashShardedMetaFilter(sc.shardIndex, sc.shardTotal, sc.replicationFactor)
+}
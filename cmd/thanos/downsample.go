@@ -20,6 +20,7 @@ import (
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
 	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/bloom"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
 	"github.com/thanos-io/thanos/pkg/compact"
 	"github.com/thanos-io/thanos/pkg/compact/downsample"
@@ -62,6 +63,7 @@ func RunDownsample(
 	dataDir string,
 	objStoreConfig *extflag.PathOrContent,
 	comp component.Component,
+	levels downsample.Levels,
 	hashFunc metadata.HashFunc,
 ) error {
 	confContentYaml, err := objStoreConfig.Content()
@@ -103,28 +105,25 @@ func RunDownsample(
 			defer runutil.CloseWithLogOnErr(logger, bkt, "bucket client")
 			statusProber.Ready()
 
-			level.Info(logger).Log("msg", "start first pass of downsampling")
-			metas, _, err := metaFetcher.Fetch(ctx)
-			if err != nil {
-				return errors.Wrap(err, "sync before first pass of downsampling")
-			}
-
-			for _, meta := range metas {
-				groupKey := compact.DefaultGroupKey(meta.Thanos)
-				metrics.downsamples.WithLabelValues(groupKey)
-				metrics.downsampleFailures.WithLabelValues(groupKey)
-			}
-			if err := downsampleBucket(ctx, logger, metrics, bkt, metas, dataDir, hashFunc); err != nil {
-				return errors.Wrap(err, "downsampling failed")
-			}
+			// Each pass downsamples blocks one level further, so we need as many passes as there are
+			// levels to ensure that the last level is generated for blocks created in previous passes.
+			for pass := 1; pass < len(levels); pass++ {
+				level.Info(logger).Log("msg", "start pass of downsampling", "pass", pass)
+				metas, _, err := metaFetcher.Fetch(ctx)
+				if err != nil {
+					return errors.Wrapf(err, "sync before pass %d of downsampling", pass)
+				}
 
-			level.Info(logger).Log("msg", "start second pass of downsampling")
-			metas, _, err = metaFetcher.Fetch(ctx)
-			if err != nil {
-				return errors.Wrap(err, "sync before second pass of downsampling")
-			}
-			if err := downsampleBucket(ctx, logger, metrics, bkt, metas, dataDir, hashFunc); err != nil {
-				return errors.Wrap(err, "downsampling failed")
+				if pass == 1 {
+					for _, meta := range metas {
+						groupKey := compact.DefaultGroupKey(meta.Thanos)
+						metrics.downsamples.WithLabelValues(groupKey)
+						metrics.downsampleFailures.WithLabelValues(groupKey)
+					}
+				}
+				if err := downsampleBucket(ctx, logger, metrics, bkt, metas, dataDir, levels, hashFunc, false, nil); err != nil {
+					return errors.Wrap(err, "downsampling failed")
+				}
 			}
 
 			return nil
@@ -160,7 +159,10 @@ func downsampleBucket(
 	bkt objstore.Bucket,
 	metas map[ulid.ULID]*metadata.Meta,
 	dir string,
+	levels downsample.Levels,
 	hashFunc metadata.HashFunc,
+	enableBloomFilters bool,
+	progress *compact.Progress,
 ) (rerr error) {
 	if err := os.MkdirAll(dir, 0777); err != nil {
 		return errors.Wrap(err, "create dir")
@@ -177,25 +179,24 @@ func downsampleBucket(
 		}
 	}()
 
-	// mapping from a hash over all source IDs to blocks. We don't need to downsample a block
+	// mapping from a hash over all source IDs to blocks per target resolution. We don't need to downsample a block
 	// if a downsampled version with the same hash already exists.
-	sources5m := map[ulid.ULID]struct{}{}
-	sources1h := map[ulid.ULID]struct{}{}
+	sources := make(map[int64]map[ulid.ULID]struct{}, len(levels))
+	for _, l := range levels[1:] {
+		sources[l.Resolution] = map[ulid.ULID]struct{}{}
+	}
 
 	for _, m := range metas {
-		switch m.Thanos.Downsample.Resolution {
-		case downsample.ResLevel0:
+		if m.Thanos.Downsample.Resolution == downsample.ResLevel0 {
+			continue
+		}
+		s, ok := sources[m.Thanos.Downsample.Resolution]
+		if !ok {
+			level.Warn(logger).Log("msg", "block has resolution which is not a configured downsampling level; ignoring", "id", m.ULID, "resolution", m.Thanos.Downsample.Resolution)
 			continue
-		case downsample.ResLevel1:
-			for _, id := range m.Compaction.Sources {
-				sources5m[id] = struct{}{}
-			}
-		case downsample.ResLevel2:
-			for _, id := range m.Compaction.Sources {
-				sources1h[id] = struct{}{}
-			}
-		default:
-			return errors.Errorf("unexpected downsampling resolution %d", m.Thanos.Downsample.Resolution)
+		}
+		for _, id := range m.Compaction.Sources {
+			s[id] = struct{}{}
 		}
 	}
 
@@ -219,59 +220,39 @@ func downsampleBucket(
 	for _, mk := range metasULIDS {
 		m := metas[mk]
 
-		switch m.Thanos.Downsample.Resolution {
-		case downsample.ResLevel0:
-			missing := false
-			for _, id := range m.Compaction.Sources {
-				if _, ok := sources5m[id]; !ok {
-					missing = true
-					break
-				}
-			}
-			if !missing {
-				continue
-			}
-			// Only downsample blocks once we are sure to get roughly 2 chunks out of it.
-			// NOTE(fabxc): this must match with at which block size the compactor creates downsampled
-			// blocks. Otherwise we may never downsample some data.
-			if m.MaxTime-m.MinTime < downsample.DownsampleRange0 {
-				continue
+		next, ok := levels.Next(m.Thanos.Downsample.Resolution)
+		if !ok {
+			continue
+		}
+		missing := false
+		for _, id := range m.Compaction.Sources {
+			if _, ok := sources[next.Resolution][id]; !ok {
+				missing = true
+				break
 			}
+		}
+		if !missing {
+			continue
+		}
+		// Only downsample blocks once we are sure to get roughly 2 chunks out of it.
+		// NOTE(fabxc): this must match with at which block size the compactor creates downsampled
+		// blocks. Otherwise we may never downsample some data.
+		if m.MaxTime-m.MinTime < next.MinSourceRange {
+			continue
+		}
 
-			if err := processDownsampling(ctx, logger, bkt, m, dir, downsample.ResLevel1, hashFunc); err != nil {
-				metrics.downsampleFailures.WithLabelValues(compact.DefaultGroupKey(m.Thanos)).Inc()
-				return errors.Wrap(err, "downsampling to 5 min")
-			}
-			metrics.downsamples.WithLabelValues(compact.DefaultGroupKey(m.Thanos)).Inc()
-
-		case downsample.ResLevel1:
-			missing := false
-			for _, id := range m.Compaction.Sources {
-				if _, ok := sources1h[id]; !ok {
-					missing = true
-					break
-				}
-			}
-			if !missing {
-				continue
-			}
-			// Only downsample blocks once we are sure to get roughly 2 chunks out of it.
-			// NOTE(fabxc): this must match with at which block size the compactor creates downsampled
-			// blocks. Otherwise we may never downsample some data.
-			if m.MaxTime-m.MinTime < downsample.DownsampleRange1 {
-				continue
-			}
-			if err := processDownsampling(ctx, logger, bkt, m, dir, downsample.ResLevel2, hashFunc); err != nil {
-				metrics.downsampleFailures.WithLabelValues(compact.DefaultGroupKey(m.Thanos)).Inc()
-				return errors.Wrap(err, "downsampling to 60 min")
-			}
-			metrics.downsamples.WithLabelValues(compact.DefaultGroupKey(m.Thanos)).Inc()
+		begin := time.Now()
+		if err := processDownsampling(ctx, logger, bkt, m, dir, next.Resolution, hashFunc, enableBloomFilters); err != nil {
+			metrics.downsampleFailures.WithLabelValues(compact.DefaultGroupKey(m.Thanos)).Inc()
+			return errors.Wrapf(err, "downsampling to %v", time.Duration(next.Resolution)*time.Millisecond)
 		}
+		metrics.downsamples.WithLabelValues(compact.DefaultGroupKey(m.Thanos)).Inc()
+		progress.DownsampleDone(time.Since(begin))
 	}
 	return nil
 }
 
-func processDownsampling(ctx context.Context, logger log.Logger, bkt objstore.Bucket, m *metadata.Meta, dir string, resolution int64, hashFunc metadata.HashFunc) error {
+func processDownsampling(ctx context.Context, logger log.Logger, bkt objstore.Bucket, m *metadata.Meta, dir string, resolution int64, hashFunc metadata.HashFunc, enableBloomFilters bool) error {
 	begin := time.Now()
 	bdir := filepath.Join(dir, m.ULID.String())
 
@@ -313,6 +294,12 @@ func processDownsampling(ctx context.Context, logger log.Logger, bkt objstore.Bu
 		return errors.Wrap(err, "output block index not valid")
 	}
 
+	if enableBloomFilters {
+		if err := bloom.WriteBlockFilter(logger, resdir, bloom.DefaultFalsePositiveRate); err != nil {
+			return errors.Wrapf(err, "write bloom filter of downsampled block %s", id)
+		}
+	}
+
 	begin = time.Now()
 
 	err = block.Upload(ctx, logger, bkt, resdir, hashFunc)
@@ -33,6 +33,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/discovery/cache"
 	"github.com/thanos-io/thanos/pkg/discovery/dns"
 	"github.com/thanos-io/thanos/pkg/extgrpc"
+	"github.com/thanos-io/thanos/pkg/extgrpc/snappy"
 	"github.com/thanos-io/thanos/pkg/extkingpin"
 	"github.com/thanos-io/thanos/pkg/extprom"
 	extpromhttp "github.com/thanos-io/thanos/pkg/extprom/http"
@@ -47,6 +48,7 @@ import (
 	httpserver "github.com/thanos-io/thanos/pkg/server/http"
 	"github.com/thanos-io/thanos/pkg/store"
 	"github.com/thanos-io/thanos/pkg/tls"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus"
 	"github.com/thanos-io/thanos/pkg/ui"
 )
 
@@ -79,6 +81,7 @@ func registerQuery(app *extkingpin.App) {
 
 	lookbackDelta := cmd.Flag("query.lookback-delta", "The maximum lookback duration for retrieving metrics during expression evaluations. PromQL always evaluates the query for the certain timestamp (query range timestamps are deduced by step). Since scrape intervals might be different, PromQL looks back for given amount of time to get latest sample. If it exceeds the maximum lookback delta it assumes series is stale and returns none (a gap). This is why lookback delta should be set to at least 2 times of the slowest scrape interval. If unset it will use the promql default of 5m.").Duration()
 	dynamicLookbackDelta := cmd.Flag("query.dynamic-lookback-delta", "Allow for larger lookback duration for queries based on resolution.").Hidden().Default("true").Bool()
+	dc := (&downsamplingConfig{}).registerFlag(cmd)
 
 	maxConcurrentSelects := cmd.Flag("query.max-concurrent-select", "Maximum number of select requests made concurrently per a query.").
 		Default("4").Int()
@@ -121,9 +124,15 @@ func registerQuery(app *extkingpin.App) {
 
 	unhealthyStoreTimeout := extkingpin.ModelDuration(cmd.Flag("store.unhealthy-timeout", "Timeout before an unhealthy store is cleaned from the store UI page.").Default("5m"))
 
+	seriesCompressions := cmd.Flag("store.series-compression", "gRPC compression accepted for Series responses of store APIs, in order of preference (repeated). The first one supported by a store API, as advertised in its Info response, is requested for its Series calls. Series responses are not compressed by default.").
+		PlaceHolder("<compression>").Enums(snappy.Name)
+
 	enableAutodownsampling := cmd.Flag("query.auto-downsampling", "Enable automatic adjustment (step / 5) to what source of data should be used in store gateways if no max_source_resolution param is specified.").
 		Default("false").Bool()
 
+	enableQueryPushdown := cmd.Flag("query.enable-aggregation-pushdown", "Experimental: Pass max_over_time, min_over_time and count_over_time functions to store APIs in series request hints, so that stores supporting them return only the samples needed to evaluate these functions. Queries with subqueries are never pushed down.").
+		Default("false").Bool()
+
 	enableQueryPartialResponse := cmd.Flag("query.partial-response", "Enable partial response for queries if no partial_response param is specified. --no-query.partial-response for disabling.").
 		Default("true").Bool()
 
@@ -178,6 +187,11 @@ func registerQuery(app *extkingpin.App) {
 			fileSD = file.NewDiscovery(conf, logger)
 		}
 
+		downsamplingLevels, err := dc.parse()
+		if err != nil {
+			return err
+		}
+
 		if *webRoutePrefix == "" {
 			*webRoutePrefix = *webExternalPrefix
 		}
@@ -215,6 +229,7 @@ func registerQuery(app *extkingpin.App) {
 			time.Duration(*queryTimeout),
 			*lookbackDelta,
 			*dynamicLookbackDelta,
+			downsamplingLevels,
 			time.Duration(*defaultEvaluationInterval),
 			time.Duration(*storeResponseTimeout),
 			*queryReplicaLabels,
@@ -224,6 +239,7 @@ func registerQuery(app *extkingpin.App) {
 			*ruleEndpoints,
 			*metadataEndpoints,
 			*enableAutodownsampling,
+			*enableQueryPushdown,
 			*enableQueryPartialResponse,
 			*enableRulePartialResponse,
 			*enableMetricMetadataPartialResponse,
@@ -231,6 +247,7 @@ func registerQuery(app *extkingpin.App) {
 			time.Duration(*dnsSDInterval),
 			*dnsSDResolver,
 			time.Duration(*unhealthyStoreTimeout),
+			*seriesCompressions,
 			time.Duration(*instantDefaultMaxSourceResolution),
 			*defaultMetadataTimeRange,
 			*strictStores,
@@ -271,6 +288,7 @@ func runQuery(
 	queryTimeout time.Duration,
 	lookbackDelta time.Duration,
 	dynamicLookbackDelta bool,
+	downsamplingLevels downsample.Levels,
 	defaultEvaluationInterval time.Duration,
 	storeResponseTimeout time.Duration,
 	queryReplicaLabels []string,
@@ -280,6 +298,7 @@ func runQuery(
 	ruleAddrs []string,
 	metadataAddrs []string,
 	enableAutodownsampling bool,
+	enableQueryPushdown bool,
 	enableQueryPartialResponse bool,
 	enableRulePartialResponse bool,
 	enableMetricMetadataPartialResponse bool,
@@ -287,6 +306,7 @@ func runQuery(
 	dnsSDInterval time.Duration,
 	dnsSDResolver string,
 	unhealthyStoreTimeout time.Duration,
+	seriesCompressions []string,
 	instantDefaultMaxSourceResolution time.Duration,
 	defaultMetadataTimeRange time.Duration,
 	strictStores []string,
@@ -363,11 +383,13 @@ func runQuery(
 				return specs
 			},
 			dialOpts,
+			seriesCompressions,
 			unhealthyStoreTimeout,
 		)
 		proxy            = store.NewProxyStore(logger, reg, stores.Get, component.Query, selectorLset, storeResponseTimeout)
 		rulesProxy       = rules.NewProxy(logger, stores.GetRulesClients)
 		metadataProxy    = metadata.NewProxy(logger, stores.GetMetadataClients)
+		tsdbStatusProxy  = tsdbstatus.NewProxy(logger, stores.GetTSDBStatusClients)
 		queryableCreator = query.NewQueryableCreator(
 			logger,
 			extprom.WrapRegistererWithPrefix("thanos_query_", reg),
@@ -500,12 +522,14 @@ func runQuery(
 		api := v1.NewQueryAPI(
 			logger,
 			stores,
-			engineFactory(promql.NewEngine, engineOpts, dynamicLookbackDelta),
+			engineFactory(promql.NewEngine, engineOpts, dynamicLookbackDelta, downsamplingLevels.Resolutions()),
 			queryableCreator,
 			// NOTE: Will share the same replica label as the query for now.
 			rules.NewGRPCClientWithDedup(rulesProxy, queryReplicaLabels),
 			metadata.NewGRPCClient(metadataProxy),
+			tsdbStatusProxy,
 			enableAutodownsampling,
+			enableQueryPushdown,
 			enableQueryPartialResponse,
 			enableRulePartialResponse,
 			enableMetricMetadataPartialResponse,
@@ -551,6 +575,7 @@ func runQuery(
 			grpcserver.WithServer(store.RegisterStoreServer(proxy)),
 			grpcserver.WithServer(rules.RegisterRulesServer(rulesProxy)),
 			grpcserver.WithServer(metadata.RegisterMetadataServer(metadataProxy)),
+			grpcserver.WithServer(tsdbstatus.RegisterTSDBStatusServer(tsdbStatusProxy)),
 			grpcserver.WithListen(grpcBindAddr),
 			grpcserver.WithGracePeriod(grpcGracePeriod),
 			grpcserver.WithTLSConfig(tlsCfg),
@@ -602,8 +627,8 @@ func firstDuplicate(ss []string) string {
 	return ""
 }
 
-// engineFactory creates from 1 to 3 promql.Engines depending on
-// dynamicLookbackDelta and eo.LookbackDelta and returns a function
+// engineFactory creates one promql.Engine per given downsampling resolution (in increasing order) or just
+// a single one, depending on dynamicLookbackDelta and eo.LookbackDelta and returns a function
 // that returns appropriate engine for given maxSourceResolutionMillis.
 //
 // TODO: it seems like a good idea to tweak Prometheus itself
@@ -612,10 +637,11 @@ func engineFactory(
 	newEngine func(promql.EngineOpts) *promql.Engine,
 	eo promql.EngineOpts,
 	dynamicLookbackDelta bool,
+	downsamplingResolutions []int64,
 ) func(int64) *promql.Engine {
 	resolutions := []int64{downsample.ResLevel0}
 	if dynamicLookbackDelta {
-		resolutions = []int64{downsample.ResLevel0, downsample.ResLevel1, downsample.ResLevel2}
+		resolutions = downsamplingResolutions
 	}
 	var (
 		engines = make([]*promql.Engine, len(resolutions))
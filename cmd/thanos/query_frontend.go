@@ -71,6 +71,8 @@ func registerQueryFrontend(app *extkingpin.App) {
 	cmd.Flag("query-range.request-downsampled", "Make additional query for downsampled data in case of empty or incomplete response to range request.").
 		Default("true").BoolVar(&cfg.QueryRangeConfig.RequestDownsampled)
 
+	dc := (&downsamplingConfig{}).registerFlag(cmd)
+
 	cmd.Flag("query-range.split-interval", "Split query range requests by an interval and execute in parallel, it should be greater than 0 when query-range.response-cache-config is configured.").
 		Default("24h").DurationVar(&cfg.QueryRangeConfig.SplitQueriesByInterval)
 
@@ -138,6 +140,12 @@ func registerQueryFrontend(app *extkingpin.App) {
 			return errors.Wrap(err, "error while parsing config for request logging")
 		}
 
+		downsamplingLevels, err := dc.parse()
+		if err != nil {
+			return err
+		}
+		cfg.QueryRangeConfig.DownsamplingResolutions = downsamplingLevels.Resolutions()
+
 		return runQueryFrontend(g, logger, reg, tracer, httpLogOpts, cfg, comp)
 	})
 }
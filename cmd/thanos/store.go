@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/alecthomas/units"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	grpc_logging "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
@@ -21,6 +22,7 @@ import (
 	blocksAPI "github.com/thanos-io/thanos/pkg/api/blocks"
 	"github.com/thanos-io/thanos/pkg/block"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
 	"github.com/thanos-io/thanos/pkg/component"
 	"github.com/thanos-io/thanos/pkg/extflag"
 	"github.com/thanos-io/thanos/pkg/extkingpin"
@@ -37,6 +39,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/store"
 	storecache "github.com/thanos-io/thanos/pkg/store/cache"
 	"github.com/thanos-io/thanos/pkg/tls"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus"
 	"github.com/thanos-io/thanos/pkg/ui"
 )
 
@@ -71,8 +74,24 @@ func registerStore(app *extkingpin.App) {
 		"Maximum amount of touched series returned via a single Series call. The Series call fails if this limit is exceeded. 0 means no limit.").
 		Default("0").Uint()
 
+	maxPostingsBytes := cmd.Flag("store.grpc.postings-bytes-limit",
+		"Maximum amount of postings bytes fetched, from the index cache or object storage, via a single Series call. The Series call fails with ResourceExhausted if this limit is exceeded. 0 means no limit.").
+		Default("0").Bytes()
+	maxSeriesBytes := cmd.Flag("store.grpc.series-bytes-limit",
+		"Maximum amount of series bytes fetched, from the index cache or object storage, via a single Series call. The Series call fails with ResourceExhausted if this limit is exceeded. 0 means no limit.").
+		Default("0").Bytes()
+	maxChunksBytes := cmd.Flag("store.grpc.chunks-bytes-limit",
+		"Maximum amount of chunk bytes fetched from object storage via a single Series call. The Series call fails with ResourceExhausted if this limit is exceeded. 0 means no limit.").
+		Default("0").Bytes()
+
 	maxConcurrent := cmd.Flag("store.grpc.series-max-concurrency", "Maximum number of concurrent Series calls.").Default("20").Int()
 
+	seriesBatchSize := cmd.Flag("store.grpc.series-batch-size", "Maximum number of series, with their chunks, loaded at once per block while streaming a Series response. The next batch of each block is prefetched while the current one is merged, so a Series call holds up to two batches per block. 0 means all matching series of a block are loaded at once.").
+		Default(fmt.Sprintf("%v", store.DefaultSeriesBatchSize)).Int()
+
+	mergeSmallChunks := cmd.Flag("store.grpc.merge-small-chunks", "Re-encode consecutive small chunks of a series into chunks of up to 120 samples before sending them in Series responses. Reduces network traffic for series spanning many blocks at the cost of CPU.").
+		Default("false").Bool()
+
 	objStoreConfig := extkingpin.RegisterCommonObjStoreFlags(cmd, "", true)
 
 	syncInterval := cmd.Flag("sync-block-duration", "Repeat interval for syncing the blocks between local and remote view.").
@@ -95,6 +114,10 @@ func registerStore(app *extkingpin.App) {
 
 	selectorRelabelConf := extkingpin.RegisterSelectorRelabelFlags(cmd)
 
+	shardingConf := (&shardingConfig{}).registerFlag(cmd)
+
+	warmupConf := (&warmupConfig{}).registerFlag(cmd)
+
 	postingOffsetsInMemSampling := cmd.Flag("store.index-header-posting-offsets-in-mem-sampling", "Controls what is the ratio of postings offsets store will hold in memory. "+
 		"Larger value will keep less offsets, which will increase CPU cycles needed for query touching those postings. It's meant for setups that want low baseline memory pressure and where less traffic is expected. "+
 		"On the contrary, smaller value will increase baseline memory usage, but improve latency slightly. 1 will keep all in memory. Default value is the same as in Prometheus which gives a good balance.").
@@ -116,6 +139,10 @@ func registerStore(app *extkingpin.App) {
 	lazyIndexReaderIdleTimeout := cmd.Flag("store.index-header-lazy-reader-idle-timeout", "If index-header lazy reader is enabled and this idle timeout setting is > 0, memory map-ed index-headers will be automatically released after 'idle timeout' inactivity.").
 		Hidden().Default("5m").Duration()
 
+	dc := (&downsamplingConfig{}).registerFlag(cmd)
+
+	bucketIndexConf := (&bucketIndexConfig{}).registerFlag(cmd)
+
 	webExternalPrefix := cmd.Flag("web.external-prefix", "Static prefix for all HTML links and redirect URLs in the bucket web UI interface. Actual endpoints are still served on / or the web.route-prefix. This allows thanos bucket web UI to be served behind a reverse proxy that strips a URL sub-path.").Default("").String()
 	webPrefixHeaderName := cmd.Flag("web.prefix-header", "Name of HTTP request header used for dynamic prefixing of UI links and redirects. This option is ignored if web.external-prefix argument is set. Security risk: enable this option only if a reverse proxy in front of thanos is resetting the header. The --web.prefix-header=X-Forwarded-Prefix option can be useful, for example, if Thanos UI is served via Traefik reverse proxy with PathPrefixStrip option enabled, which sends the stripped prefix value in X-Forwarded-Prefix header. This allows thanos UI to be served on a sub-path.").Default("").String()
 	webDisableCORS := cmd.Flag("web.disable-cors", "Whether to disable CORS headers to be set by Thanos. By default Thanos sets CORS headers to be allowed by all.").Default("false").Bool()
@@ -137,6 +164,11 @@ func registerStore(app *extkingpin.App) {
 			return errors.Wrap(err, "error while parsing config for request logging")
 		}
 
+		downsamplingLevels, err := dc.parse()
+		if err != nil {
+			return err
+		}
+
 		return runStore(g,
 			logger,
 			reg,
@@ -158,7 +190,12 @@ func registerStore(app *extkingpin.App) {
 			uint64(*chunkPoolSize),
 			uint64(*maxSampleCount),
 			uint64(*maxTouchedSeriesCount),
+			*maxPostingsBytes,
+			*maxSeriesBytes,
+			*maxChunksBytes,
 			*maxConcurrent,
+			*seriesBatchSize,
+			*mergeSmallChunks,
 			component.Store,
 			debugLogging,
 			*syncInterval,
@@ -180,6 +217,10 @@ func registerStore(app *extkingpin.App) {
 			getFlagsMap(cmd.Flags()),
 			*lazyIndexReaderEnabled,
 			*lazyIndexReaderIdleTimeout,
+			downsamplingLevels,
+			*bucketIndexConf,
+			*shardingConf,
+			*warmupConf,
 		)
 	})
 }
@@ -201,7 +242,10 @@ func runStore(
 	grpcCert, grpcKey, grpcClientCA, httpBindAddr string,
 	httpGracePeriod time.Duration,
 	indexCacheSizeBytes, chunkPoolSizeBytes, maxSampleCount, maxSeriesCount uint64,
+	maxPostingsBytes, maxSeriesBytes, maxChunksBytes units.Base2Bytes,
 	maxConcurrency int,
+	seriesBatchSize int,
+	mergeSmallChunks bool,
 	component component.Component,
 	verbose bool,
 	syncInterval time.Duration,
@@ -219,6 +263,10 @@ func runStore(
 	flagsMap map[string]string,
 	lazyIndexReaderEnabled bool,
 	lazyIndexReaderIdleTimeout time.Duration,
+	downsamplingLevels downsample.Levels,
+	bucketIndexConf bucketIndexConfig,
+	shardingConf shardingConfig,
+	warmupConf warmupConfig,
 ) error {
 	grpcProbe := prober.NewGRPC()
 	httpProbe := prober.NewHTTP()
@@ -303,22 +351,42 @@ func runStore(
 	}
 
 	ignoreDeletionMarkFilter := block.NewIgnoreDeletionMarkFilter(logger, bkt, ignoreDeletionMarksDelay, metaFetchConcurrency)
-	metaFetcher, err := block.NewMetaFetcher(logger, metaFetchConcurrency, bkt, dataDir, extprom.WrapRegistererWithPrefix("thanos_", reg),
-		[]block.MetadataFilter{
-			block.NewTimePartitionMetaFilter(filterConf.MinTime, filterConf.MaxTime),
-			block.NewLabelShardedMetaFilter(relabelConfig),
-			block.NewConsistencyDelayMetaFilter(logger, consistencyDelay, extprom.WrapRegistererWithPrefix("thanos_", reg)),
-			ignoreDeletionMarkFilter,
-			block.NewDeduplicateFilter(),
-		}, nil)
+	baseMetaFetcher, err := block.NewBaseFetcher(logger, metaFetchConcurrency, bkt, dataDir, extprom.WrapRegistererWithPrefix("thanos_", reg))
 	if err != nil {
 		return errors.Wrap(err, "meta fetcher")
 	}
+	filters := []block.MetadataFilter{
+		block.NewTimePartitionMetaFilter(filterConf.MinTime, filterConf.MaxTime),
+		block.NewLabelShardedMetaFilter(relabelConfig),
+		block.NewConsistencyDelayMetaFilter(logger, consistencyDelay, extprom.WrapRegistererWithPrefix("thanos_", reg)),
+		ignoreDeletionMarkFilter,
+		block.NewDeduplicateFilter(),
+	}
+	// Shard after deduplication, so blocks replaced by compacted ones are dropped regardless of the shard owning the compacted block.
+	shardFilter, err := shardingConf.filter()
+	if err != nil {
+		return errors.Wrap(err, "sharding")
+	}
+	if shardFilter != nil {
+		filters = append(filters, shardFilter)
+	}
+	var metaFetcher block.MetadataFetcher
+	if bucketIndexConf.enabled {
+		metaFetcher = baseMetaFetcher.NewBucketIndexFetcher(extprom.WrapRegistererWithPrefix("thanos_", reg), bucketIndexConf.maxStaleness, filters, nil)
+	} else {
+		metaFetcher = baseMetaFetcher.NewMetaFetcher(extprom.WrapRegistererWithPrefix("thanos_", reg), filters, nil)
+	}
 
 	// Limit the concurrency on queries against the Thanos store.
 	if maxConcurrency < 0 {
 		return errors.Errorf("max concurrency value cannot be lower than 0 (got %v)", maxConcurrency)
 	}
+	if seriesBatchSize < 0 {
+		return errors.Errorf("series batch size cannot be lower than 0 (got %v)", seriesBatchSize)
+	}
+	if warmupConf.maxLabelPairs < 0 {
+		return errors.Errorf("warm up max label pairs cannot be lower than 0 (got %v)", warmupConf.maxLabelPairs)
+	}
 
 	queriesGate := gate.New(extprom.WrapRegistererWithPrefix("thanos_bucket_store_series_", reg), maxConcurrency)
 
@@ -327,6 +395,23 @@ func runStore(
 		return errors.Wrap(err, "create chunk pool")
 	}
 
+	bucketStoreOpts := []store.BucketStoreOption{
+		store.WithDownsamplingResolutions(downsamplingLevels.Resolutions()),
+		store.WithSeriesBatchSize(seriesBatchSize),
+		store.WithSmallChunksMerging(mergeSmallChunks),
+		store.WithBytesLimiterFactories(
+			store.NewBytesLimiterFactory(maxPostingsBytes),
+			store.NewBytesLimiterFactory(maxSeriesBytes),
+			store.NewBytesLimiterFactory(maxChunksBytes),
+		),
+	}
+	if warmupConf.enabled {
+		bucketStoreOpts = append(bucketStoreOpts, store.WithWarmup(store.WarmupConfig{
+			LabelNames:    warmupConf.labelNames,
+			MaxLabelPairs: warmupConf.maxLabelPairs,
+		}))
+	}
+
 	bs, err := store.NewBucketStore(
 		logger,
 		reg,
@@ -347,6 +432,7 @@ func runStore(
 		false,
 		lazyIndexReaderEnabled,
 		lazyIndexReaderIdleTimeout,
+		bucketStoreOpts...,
 	)
 	if err != nil {
 		return errors.Wrap(err, "create object storage store")
@@ -390,6 +476,7 @@ func runStore(
 
 		s := grpcserver.New(logger, reg, tracer, grpcLogOpts, tagOpts, component, grpcProbe,
 			grpcserver.WithServer(store.RegisterStoreServer(bs)),
+			grpcserver.WithServer(tsdbstatus.RegisterTSDBStatusServer(bs)),
 			grpcserver.WithListen(grpcBindAddr),
 			grpcserver.WithGracePeriod(grpcGracePeriod),
 			grpcserver.WithTLSConfig(tlsCfg),
@@ -4,12 +4,18 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -28,6 +34,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/common/route"
 	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
 	v1 "github.com/thanos-io/thanos/pkg/api/blocks"
@@ -45,14 +54,19 @@ import (
 	"github.com/thanos-io/thanos/pkg/model"
 	"github.com/thanos-io/thanos/pkg/objstore"
 	"github.com/thanos-io/thanos/pkg/objstore/client"
+	"github.com/thanos-io/thanos/pkg/parquet"
 	"github.com/thanos-io/thanos/pkg/prober"
 	"github.com/thanos-io/thanos/pkg/replicate"
 	"github.com/thanos-io/thanos/pkg/runutil"
 	httpserver "github.com/thanos-io/thanos/pkg/server/http"
+	"github.com/thanos-io/thanos/pkg/store"
+	"github.com/thanos-io/thanos/pkg/store/storepb"
 	"github.com/thanos-io/thanos/pkg/ui"
 	"github.com/thanos-io/thanos/pkg/verifier"
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
+	"gopkg.in/alecthomas/kingpin.v2"
 	"gopkg.in/yaml.v3"
 )
 
@@ -81,7 +95,52 @@ func registerBucket(app extkingpin.AppClause) {
 	registerBucketDownsample(cmd, objStoreConfig)
 	registerBucketCleanup(cmd, objStoreConfig)
 	registerBucketMarkBlock(cmd, objStoreConfig)
+	registerBucketUnmark(cmd, objStoreConfig)
+	registerBucketRestore(cmd, objStoreConfig)
 	registerBucketRewrite(cmd, objStoreConfig)
+	registerBucketCompactPlan(cmd, objStoreConfig)
+	registerBucketImport(cmd, objStoreConfig)
+	registerBucketExport(cmd, objStoreConfig)
+	registerBucketAnalyze(cmd, objStoreConfig)
+	registerBucketDiff(cmd, objStoreConfig)
+}
+
+// blockSelectionConfig holds the flags selecting blocks by their time range and external labels.
+type blockSelectionConfig struct {
+	minTime, maxTime *model.TimeOrDurationValue
+	matchers         string
+	// set is true if any of the selection flags was specified.
+	set bool
+}
+
+func (sc *blockSelectionConfig) registerFlag(cmd extkingpin.FlagClause) *blockSelectionConfig {
+	sc.minTime = model.TimeOrDuration(cmd.Flag("min-time", "Start of time range limit of selected blocks. Only blocks with data later than this value are selected. "+
+		"Option can be a constant time in RFC3339 format or time duration relative to current time, such as -1d or 2h45m. Valid duration units are ms, s, m, h, d, w, y.").
+		Default("0000-01-01T00:00:00Z").Action(sc.markSet))
+	sc.maxTime = model.TimeOrDuration(cmd.Flag("max-time", "End of time range limit of selected blocks. Only blocks with data earlier than this value are selected. "+
+		"Option can be a constant time in RFC3339 format or time duration relative to current time, such as -1d or 2h45m. Valid duration units are ms, s, m, h, d, w, y.").
+		Default("9999-12-31T23:59:59Z").Action(sc.markSet))
+	cmd.Flag("matchers", "Only blocks whose external labels match this series selector are selected, e.g. '{cluster=\"eu1\", replica=~\"r[0-9]\"}'.").
+		PlaceHolder("<selector>").Default("").Action(sc.markSet).StringVar(&sc.matchers)
+	return sc
+}
+
+func (sc *blockSelectionConfig) markSet(*kingpin.ParseContext) error {
+	sc.set = true
+	return nil
+}
+
+// filters returns the metadata filters selecting the configured blocks.
+func (sc *blockSelectionConfig) filters() ([]block.MetadataFilter, error) {
+	filters := []block.MetadataFilter{block.NewTimePartitionMetaFilter(*sc.minTime, *sc.maxTime)}
+	if sc.matchers == "" {
+		return filters, nil
+	}
+	matchers, err := parser.ParseMetricSelector(sc.matchers)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse block label matchers")
+	}
+	return append(filters, block.NewLabelMatchersMetaFilter(matchers)), nil
 }
 
 func registerBucketVerify(app extkingpin.AppClause, objStoreConfig *extflag.PathOrContent) {
@@ -175,9 +234,10 @@ func registerBucketVerify(app extkingpin.AppClause, objStoreConfig *extflag.Path
 }
 
 func registerBucketLs(app extkingpin.AppClause, objStoreConfig *extflag.PathOrContent) {
-	cmd := app.Command("ls", "List all blocks in the bucket.")
+	cmd := app.Command("ls", "List all blocks in the bucket, optionally only those matching the given time range and external label matchers.")
 	output := cmd.Flag("output", "Optional format in which to print each block's information. Options are 'json', 'wide' or a custom template.").
 		Short('o').Default("").String()
+	selection := (&blockSelectionConfig{}).registerFlag(cmd)
 	cmd.Setup(func(g *run.Group, logger log.Logger, reg *prometheus.Registry, _ opentracing.Tracer, _ <-chan struct{}, _ bool) error {
 		confContentYaml, err := objStoreConfig.Content()
 		if err != nil {
@@ -189,7 +249,11 @@ func registerBucketLs(app extkingpin.AppClause, objStoreConfig *extflag.PathOrCo
 			return err
 		}
 
-		fetcher, err := block.NewMetaFetcher(logger, block.FetcherConcurrency, bkt, "", extprom.WrapRegistererWithPrefix(extpromPrefix, reg), nil, nil)
+		filters, err := selection.filters()
+		if err != nil {
+			return err
+		}
+		fetcher, err := block.NewMetaFetcher(logger, block.FetcherConcurrency, bkt, "", extprom.WrapRegistererWithPrefix(extpromPrefix, reg), filters, nil)
 		if err != nil {
 			return err
 		}
@@ -270,6 +334,7 @@ func registerBucketInspect(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 	sortBy := cmd.Flag("sort-by", "Sort by columns. It's also possible to sort by multiple columns, e.g. '--sort-by FROM --sort-by UNTIL'. I.e., if the 'FROM' value is equal the rows are then further sorted by the 'UNTIL' value.").
 		Default("FROM", "UNTIL").Enums(inspectColumns...)
 	timeout := cmd.Flag("timeout", "Timeout to download metadata from remote storage").Default("5m").Duration()
+	dc := (&downsamplingConfig{}).registerFlag(cmd)
 
 	cmd.Setup(func(g *run.Group, logger log.Logger, reg *prometheus.Registry, _ opentracing.Tracer, _ <-chan struct{}, _ bool) error {
 
@@ -279,6 +344,11 @@ func registerBucketInspect(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 			return errors.Wrap(err, "error parsing selector flag")
 		}
 
+		levels, err := dc.parse()
+		if err != nil {
+			return err
+		}
+
 		confContentYaml, err := objStoreConfig.Content()
 		if err != nil {
 			return err
@@ -313,7 +383,7 @@ func registerBucketInspect(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 			blockMetas = append(blockMetas, meta)
 		}
 
-		return printTable(blockMetas, selectorLabels, *sortBy)
+		return printTable(blockMetas, selectorLabels, *sortBy, levels)
 	})
 }
 
@@ -430,10 +500,11 @@ func registerBucketWeb(app extkingpin.AppClause, objStoreConfig *extflag.PathOrC
 
 // Provide a list of resolution, can not use Enum directly, since string does not implement int64 function.
 func listResLevel() []string {
-	return []string{
-		time.Duration(downsample.ResLevel0).String(),
-		time.Duration(downsample.ResLevel1).String(),
-		time.Duration(downsample.ResLevel2).String()}
+	var res []string
+	for _, r := range downsample.DefaultLevels.Resolutions() {
+		res = append(res, (time.Duration(r) * time.Millisecond).String())
+	}
+	return res
 }
 
 func registerBucketReplicate(app extkingpin.AppClause, objStoreConfig *extflag.PathOrContent) {
@@ -444,6 +515,11 @@ func registerBucketReplicate(app extkingpin.AppClause, objStoreConfig *extflag.P
 	compactions := cmd.Flag("compaction", "Only blocks with these compaction levels will be replicated. Repeated flag.").Default("1", "2", "3", "4").Ints()
 	matcherStrs := cmd.Flag("matcher", "Only blocks whose external labels exactly match this matcher will be replicated.").PlaceHolder("key=\"value\"").Strings()
 	singleRun := cmd.Flag("single-run", "Run replication only one time, then exit.").Default("false").Bool()
+	interval := cmd.Flag("interval", "Interval between replication runs if not running only one time.").Default("1m").Duration()
+	propagateDeletions := cmd.Flag("propagate-deletions", "Propagate deletions from the origin to the target bucket. Deletion marks of replicated blocks are copied and blocks are no longer replicated once marked for deletion. "+
+		"Replicated blocks of the target bucket selected by the other flags but not present in the origin bucket anymore are marked for deletion. Blocks of the target bucket marked for deletion are deleted after --delete-delay.").
+		Default("false").Bool()
+	deleteDelay := cmd.Flag("delete-delay", "Time before a block marked for deletion is deleted from the target bucket, if deletions are propagated.").Default("48h").Duration()
 	minTime := model.TimeOrDuration(cmd.Flag("min-time", "Start of time range limit to replicate. Thanos Replicate will replicate only metrics, which happened later than this value. Option can be a constant time in RFC3339 format or time duration relative to current time, such as -1d or 2h45m. Valid duration units are ms, s, m, h, d, w, y.").
 		Default("0000-01-01T00:00:00Z"))
 	maxTime := model.TimeOrDuration(cmd.Flag("max-time", "End of time range limit to replicate. Thanos Replicate will replicate only metrics, which happened earlier than this value. Option can be a constant time in RFC3339 format or time duration relative to current time, such as -1d or 2h45m. Valid duration units are ms, s, m, h, d, w, y.").
@@ -483,9 +559,12 @@ func registerBucketReplicate(app extkingpin.AppClause, objStoreConfig *extflag.P
 			objStoreConfig,
 			toObjStoreConfig,
 			*singleRun,
+			*interval,
 			minTime,
 			maxTime,
 			blockIDs,
+			*propagateDeletions,
+			*deleteDelay,
 		)
 	})
 }
@@ -497,9 +576,14 @@ func registerBucketDownsample(app extkingpin.AppClause, objStoreConfig *extflag.
 		Default("./data").String()
 	hashFunc := cmd.Flag("hash-func", "Specify which hash function to use when calculating the hashes of produced files. If no function has been specified, it does not happen. This permits avoiding downloading some files twice albeit at some performance cost. Possible values are: \"\", \"SHA256\".").
 		Default("").Enum("SHA256", "")
+	dc := (&downsamplingConfig{}).registerFlag(cmd)
 
 	cmd.Setup(func(g *run.Group, logger log.Logger, reg *prometheus.Registry, tracer opentracing.Tracer, _ <-chan struct{}, _ bool) error {
-		return RunDownsample(g, logger, reg, *httpAddr, time.Duration(*httpGracePeriod), *dataDir, objStoreConfig, component.Downsample, metadata.HashFunc(*hashFunc))
+		levels, err := dc.parse()
+		if err != nil {
+			return err
+		}
+		return RunDownsample(g, logger, reg, *httpAddr, time.Duration(*httpGracePeriod), *dataDir, objStoreConfig, component.Downsample, levels, metadata.HashFunc(*hashFunc))
 	})
 }
 
@@ -592,7 +676,7 @@ func registerBucketCleanup(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 	})
 }
 
-func printTable(blockMetas []*metadata.Meta, selectorLabels labels.Labels, sortBy []string) error {
+func printTable(blockMetas []*metadata.Meta, selectorLabels labels.Labels, sortBy []string, levels downsample.Levels) error {
 	header := inspectColumns
 
 	var lines [][]string
@@ -606,7 +690,7 @@ func printTable(blockMetas []*metadata.Meta, selectorLabels labels.Labels, sortB
 		timeRange := time.Duration((blockMeta.MaxTime - blockMeta.MinTime) * int64(time.Millisecond))
 
 		untilDown := "-"
-		if until, err := compact.UntilNextDownsampling(blockMeta); err == nil {
+		if until, err := compact.UntilNextDownsampling(blockMeta, levels); err == nil {
 			untilDown = until.String()
 		}
 		var labels []string
@@ -777,6 +861,259 @@ func registerBucketMarkBlock(app extkingpin.AppClause, objStoreConfig *extflag.P
 	})
 }
 
+// rewriteSupportedRelabelActions are relabel actions which can be applied to series by bucket rewrite.
+var rewriteSupportedRelabelActions = map[relabel.Action]struct{}{
+	relabel.Keep:      {},
+	relabel.Drop:      {},
+	relabel.Replace:   {},
+	relabel.HashMod:   {},
+	relabel.LabelMap:  {},
+	relabel.LabelDrop: {},
+	relabel.LabelKeep: {},
+}
+
+func registerBucketUnmark(app extkingpin.AppClause, objStoreConfig *extflag.PathOrContent) {
+	cmd := app.Command("unmark", "Remove deletion or no-compact marker of blocks. Deletion marker is removed only if the block meta file exists, as otherwise the block might be partially deleted already. "+
+		"NOTE: Block marked for deletion might be deleted by the compactor at any time after its delete delay; use 'tools bucket restore' to verify blocks before removing their deletion markers.")
+	blockIDs := cmd.Flag("id", "ID (ULID) of the blocks to be unmarked (repeated flag)").Required().Strings()
+	marker := cmd.Flag("marker", "Marker to be removed.").Required().Enum(metadata.DeletionMarkFilename, metadata.NoCompactMarkFilename)
+
+	cmd.Setup(func(g *run.Group, logger log.Logger, reg *prometheus.Registry, _ opentracing.Tracer, _ <-chan struct{}, _ bool) error {
+		confContentYaml, err := objStoreConfig.Content()
+		if err != nil {
+			return err
+		}
+
+		bkt, err := client.NewBucket(logger, confContentYaml, reg, component.Mark.String())
+		if err != nil {
+			return err
+		}
+
+		var ids []ulid.ULID
+		for _, id := range *blockIDs {
+			u, err := ulid.Parse(id)
+			if err != nil {
+				return errors.Errorf("block.id is not a valid UUID, got: %v", id)
+			}
+			ids = append(ids, u)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		g.Add(func() error {
+			for _, id := range ids {
+				if err := block.RemoveMark(ctx, logger, bkt, id, *marker); err != nil {
+					return errors.Wrapf(err, "unmark %v for %v", id, *marker)
+				}
+			}
+			level.Info(logger).Log("msg", "unmarking done", "marker", *marker, "IDs", strings.Join(*blockIDs, ","))
+			return nil
+		}, func(err error) {
+			cancel()
+		})
+		return nil
+	})
+}
+
+func registerBucketRestore(app extkingpin.AppClause, objStoreConfig *extflag.PathOrContent) {
+	cmd := app.Command("restore", "Restore blocks marked for deletion which were not deleted yet, by removing their deletion markers after verifying the blocks. "+
+		"Blocks are chosen either by --id or by the time they were marked for deletion and the block selection flags, at least one of them is required.\n\n"+
+		"Blocks marked for deletion earlier than --delete-delay ago are skipped, as the compactor might be deleting them already. "+
+		"Blocks sharing source blocks with a block not marked for deletion, e.g. compacted by the compactor, are skipped too, as restoring them would create overlapping blocks. "+
+		"For every other block, presence and sizes of files recorded in its meta file are verified and, unless disabled, its index is downloaded and verified.")
+	blockIDs := cmd.Flag("id", "ID (ULID) of the blocks to restore (repeated flag). If specified, mark time and block selection flags are ignored.").Strings()
+	markTimeSet := false
+	markSet := func(*kingpin.ParseContext) error {
+		markTimeSet = true
+		return nil
+	}
+	markedAfter := model.TimeOrDuration(cmd.Flag("marked-after", "Only blocks marked for deletion after this time are restored. "+
+		"Option can be a constant time in RFC3339 format or time duration relative to current time, such as -1d or 2h45m. Valid duration units are ms, s, m, h, d, w, y.").
+		Default("0000-01-01T00:00:00Z").Action(markSet))
+	markedBefore := model.TimeOrDuration(cmd.Flag("marked-before", "Only blocks marked for deletion before this time are restored. "+
+		"Option can be a constant time in RFC3339 format or time duration relative to current time, such as -1d or 2h45m. Valid duration units are ms, s, m, h, d, w, y.").
+		Default("9999-12-31T23:59:59Z").Action(markSet))
+	selection := (&blockSelectionConfig{}).registerFlag(cmd)
+	deleteDelay := cmd.Flag("delete-delay", "Delete delay of the compactor. Blocks marked for deletion earlier than this duration ago are not restored.").Default("48h").Duration()
+	verifyIndex := cmd.Flag("verify-index", "Download and verify the index of each block before restoring it. Use --no-verify-index to only check files of the blocks.").Default("true").Bool()
+	dryRun := cmd.Flag("dry-run", "Only verify and print blocks which would be restored, without removing their deletion markers.").Default("false").Bool()
+	tmpDir := cmd.Flag("tmp.dir", "Working directory for downloaded index files").Default(filepath.Join(os.TempDir(), "thanos-restore")).String()
+	timeout := cmd.Flag("timeout", "Timeout to verify and restore the blocks").Default("1h").Duration()
+
+	cmd.Setup(func(g *run.Group, logger log.Logger, reg *prometheus.Registry, _ opentracing.Tracer, _ <-chan struct{}, _ bool) error {
+		var ids []ulid.ULID
+		for _, id := range *blockIDs {
+			u, err := ulid.Parse(id)
+			if err != nil {
+				return errors.Errorf("id is not a valid block ULID, got: %v", id)
+			}
+			ids = append(ids, u)
+		}
+		if len(ids) == 0 && !markTimeSet && !selection.set {
+			return errors.New("restoring all blocks marked for deletion is not allowed, specify --id, --marked-after, --marked-before or block selection flags")
+		}
+		filters, err := selection.filters()
+		if err != nil {
+			return err
+		}
+
+		confContentYaml, err := objStoreConfig.Content()
+		if err != nil {
+			return err
+		}
+		bkt, err := client.NewBucket(logger, confContentYaml, reg, component.Bucket.String())
+		if err != nil {
+			return err
+		}
+		defer runutil.CloseWithLogOnErr(logger, bkt, "bucket client")
+
+		// Dummy actor to immediately kill the group after the run function returns.
+		g.Add(func() error { return nil }, func(error) {})
+
+		if err := os.MkdirAll(*tmpDir, os.ModePerm); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+
+		marks := map[ulid.ULID]*metadata.DeletionMark{}
+		if len(ids) > 0 {
+			for _, id := range ids {
+				m := &metadata.DeletionMark{}
+				if err := metadata.ReadMarker(ctx, logger, bkt, id.String(), m); err != nil {
+					if errors.Cause(err) == metadata.ErrorMarkerNotFound {
+						level.Warn(logger).Log("msg", "block is not marked for deletion, skipping", "block", id)
+						continue
+					}
+					return errors.Wrapf(err, "read deletion mark of block %v", id)
+				}
+				marks[id] = m
+			}
+		} else {
+			ignoreDeletionMarkFilter := block.NewIgnoreDeletionMarkFilter(logger, bkt, 0, block.FetcherConcurrency)
+			fetcher, err := block.NewMetaFetcher(logger, block.FetcherConcurrency, bkt, "", extprom.WrapRegistererWithPrefix(extpromPrefix, reg),
+				append(filters, ignoreDeletionMarkFilter), nil)
+			if err != nil {
+				return err
+			}
+			if _, _, err := fetcher.Fetch(ctx); err != nil {
+				return err
+			}
+			for id, m := range ignoreDeletionMarkFilter.DeletionMarkBlocks() {
+				if ms := m.DeletionTime * 1000; ms < markedAfter.PrometheusTimestamp() || ms > markedBefore.PrometheusTimestamp() {
+					continue
+				}
+				marks[id] = m
+			}
+		}
+
+		restoreIDs := make([]ulid.ULID, 0, len(marks))
+		for id := range marks {
+			restoreIDs = append(restoreIDs, id)
+		}
+		sort.Slice(restoreIDs, func(i, j int) bool { return restoreIDs[i].Compare(restoreIDs[j]) < 0 })
+
+		// Blocks which are not marked for deletion, regardless of the block selection.
+		liveFetcher, err := block.NewMetaFetcher(logger, block.FetcherConcurrency, bkt, "", nil,
+			[]block.MetadataFilter{block.NewIgnoreDeletionMarkFilter(logger, bkt, 0, block.FetcherConcurrency)}, nil)
+		if err != nil {
+			return err
+		}
+		live, _, err := liveFetcher.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+		compacted := compactedSources(live)
+
+		var failed int
+		for _, id := range restoreIDs {
+			if markedAgo := time.Since(time.Unix(marks[id].DeletionTime, 0)); markedAgo > *deleteDelay {
+				level.Warn(logger).Log("msg", "block was marked for deletion earlier than delete delay, skipping", "block", id, "marked_ago", markedAgo)
+				failed++
+				continue
+			}
+			meta, err := block.DownloadMeta(ctx, logger, bkt, id)
+			if err != nil {
+				level.Error(logger).Log("msg", "failed to download meta, skipping", "block", id, "err", err)
+				failed++
+				continue
+			}
+			if into, ok := compactedInto(&meta, compacted); ok {
+				level.Warn(logger).Log("msg", "block was compacted into another block, skipping as restoring it would create overlapping blocks; mark the compacted block for deletion first, if needed",
+					"block", id, "compacted_into", into)
+				failed++
+				continue
+			}
+			if err := verifyBlockToRestore(ctx, logger, bkt, &meta, *tmpDir, *verifyIndex); err != nil {
+				level.Error(logger).Log("msg", "block verification failed, skipping", "block", id, "err", err)
+				failed++
+				continue
+			}
+			if *dryRun {
+				level.Info(logger).Log("msg", "dry run: block would be restored", "block", id)
+				continue
+			}
+			if err := block.RemoveMark(ctx, logger, bkt, id, metadata.DeletionMarkFilename); err != nil {
+				return errors.Wrapf(err, "restore block %v", id)
+			}
+		}
+		level.Info(logger).Log("msg", "restore done", "blocks", len(restoreIDs)-failed, "skipped", failed)
+		if failed > 0 {
+			return errors.Errorf("%d blocks could not be restored", failed)
+		}
+		return nil
+	})
+}
+
+// compactedSources returns the source blocks of the given blocks, mapped to the block compacted from them, per resolution.
+// Downsampled blocks share their sources with the raw block they were downsampled from, so resolutions are kept apart.
+func compactedSources(metas map[ulid.ULID]*metadata.Meta) map[int64]map[ulid.ULID]ulid.ULID {
+	res := map[int64]map[ulid.ULID]ulid.ULID{}
+	for id, m := range metas {
+		sources, ok := res[m.Thanos.Downsample.Resolution]
+		if !ok {
+			sources = map[ulid.ULID]ulid.ULID{}
+			res[m.Thanos.Downsample.Resolution] = sources
+		}
+		for _, s := range m.Compaction.Sources {
+			sources[s] = id
+		}
+	}
+	return res
+}
+
+// compactedInto returns the ID of another block of the same resolution sharing source blocks with the given block, if any.
+func compactedInto(meta *metadata.Meta, compacted map[int64]map[ulid.ULID]ulid.ULID) (ulid.ULID, bool) {
+	for _, s := range meta.Compaction.Sources {
+		if id, ok := compacted[meta.Thanos.Downsample.Resolution][s]; ok && id != meta.ULID {
+			return id, true
+		}
+	}
+	return ulid.ULID{}, false
+}
+
+// verifyBlockToRestore checks that files of the block are present in the bucket and optionally verifies its index.
+func verifyBlockToRestore(ctx context.Context, logger log.Logger, bkt objstore.Bucket, meta *metadata.Meta, tmpDir string, verifyIndex bool) error {
+	id := meta.ULID
+	if err := block.VerifyFilesInBucket(ctx, bkt, meta); err != nil {
+		return err
+	}
+	if !verifyIndex {
+		return nil
+	}
+
+	fn := filepath.Join(tmpDir, id.String()+"-"+block.IndexFilename)
+	if err := objstore.DownloadFile(ctx, logger, bkt, path.Join(id.String(), block.IndexFilename), fn); err != nil {
+		return errors.Wrap(err, "download index")
+	}
+	defer func() {
+		if err := os.Remove(fn); err != nil {
+			level.Warn(logger).Log("msg", "failed to remove downloaded index", "block", id, "err", err)
+		}
+	}()
+	return block.VerifyIndex(logger, fn, meta.MinTime, meta.MaxTime)
+}
+
 func registerBucketRewrite(app extkingpin.AppClause, objStoreConfig *extflag.PathOrContent) {
 	cmd := app.Command(component.Rewrite.String(), "Rewrite chosen blocks in the bucket, while deleting or modifying series "+
 		"Resulted block has modified stats in meta.json. Additionally compaction.sources are altered to not confuse readers of meta.json. "+
@@ -784,16 +1121,24 @@ func registerBucketRewrite(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 		"NOTE: It's recommended to turn off compactor while doing this operation. If the compactor is running and touching exactly same block that "+
 		"is being rewritten, the resulted rewritten block might only cause overlap (mitigated by marking overlapping block manually for deletion) "+
 		"and the data you wanted to rewrite could already part of bigger block.\n\n"+
+		"Blocks are chosen either by --id or, if no ID is given, by the time range and external label matchers. Downsampled blocks and blocks "+
+		"marked for no compaction are never rewritten. Each rewritten source block gets "+
+		"a rewritten-mark.json, so interrupted rewrite with the same configuration can be rerun and continues where it stopped.\n\n"+
 		"Use FILESYSTEM type of bucket to rewrite block on disk (suitable for vanilla Prometheus) "+
-		"After rewrite, it's caller responsibility to delete or mark source block for deletion to avoid overlaps. "+
+		"After rewrite, it's caller responsibility to delete or mark source block for deletion to avoid overlaps, unless --delete-blocks is specified. "+
 		"WARNING: This procedure is *IRREVERSIBLE* after certain time (delete delay), so do backup your blocks first.")
-	blockIDs := cmd.Flag("id", "ID (ULID) of the blocks for rewrite (repeated flag).").Required().Strings()
+	blockIDs := cmd.Flag("id", "ID (ULID) of the blocks for rewrite (repeated flag). If specified, time range and matchers are ignored.").Strings()
+	selection := (&blockSelectionConfig{}).registerFlag(cmd)
 	tmpDir := cmd.Flag("tmp.dir", "Working directory for temporary files").Default(filepath.Join(os.TempDir(), "thanos-rewrite")).String()
 	hashFunc := cmd.Flag("hash-func", "Specify which hash function to use when calculating the hashes of produced files. If no function has been specified, it does not happen. This permits avoiding downloading some files twice albeit at some performance cost. Possible values are: \"\", \"SHA256\".").
 		Default("").Enum("SHA256", "")
 	dryRun := cmd.Flag("dry-run", "Prints the series changes instead of doing them. Defaults to true, for user to double check. (: Pass --no-dry-run to skip this.").Default("true").Bool()
-	toDelete := extflag.RegisterPathOrContent(cmd, "rewrite.to-delete-config", "YAML file that contains []metadata.DeletionRequest that will be applied to blocks", true)
+	toDelete := extflag.RegisterPathOrContent(cmd, "rewrite.to-delete-config", "YAML file that contains []metadata.DeletionRequest that will be applied to blocks", false)
+	toRelabel := extflag.RegisterPathOrContent(cmd, "rewrite.to-relabel-config", "YAML file that contains relabel configs that will be applied to all series of blocks, after deletions. "+
+		"Series which end up with the same labels are merged together. As relabelling changes the order of series, labels and chunk references of all series of a block are held in memory while rewriting it.", false)
 	provideChangeLog := cmd.Flag("rewrite.add-change-log", "If specified, all modifications are written to new block directory. Disable if latency is to high.").Default("true").Bool()
+	concurrency := cmd.Flag("concurrency", "Number of blocks to rewrite concurrently.").Default("1").Int()
+	deleteBlocks := cmd.Flag("delete-blocks", "Whether to mark source blocks for deletion once the rewritten block is uploaded and verified.").Default("false").Bool()
 	cmd.Setup(func(g *run.Group, logger log.Logger, reg *prometheus.Registry, _ opentracing.Tracer, _ <-chan struct{}, _ bool) error {
 		confContentYaml, err := objStoreConfig.Content()
 		if err != nil {
@@ -815,6 +1160,36 @@ func registerBucketRewrite(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 			return err
 		}
 
+		relabelYaml, err := toRelabel.Content()
+		if err != nil {
+			return err
+		}
+		relabels, err := block.ParseRelabelConfig(relabelYaml, rewriteSupportedRelabelActions)
+		if err != nil {
+			return err
+		}
+
+		if len(deletionsYaml) == 0 && len(relabelYaml) == 0 {
+			return errors.New("nothing to rewrite; specify --rewrite.to-delete-config or --rewrite.to-relabel-config")
+		}
+		var modifiers []compactv2.Modifier
+		if len(deletionsYaml) > 0 {
+			modifiers = append(modifiers, compactv2.WithDeletionModifier(deletions...))
+		}
+		if len(relabelYaml) > 0 {
+			modifiers = append(modifiers, compactv2.WithRelabelModifier(relabels...))
+		}
+		if *concurrency < 1 {
+			return errors.Errorf("concurrency must be at least 1, got %d", *concurrency)
+		}
+
+		// Rewrite hash identifies the rewrite configuration, so only blocks rewritten with exactly the same configuration are skipped on rerun.
+		h := sha256.New()
+		_, _ = h.Write(deletionsYaml)
+		_, _ = h.Write([]byte{0xff})
+		_, _ = h.Write(relabelYaml)
+		rewriteHash := hex.EncodeToString(h.Sum(nil))
+
 		var ids []ulid.ULID
 		for _, id := range *blockIDs {
 			u, err := ulid.Parse(id)
@@ -823,6 +1198,13 @@ func registerBucketRewrite(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 			}
 			ids = append(ids, u)
 		}
+		if len(ids) == 0 && !selection.set {
+			return errors.New("rewriting all blocks is not allowed, specify --id or block selection flags")
+		}
+		filters, err := selection.filters()
+		if err != nil {
+			return err
+		}
 
 		if err := os.RemoveAll(*tmpDir); err != nil {
 			return err
@@ -833,14 +1215,32 @@ func registerBucketRewrite(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 
 		ctx, cancel := context.WithCancel(context.Background())
 		g.Add(func() error {
+			var alreadyRewritten []ulid.ULID
+			ids, alreadyRewritten, err = selectBlocksToRewrite(ctx, logger, bkt, reg, ids, filters, rewriteHash)
+			if err != nil {
+				return err
+			}
+			if len(alreadyRewritten) > 0 {
+				level.Info(logger).Log("msg", "skipping blocks already rewritten with the same configuration", "blocks", len(alreadyRewritten))
+			}
+			level.Info(logger).Log("msg", "blocks selected for rewrite", "blocks", len(ids))
+
 			chunkPool := chunkenc.NewPool()
-			changeLog := compactv2.NewChangeLog(ioutil.Discard)
-			for _, id := range ids {
+			markedForDeletion := promauto.With(reg).NewCounter(prometheus.CounterOpts{
+				Name: "thanos_rewrite_blocks_marked_for_deletion_total",
+				Help: "Total number of source blocks marked for deletion after being rewritten.",
+			})
+			rewrite := func(id ulid.ULID) error {
 				// Delete series from block & modify.
 				level.Info(logger).Log("msg", "downloading block", "source", id)
 				if err := block.Download(ctx, logger, bkt, id, filepath.Join(*tmpDir, id.String())); err != nil {
 					return errors.Wrapf(err, "download %v", id)
 				}
+				defer func() {
+					if err := os.RemoveAll(filepath.Join(*tmpDir, id.String())); err != nil {
+						level.Warn(logger).Log("msg", "failed to remove downloaded block", "block", id, "err", err)
+					}
+				}()
 
 				meta, err := metadata.ReadFromDir(filepath.Join(*tmpDir, id.String()))
 				if err != nil {
@@ -850,6 +1250,7 @@ func registerBucketRewrite(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 				if err != nil {
 					return errors.Wrapf(err, "open block %v", id)
 				}
+				defer runutil.CloseWithLogOnErr(logger, b, "close block %v", id)
 
 				p := compactv2.NewProgressLogger(logger, int(b.Meta().Stats.NumSeries))
 				newID := ulid.MustNew(ulid.Now(), rand.Reader)
@@ -857,6 +1258,7 @@ func registerBucketRewrite(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 				meta.Thanos.Rewrites = append(meta.Thanos.Rewrites, metadata.Rewrite{
 					Sources:          meta.Compaction.Sources,
 					DeletionsApplied: deletions,
+					RelabelsApplied:  relabels,
 				})
 				meta.Compaction.Sources = []ulid.ULID{newID}
 				meta.Thanos.Source = metadata.BucketRewriteSource
@@ -864,7 +1266,16 @@ func registerBucketRewrite(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 				if err := os.MkdirAll(filepath.Join(*tmpDir, newID.String()), os.ModePerm); err != nil {
 					return err
 				}
+				defer func() {
+					if *dryRun {
+						return
+					}
+					if err := os.RemoveAll(filepath.Join(*tmpDir, newID.String())); err != nil {
+						level.Warn(logger).Log("msg", "failed to remove rewritten block", "block", newID, "err", err)
+					}
+				}()
 
+				changeLog := compactv2.NewChangeLog(ioutil.Discard)
 				if *provideChangeLog {
 					f, err := os.OpenFile(filepath.Join(*tmpDir, newID.String(), "change.log"), os.O_CREATE|os.O_WRONLY, os.ModePerm)
 					if err != nil {
@@ -888,13 +1299,13 @@ func registerBucketRewrite(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 					comp = compactv2.New(*tmpDir, logger, changeLog, chunkPool)
 				}
 
-				level.Info(logger).Log("msg", "starting rewrite for block", "source", id, "new", newID, "toDelete", string(deletionsYaml))
-				if err := comp.WriteSeries(ctx, []block.Reader{b}, d, p, compactv2.WithDeletionModifier(deletions...)); err != nil {
+				level.Info(logger).Log("msg", "starting rewrite for block", "source", id, "new", newID, "toDelete", string(deletionsYaml), "toRelabel", string(relabelYaml))
+				if err := comp.WriteSeries(ctx, []block.Reader{b}, d, p, modifiers...); err != nil {
 					return errors.Wrapf(err, "writing series from %v to %v", id, newID)
 				}
 
 				if *dryRun {
-					level.Info(logger).Log("msg", "dry run finished. Changes should be printed to stderr")
+					level.Info(logger).Log("msg", "dry run finished for block. Changes should be printed to stderr", "source", id)
 					return nil
 				}
 
@@ -906,14 +1317,66 @@ func registerBucketRewrite(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 				if err := meta.WriteToDir(logger, filepath.Join(*tmpDir, newID.String())); err != nil {
 					return err
 				}
+				if err := block.VerifyIndex(logger, filepath.Join(*tmpDir, newID.String(), block.IndexFilename), meta.MinTime, meta.MaxTime); err != nil {
+					return errors.Wrapf(err, "verify index of rewritten block %v", newID)
+				}
 
 				level.Info(logger).Log("msg", "uploading new block", "source", id, "new", newID)
 				if err := block.Upload(ctx, logger, bkt, filepath.Join(*tmpDir, newID.String()), metadata.HashFunc(*hashFunc)); err != nil {
 					return errors.Wrap(err, "upload")
 				}
+				// Meta file is uploaded last, so its presence means the whole block is in the bucket.
+				ok, err := bkt.Exists(ctx, path.Join(newID.String(), block.MetaFilename))
+				if err != nil {
+					return errors.Wrapf(err, "check uploaded block %v", newID)
+				}
+				if !ok {
+					return errors.Errorf("uploaded block %v not found in bucket", newID)
+				}
 				level.Info(logger).Log("msg", "uploaded", "source", id, "new", newID)
+
+				if err := block.MarkRewritten(ctx, logger, bkt, id, newID, rewriteHash); err != nil {
+					return errors.Wrapf(err, "mark %v as rewritten", id)
+				}
+				if *deleteBlocks {
+					if err := block.MarkForDeletion(ctx, logger, bkt, id, fmt.Sprintf("rewritten into %v", newID), markedForDeletion); err != nil {
+						return errors.Wrapf(err, "mark %v for deletion", id)
+					}
+				}
+				return nil
+			}
+
+			var (
+				eg            errgroup.Group
+				ch            = make(chan ulid.ULID)
+				gCtx, gCancel = context.WithCancel(ctx)
+			)
+			defer gCancel()
+			for i := 0; i < *concurrency; i++ {
+				eg.Go(func() error {
+					for id := range ch {
+						if err := rewrite(id); err != nil {
+							gCancel()
+							return err
+						}
+					}
+					return nil
+				})
+			}
+		feed:
+			for _, id := range ids {
+				select {
+				case ch <- id:
+				case <-gCtx.Done():
+					break feed
+				}
+			}
+			close(ch)
+			if err := eg.Wait(); err != nil {
+				return err
 			}
-			level.Info(logger).Log("msg", "rewrite done", "IDs", strings.Join(*blockIDs, ","))
+
+			level.Info(logger).Log("msg", "rewrite done", "IDs", fmt.Sprint(ids))
 			return nil
 		}, func(err error) {
 			cancel()
@@ -921,3 +1384,910 @@ func registerBucketRewrite(app extkingpin.AppClause, objStoreConfig *extflag.Pat
 		return nil
 	})
 }
+
+// selectBlocksToRewrite returns the blocks to rewrite, either the given IDs or, if none are given, blocks matching the filters.
+// Blocks which were already rewritten with the same rewrite hash and blocks produced by such rewrites are
+// returned separately, so the interrupted rewrite can be resumed. Downsampled blocks, whose aggregated chunks can't be
+// rewritten, and blocks marked for no compaction are excluded.
+func selectBlocksToRewrite(
+	ctx context.Context,
+	logger log.Logger,
+	bkt objstore.InstrumentedBucket,
+	reg prometheus.Registerer,
+	ids []ulid.ULID,
+	filters []block.MetadataFilter,
+	rewriteHash string,
+) (toRewrite []ulid.ULID, alreadyRewritten []ulid.ULID, err error) {
+	var (
+		candidates = ids
+		deleted    []ulid.ULID
+	)
+	if len(ids) == 0 {
+		ignoreDeletionMarkFilter := block.NewIgnoreDeletionMarkFilter(logger, bkt, 0, block.FetcherConcurrency)
+		fetcher, err := block.NewMetaFetcher(logger, block.FetcherConcurrency, bkt, "", extprom.WrapRegistererWithPrefix(extpromPrefix, reg),
+			append(filters, ignoreDeletionMarkFilter), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		metas, _, err := fetcher.Fetch(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		for id := range metas {
+			candidates = append(candidates, id)
+		}
+		// Blocks already marked for deletion are only checked for their rewritten-mark, so the blocks they were
+		// rewritten into are not rewritten again.
+		for id := range ignoreDeletionMarkFilter.DeletionMarkBlocks() {
+			if _, ok := metas[id]; !ok {
+				deleted = append(deleted, id)
+			}
+		}
+	}
+
+	done := map[ulid.ULID]struct{}{}
+	for _, id := range append(append([]ulid.ULID{}, candidates...), deleted...) {
+		m := metadata.RewrittenMark{}
+		if err := metadata.ReadMarker(ctx, logger, bkt, id.String(), &m); err != nil {
+			if errors.Cause(err) == metadata.ErrorMarkerNotFound {
+				continue
+			}
+			return nil, nil, errors.Wrapf(err, "read rewritten mark of %v", id)
+		}
+		if m.RewriteHash != rewriteHash {
+			continue
+		}
+		done[id] = struct{}{}
+		done[m.NewID] = struct{}{}
+	}
+
+	for _, id := range candidates {
+		if _, ok := done[id]; ok {
+			alreadyRewritten = append(alreadyRewritten, id)
+			continue
+		}
+		ok, err := canRewrite(ctx, logger, bkt, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			continue
+		}
+		toRewrite = append(toRewrite, id)
+	}
+	sort.Slice(toRewrite, func(i, j int) bool {
+		return toRewrite[i].Compare(toRewrite[j]) < 0
+	})
+	return toRewrite, alreadyRewritten, nil
+}
+
+// canRewrite returns false if the block is downsampled or marked for no compaction.
+func canRewrite(ctx context.Context, logger log.Logger, bkt objstore.InstrumentedBucket, id ulid.ULID) (bool, error) {
+	meta, err := block.DownloadMeta(ctx, logger, bkt, id)
+	if err != nil {
+		return false, err
+	}
+	if meta.Thanos.Downsample.Resolution > 0 {
+		level.Warn(logger).Log("msg", "skipping downsampled block, as aggregated chunks can't be rewritten", "block", id)
+		return false, nil
+	}
+	if err := metadata.ReadMarker(ctx, logger, bkt, id.String(), &metadata.NoCompactMark{}); err == nil {
+		level.Warn(logger).Log("msg", "skipping block marked for no compaction", "block", id)
+		return false, nil
+	} else if errors.Cause(err) != metadata.ErrorMarkerNotFound {
+		return false, errors.Wrapf(err, "read no compaction mark of %v", id)
+	}
+	return true, nil
+}
+
+func registerBucketCompactPlan(app extkingpin.AppClause, objStoreConfig *extflag.PathOrContent) {
+	cmd := app.Command("compact-plan", "Print the compaction groups and the compactions, downsamplings and retention deletions the compactor would do in its next iteration, without executing them. "+
+		"NOTE: The plan is based on block metadata only, so the work which would fail or be excluded by the compactor (e.g. due to the index size limit) is still listed.")
+	output := cmd.Flag("output", "Format in which to print the plan. Options are 'table' or 'json'.").
+		Short('o').Default("table").Enum("table", "json")
+	timeout := cmd.Flag("timeout", "Timeout to download metadata from remote storage").Default("5m").Duration()
+	deleteDelay := cmd.Flag("delete-delay", "Time before a block marked for deletion is deleted from bucket, as configured in compactor.").Default("48h").Duration()
+	consistencyDelay := cmd.Flag("consistency-delay", "Minimum age of fresh (non-compacted) blocks before they are being processed, as configured in compactor.").
+		Default("30m").Duration()
+	dedupReplicaLabels := cmd.Flag("deduplication.replica-label", "Label to treat as a replica indicator of blocks that can be deduplicated (repeated flag), as configured in compactor.").
+		Strings()
+	disableDownsampling := cmd.Flag("downsampling.disable", "Do not plan downsampling.").Default("false").Bool()
+	dc := (&downsamplingConfig{}).registerFlag(cmd)
+	rc := (&retentionConfig{}).registerFlag(cmd)
+	selectorRelabelConf := extkingpin.RegisterSelectorRelabelFlags(cmd)
+
+	cmd.Setup(func(g *run.Group, logger log.Logger, reg *prometheus.Registry, _ opentracing.Tracer, _ <-chan struct{}, _ bool) error {
+		downsamplingLevels, err := dc.parse()
+		if err != nil {
+			return err
+		}
+		retentionByResolution, err := rc.byResolution(downsamplingLevels)
+		if err != nil {
+			return err
+		}
+		if *disableDownsampling {
+			downsamplingLevels = nil
+		}
+
+		relabelContentYaml, err := selectorRelabelConf.Content()
+		if err != nil {
+			return errors.Wrap(err, "get content of relabel configuration")
+		}
+		relabelConfig, err := block.ParseRelabelConfig(relabelContentYaml, block.SelectorSupportedRelabelActions)
+		if err != nil {
+			return err
+		}
+
+		confContentYaml, err := objStoreConfig.Content()
+		if err != nil {
+			return err
+		}
+		bkt, err := client.NewBucket(logger, confContentYaml, reg, component.Bucket.String())
+		if err != nil {
+			return err
+		}
+		defer runutil.CloseWithLogOnErr(logger, bkt, "bucket client")
+
+		// Dummy actor to immediately kill the group after the run function returns.
+		g.Add(func() error { return nil }, func(error) {})
+
+		// Blocks are filtered in the same way as in compactor.
+		noCompactMarkerFilter := compact.NewGatherNoCompactionMarkFilter(logger, bkt)
+		fetcher, err := block.NewMetaFetcher(logger, block.FetcherConcurrency, bkt, "", extprom.WrapRegistererWithPrefix(extpromPrefix, reg),
+			[]block.MetadataFilter{
+				block.NewLabelShardedMetaFilter(relabelConfig),
+				block.NewConsistencyDelayMetaFilter(logger, *consistencyDelay, extprom.WrapRegistererWithPrefix(extpromPrefix, reg)),
+				block.NewIgnoreDeletionMarkFilter(logger, bkt, *deleteDelay/2, block.FetcherConcurrency),
+				block.NewDeduplicateFilter(),
+				noCompactMarkerFilter,
+			}, []block.MetadataModifier{block.NewReplicaLabelRemover(logger, *dedupReplicaLabels)},
+		)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+
+		metas, _, err := fetcher.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+
+		levels, err := compactions.levels(compactions.maxLevel())
+		if err != nil {
+			return errors.Wrap(err, "get compaction levels")
+		}
+		stubCounter := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+		grouper := compact.NewDefaultGrouper(logger, bkt, false, len(*dedupReplicaLabels) > 0, false, false, nil, stubCounter, stubCounter, metadata.NoneFunc)
+
+		plan, err := compact.PlanIteration(ctx, grouper, compact.NewPlanner(logger, levels, noCompactMarkerFilter), metas, downsamplingLevels, retentionByResolution, time.Now())
+		if err != nil {
+			return errors.Wrap(err, "plan compactor iteration")
+		}
+
+		if *output == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "\t")
+			return enc.Encode(plan)
+		}
+		printCompactPlan(plan)
+		return nil
+	})
+}
+
+func printCompactPlan(plan *compact.IterationPlan) {
+	formatTime := func(t int64) string {
+		return time.Unix(t/1000, 0).Format("02-01-2006 15:04:05")
+	}
+	formatResolution := func(res int64) string {
+		return time.Duration(res * int64(time.Millisecond)).String()
+	}
+
+	var lines [][]string
+	for _, g := range plan.Groups {
+		var labels []string
+		for _, key := range getKeysAlphabetically(g.Labels) {
+			labels = append(labels, fmt.Sprintf("%s=%s", key, g.Labels[key]))
+		}
+		lines = append(lines, []string{g.Key, strings.Join(labels, ","), formatResolution(g.Resolution), strconv.Itoa(g.Blocks), formatTime(g.MinTime), formatTime(g.MaxTime)})
+	}
+	printTitledTable("Groups", []string{"GROUP", "LABELS", "RESOLUTION", "#BLOCKS", "FROM", "UNTIL"}, lines)
+
+	lines = lines[:0]
+	for _, c := range plan.Compactions {
+		blocks := make([]string, 0, len(c.Blocks))
+		for _, id := range c.Blocks {
+			blocks = append(blocks, id.String())
+		}
+		lines = append(lines, []string{c.Group, strconv.Itoa(c.Level), formatTime(c.MinTime), formatTime(c.MaxTime), strings.Join(blocks, ","), c.Result.String()})
+	}
+	printTitledTable("Compactions", []string{"GROUP", "COMP-LEVEL", "FROM", "UNTIL", "BLOCKS", "RESULT"}, lines)
+
+	lines = lines[:0]
+	for _, d := range plan.Downsamples {
+		lines = append(lines, []string{d.Group, d.Block.String(), formatTime(d.MinTime), formatTime(d.MaxTime), formatResolution(d.Resolution), d.Result.String()})
+	}
+	printTitledTable("Downsamplings", []string{"GROUP", "BLOCK", "FROM", "UNTIL", "RESOLUTION", "RESULT"}, lines)
+
+	lines = lines[:0]
+	for _, d := range plan.Deletions {
+		lines = append(lines, []string{d.Block.String(), formatResolution(d.Resolution), formatTime(d.MaxTime), d.Retention.String()})
+	}
+	printTitledTable("Retention deletions", []string{"BLOCK", "RESOLUTION", "UNTIL", "RETENTION"}, lines)
+
+	if len(plan.Compactions)+len(plan.Downsamples) > 0 {
+		fmt.Fprintln(os.Stdout, "Simulated blocks (RESULT) get a different ID once produced by the compactor.")
+	}
+}
+
+func printTitledTable(title string, header []string, lines [][]string) {
+	fmt.Fprintf(os.Stdout, "%s (%d):\n", title, len(lines))
+	if len(lines) == 0 {
+		fmt.Fprintln(os.Stdout, "")
+		return
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(header)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetAutoWrapText(false)
+	table.SetReflowDuringAutoWrap(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.AppendBulk(lines)
+	table.Render()
+	fmt.Fprintln(os.Stdout, "")
+}
+
+func registerBucketImport(app extkingpin.AppClause, objStoreConfig *extflag.PathOrContent) {
+	cmd := app.Command("import", "Import samples with timestamps from OpenMetrics or Prometheus text format or CSV into new blocks in the bucket. "+
+		"Blocks are aligned to the block duration and get the given external labels, so they are handled by other Thanos components like any other block. "+
+		"NOTE: Make sure imported blocks do not overlap with existing blocks of the same external labels, otherwise the compactor halts.")
+	inputFile := cmd.Flag("input-file", "Path to the file with samples to import.").Required().ExistingFile()
+	inputFormat := cmd.Flag("input-format", "Format of the input file. Options are 'openmetrics', 'prometheus' or 'csv'. Every sample must have a timestamp. "+
+		"CSV has to have a header row naming the 'timestamp' (in milliseconds) and 'value' columns, all other columns are labels.").
+		Default(block.OpenMetricsImportFormat).Enum(block.OpenMetricsImportFormat, block.PrometheusImportFormat, block.CSVImportFormat)
+	labelStrs := cmd.Flag("label", "External label to attach to the imported blocks (repeated), e.g. cluster=\"eu1\". At least one is required.").PlaceHolder("<name>=\"<value>\"").Required().Strings()
+	blockDuration := extkingpin.ModelDuration(cmd.Flag("block-duration", "Duration of the imported blocks. Keep the default, unless the data is sparse.").Default("2h"))
+	tmpDir := cmd.Flag("tmp.dir", "Working directory for temporary files").Default(filepath.Join(os.TempDir(), "thanos-import")).String()
+	hashFunc := cmd.Flag("hash-func", "Specify which hash function to use when calculating the hashes of produced files. If no function has been specified, it does not happen. This permits avoiding downloading some files twice albeit at some performance cost. Possible values are: \"\", \"SHA256\".").
+		Default("").Enum("SHA256", "")
+	cmd.Setup(func(g *run.Group, logger log.Logger, reg *prometheus.Registry, _ opentracing.Tracer, _ <-chan struct{}, _ bool) error {
+		lset, err := parseFlagLabels(*labelStrs)
+		if err != nil {
+			return errors.Wrap(err, "parse labels")
+		}
+
+		confContentYaml, err := objStoreConfig.Content()
+		if err != nil {
+			return err
+		}
+
+		bkt, err := client.NewBucket(logger, confContentYaml, reg, component.Bucket.String())
+		if err != nil {
+			return err
+		}
+
+		input, err := ioutil.ReadFile(*inputFile)
+		if err != nil {
+			return errors.Wrap(err, "read input file")
+		}
+
+		if err := os.RemoveAll(*tmpDir); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(*tmpDir, os.ModePerm); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			defer runutil.CloseWithLogOnErr(logger, bkt, "bucket client")
+
+			ids, err := block.CreateBlocksFromText(ctx, logger, input, *inputFormat, time.Duration(*blockDuration).Milliseconds(), *tmpDir, metadata.Thanos{
+				Version:    metadata.ThanosVersion1,
+				Labels:     lset.Map(),
+				Downsample: metadata.ThanosDownsample{Resolution: 0},
+				Source:     metadata.BucketImportSource,
+			})
+			if err != nil {
+				return errors.Wrap(err, "create blocks")
+			}
+
+			for _, id := range ids {
+				if err := block.Upload(ctx, logger, bkt, filepath.Join(*tmpDir, id.String()), metadata.HashFunc(*hashFunc)); err != nil {
+					return errors.Wrapf(err, "upload block %v", id)
+				}
+				level.Info(logger).Log("msg", "uploaded block", "id", id)
+			}
+			level.Info(logger).Log("msg", "import done", "blocks", len(ids))
+			return os.RemoveAll(*tmpDir)
+		}, func(err error) {
+			cancel()
+		})
+		return nil
+	})
+}
+
+func registerBucketExport(app extkingpin.AppClause, objStoreConfig *extflag.PathOrContent) {
+	cmd := app.Command("export", "Export raw samples of series matching the selector within the time range from the bucket into files in OpenMetrics text or Parquet format. "+
+		"Blocks are read like in Store Gateway, so only index headers and the required parts of index and chunks are fetched, without downloading whole blocks. "+
+		"One file is written for each split interval, so the exported samples of every series are in time order within each file.")
+	selector := cmd.Flag("selector", "Series selector of the exported series, e.g. '{__name__=~\"http_.*\", cluster=\"eu1\"}'. External labels of blocks can be matched as well.").
+		Required().String()
+	minTime := model.TimeOrDuration(cmd.Flag("min-time", "Start of time range limit of exported samples. Option can be a constant time in RFC3339 format or time duration relative to current time, such as -1d or 2h45m. Valid duration units are ms, s, m, h, d, w, y.").
+		Default("0000-01-01T00:00:00Z"))
+	maxTime := model.TimeOrDuration(cmd.Flag("max-time", "End of time range limit of exported samples. Option can be a constant time in RFC3339 format or time duration relative to current time, such as -1d or 2h45m. Valid duration units are ms, s, m, h, d, w, y.").
+		Default("9999-12-31T23:59:59Z"))
+	splitInterval := extkingpin.ModelDuration(cmd.Flag("split-interval", "Time range of samples in each output file. Bigger interval means fewer files, but more memory used as all series of the interval are loaded at once.").
+		Default("1d"))
+	outputDir := cmd.Flag("output-dir", "Directory to write the output files into. File names are the time ranges of their samples in <min-time>-<max-time>.<om|parquet> format, in milliseconds.").
+		Required().String()
+	outputFormat := cmd.Flag("output-format", "Format of the output files. Options are 'openmetrics' for OpenMetrics text format or 'parquet' for Parquet files with labels (map of label names to values), timestamp (milliseconds) and value columns.").
+		Default("openmetrics").Enum("openmetrics", "parquet")
+	tmpDir := cmd.Flag("tmp.dir", "Working directory for index headers of blocks").Default(filepath.Join(os.TempDir(), "thanos-export")).String()
+	cmd.Setup(func(g *run.Group, logger log.Logger, reg *prometheus.Registry, _ opentracing.Tracer, _ <-chan struct{}, _ bool) error {
+		promMatchers, err := parser.ParseMetricSelector(*selector)
+		if err != nil {
+			return errors.Wrap(err, "parse selector")
+		}
+		matchers, err := storepb.PromMatchersToMatchers(promMatchers...)
+		if err != nil {
+			return errors.Wrap(err, "convert selector")
+		}
+		if time.Duration(*splitInterval) <= 0 {
+			return errors.New("split interval must be positive")
+		}
+
+		confContentYaml, err := objStoreConfig.Content()
+		if err != nil {
+			return err
+		}
+
+		bkt, err := client.NewBucket(logger, confContentYaml, reg, component.Bucket.String())
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(*outputDir, os.ModePerm); err != nil {
+			return errors.Wrap(err, "create output directory")
+		}
+		if err := os.MkdirAll(*tmpDir, os.ModePerm); err != nil {
+			return err
+		}
+
+		fetcher, err := block.NewMetaFetcher(logger, block.FetcherConcurrency, bkt, "", extprom.WrapRegistererWithPrefix(extpromPrefix, reg), []block.MetadataFilter{
+			block.NewTimePartitionMetaFilter(*minTime, *maxTime),
+			block.NewIgnoreDeletionMarkFilter(logger, bkt, 0, block.FetcherConcurrency),
+			block.NewDeduplicateFilter(),
+		}, nil)
+		if err != nil {
+			return err
+		}
+		bs, err := store.NewBucketStore(
+			logger,
+			extprom.WrapRegistererWithPrefix(extpromPrefix, reg),
+			bkt,
+			fetcher,
+			*tmpDir,
+			nil,
+			nil,
+			nil,
+			store.NewChunksLimiterFactory(0),
+			store.NewSeriesLimiterFactory(0),
+			store.NewGapBasedPartitioner(store.PartitionerMaxGapSize),
+			false,
+			block.FetcherConcurrency,
+			&store.FilterConfig{MinTime: *minTime, MaxTime: *maxTime},
+			false,
+			store.DefaultPostingOffsetInMemorySampling,
+			false,
+			false,
+			0,
+		)
+		if err != nil {
+			return errors.Wrap(err, "create bucket store")
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			defer runutil.CloseWithLogOnErr(logger, bkt, "bucket client")
+			defer runutil.CloseWithLogOnErr(logger, bs, "bucket store")
+
+			if err := bs.SyncBlocks(ctx); err != nil {
+				return errors.Wrap(err, "sync blocks")
+			}
+			storeClient := storepb.ServerAsClient(bs, 0)
+
+			// Export only the time range covered by blocks, so open time range limits do not produce empty files.
+			mint, maxt := bs.TimeRange()
+			if m := minTime.PrometheusTimestamp(); m > mint {
+				mint = m
+			}
+			if m := maxTime.PrometheusTimestamp(); m < maxt {
+				maxt = m
+			}
+			interval := time.Duration(*splitInterval).Milliseconds()
+			for t := mint - mint%interval; t <= maxt; t += interval {
+				start, end := t, t+interval-1
+				if start < mint {
+					start = mint
+				}
+				if end > maxt {
+					end = maxt
+				}
+				ext := "om"
+				if *outputFormat == "parquet" {
+					ext = "parquet"
+				}
+				file := filepath.Join(*outputDir, fmt.Sprintf("%d-%d.%s", start, end, ext))
+				series, samples, err := exportFile(ctx, storeClient, file, *outputFormat, start, end, matchers)
+				if err != nil {
+					return errors.Wrapf(err, "export samples into %s", file)
+				}
+				level.Info(logger).Log("msg", "exported samples", "file", file, "series", series, "samples", samples)
+			}
+			level.Info(logger).Log("msg", "export done")
+			return nil
+		}, func(err error) {
+			cancel()
+		})
+		return nil
+	})
+}
+
+// exportFile writes raw samples of the series matching the matchers within [mint, maxt] into the file in the given format.
+func exportFile(ctx context.Context, storeClient storepb.StoreClient, file, format string, mint, maxt int64, matchers []storepb.LabelMatcher) (series, samples int, err error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer runutil.CloseWithErrCapture(&err, f, "close output file")
+
+	w := bufio.NewWriter(f)
+	var ew exportWriter = newOpenMetricsWriter(w)
+	if format == "parquet" {
+		ew = &parquetExportWriter{w: parquet.NewWriter(w, parquet.DefaultRowGroupSize)}
+	}
+	if series, samples, err = writeExport(ctx, storeClient, ew, mint, maxt, matchers); err != nil {
+		return 0, 0, err
+	}
+	return series, samples, w.Flush()
+}
+
+// writeExport writes raw samples of the series matching the matchers within [mint, maxt] with the export writer and closes it.
+func writeExport(ctx context.Context, storeClient storepb.StoreClient, ew exportWriter, mint, maxt int64, matchers []storepb.LabelMatcher) (series, samples int, err error) {
+	seriesClient, err := storeClient.Series(ctx, &storepb.SeriesRequest{
+		MinTime:                 mint,
+		MaxTime:                 maxt,
+		Matchers:                matchers,
+		PartialResponseStrategy: storepb.PartialResponseStrategy_ABORT,
+	})
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "series")
+	}
+
+	for {
+		resp, err := seriesClient.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "receive series")
+		}
+		if resp.GetWarning() != "" {
+			return 0, 0, errors.New(resp.GetWarning())
+		}
+		s := resp.GetSeries()
+		if s == nil {
+			continue
+		}
+
+		lset := s.PromLabels()
+		if err := ew.WriteSeries(lset); err != nil {
+			return 0, 0, err
+		}
+		it, err := seriesIterator(s)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "series %v", lset)
+		}
+		for it.Next() {
+			t, v := it.At()
+			if t < mint || t > maxt {
+				continue
+			}
+			if err := ew.WriteSample(t, v); err != nil {
+				return 0, 0, err
+			}
+			samples++
+		}
+		if it.Err() != nil {
+			return 0, 0, errors.Wrapf(it.Err(), "iterate chunks of series %v", lset)
+		}
+		series++
+	}
+	return series, samples, ew.Close()
+}
+
+// exportWriter writes exported samples of series in some file format.
+type exportWriter interface {
+	// WriteSeries starts writing samples of the series with the given labels.
+	WriteSeries(lset labels.Labels) error
+	// WriteSample writes a sample of the last started series.
+	WriteSample(t int64, v float64) error
+	// Close writes the end of the file, without closing the underlying writer.
+	Close() error
+}
+
+var openMetricsLabelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// openMetricsWriter writes samples in OpenMetrics text format.
+type openMetricsWriter struct {
+	w      io.Writer
+	buf    bytes.Buffer
+	prefix string
+}
+
+func newOpenMetricsWriter(w io.Writer) *openMetricsWriter {
+	return &openMetricsWriter{w: w}
+}
+
+func (o *openMetricsWriter) WriteSeries(lset labels.Labels) error {
+	o.buf.Reset()
+	o.buf.WriteString(lset.Get(labels.MetricName))
+	o.buf.WriteByte('{')
+	first := true
+	for _, l := range lset {
+		if l.Name == labels.MetricName {
+			continue
+		}
+		if !first {
+			o.buf.WriteByte(',')
+		}
+		first = false
+		o.buf.WriteString(l.Name)
+		o.buf.WriteString(`="`)
+		o.buf.WriteString(openMetricsLabelValueReplacer.Replace(l.Value))
+		o.buf.WriteByte('"')
+	}
+	o.buf.WriteString("} ")
+	o.prefix = o.buf.String()
+	return nil
+}
+
+func (o *openMetricsWriter) WriteSample(t int64, v float64) error {
+	_, err := fmt.Fprint(o.w, o.prefix, strconv.FormatFloat(v, 'g', -1, 64), " ", strconv.FormatFloat(float64(t)/1000, 'f', -1, 64), "\n")
+	return err
+}
+
+func (o *openMetricsWriter) Close() error {
+	_, err := fmt.Fprintln(o.w, "# EOF")
+	return err
+}
+
+// parquetExportWriter writes samples in Parquet format, with labels, timestamp and value columns.
+type parquetExportWriter struct {
+	w    *parquet.Writer
+	lset labels.Labels
+}
+
+func (p *parquetExportWriter) WriteSeries(lset labels.Labels) error {
+	p.lset = lset
+	return nil
+}
+
+func (p *parquetExportWriter) WriteSample(t int64, v float64) error {
+	return p.w.Append(p.lset, t, v)
+}
+
+func (p *parquetExportWriter) Close() error {
+	return p.w.Close()
+}
+
+// seriesIterator returns an iterator over the samples of all raw chunks of the series in time order.
+// Overlapping chunks, e.g. from replicated blocks, are merged and samples with the same timestamp are returned only once.
+func seriesIterator(s *storepb.Series) (chunkenc.Iterator, error) {
+	series := make([]storage.Series, 0, len(s.Chunks))
+	for _, c := range s.Chunks {
+		if c.Raw == nil {
+			return nil, errors.New("no raw chunk")
+		}
+		chk, err := chunkenc.FromData(chunkenc.EncXOR, c.Raw.Data)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode chunk")
+		}
+		series = append(series, &storage.SeriesEntry{SampleIteratorFn: func() chunkenc.Iterator { return chk.Iterator(nil) }})
+	}
+	if len(series) == 0 {
+		return chunkenc.NewNopIterator(), nil
+	}
+	return storage.ChainedSeriesMerge(series...).Iterator(), nil
+}
+
+func registerBucketAnalyze(app extkingpin.AppClause, objStoreConfig *extflag.PathOrContent) {
+	cmd := app.Command("analyze", "Analyze cardinality of blocks in the bucket, i.e. top metrics by series count and chunk bytes, top label names by value count and top label pairs by series count. "+
+		"Only index files are downloaded; chunk bytes are approximated from chunk references and sizes of chunk files.\n\n"+
+		"Blocks are chosen either by --id or, if no ID is given, by the time range and external label matchers, in which case the statistics of all chosen blocks are added up.")
+	blockIDs := cmd.Flag("id", "ID (ULID) of the blocks to analyze (repeated flag). If specified, time range, matchers and resolution are ignored.").Strings()
+	selection := (&blockSelectionConfig{}).registerFlag(cmd)
+	resolution := cmd.Flag("resolution", "Only blocks with this resolution are analyzed, as downsampled blocks contain the same series as raw blocks.").
+		Default("0s").HintAction(listResLevel).Duration()
+	limit := cmd.Flag("limit", "Number of top entries to print in each list. 0 means all entries.").Default("20").Int()
+	output := cmd.Flag("output", "Format in which to print the analysis. Options are 'table' or 'json'.").
+		Short('o').Default("table").Enum("table", "json")
+	tmpDir := cmd.Flag("tmp.dir", "Working directory for downloaded index files").Default(filepath.Join(os.TempDir(), "thanos-analyze")).String()
+	timeout := cmd.Flag("timeout", "Timeout to download metadata and index files from remote storage").Default("1h").Duration()
+	cmd.Setup(func(g *run.Group, logger log.Logger, reg *prometheus.Registry, _ opentracing.Tracer, _ <-chan struct{}, _ bool) error {
+		var ids []ulid.ULID
+		for _, id := range *blockIDs {
+			u, err := ulid.Parse(id)
+			if err != nil {
+				return errors.Errorf("id is not a valid block ULID, got: %v", id)
+			}
+			ids = append(ids, u)
+		}
+		filters, err := selection.filters()
+		if err != nil {
+			return err
+		}
+
+		confContentYaml, err := objStoreConfig.Content()
+		if err != nil {
+			return err
+		}
+		bkt, err := client.NewBucket(logger, confContentYaml, reg, component.Bucket.String())
+		if err != nil {
+			return err
+		}
+		defer runutil.CloseWithLogOnErr(logger, bkt, "bucket client")
+
+		// Dummy actor to immediately kill the group after the run function returns.
+		g.Add(func() error { return nil }, func(error) {})
+
+		if err := os.MkdirAll(*tmpDir, os.ModePerm); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+
+		if len(ids) == 0 {
+			fetcher, err := block.NewMetaFetcher(logger, block.FetcherConcurrency, bkt, "", extprom.WrapRegistererWithPrefix(extpromPrefix, reg),
+				append(filters, block.NewIgnoreDeletionMarkFilter(logger, bkt, 0, block.FetcherConcurrency), block.NewDeduplicateFilter()), nil)
+			if err != nil {
+				return err
+			}
+			metas, _, err := fetcher.Fetch(ctx)
+			if err != nil {
+				return err
+			}
+			for id, m := range metas {
+				if m.Thanos.Downsample.Resolution == resolution.Milliseconds() {
+					ids = append(ids, id)
+				}
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i].Compare(ids[j]) < 0 })
+		}
+
+		stats := block.NewCardinalityStats()
+		for _, id := range ids {
+			level.Info(logger).Log("msg", "analyzing block", "block", id)
+			if err := analyzeBlock(ctx, logger, bkt, id, *tmpDir, stats); err != nil {
+				return errors.Wrapf(err, "analyze block %v", id)
+			}
+		}
+
+		if *output == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "\t")
+			return enc.Encode(struct {
+				Blocks                 int                          `json:"blocks"`
+				TotalSeries            int64                        `json:"totalSeries"`
+				TotalChunkBytes        int64                        `json:"totalChunkBytes"`
+				TopMetrics             []block.MetricCardinality    `json:"topMetrics"`
+				TopMetricsByChunkBytes []block.MetricCardinality    `json:"topMetricsByChunkBytes"`
+				TopLabelNames          []block.LabelNameCardinality `json:"topLabelNames"`
+				TopLabelPairs          []block.LabelPairCardinality `json:"topLabelPairs"`
+			}{
+				Blocks:                 stats.Blocks,
+				TotalSeries:            stats.TotalSeries,
+				TotalChunkBytes:        stats.TotalChunkBytes,
+				TopMetrics:             stats.TopMetrics(*limit),
+				TopMetricsByChunkBytes: stats.TopMetricsByChunkBytes(*limit),
+				TopLabelNames:          stats.TopLabelNames(*limit),
+				TopLabelPairs:          stats.TopLabelPairs(*limit),
+			})
+		}
+		printCardinalityStats(stats, *limit)
+		return nil
+	})
+}
+
+// analyzeBlock downloads the index of the block and adds its cardinality statistics to the stats.
+func analyzeBlock(ctx context.Context, logger log.Logger, bkt objstore.Bucket, id ulid.ULID, tmpDir string, stats *block.CardinalityStats) error {
+	var segments []string
+	if err := bkt.Iter(ctx, path.Join(id.String(), block.ChunksDirname), func(name string) error {
+		segments = append(segments, name)
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "list chunk files")
+	}
+	sort.Strings(segments)
+
+	segmentSizes := make([]int64, 0, len(segments))
+	for _, name := range segments {
+		attrs, err := bkt.Attributes(ctx, name)
+		if err != nil {
+			return errors.Wrapf(err, "get attributes of %s", name)
+		}
+		segmentSizes = append(segmentSizes, attrs.Size)
+	}
+
+	fn := filepath.Join(tmpDir, id.String()+"-"+block.IndexFilename)
+	if err := objstore.DownloadFile(ctx, logger, bkt, path.Join(id.String(), block.IndexFilename), fn); err != nil {
+		return errors.Wrap(err, "download index")
+	}
+	defer func() {
+		if err := os.Remove(fn); err != nil {
+			level.Warn(logger).Log("msg", "failed to remove downloaded index", "block", id, "err", err)
+		}
+	}()
+	return stats.GatherIndex(fn, segmentSizes)
+}
+
+func printCardinalityStats(stats *block.CardinalityStats, limit int) {
+	p := message.NewPrinter(language.English)
+	fmt.Fprintln(os.Stdout, p.Sprintf("Blocks: %d, series: %d, chunk bytes: %d", stats.Blocks, stats.TotalSeries, stats.TotalChunkBytes))
+	fmt.Fprintln(os.Stdout, "")
+
+	percentage := func(part, total int64) string {
+		if total == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f%%", float64(part)*100/float64(total))
+	}
+
+	var lines [][]string
+	for _, m := range stats.TopMetrics(limit) {
+		lines = append(lines, []string{m.Name, p.Sprintf("%d", m.Series), percentage(m.Series, stats.TotalSeries), p.Sprintf("%d", m.ChunkBytes)})
+	}
+	printTitledTable("Top metrics by series", []string{"METRIC", "SERIES", "% OF SERIES", "CHUNK BYTES"}, lines)
+
+	lines = lines[:0]
+	for _, m := range stats.TopMetricsByChunkBytes(limit) {
+		lines = append(lines, []string{m.Name, p.Sprintf("%d", m.ChunkBytes), percentage(m.ChunkBytes, stats.TotalChunkBytes), p.Sprintf("%d", m.Series)})
+	}
+	printTitledTable("Top metrics by chunk bytes", []string{"METRIC", "CHUNK BYTES", "% OF CHUNK BYTES", "SERIES"}, lines)
+
+	lines = lines[:0]
+	for _, l := range stats.TopLabelNames(limit) {
+		lines = append(lines, []string{l.Name, p.Sprintf("%d", l.Values)})
+	}
+	printTitledTable("Top label names by values", []string{"LABEL NAME", "VALUES"}, lines)
+
+	lines = lines[:0]
+	for _, l := range stats.TopLabelPairs(limit) {
+		lines = append(lines, []string{l.Name, l.Value, p.Sprintf("%d", l.Series), percentage(l.Series, stats.TotalSeries)})
+	}
+	printTitledTable("Top label pairs by series", []string{"LABEL NAME", "LABEL VALUE", "SERIES", "% OF SERIES"}, lines)
+}
+
+func registerBucketDiff(app extkingpin.AppClause, objStoreConfig *extflag.PathOrContent) {
+	cmd := app.Command("diff", "Compare blocks of the bucket with blocks of another bucket, e.g. after 'tools bucket replicate', reporting blocks and objects missing or differing in the other bucket "+
+		"as well as blocks present only in the other bucket. Only chunks, index and meta files are compared, by their sizes and optionally by the hashes recorded in the meta files.\n\n"+
+		"Exits with an error if any block of the bucket is missing or differs in the other bucket, unless --replicate is given and all such blocks are replicated.")
+	toObjStoreConfig := extkingpin.RegisterCommonObjStoreFlags(cmd, "-to", false, "The object storage which blocks are compared with.")
+	selection := (&blockSelectionConfig{}).registerFlag(cmd)
+	verifyHashes := cmd.Flag("verify-hashes", "Download objects of the other bucket which have a hash in the meta file (see --hash-func of uploading components) and verify their hashes.").
+		Default("false").Bool()
+	replicateDiff := cmd.Flag("replicate", "Copy objects missing or differing in the other bucket from the bucket. Meta files are copied last.").Default("false").Bool()
+	output := cmd.Flag("output", "Format in which to print the differences. Options are 'table' or 'json'.").
+		Short('o').Default("table").Enum("table", "json")
+	timeout := cmd.Flag("timeout", "Timeout to compare and replicate the blocks").Default("1h").Duration()
+	cmd.Setup(func(g *run.Group, logger log.Logger, reg *prometheus.Registry, _ opentracing.Tracer, _ <-chan struct{}, _ bool) error {
+		filters, err := selection.filters()
+		if err != nil {
+			return err
+		}
+
+		confContentYaml, err := objStoreConfig.Content()
+		if err != nil {
+			return err
+		}
+		fromBkt, err := client.NewBucket(logger, confContentYaml, prometheus.WrapRegistererWith(prometheus.Labels{"bucket": "from"}, reg), component.Bucket.String())
+		if err != nil {
+			return err
+		}
+		defer runutil.CloseWithLogOnErr(logger, fromBkt, "bucket client")
+
+		toConfContentYaml, err := toObjStoreConfig.Content()
+		if err != nil {
+			return err
+		}
+		if len(toConfContentYaml) == 0 {
+			return errors.New("no bucket was configured to compare with")
+		}
+		toBkt, err := client.NewBucket(logger, toConfContentYaml, prometheus.WrapRegistererWith(prometheus.Labels{"bucket": "to"}, reg), component.Bucket.String())
+		if err != nil {
+			return err
+		}
+		defer runutil.CloseWithLogOnErr(logger, toBkt, "target bucket client")
+
+		// Dummy actor to immediately kill the group after the run function returns.
+		g.Add(func() error { return nil }, func(error) {})
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+
+		fetchMetas := func(bkt objstore.InstrumentedBucket, name string) (map[ulid.ULID]*metadata.Meta, error) {
+			fetcher, err := block.NewMetaFetcher(logger, block.FetcherConcurrency, bkt, "",
+				extprom.WrapRegistererWithPrefix(extpromPrefix, prometheus.WrapRegistererWith(prometheus.Labels{"bucket": name}, reg)),
+				append(filters, block.NewIgnoreDeletionMarkFilter(logger, bkt, 0, block.FetcherConcurrency)), nil)
+			if err != nil {
+				return nil, err
+			}
+			metas, _, err := fetcher.Fetch(ctx)
+			return metas, errors.Wrapf(err, "fetch metas from %s bucket", name)
+		}
+		fromMetas, err := fetchMetas(fromBkt, "from")
+		if err != nil {
+			return err
+		}
+		toMetas, err := fetchMetas(toBkt, "to")
+		if err != nil {
+			return err
+		}
+
+		diffs, err := replicate.DiffBuckets(ctx, logger, fromBkt, toBkt, fromMetas, toMetas, *verifyHashes)
+		if err != nil {
+			return err
+		}
+
+		if *output == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "\t")
+			if err := enc.Encode(diffs); err != nil {
+				return err
+			}
+		} else {
+			printBucketDiffs(diffs, len(fromMetas), len(toMetas))
+		}
+
+		incomplete := 0
+		for _, d := range diffs {
+			if d.MissingInOrigin {
+				continue
+			}
+			if !*replicateDiff {
+				incomplete++
+				continue
+			}
+			level.Info(logger).Log("msg", "replicating block", "block", d.ID)
+			if err := replicate.ReplicateDiff(ctx, logger, fromBkt, toBkt, d); err != nil {
+				return errors.Wrapf(err, "replicate block %v", d.ID)
+			}
+		}
+		if incomplete > 0 {
+			return errors.Errorf("%d blocks are missing or differ in the target bucket", incomplete)
+		}
+		return nil
+	})
+}
+
+func printBucketDiffs(diffs []replicate.BlockDiff, fromBlocks, toBlocks int) {
+	var lines [][]string
+	for _, d := range diffs {
+		switch {
+		case d.MissingInOrigin:
+			lines = append(lines, []string{d.ID.String(), "only in target bucket", ""})
+		case d.MissingInTarget:
+			lines = append(lines, []string{d.ID.String(), "missing in target bucket", ""})
+		default:
+			for _, f := range d.Files {
+				lines = append(lines, []string{d.ID.String(), string(f.Reason), f.Name})
+			}
+		}
+	}
+	fmt.Fprintf(os.Stdout, "Blocks in bucket: %d, blocks in target bucket: %d\n\n", fromBlocks, toBlocks)
+	printTitledTable("Differences", []string{"BLOCK", "DIFFERENCE", "OBJECT"}, lines)
+}
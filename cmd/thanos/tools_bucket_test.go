@@ -0,0 +1,179 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/component"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/parquet"
+	"github.com/thanos-io/thanos/pkg/store"
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+	"github.com/thanos-io/thanos/pkg/testutil"
+	"github.com/thanos-io/thanos/pkg/testutil/e2eutil"
+)
+
+func TestSelectBlocksToRewrite(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	bkt := objstore.WithNoopInstr(objstore.NewInMemBucket())
+
+	// Block 5 is downsampled and block 6 is marked for no compaction, so they are never rewritten.
+	ids := make([]ulid.ULID, 7)
+	for i := range ids {
+		ids[i] = ulid.MustNew(uint64(i+1), nil)
+		cluster := "eu1"
+		if i == 4 {
+			cluster = "us1"
+		}
+		var resolution int64
+		if i == 5 {
+			resolution = 300000
+		}
+		var buf bytes.Buffer
+		testutil.Ok(t, json.NewEncoder(&buf).Encode(&metadata.Meta{
+			BlockMeta: tsdb.BlockMeta{ULID: ids[i], MinTime: 0, MaxTime: 100, Version: 1},
+			Thanos: metadata.Thanos{
+				Version:    1,
+				Labels:     map[string]string{"cluster": cluster},
+				Downsample: metadata.ThanosDownsample{Resolution: resolution},
+			},
+		}))
+		testutil.Ok(t, bkt.Upload(ctx, path.Join(ids[i].String(), block.MetaFilename), &buf))
+	}
+	testutil.Ok(t, block.MarkForNoCompact(ctx, logger, bkt, ids[6], metadata.ManualNoCompactReason, "", promauto.With(nil).NewCounter(prometheus.CounterOpts{})))
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "cluster", "eu1")}
+	filters := []block.MetadataFilter{block.NewLabelMatchersMetaFilter(matchers)}
+
+	toRewrite, done, err := selectBlocksToRewrite(ctx, logger, bkt, nil, nil, filters, "hash")
+	testutil.Ok(t, err)
+	testutil.Equals(t, ids[:4], toRewrite)
+	testutil.Equals(t, 0, len(done))
+
+	// Block 0 was rewritten into block 1 and marked for deletion, block 2 was rewritten with a different configuration.
+	testutil.Ok(t, block.MarkRewritten(ctx, logger, bkt, ids[0], ids[1], "hash"))
+	testutil.Ok(t, block.MarkForDeletion(ctx, logger, bkt, ids[0], "", promauto.With(nil).NewCounter(prometheus.CounterOpts{})))
+	testutil.Ok(t, block.MarkRewritten(ctx, logger, bkt, ids[2], ids[3], "other-hash"))
+
+	toRewrite, done, err = selectBlocksToRewrite(ctx, logger, bkt, nil, nil, filters, "hash")
+	testutil.Ok(t, err)
+	testutil.Equals(t, ids[2:4], toRewrite)
+	testutil.Equals(t, []ulid.ULID{ids[1]}, done)
+
+	// Explicitly given blocks are checked for rewritten marks too.
+	toRewrite, done, err = selectBlocksToRewrite(ctx, logger, bkt, nil, []ulid.ULID{ids[0], ids[4], ids[5], ids[6]}, filters, "hash")
+	testutil.Ok(t, err)
+	testutil.Equals(t, []ulid.ULID{ids[4]}, toRewrite)
+	testutil.Equals(t, []ulid.ULID{ids[0]}, done)
+}
+
+func TestCompactedInto(t *testing.T) {
+	ids := make([]ulid.ULID, 8)
+	for i := range ids {
+		ids[i] = ulid.MustNew(uint64(i+1), nil)
+	}
+	meta := func(id ulid.ULID, sources ...ulid.ULID) *metadata.Meta {
+		return &metadata.Meta{BlockMeta: tsdb.BlockMeta{ULID: id, Compaction: tsdb.BlockMetaCompaction{Sources: sources}}}
+	}
+	downsampled := func(m *metadata.Meta) *metadata.Meta {
+		m.Thanos.Downsample.Resolution = 300000
+		return m
+	}
+
+	// Blocks 0 and 1 were compacted into block 2, which was compacted with block 3 into the live block 4.
+	// Block 6 was downsampled into the live block 7.
+	compacted := compactedSources(map[ulid.ULID]*metadata.Meta{
+		ids[4]: meta(ids[4], ids[0], ids[1], ids[3]),
+		ids[5]: meta(ids[5], ids[5]),
+		ids[7]: downsampled(meta(ids[7], ids[6])),
+	})
+	for _, tcase := range []struct {
+		meta *metadata.Meta
+		into ulid.ULID
+		ok   bool
+	}{
+		{meta: meta(ids[0], ids[0]), into: ids[4], ok: true},
+		{meta: meta(ids[2], ids[0], ids[1]), into: ids[4], ok: true},
+		{meta: meta(ids[5], ids[5])},
+		{meta: meta(ids[6], ids[6])},
+		{meta: downsampled(meta(ids[2], ids[0], ids[1]))},
+		{meta: downsampled(meta(ulid.MustNew(100, nil), ids[6])), into: ids[7], ok: true},
+		{meta: meta(ulid.MustNew(100, nil), ulid.MustNew(100, nil))},
+	} {
+		into, ok := compactedInto(tcase.meta, compacted)
+		testutil.Equals(t, tcase.ok, ok)
+		testutil.Equals(t, tcase.into, into)
+	}
+}
+
+func TestWriteExport(t *testing.T) {
+	db, err := e2eutil.NewTSDB()
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, db.Close()) }()
+
+	app := db.Appender(context.Background())
+	for i := int64(0); i < 5; i++ {
+		_, err := app.Add(labels.FromStrings(labels.MetricName, "up", "job", "a\"b\\c\nd"), i*1000, float64(i))
+		testutil.Ok(t, err)
+		_, err = app.Add(labels.FromStrings(labels.MetricName, "other"), i*1000, float64(i))
+		testutil.Ok(t, err)
+	}
+	testutil.Ok(t, app.Commit())
+
+	storeClient := storepb.ServerAsClient(store.NewTSDBStore(nil, db, component.Rule, labels.FromStrings("cluster", "eu1")), 0)
+	matchers := []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: labels.MetricName, Value: "up"}}
+	var buf bytes.Buffer
+	series, samples, err := writeExport(context.Background(), storeClient, newOpenMetricsWriter(&buf), 1000, 2500, matchers)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1, series)
+	testutil.Equals(t, 2, samples)
+	testutil.Equals(t, `up{cluster="eu1",job="a\"b\\c\nd"} 1 1
+up{cluster="eu1",job="a\"b\\c\nd"} 2 2
+# EOF
+`, buf.String())
+
+	buf.Reset()
+	series, samples, err = writeExport(context.Background(), storeClient, &parquetExportWriter{w: parquet.NewWriter(&buf, 0)}, 1000, 2500, matchers)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1, series)
+	testutil.Equals(t, 2, samples)
+	testutil.Assert(t, bytes.HasPrefix(buf.Bytes(), []byte("PAR1")) && bytes.HasSuffix(buf.Bytes(), []byte("PAR1")), "not a Parquet file")
+}
+
+func TestSeriesIterator(t *testing.T) {
+	chunk := func(samples ...int64) storepb.AggrChunk {
+		c := chunkenc.NewXORChunk()
+		app, err := c.Appender()
+		testutil.Ok(t, err)
+		for _, s := range samples {
+			app.Append(s, float64(s))
+		}
+		return storepb.AggrChunk{MinTime: samples[0], MaxTime: samples[len(samples)-1], Raw: &storepb.Chunk{Type: storepb.Chunk_XOR, Data: c.Bytes()}}
+	}
+
+	// Chunks overlap, e.g. from replicated blocks, and sample 3 is in both of them.
+	it, err := seriesIterator(&storepb.Series{Chunks: []storepb.AggrChunk{chunk(1, 3, 5), chunk(2, 3, 4, 6)}})
+	testutil.Ok(t, err)
+	var got []int64
+	for it.Next() {
+		ts, _ := it.At()
+		got = append(got, ts)
+	}
+	testutil.Ok(t, it.Err())
+	testutil.Equals(t, []int64{1, 2, 3, 4, 5, 6}, got)
+}
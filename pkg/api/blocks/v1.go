@@ -4,14 +4,19 @@
 package v1
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
 	"github.com/prometheus/common/route"
+	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/thanos-io/thanos/pkg/api"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact"
 	extpromhttp "github.com/thanos-io/thanos/pkg/extprom/http"
 	"github.com/thanos-io/thanos/pkg/logging"
 )
@@ -22,6 +27,8 @@ type BlocksAPI struct {
 	logger           log.Logger
 	globalBlocksInfo *BlocksInfo
 	loadedBlocksInfo *BlocksInfo
+	progress         *compact.Progress
+	deletionRequests *compact.DeletionRequests
 	disableCORS      bool
 }
 
@@ -55,6 +62,13 @@ func (bapi *BlocksAPI) Register(r *route.Router, tracer opentracing.Tracer, logg
 	instr := api.GetInstr(tracer, logger, ins, logMiddleware, bapi.disableCORS)
 
 	r.Get("/blocks", instr("blocks", bapi.blocks))
+	if bapi.progress != nil {
+		r.Get("/progress", instr("progress", bapi.compactionProgress))
+	}
+	if bapi.deletionRequests != nil {
+		r.Get("/deletion_requests", instr("deletion_requests", bapi.listDeletionRequests))
+		r.Post("/deletion_requests", instr("add_deletion_requests", bapi.addDeletionRequests))
+	}
 }
 
 func (bapi *BlocksAPI) blocks(r *http.Request) (interface{}, []error, *api.ApiError) {
@@ -65,6 +79,79 @@ func (bapi *BlocksAPI) blocks(r *http.Request) (interface{}, []error, *api.ApiEr
 	return bapi.globalBlocksInfo, nil, nil
 }
 
+func (bapi *BlocksAPI) compactionProgress(_ *http.Request) (interface{}, []error, *api.ApiError) {
+	return bapi.progress.Status(), nil, nil
+}
+
+func (bapi *BlocksAPI) listDeletionRequests(r *http.Request) (interface{}, []error, *api.ApiError) {
+	reqs, err := bapi.deletionRequests.List(r.Context())
+	if err != nil {
+		return nil, nil, &api.ApiError{Typ: api.ErrorInternal, Err: err}
+	}
+	if reqs == nil {
+		reqs = []*compact.DeletionRequest{}
+	}
+	return reqs, nil, nil
+}
+
+// addDeletionRequests creates a deletion request for each of the given series selectors, in the same form as
+// Prometheus delete series API.
+func (bapi *BlocksAPI) addDeletionRequests(r *http.Request) (interface{}, []error, *api.ApiError) {
+	if err := r.ParseForm(); err != nil {
+		return nil, nil, &api.ApiError{Typ: api.ErrorBadData, Err: errors.Wrap(err, "parse form")}
+	}
+	if len(r.Form["match[]"]) == 0 {
+		return nil, nil, &api.ApiError{Typ: api.ErrorBadData, Err: errors.New("no match[] parameter provided")}
+	}
+	start, err := parseTimeParam(r, "start", time.Unix(0, 0))
+	if err != nil {
+		return nil, nil, &api.ApiError{Typ: api.ErrorBadData, Err: err}
+	}
+	end, err := parseTimeParam(r, "end", time.Now())
+	if err != nil {
+		return nil, nil, &api.ApiError{Typ: api.ErrorBadData, Err: err}
+	}
+
+	if end.Before(start) {
+		return nil, nil, &api.ApiError{Typ: api.ErrorBadData, Err: errors.New("end timestamp must not be before start time")}
+	}
+	for _, s := range r.Form["match[]"] {
+		if _, err := parser.ParseMetricSelector(s); err != nil {
+			return nil, nil, &api.ApiError{Typ: api.ErrorBadData, Err: err}
+		}
+	}
+
+	var reqs []*compact.DeletionRequest
+	for _, s := range r.Form["match[]"] {
+		req, err := bapi.deletionRequests.Add(r.Context(), s, timestamp(start), timestamp(end))
+		if err != nil {
+			return nil, nil, &api.ApiError{Typ: api.ErrorInternal, Err: err}
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil, nil
+}
+
+func parseTimeParam(r *http.Request, paramName string, defaultValue time.Time) (time.Time, error) {
+	val := r.FormValue(paramName)
+	if val == "" {
+		return defaultValue, nil
+	}
+	if t, err := strconv.ParseFloat(val, 64); err == nil {
+		s, ns := math.Modf(t)
+		ns = math.Round(ns*1000) / 1000
+		return time.Unix(int64(s), int64(ns*float64(time.Second))), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, val); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.Errorf("invalid time value for '%s': cannot parse %q to a valid timestamp", paramName, val)
+}
+
+func timestamp(t time.Time) int64 {
+	return t.Unix()*1000 + int64(t.Nanosecond())/int64(time.Millisecond)
+}
+
 func (b *BlocksInfo) set(blocks []metadata.Meta, err error) {
 	if err != nil {
 		// Last view is maintained.
@@ -87,3 +174,13 @@ func (bapi *BlocksAPI) SetGlobal(blocks []metadata.Meta, err error) {
 func (bapi *BlocksAPI) SetLoaded(blocks []metadata.Meta, err error) {
 	bapi.loadedBlocksInfo.set(blocks, err)
 }
+
+// SetProgress sets the compactor progress exposed by the API. It has to be called before Register.
+func (bapi *BlocksAPI) SetProgress(progress *compact.Progress) {
+	bapi.progress = progress
+}
+
+// SetDeletionRequests sets the series deletion requests managed by the API. It has to be called before Register.
+func (bapi *BlocksAPI) SetDeletionRequests(deletionRequests *compact.DeletionRequests) {
+	bapi.deletionRequests = deletionRequests
+}
@@ -51,6 +51,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/runutil"
 	"github.com/thanos-io/thanos/pkg/store/storepb"
 	"github.com/thanos-io/thanos/pkg/tracing"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus/tsdbstatuspb"
 )
 
 const (
@@ -73,8 +74,10 @@ type QueryAPI struct {
 	queryEngine func(int64) *promql.Engine
 	ruleGroups  rules.UnaryClient
 	metadatas   metadata.UnaryClient
+	tsdbStatus  tsdbstatuspb.TSDBStatusServer
 
 	enableAutodownsampling              bool
+	enableQueryPushdown                 bool
 	enableQueryPartialResponse          bool
 	enableRulePartialResponse           bool
 	enableMetricMetadataPartialResponse bool
@@ -96,7 +99,9 @@ func NewQueryAPI(
 	c query.QueryableCreator,
 	ruleGroups rules.UnaryClient,
 	metadatas metadata.UnaryClient,
+	tsdbStatus tsdbstatuspb.TSDBStatusServer,
 	enableAutodownsampling bool,
+	enableQueryPushdown bool,
 	enableQueryPartialResponse bool,
 	enableRulePartialResponse bool,
 	enableMetricMetadataPartialResponse bool,
@@ -116,8 +121,10 @@ func NewQueryAPI(
 		gate:            gate,
 		ruleGroups:      ruleGroups,
 		metadatas:       metadatas,
+		tsdbStatus:      tsdbStatus,
 
 		enableAutodownsampling:                 enableAutodownsampling,
+		enableQueryPushdown:                    enableQueryPushdown,
 		enableQueryPartialResponse:             enableQueryPartialResponse,
 		enableRulePartialResponse:              enableRulePartialResponse,
 		enableMetricMetadataPartialResponse:    enableMetricMetadataPartialResponse,
@@ -155,6 +162,8 @@ func (qapi *QueryAPI) Register(r *route.Router, tracer opentracing.Tracer, logge
 	r.Get("/rules", instr("rules", NewRulesHandler(qapi.ruleGroups, qapi.enableRulePartialResponse)))
 
 	r.Get("/metadata", instr("metadata", NewMetricMetadataHandler(qapi.metadatas, qapi.enableMetricMetadataPartialResponse)))
+
+	r.Get("/status/tsdb", instr("tsdb_status", qapi.tsdbStatusHandler))
 }
 
 type queryData struct {
@@ -178,6 +187,27 @@ func (qapi *QueryAPI) parseEnableDedupParam(r *http.Request) (enableDeduplicatio
 	return enableDeduplication, nil
 }
 
+// queryPushdown returns true if functions of the given query can be pushed down to stores. Stores reduce
+// samples for windows aligned to the query step, which doesn't hold for windows of subqueries.
+func (qapi *QueryAPI) queryPushdown(query string) bool {
+	if !qapi.enableQueryPushdown {
+		return false
+	}
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		// The engine reports the error.
+		return false
+	}
+	pushdown := true
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		if _, ok := node.(*parser.SubqueryExpr); ok {
+			pushdown = false
+		}
+		return nil
+	})
+	return pushdown
+}
+
 func (qapi *QueryAPI) parseReplicaLabelsParam(r *http.Request) (replicaLabels []string, _ *api.ApiError) {
 	if err := r.ParseForm(); err != nil {
 		return nil, &api.ApiError{Typ: api.ErrorInternal, Err: errors.Wrap(err, "parse form")}
@@ -307,7 +337,7 @@ func (qapi *QueryAPI) query(r *http.Request) (interface{}, []error, *api.ApiErro
 	span, ctx := tracing.StartSpan(ctx, "promql_instant_query")
 	defer span.Finish()
 
-	qry, err := qe.NewInstantQuery(qapi.queryableCreate(enableDedup, replicaLabels, storeDebugMatchers, maxSourceResolution, enablePartialResponse, false), r.FormValue("query"), ts)
+	qry, err := qe.NewInstantQuery(qapi.queryableCreate(enableDedup, replicaLabels, storeDebugMatchers, maxSourceResolution, enablePartialResponse, false, qapi.queryPushdown(r.FormValue("query"))), r.FormValue("query"), ts)
 	if err != nil {
 		return nil, nil, &api.ApiError{Typ: api.ErrorBadData, Err: err}
 	}
@@ -415,7 +445,7 @@ func (qapi *QueryAPI) queryRange(r *http.Request) (interface{}, []error, *api.Ap
 	defer span.Finish()
 
 	qry, err := qe.NewRangeQuery(
-		qapi.queryableCreate(enableDedup, replicaLabels, storeDebugMatchers, maxSourceResolution, enablePartialResponse, false),
+		qapi.queryableCreate(enableDedup, replicaLabels, storeDebugMatchers, maxSourceResolution, enablePartialResponse, false, qapi.queryPushdown(r.FormValue("query"))),
 		r.FormValue("query"),
 		start,
 		end,
@@ -482,7 +512,7 @@ func (qapi *QueryAPI) labelValues(r *http.Request) (interface{}, []error, *api.A
 		matcherSets = append(matcherSets, matchers)
 	}
 
-	q, err := qapi.queryableCreate(true, nil, storeDebugMatchers, 0, enablePartialResponse, true).
+	q, err := qapi.queryableCreate(true, nil, storeDebugMatchers, 0, enablePartialResponse, true, false).
 		Querier(ctx, timestamp.FromTime(start), timestamp.FromTime(end))
 	if err != nil {
 		return nil, nil, &api.ApiError{Typ: api.ErrorExec, Err: err}
@@ -560,7 +590,7 @@ func (qapi *QueryAPI) series(r *http.Request) (interface{}, []error, *api.ApiErr
 		return nil, nil, apiErr
 	}
 
-	q, err := qapi.queryableCreate(enableDedup, replicaLabels, storeDebugMatchers, math.MaxInt64, enablePartialResponse, true).
+	q, err := qapi.queryableCreate(enableDedup, replicaLabels, storeDebugMatchers, math.MaxInt64, enablePartialResponse, true, false).
 		Querier(r.Context(), timestamp.FromTime(start), timestamp.FromTime(end))
 	if err != nil {
 		return nil, nil, &api.ApiError{Typ: api.ErrorExec, Err: err}
@@ -610,7 +640,7 @@ func (qapi *QueryAPI) labelNames(r *http.Request) (interface{}, []error, *api.Ap
 		matcherSets = append(matcherSets, matchers)
 	}
 
-	q, err := qapi.queryableCreate(true, nil, storeDebugMatchers, 0, enablePartialResponse, true).
+	q, err := qapi.queryableCreate(true, nil, storeDebugMatchers, 0, enablePartialResponse, true, false).
 		Querier(r.Context(), timestamp.FromTime(start), timestamp.FromTime(end))
 	if err != nil {
 		return nil, nil, &api.ApiError{Typ: api.ErrorExec, Err: err}
@@ -805,6 +835,46 @@ func labelValuesByMatchers(sets []storage.SeriesSet, name string) ([]string, sto
 	return labelValues, warnings, nil
 }
 
+// tsdbStatusHandler returns cardinality statistics merged from all stores, similar to Prometheus /api/v1/status/tsdb endpoint.
+func (qapi *QueryAPI) tsdbStatusHandler(r *http.Request) (interface{}, []error, *api.ApiError) {
+	start, end, err := parseMetadataTimeRange(r, qapi.defaultMetadataTimeRange)
+	if err != nil {
+		return nil, nil, &api.ApiError{Typ: api.ErrorBadData, Err: err}
+	}
+
+	enablePartialResponse, apiErr := qapi.parsePartialResponseParam(r, qapi.enableQueryPartialResponse)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	req := &tsdbstatuspb.TSDBStatusRequest{
+		MinTime:                 timestamp.FromTime(start),
+		MaxTime:                 timestamp.FromTime(end),
+		PartialResponseStrategy: storepb.PartialResponseStrategy_ABORT,
+	}
+	if enablePartialResponse {
+		req.PartialResponseStrategy = storepb.PartialResponseStrategy_WARN
+	}
+
+	if limitStr := r.FormValue("limit"); limitStr != "" {
+		limit, err := strconv.ParseInt(limitStr, 10, 32)
+		if err != nil || limit < 0 {
+			return nil, nil, &api.ApiError{Typ: api.ErrorBadData, Err: errors.Errorf("invalid tsdb status limit='%v'", limitStr)}
+		}
+		req.Limit = int32(limit)
+	}
+
+	resp, err := qapi.tsdbStatus.TSDBStatus(r.Context(), req)
+	if err != nil {
+		return nil, nil, &api.ApiError{Typ: api.ErrorInternal, Err: errors.Wrap(err, "retrieving tsdb status")}
+	}
+
+	var warnings []error
+	for _, w := range resp.Warnings {
+		warnings = append(warnings, errors.New(w))
+	}
+	return resp.Statistics, warnings, nil
+}
+
 func NewMetricMetadataHandler(client metadata.UnaryClient, enablePartialResponse bool) func(*http.Request) (interface{}, []error, *api.ApiError) {
 	ps := storepb.PartialResponseStrategy_ABORT
 	if enablePartialResponse {
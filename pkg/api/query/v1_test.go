@@ -1390,6 +1390,26 @@ func TestParseStoreDebugMatchersParam(t *testing.T) {
 	}
 }
 
+func TestQueryPushdown(t *testing.T) {
+	for _, tc := range []struct {
+		query    string
+		enabled  bool
+		expected bool
+	}{
+		{query: `max_over_time(up[5m])`, enabled: false, expected: false},
+		{query: `max_over_time(up[5m])`, enabled: true, expected: true},
+		{query: `sum by (job) (count_over_time(up[5m]))`, enabled: true, expected: true},
+		{query: `max_over_time(up[5m:1m])`, enabled: true, expected: false},
+		{query: `max_over_time(rate(up[5m])[1h:])`, enabled: true, expected: false},
+		{query: `max_over_time(up[5m]`, enabled: true, expected: false},
+	} {
+		t.Run(fmt.Sprintf("%s/%v", tc.query, tc.enabled), func(t *testing.T) {
+			api := QueryAPI{enableQueryPushdown: tc.enabled}
+			testutil.Equals(t, tc.expected, api.queryPushdown(tc.query))
+		})
+	}
+}
+
 func TestRulesHandler(t *testing.T) {
 	twoHAgo := time.Now().Add(-2 * time.Hour)
 	all := []*rulespb.Rule{
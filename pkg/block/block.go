@@ -38,6 +38,8 @@ const (
 	IndexHeaderFilename = "index-header"
 	// ChunksDirname is the known dir name for chunks with compressed samples.
 	ChunksDirname = "chunks"
+	// BloomFilterFilename is the optional file with bloom filters of the label pairs of the block index.
+	BloomFilterFilename = "bloom-filter"
 
 	// DebugMetas is a directory for debug meta files that happen in the past. Useful for debugging.
 	DebugMetas = "debug/metas"
@@ -145,6 +147,12 @@ func Upload(ctx context.Context, logger log.Logger, bkt objstore.Bucket, bdir st
 		return cleanUp(logger, bkt, id, errors.Wrap(err, "upload index"))
 	}
 
+	if _, err := os.Stat(path.Join(bdir, BloomFilterFilename)); err == nil {
+		if err := objstore.UploadFile(ctx, logger, bkt, path.Join(bdir, BloomFilterFilename), path.Join(id.String(), BloomFilterFilename)); err != nil {
+			return cleanUp(logger, bkt, id, errors.Wrap(err, "upload bloom filter"))
+		}
+	}
+
 	// Meta.json always need to be uploaded as a last item. This will allow to assume block directories without meta file to be pending uploads.
 	if err := bkt.Upload(ctx, path.Join(id.String(), MetaFilename), strings.NewReader(metaEncoded.String())); err != nil {
 		// Don't call cleanUp here. Despite getting error, meta.json may have been uploaded in certain cases,
@@ -346,6 +354,25 @@ func gatherFileStats(blockDir string, hf metadata.HashFunc, logger log.Logger) (
 	}
 	res = append(res, mf)
 
+	bloomFile, err := os.Stat(filepath.Join(blockDir, BloomFilterFilename))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "stat %v", filepath.Join(blockDir, BloomFilterFilename))
+	}
+	if err == nil {
+		mf := metadata.File{
+			RelPath:   bloomFile.Name(),
+			SizeBytes: bloomFile.Size(),
+		}
+		if hf != metadata.NoneFunc {
+			h, err := metadata.CalculateHash(filepath.Join(blockDir, BloomFilterFilename), hf, logger)
+			if err != nil {
+				return nil, errors.Wrapf(err, "calculate hash %v", bloomFile.Name())
+			}
+			mf.Hash = &h
+		}
+		res = append(res, mf)
+	}
+
 	metaFile, err := os.Stat(filepath.Join(blockDir, MetaFilename))
 	if err != nil {
 		return nil, errors.Wrapf(err, "stat %v", filepath.Join(blockDir, MetaFilename))
@@ -390,3 +417,105 @@ func MarkForNoCompact(ctx context.Context, logger log.Logger, bkt objstore.Bucke
 	level.Info(logger).Log("msg", "block has been marked for no compaction", "block", id)
 	return nil
 }
+
+// MarkRewritten creates a file which marks block as rewritten into the given new block.
+func MarkRewritten(ctx context.Context, logger log.Logger, bkt objstore.Bucket, id, newID ulid.ULID, rewriteHash string) error {
+	m := path.Join(id.String(), metadata.RewrittenMarkFilename)
+	rewrittenMark, err := json.Marshal(metadata.RewrittenMark{
+		ID:      id,
+		Version: metadata.RewrittenMarkVersion1,
+
+		NewID:       newID,
+		RewriteHash: rewriteHash,
+		RewriteTime: time.Now().Unix(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "json encode rewritten mark")
+	}
+
+	if err := bkt.Upload(ctx, m, bytes.NewBuffer(rewrittenMark)); err != nil {
+		return errors.Wrapf(err, "upload file %s to bucket", m)
+	}
+	level.Info(logger).Log("msg", "block has been marked as rewritten", "block", id, "new", newID)
+	return nil
+}
+
+// MarkReplicated uploads a file that marks the block as copied from another bucket by bucket replicate.
+func MarkReplicated(ctx context.Context, logger log.Logger, bkt objstore.Bucket, id ulid.ULID) error {
+	m := path.Join(id.String(), metadata.ReplicatedMarkFilename)
+	replicatedMark, err := json.Marshal(metadata.ReplicatedMark{
+		ID:            id,
+		Version:       metadata.ReplicatedMarkVersion1,
+		ReplicateTime: time.Now().Unix(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "json encode replicated mark")
+	}
+
+	if err := bkt.Upload(ctx, m, bytes.NewBuffer(replicatedMark)); err != nil {
+		return errors.Wrapf(err, "upload file %s to bucket", m)
+	}
+	level.Debug(logger).Log("msg", "block has been marked as replicated", "block", id)
+	return nil
+}
+
+// RemoveMark deletes the given marker file of the block, e.g. to undo MarkForDeletion or MarkForNoCompact.
+// Deletion mark is removed only if the block meta file exists, as otherwise the block might be already partially deleted.
+func RemoveMark(ctx context.Context, logger log.Logger, bkt objstore.Bucket, id ulid.ULID, markerFilename string) error {
+	m := path.Join(id.String(), markerFilename)
+	markExists, err := bkt.Exists(ctx, m)
+	if err != nil {
+		return errors.Wrapf(err, "check exists %s in bucket", m)
+	}
+	if !markExists {
+		level.Warn(logger).Log("msg", "requested to remove mark, but file does not exist", "block", id, "marker", markerFilename)
+		return nil
+	}
+
+	if markerFilename == metadata.DeletionMarkFilename {
+		metaFile := path.Join(id.String(), MetaFilename)
+		metaExists, err := bkt.Exists(ctx, metaFile)
+		if err != nil {
+			return errors.Wrapf(err, "check exists %s in bucket", metaFile)
+		}
+		if !metaExists {
+			return errors.Errorf("block %s has no %s, it might be partially deleted already", id, MetaFilename)
+		}
+	}
+
+	if err := bkt.Delete(ctx, m); err != nil {
+		return errors.Wrapf(err, "delete file %s from bucket", m)
+	}
+	level.Info(logger).Log("msg", "mark has been removed from block", "block", id, "marker", markerFilename)
+	return nil
+}
+
+// VerifyFilesInBucket checks that files of the block listed in its meta file are present in the bucket with the recorded sizes.
+// If the meta file does not list files, only presence of the index and segment files is checked.
+func VerifyFilesInBucket(ctx context.Context, bkt objstore.BucketReader, meta *metadata.Meta) error {
+	files := meta.Thanos.Files
+	if len(files) == 0 {
+		files = append(files, metadata.File{RelPath: IndexFilename})
+		for _, s := range meta.Thanos.SegmentFiles {
+			files = append(files, metadata.File{RelPath: path.Join(ChunksDirname, s)})
+		}
+	}
+
+	for _, f := range files {
+		if f.RelPath == MetaFilename {
+			continue
+		}
+		name := path.Join(meta.ULID.String(), f.RelPath)
+		attrs, err := bkt.Attributes(ctx, name)
+		if bkt.IsObjNotFoundErr(err) {
+			return errors.Errorf("file %s is missing", name)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "get attributes of %s", name)
+		}
+		if f.SizeBytes > 0 && attrs.Size != f.SizeBytes {
+			return errors.Errorf("file %s has size %d, expected %d", name, attrs.Size, f.SizeBytes)
+		}
+	}
+	return nil
+}
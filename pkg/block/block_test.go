@@ -373,6 +373,77 @@ func TestMarkForNoCompact(t *testing.T) {
 	}
 }
 
+func TestRemoveMark(t *testing.T) {
+	defer testutil.TolerantVerifyLeak(t)
+	ctx := context.Background()
+
+	tmpDir, err := ioutil.TempDir("", "test-block-remove-mark")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(tmpDir)) }()
+
+	bkt := objstore.NewInMemBucket()
+	id, err := e2eutil.CreateBlock(ctx, tmpDir, []labels.Labels{
+		{{Name: "a", Value: "1"}},
+		{{Name: "b", Value: "1"}},
+	}, 100, 0, 1000, labels.Labels{{Name: "ext1", Value: "val1"}}, 124, metadata.NoneFunc)
+	testutil.Ok(t, err)
+	testutil.Ok(t, Upload(ctx, log.NewNopLogger(), bkt, path.Join(tmpDir, id.String()), metadata.NoneFunc))
+
+	c := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+	testutil.Ok(t, MarkForDeletion(ctx, log.NewNopLogger(), bkt, id, "", c))
+	testutil.Ok(t, MarkForNoCompact(ctx, log.NewNopLogger(), bkt, id, metadata.ManualNoCompactReason, "", c))
+
+	testutil.Ok(t, RemoveMark(ctx, log.NewNopLogger(), bkt, id, metadata.NoCompactMarkFilename))
+	exists, err := bkt.Exists(ctx, path.Join(id.String(), metadata.NoCompactMarkFilename))
+	testutil.Ok(t, err)
+	testutil.Assert(t, !exists, "no-compact mark should be removed")
+
+	// Removing missing mark is a noop.
+	testutil.Ok(t, RemoveMark(ctx, log.NewNopLogger(), bkt, id, metadata.NoCompactMarkFilename))
+
+	// Deletion mark of a block without meta file is not removed.
+	metaFile, ok := bkt.Objects()[path.Join(id.String(), MetaFilename)]
+	testutil.Assert(t, ok, "meta file should exist")
+	testutil.Ok(t, bkt.Delete(ctx, path.Join(id.String(), MetaFilename)))
+	testutil.NotOk(t, RemoveMark(ctx, log.NewNopLogger(), bkt, id, metadata.DeletionMarkFilename))
+
+	testutil.Ok(t, bkt.Upload(ctx, path.Join(id.String(), MetaFilename), bytes.NewReader(metaFile)))
+	testutil.Ok(t, RemoveMark(ctx, log.NewNopLogger(), bkt, id, metadata.DeletionMarkFilename))
+	exists, err = bkt.Exists(ctx, path.Join(id.String(), metadata.DeletionMarkFilename))
+	testutil.Ok(t, err)
+	testutil.Assert(t, !exists, "deletion mark should be removed")
+}
+
+func TestVerifyFilesInBucket(t *testing.T) {
+	defer testutil.TolerantVerifyLeak(t)
+	ctx := context.Background()
+
+	tmpDir, err := ioutil.TempDir("", "test-block-verify-files")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(tmpDir)) }()
+
+	bkt := objstore.NewInMemBucket()
+	id, err := e2eutil.CreateBlock(ctx, tmpDir, []labels.Labels{
+		{{Name: "a", Value: "1"}},
+		{{Name: "b", Value: "1"}},
+	}, 100, 0, 1000, labels.Labels{{Name: "ext1", Value: "val1"}}, 124, metadata.NoneFunc)
+	testutil.Ok(t, err)
+	testutil.Ok(t, Upload(ctx, log.NewNopLogger(), bkt, path.Join(tmpDir, id.String()), metadata.NoneFunc))
+
+	meta, err := DownloadMeta(ctx, log.NewNopLogger(), bkt, id)
+	testutil.Ok(t, err)
+	testutil.Ok(t, VerifyFilesInBucket(ctx, bkt, &meta))
+
+	// Truncated chunk file.
+	chunkFile := path.Join(id.String(), ChunksDirname, "000001")
+	testutil.Ok(t, bkt.Upload(ctx, chunkFile, bytes.NewReader([]byte("truncated"))))
+	testutil.NotOk(t, VerifyFilesInBucket(ctx, bkt, &meta))
+
+	// Missing chunk file.
+	testutil.Ok(t, bkt.Delete(ctx, chunkFile))
+	testutil.NotOk(t, VerifyFilesInBucket(ctx, bkt, &meta))
+}
+
 // TestHashDownload uploads an empty block to in-memory storage
 // and tries to download it to the same dir. It should not try
 // to download twice.
@@ -0,0 +1,211 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+// Package bloom implements per-block bloom filters of label pairs. They are stored next to
+// the block index and allow to skip blocks which cannot contain series matching the
+// equality matchers of a query, without touching the block index.
+package bloom
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/cespare/xxhash"
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb/index"
+
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/runutil"
+)
+
+const (
+	// FormatV1 represents first version of the bloom filter file.
+	FormatV1 = 1
+
+	// MagicBloom are 4 bytes at the head of a bloom filter file.
+	MagicBloom = 0xB1005EED
+
+	// DefaultFalsePositiveRate is the false positive rate bloom filters are sized for.
+	DefaultFalsePositiveRate = 0.01
+
+	// headerLen is the number of bytes of magic, version, number of hash functions and number of bits.
+	headerLen = 4 + 1 + 1 + 8
+
+	// labelSep separates label name and value in the filter keys. It is not valid UTF-8,
+	// so it can't be part of a label name.
+	labelSep = '\xff'
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Filter is a bloom filter of label name and value pairs. The metric name is tracked
+// as the value of the __name__ label.
+type Filter struct {
+	m    uint64
+	k    uint8
+	bits []uint64
+}
+
+// New returns an empty filter sized for n items at the given false positive rate.
+func New(n int, fpRate float64) *Filter {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	// Round up to whole words.
+	m = (m + 63) / 64 * 64
+	k := math.Round(float64(m) / float64(n) * math.Ln2)
+	if k < 1 {
+		k = 1
+	}
+	if k > math.MaxUint8 {
+		k = math.MaxUint8
+	}
+	return &Filter{m: m, k: uint8(k), bits: make([]uint64, m/64)}
+}
+
+func (f *Filter) locations(key []byte, fn func(uint64) bool) bool {
+	// Double hashing: derives the k locations from two halves of a single hash.
+	h := xxhash.Sum64(key)
+	h1, h2 := h&math.MaxUint32, h>>32
+	for i := uint64(0); i < uint64(f.k); i++ {
+		if !fn((h1 + i*h2) % f.m) {
+			return false
+		}
+	}
+	return true
+}
+
+// Add adds the given label pair to the filter.
+func (f *Filter) Add(name, value string) {
+	f.locations(key(name, value), func(loc uint64) bool {
+		f.bits[loc/64] |= 1 << (loc % 64)
+		return true
+	})
+}
+
+// Test returns false if the given label pair was definitely not added to the filter.
+func (f *Filter) Test(name, value string) bool {
+	return f.locations(key(name, value), func(loc uint64) bool {
+		return f.bits[loc/64]&(1<<(loc%64)) != 0
+	})
+}
+
+// MayMatch returns false if no series added to the filter can match all the given matchers.
+// Only equality matchers with a non-empty value are considered, all other matchers may always match.
+func (f *Filter) MayMatch(matchers []*labels.Matcher) bool {
+	for _, m := range matchers {
+		if m.Type != labels.MatchEqual || m.Value == "" {
+			continue
+		}
+		if !f.Test(m.Name, m.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func key(name, value string) []byte {
+	b := make([]byte, 0, len(name)+1+len(value))
+	b = append(b, name...)
+	b = append(b, labelSep)
+	return append(b, value...)
+}
+
+// Bytes returns the encoded filter.
+func (f *Filter) Bytes() []byte {
+	b := make([]byte, headerLen, headerLen+len(f.bits)*8+crc32.Size)
+	binary.BigEndian.PutUint32(b[0:4], MagicBloom)
+	b[4] = FormatV1
+	b[5] = f.k
+	binary.BigEndian.PutUint64(b[6:14], f.m)
+	for _, w := range f.bits {
+		b = append(b, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(b[len(b)-8:], w)
+	}
+	sum := make([]byte, crc32.Size)
+	binary.BigEndian.PutUint32(sum, crc32.Checksum(b, castagnoliTable))
+	return append(b, sum...)
+}
+
+// Decode decodes a filter encoded with Bytes.
+func Decode(b []byte) (*Filter, error) {
+	if len(b) < headerLen+crc32.Size {
+		return nil, errors.Errorf("bloom filter of %d bytes is too short", len(b))
+	}
+	if m := binary.BigEndian.Uint32(b[0:4]); m != MagicBloom {
+		return nil, errors.Errorf("invalid magic number %x", m)
+	}
+	if v := b[4]; v != FormatV1 {
+		return nil, errors.Errorf("unknown bloom filter format version %d", v)
+	}
+	data, sum := b[:len(b)-crc32.Size], b[len(b)-crc32.Size:]
+	if exp := crc32.Checksum(data, castagnoliTable); binary.BigEndian.Uint32(sum) != exp {
+		return nil, errors.New("bloom filter checksum mismatch")
+	}
+
+	f := &Filter{k: b[5], m: binary.BigEndian.Uint64(b[6:14])}
+	if f.k == 0 || f.m == 0 || f.m%64 != 0 || uint64(len(data)-headerLen) != f.m/8 {
+		return nil, errors.Errorf("invalid bloom filter of %d bits with %d hash functions in %d bytes", f.m, f.k, len(b))
+	}
+	f.bits = make([]uint64, f.m/64)
+	for i := range f.bits {
+		f.bits[i] = binary.BigEndian.Uint64(data[headerLen+i*8:])
+	}
+	return f, nil
+}
+
+// Build returns a filter of all label pairs of the given index.
+func Build(ir *index.Reader, fpRate float64) (*Filter, error) {
+	names, err := ir.LabelNames()
+	if err != nil {
+		return nil, errors.Wrap(err, "label names")
+	}
+
+	values := make([][]string, 0, len(names))
+	n := 0
+	for _, name := range names {
+		vals, err := ir.LabelValues(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "label values of %s", name)
+		}
+		values = append(values, vals)
+		n += len(vals)
+	}
+
+	f := New(n, fpRate)
+	for i, name := range names {
+		for _, v := range values[i] {
+			f.Add(name, v)
+		}
+	}
+	return f, nil
+}
+
+// WriteBlockFilter builds the filter of the block in the given directory and writes it
+// into the block directory, next to the index.
+func WriteBlockFilter(logger log.Logger, blockDir string, fpRate float64) (err error) {
+	ir, err := index.NewFileReader(filepath.Join(blockDir, block.IndexFilename))
+	if err != nil {
+		return errors.Wrap(err, "open index")
+	}
+	defer runutil.CloseWithLogOnErr(logger, ir, "close index reader")
+
+	f, err := Build(ir, fpRate)
+	if err != nil {
+		return errors.Wrap(err, "build bloom filter")
+	}
+
+	filename := filepath.Join(blockDir, block.BloomFilterFilename)
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, f.Bytes(), 0666); err != nil {
+		return errors.Wrapf(err, "write %s", tmp)
+	}
+	return os.Rename(tmp, filename)
+}
@@ -0,0 +1,125 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package bloom
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/testutil"
+	"github.com/thanos-io/thanos/pkg/testutil/e2eutil"
+)
+
+func TestFilter(t *testing.T) {
+	const n = 10000
+
+	f := New(n, DefaultFalsePositiveRate)
+	for i := 0; i < n; i++ {
+		f.Add("pod", fmt.Sprintf("pod-%d", i))
+	}
+	for i := 0; i < n; i++ {
+		testutil.Assert(t, f.Test("pod", fmt.Sprintf("pod-%d", i)), "expected added pair to be found")
+	}
+
+	falsePositives := 0
+	for i := n; i < 2*n; i++ {
+		if f.Test("pod", fmt.Sprintf("pod-%d", i)) {
+			falsePositives++
+		}
+	}
+	testutil.Assert(t, float64(falsePositives)/n < 2*DefaultFalsePositiveRate, "too many false positives: %d", falsePositives)
+
+	// The separator makes sure that name and value don't bleed into each other.
+	f = New(1, DefaultFalsePositiveRate)
+	f.Add("ab", "c")
+	testutil.Assert(t, f.Test("ab", "c"), "expected added pair to be found")
+	testutil.Assert(t, !f.Test("a", "bc"), "expected different pair to be missing")
+}
+
+func TestFilter_MayMatch(t *testing.T) {
+	f := New(10, DefaultFalsePositiveRate)
+	f.Add(labels.MetricName, "up")
+	f.Add("job", "api")
+
+	for _, tcase := range []struct {
+		matchers []*labels.Matcher
+		expected bool
+	}{
+		{matchers: nil, expected: true},
+		{matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "up")}, expected: true},
+		{matchers: []*labels.Matcher{
+			labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "up"),
+			labels.MustNewMatcher(labels.MatchEqual, "job", "api"),
+		}, expected: true},
+		{matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "down")}, expected: false},
+		{matchers: []*labels.Matcher{
+			labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "up"),
+			labels.MustNewMatcher(labels.MatchEqual, "job", "db"),
+		}, expected: false},
+		// Matchers other than equality ones are not checked.
+		{matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchNotEqual, "job", "api")}, expected: true},
+		{matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, "job", "db")}, expected: true},
+		// An empty value matches series without the label.
+		{matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "instance", "")}, expected: true},
+	} {
+		t.Run(fmt.Sprintf("%v", tcase.matchers), func(t *testing.T) {
+			testutil.Equals(t, tcase.expected, f.MayMatch(tcase.matchers))
+		})
+	}
+}
+
+func TestFilter_Encoding(t *testing.T) {
+	f := New(100, DefaultFalsePositiveRate)
+	f.Add("job", "api")
+
+	b := f.Bytes()
+	dec, err := Decode(b)
+	testutil.Ok(t, err)
+	testutil.Equals(t, f, dec)
+
+	// Corrupted data.
+	b[headerLen]++
+	_, err = Decode(b)
+	testutil.NotOk(t, err)
+
+	_, err = Decode(b[:headerLen])
+	testutil.NotOk(t, err)
+}
+
+func TestWriteBlockFilter(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test-bloom-filter")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(tmpDir)) }()
+
+	series := []labels.Labels{
+		labels.FromStrings(labels.MetricName, "up", "pod", "a"),
+		labels.FromStrings(labels.MetricName, "up", "pod", "b"),
+	}
+	id, err := e2eutil.CreateBlock(context.Background(), tmpDir, series, 10, 0, 1000, labels.FromStrings("ext", "1"), 0, metadata.NoneFunc)
+	testutil.Ok(t, err)
+
+	bdir := filepath.Join(tmpDir, id.String())
+	testutil.Ok(t, WriteBlockFilter(log.NewNopLogger(), bdir, DefaultFalsePositiveRate))
+
+	b, err := ioutil.ReadFile(filepath.Join(bdir, block.BloomFilterFilename))
+	testutil.Ok(t, err)
+	f, err := Decode(b)
+	testutil.Ok(t, err)
+
+	testutil.Assert(t, f.Test(labels.MetricName, "up"), "expected metric name to be found")
+	testutil.Assert(t, f.Test("pod", "a"), "expected label pair to be found")
+	testutil.Assert(t, f.Test("pod", "b"), "expected label pair to be found")
+	testutil.Assert(t, !f.Test("pod", "c"), "expected label pair to be missing")
+	// External labels are not part of the index.
+	testutil.Assert(t, !f.Test("ext", "1"), "expected external label to be missing")
+}
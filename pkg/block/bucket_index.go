@@ -0,0 +1,386 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package block
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/extprom"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/runutil"
+)
+
+const (
+	// BucketIndexFilename is the known name of the gzip compressed JSON object storing the bucket index in the bucket root.
+	BucketIndexFilename = "bucket-index.json.gz"
+
+	// BucketIndexVersion1 is the version of the bucket index supported by Thanos.
+	BucketIndexVersion1 = 1
+)
+
+// ErrorBucketIndexNotFound is the error when the bucket index does not exist in the bucket.
+var ErrorBucketIndexNotFound = errors.New("bucket index not found")
+
+// BucketIndex stores the metadata and markers of all blocks in the bucket, so they can be discovered with a single request
+// instead of iterating the bucket.
+type BucketIndex struct {
+	// Version of the index.
+	Version int `json:"version"`
+	// UpdatedAt is a unix timestamp of when the index was built.
+	UpdatedAt int64 `json:"updated_at"`
+
+	Blocks         []*metadata.Meta          `json:"blocks"`
+	DeletionMarks  []*metadata.DeletionMark  `json:"deletion_marks"`
+	NoCompactMarks []*metadata.NoCompactMark `json:"no_compact_marks"`
+}
+
+// Markers returns markers stored in the index.
+func (i *BucketIndex) Markers() *BlockMarkers {
+	m := &BlockMarkers{
+		DeletionMarks:  make(map[ulid.ULID]*metadata.DeletionMark, len(i.DeletionMarks)),
+		NoCompactMarks: make(map[ulid.ULID]*metadata.NoCompactMark, len(i.NoCompactMarks)),
+	}
+	for _, d := range i.DeletionMarks {
+		m.DeletionMarks[d.ID] = d
+	}
+	for _, n := range i.NoCompactMarks {
+		m.NoCompactMarks[n.ID] = n
+	}
+	return m
+}
+
+// BlockMarkers are the markers of all blocks known upfront, e.g. from the bucket index.
+type BlockMarkers struct {
+	DeletionMarks  map[ulid.ULID]*metadata.DeletionMark
+	NoCompactMarks map[ulid.ULID]*metadata.NoCompactMark
+}
+
+// MarkersAwareFilter is a MetadataFilter which is able to use markers known upfront instead of reading them from the bucket.
+type MarkersAwareFilter interface {
+	MetadataFilter
+	// FilterWithMarkers filters metas like Filter does, but using the given markers.
+	FilterWithMarkers(ctx context.Context, metas map[ulid.ULID]*metadata.Meta, synced *extprom.TxGaugeVec, markers *BlockMarkers) error
+}
+
+// ReadBucketIndex reads the bucket index from the bucket.
+// It returns `ErrorBucketIndexNotFound` sentinel error if the index does not exist.
+func ReadBucketIndex(ctx context.Context, logger log.Logger, bkt objstore.InstrumentedBucketReader) (*BucketIndex, error) {
+	r, err := bkt.ReaderWithExpectedErrs(bkt.IsObjNotFoundErr).Get(ctx, BucketIndexFilename)
+	if err != nil {
+		if bkt.IsObjNotFoundErr(err) {
+			return nil, ErrorBucketIndexNotFound
+		}
+		return nil, errors.Wrapf(err, "get file: %s", BucketIndexFilename)
+	}
+	defer runutil.CloseWithLogOnErr(logger, r, "close bkt bucket index reader")
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create gzip reader: %s", BucketIndexFilename)
+	}
+	defer runutil.CloseWithLogOnErr(logger, gz, "close bucket index gzip reader")
+
+	content, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read file: %s", BucketIndexFilename)
+	}
+
+	idx := &BucketIndex{}
+	if err := json.Unmarshal(content, idx); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal file: %s", BucketIndexFilename)
+	}
+	if idx.Version != BucketIndexVersion1 {
+		return nil, errors.Errorf("unexpected bucket index version %d, expected %d", idx.Version, BucketIndexVersion1)
+	}
+	return idx, nil
+}
+
+// WriteBucketIndex uploads the given bucket index to the bucket.
+func WriteBucketIndex(ctx context.Context, bkt objstore.Bucket, idx *BucketIndex) error {
+	content, err := json.Marshal(idx)
+	if err != nil {
+		return errors.Wrap(err, "json encode bucket index")
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(content); err != nil {
+		return errors.Wrap(err, "gzip bucket index")
+	}
+	if err := gz.Close(); err != nil {
+		return errors.Wrap(err, "gzip bucket index")
+	}
+	return errors.Wrap(bkt.Upload(ctx, BucketIndexFilename, &buf), "upload bucket index")
+}
+
+// BucketIndexUpdater builds the bucket index from the current state of the bucket and uploads it.
+type BucketIndexUpdater struct {
+	logger      log.Logger
+	bkt         objstore.InstrumentedBucket
+	fetcher     MetadataFetcher
+	concurrency int
+
+	updates        prometheus.Counter
+	updateFailures prometheus.Counter
+	lastUpdate     prometheus.Gauge
+}
+
+// NewBucketIndexUpdater creates BucketIndexUpdater. The given fetcher has to return all blocks in the bucket, so it should not
+// have filters excluding blocks.
+func NewBucketIndexUpdater(logger log.Logger, reg prometheus.Registerer, bkt objstore.InstrumentedBucket, fetcher MetadataFetcher, concurrency int) *BucketIndexUpdater {
+	return &BucketIndexUpdater{
+		logger:      logger,
+		bkt:         bkt,
+		fetcher:     fetcher,
+		concurrency: concurrency,
+		updates: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "thanos_bucket_index_updates_total",
+			Help: "Total number of bucket index updates.",
+		}),
+		updateFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "thanos_bucket_index_update_failures_total",
+			Help: "Total number of failed bucket index updates.",
+		}),
+		lastUpdate: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "thanos_bucket_index_last_successful_update_timestamp_seconds",
+			Help: "Unix timestamp of the last successful bucket index update.",
+		}),
+	}
+}
+
+// Update builds the bucket index and uploads it to the bucket.
+func (u *BucketIndexUpdater) Update(ctx context.Context) (_ *BucketIndex, err error) {
+	u.updates.Inc()
+	defer func() {
+		if err != nil {
+			u.updateFailures.Inc()
+		}
+	}()
+
+	now := time.Now()
+	metas, _, err := u.fetcher.Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch metas")
+	}
+
+	idx := &BucketIndex{
+		Version:   BucketIndexVersion1,
+		UpdatedAt: now.Unix(),
+		Blocks:    make([]*metadata.Meta, 0, len(metas)),
+	}
+	for _, m := range metas {
+		idx.Blocks = append(idx.Blocks, m)
+	}
+	sort.Slice(idx.Blocks, func(i, j int) bool {
+		return idx.Blocks[i].ULID.Compare(idx.Blocks[j].ULID) < 0
+	})
+
+	if err := u.readMarkers(ctx, idx); err != nil {
+		return nil, err
+	}
+
+	if err := WriteBucketIndex(ctx, u.bkt, idx); err != nil {
+		return nil, err
+	}
+	u.lastUpdate.SetToCurrentTime()
+	level.Info(u.logger).Log("msg", "updated bucket index", "blocks", len(idx.Blocks), "deletionMarks", len(idx.DeletionMarks), "noCompactMarks", len(idx.NoCompactMarks), "duration", time.Since(now).String())
+	return idx, nil
+}
+
+// readMarkers reads the markers of all blocks in the index.
+func (u *BucketIndexUpdater) readMarkers(ctx context.Context, idx *BucketIndex) error {
+	var (
+		eg  errgroup.Group
+		ch  = make(chan ulid.ULID, u.concurrency)
+		mtx sync.Mutex
+	)
+
+	read := func(id ulid.ULID, m metadata.Marker) (bool, error) {
+		if err := metadata.ReadMarker(ctx, u.logger, u.bkt, id.String(), m); err != nil {
+			if errors.Cause(err) == metadata.ErrorMarkerNotFound {
+				return false, nil
+			}
+			if errors.Cause(err) == metadata.ErrorUnmarshalMarker {
+				level.Warn(u.logger).Log("msg", "found partial marker; skipping it in the bucket index", "block", id, "err", err)
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	for i := 0; i < u.concurrency; i++ {
+		eg.Go(func() error {
+			for id := range ch {
+				d := &metadata.DeletionMark{}
+				found, err := read(id, d)
+				if err != nil {
+					return err
+				}
+				if found {
+					mtx.Lock()
+					idx.DeletionMarks = append(idx.DeletionMarks, d)
+					mtx.Unlock()
+				}
+
+				n := &metadata.NoCompactMark{}
+				found, err = read(id, n)
+				if err != nil {
+					return err
+				}
+				if found {
+					mtx.Lock()
+					idx.NoCompactMarks = append(idx.NoCompactMarks, n)
+					mtx.Unlock()
+				}
+			}
+			return nil
+		})
+	}
+
+	// Workers scheduled, distribute blocks.
+	eg.Go(func() error {
+		defer close(ch)
+
+		for _, m := range idx.Blocks {
+			select {
+			case ch <- m.ULID:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+
+	if err := eg.Wait(); err != nil {
+		return errors.Wrap(err, "read block markers")
+	}
+
+	sort.Slice(idx.DeletionMarks, func(i, j int) bool {
+		return idx.DeletionMarks[i].ID.Compare(idx.DeletionMarks[j].ID) < 0
+	})
+	sort.Slice(idx.NoCompactMarks, func(i, j int) bool {
+		return idx.NoCompactMarks[i].ID.Compare(idx.NoCompactMarks[j].ID) < 0
+	})
+	return nil
+}
+
+// BucketIndexFetcher is a MetadataFetcher which reads block metadata and markers from the bucket index instead of
+// iterating the bucket. It falls back to iterating the bucket if the index does not exist, cannot be read or is
+// older than the max staleness.
+type BucketIndexFetcher struct {
+	wrapped      *BaseFetcher
+	metrics      *FetcherMetrics
+	maxStaleness time.Duration
+
+	filters   []MetadataFilter
+	modifiers []MetadataModifier
+
+	listener func([]metadata.Meta, error)
+
+	fallbacks prometheus.Counter
+	indexAge  prometheus.Gauge
+
+	logger log.Logger
+}
+
+// NewBucketIndexFetcher transforms BaseFetcher into *BucketIndexFetcher. Zero max staleness means the index is never considered stale.
+func (f *BaseFetcher) NewBucketIndexFetcher(reg prometheus.Registerer, maxStaleness time.Duration, filters []MetadataFilter, modifiers []MetadataModifier, logTags ...interface{}) *BucketIndexFetcher {
+	return &BucketIndexFetcher{
+		wrapped:      f,
+		metrics:      NewFetcherMetrics(reg, nil, nil),
+		maxStaleness: maxStaleness,
+		filters:      filters,
+		modifiers:    modifiers,
+		fallbacks: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Subsystem: fetcherSubSys,
+			Name:      "bucket_index_fallbacks_total",
+			Help:      "Total number of blocks metadata synchronizations which iterated the bucket because the bucket index was missing, unreadable or stale.",
+		}),
+		indexAge: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Subsystem: fetcherSubSys,
+			Name:      "bucket_index_age_seconds",
+			Help:      "Age of the bucket index at the last blocks metadata synchronization.",
+		}),
+		logger: log.With(f.logger, logTags...),
+	}
+}
+
+// Fetch returns all block metas as well as partial blocks from the bucket index, or from the bucket if the index cannot be used.
+// It's caller responsibility to not change the returned metadata files. Maps can be modified.
+//
+// Returned error indicates a failure in fetching metadata. Returned meta can be assumed as correct, with some blocks missing.
+func (f *BucketIndexFetcher) Fetch(ctx context.Context) (metas map[ulid.ULID]*metadata.Meta, partial map[ulid.ULID]error, err error) {
+	idx, err := ReadBucketIndex(ctx, f.logger, f.wrapped.bkt)
+	if err == nil {
+		age := time.Since(time.Unix(idx.UpdatedAt, 0))
+		f.indexAge.Set(age.Seconds())
+		if f.maxStaleness > 0 && age > f.maxStaleness {
+			err = errors.Errorf("bucket index is stale; updated %v ago, max staleness %v", age, f.maxStaleness)
+		}
+	}
+
+	if err != nil {
+		level.Warn(f.logger).Log("msg", "bucket index cannot be used; falling back to iterating the bucket", "err", err)
+		f.fallbacks.Inc()
+		metas, partial, err = f.wrapped.fetch(ctx, f.metrics, f.filters, f.modifiers)
+	} else {
+		metas, err = f.fetchFromIndex(ctx, idx)
+	}
+
+	if f.listener != nil {
+		blocks := make([]metadata.Meta, 0, len(metas))
+		for _, meta := range metas {
+			blocks = append(blocks, *meta)
+		}
+		f.listener(blocks, err)
+	}
+	return metas, partial, err
+}
+
+func (f *BucketIndexFetcher) fetchFromIndex(ctx context.Context, idx *BucketIndex) (_ map[ulid.ULID]*metadata.Meta, err error) {
+	start := time.Now()
+	defer func() {
+		f.metrics.SyncDuration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			f.metrics.SyncFailures.Inc()
+		}
+	}()
+	f.metrics.Syncs.Inc()
+	f.metrics.ResetTx()
+
+	metas := make(map[ulid.ULID]*metadata.Meta, len(idx.Blocks))
+	for _, m := range idx.Blocks {
+		metas[m.ULID] = m
+	}
+	if err := filterAndModify(ctx, f.metrics, metas, idx.Markers(), f.filters, f.modifiers); err != nil {
+		return nil, err
+	}
+	f.metrics.Synced.WithLabelValues(LoadedMeta).Set(float64(len(metas)))
+	f.metrics.Submit()
+
+	level.Info(f.logger).Log("msg", "successfully synchronized block metadata from bucket index", "duration", time.Since(start).String(), "returned", len(metas), "indexUpdatedAt", time.Unix(idx.UpdatedAt, 0).UTC().Format(time.RFC3339))
+	return metas, nil
+}
+
+// UpdateOnChange allows to add listener that will be update on every change.
+func (f *BucketIndexFetcher) UpdateOnChange(listener func([]metadata.Meta, error)) {
+	f.listener = listener
+}
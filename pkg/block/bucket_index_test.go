@@ -0,0 +1,86 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package block
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/tsdb"
+
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func TestBucketIndex_UpdateAndFetch(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.WithNoopInstr(objstore.NewInMemBucket())
+	now := time.Now()
+
+	upload := func(name string, v interface{}) {
+		var buf bytes.Buffer
+		testutil.Ok(t, json.NewEncoder(&buf).Encode(v))
+		testutil.Ok(t, bkt.Upload(ctx, name, &buf))
+	}
+	for i := 1; i <= 3; i++ {
+		upload(path.Join(ULID(i).String(), MetaFilename), &metadata.Meta{
+			BlockMeta: tsdb.BlockMeta{ULID: ULID(i), MinTime: 0, MaxTime: 100, Version: 1},
+			Thanos:    metadata.Thanos{Version: 1, Labels: map[string]string{"a": "b"}},
+		})
+	}
+	upload(path.Join(ULID(1).String(), metadata.DeletionMarkFilename), &metadata.DeletionMark{ID: ULID(1), Version: 1, DeletionTime: now.Add(-time.Hour).Unix()})
+	upload(path.Join(ULID(2).String(), metadata.NoCompactMarkFilename), &metadata.NoCompactMark{ID: ULID(2), Version: 1, Reason: metadata.ManualNoCompactReason})
+
+	baseFetcher, err := NewBaseFetcher(log.NewNopLogger(), 2, bkt, "", nil)
+	testutil.Ok(t, err)
+	fetcher := baseFetcher.NewBucketIndexFetcher(nil, time.Hour, []MetadataFilter{
+		NewIgnoreDeletionMarkFilter(log.NewNopLogger(), bkt, 0, 2),
+	}, nil)
+
+	// Without the index, the fetcher falls back to iterating the bucket.
+	_, err = ReadBucketIndex(ctx, log.NewNopLogger(), bkt)
+	testutil.Equals(t, ErrorBucketIndexNotFound, err)
+	metas, _, err := fetcher.Fetch(ctx)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 2, len(metas))
+	testutil.Equals(t, 1.0, promtest.ToFloat64(fetcher.fallbacks))
+
+	raw, err := NewRawMetaFetcher(log.NewNopLogger(), bkt)
+	testutil.Ok(t, err)
+	idx, err := NewBucketIndexUpdater(log.NewNopLogger(), nil, bkt, raw, 2).Update(ctx)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 3, len(idx.Blocks))
+	testutil.Equals(t, []ulid.ULID{ULID(1)}, []ulid.ULID{idx.DeletionMarks[0].ID})
+	testutil.Equals(t, []ulid.ULID{ULID(2)}, []ulid.ULID{idx.NoCompactMarks[0].ID})
+
+	read, err := ReadBucketIndex(ctx, log.NewNopLogger(), bkt)
+	testutil.Ok(t, err)
+	testutil.Equals(t, idx.UpdatedAt, read.UpdatedAt)
+	testutil.Equals(t, len(idx.Blocks), len(read.Blocks))
+
+	// Blocks and markers which appear after the index update are not visible until the next update.
+	upload(path.Join(ULID(3).String(), metadata.DeletionMarkFilename), &metadata.DeletionMark{ID: ULID(3), Version: 1, DeletionTime: now.Add(-time.Hour).Unix()})
+	metas, _, err = fetcher.Fetch(ctx)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1.0, promtest.ToFloat64(fetcher.fallbacks))
+	testutil.Equals(t, 2, len(metas))
+	_, ok := metas[ULID(1)]
+	testutil.Assert(t, !ok, "block marked for deletion should be filtered out")
+
+	// Stale index is not used.
+	read.UpdatedAt = now.Add(-2 * time.Hour).Unix()
+	testutil.Ok(t, WriteBucketIndex(ctx, bkt, read))
+	metas, _, err = fetcher.Fetch(ctx)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 2.0, promtest.ToFloat64(fetcher.fallbacks))
+	testutil.Equals(t, 1, len(metas))
+}
@@ -0,0 +1,205 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package block
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+
+	"github.com/thanos-io/thanos/pkg/runutil"
+)
+
+// CardinalityStats holds cardinality statistics of one or more block indexes.
+type CardinalityStats struct {
+	// Blocks is the number of analyzed blocks.
+	Blocks int
+	// TotalSeries is the number of series summed over all analyzed blocks.
+	TotalSeries int64
+	// TotalChunkBytes is the approximate size of all chunks summed over all analyzed blocks.
+	TotalChunkBytes int64
+
+	metrics     map[string]*MetricCardinality
+	labelValues map[string]map[string]struct{}
+	labelPairs  map[labels.Label]int64
+}
+
+// MetricCardinality holds the number of series and their chunk bytes of a metric.
+type MetricCardinality struct {
+	Name       string `json:"name"`
+	Series     int64  `json:"series"`
+	ChunkBytes int64  `json:"chunkBytes"`
+}
+
+// LabelNameCardinality holds the number of distinct values of a label name.
+type LabelNameCardinality struct {
+	Name   string `json:"name"`
+	Values int64  `json:"values"`
+}
+
+// LabelPairCardinality holds the number of series (postings) of a label pair.
+type LabelPairCardinality struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Series int64  `json:"series"`
+}
+
+// NewCardinalityStats returns empty cardinality statistics.
+func NewCardinalityStats() *CardinalityStats {
+	return &CardinalityStats{
+		metrics:     map[string]*MetricCardinality{},
+		labelValues: map[string]map[string]struct{}{},
+		labelPairs:  map[labels.Label]int64{},
+	}
+}
+
+type chunkOfMetric struct {
+	ref    uint64
+	metric *MetricCardinality
+}
+
+// GatherIndex walks all series of the index file and adds their cardinality statistics to the stats.
+// Chunk bytes are approximated from the distance between chunk references, as the chunk files are not read.
+// The chunk segment file sizes, ordered by their sequence, are used to calculate the size of the last chunk of each segment;
+// if a segment size is not given, its last chunk is not counted.
+func (s *CardinalityStats) GatherIndex(fn string, chunkSegmentSizes []int64) (err error) {
+	r, err := index.NewFileReader(fn)
+	if err != nil {
+		return errors.Wrap(err, "open index file")
+	}
+	defer runutil.CloseWithErrCapture(&err, r, "gather index cardinality file reader")
+
+	p, err := r.Postings(index.AllPostingsKey())
+	if err != nil {
+		return errors.Wrap(err, "get all postings")
+	}
+
+	var (
+		lset labels.Labels
+		chks []chunks.Meta
+		refs []chunkOfMetric
+	)
+	for p.Next() {
+		if err := r.Series(p.At(), &lset, &chks); err != nil {
+			return errors.Wrap(err, "read series")
+		}
+		s.TotalSeries++
+
+		// Strings of the label set point to the mmaped index file, so they are copied before being kept after the reader is closed.
+		name := lset.Get(labels.MetricName)
+		m, ok := s.metrics[name]
+		if !ok {
+			m = &MetricCardinality{Name: copyString(name)}
+			s.metrics[m.Name] = m
+		}
+		m.Series++
+
+		for _, l := range lset {
+			if _, ok := s.labelPairs[l]; !ok {
+				l = labels.Label{Name: copyString(l.Name), Value: copyString(l.Value)}
+			}
+			s.labelPairs[l]++
+
+			values, ok := s.labelValues[l.Name]
+			if !ok {
+				values = map[string]struct{}{}
+				s.labelValues[copyString(l.Name)] = values
+			}
+			if _, ok := values[l.Value]; !ok {
+				values[copyString(l.Value)] = struct{}{}
+			}
+		}
+		for _, c := range chks {
+			refs = append(refs, chunkOfMetric{ref: c.Ref, metric: m})
+		}
+	}
+	if p.Err() != nil {
+		return errors.Wrap(p.Err(), "walk postings")
+	}
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i].ref < refs[j].ref })
+	for i, c := range refs {
+		segment, offset := int(c.ref>>32), int64((c.ref<<32)>>32)
+
+		var size int64
+		if i+1 < len(refs) && int(refs[i+1].ref>>32) == segment {
+			size = int64((refs[i+1].ref<<32)>>32) - offset
+		} else if segment < len(chunkSegmentSizes) {
+			size = chunkSegmentSizes[segment] - offset
+		}
+		c.metric.ChunkBytes += size
+		s.TotalChunkBytes += size
+	}
+	s.Blocks++
+	return nil
+}
+
+// TopMetrics returns up to limit metrics with the most series. Zero limit means no limit.
+func (s *CardinalityStats) TopMetrics(limit int) []MetricCardinality {
+	res := make([]MetricCardinality, 0, len(s.metrics))
+	for _, m := range s.metrics {
+		res = append(res, *m)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Series != res[j].Series {
+			return res[i].Series > res[j].Series
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res[:topLimit(len(res), limit)]
+}
+
+// TopMetricsByChunkBytes returns up to limit metrics with the most chunk bytes. Zero limit means no limit.
+func (s *CardinalityStats) TopMetricsByChunkBytes(limit int) []MetricCardinality {
+	res := s.TopMetrics(0)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].ChunkBytes > res[j].ChunkBytes })
+	return res[:topLimit(len(res), limit)]
+}
+
+// TopLabelNames returns up to limit label names with the most distinct values. Zero limit means no limit.
+func (s *CardinalityStats) TopLabelNames(limit int) []LabelNameCardinality {
+	res := make([]LabelNameCardinality, 0, len(s.labelValues))
+	for name, values := range s.labelValues {
+		res = append(res, LabelNameCardinality{Name: name, Values: int64(len(values))})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Values != res[j].Values {
+			return res[i].Values > res[j].Values
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res[:topLimit(len(res), limit)]
+}
+
+// TopLabelPairs returns up to limit label pairs with the most series. Zero limit means no limit.
+func (s *CardinalityStats) TopLabelPairs(limit int) []LabelPairCardinality {
+	res := make([]LabelPairCardinality, 0, len(s.labelPairs))
+	for l, series := range s.labelPairs {
+		res = append(res, LabelPairCardinality{Name: l.Name, Value: l.Value, Series: series})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Series != res[j].Series {
+			return res[i].Series > res[j].Series
+		}
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].Value < res[j].Value
+	})
+	return res[:topLimit(len(res), limit)]
+}
+
+func topLimit(n, limit int) int {
+	if limit <= 0 || limit > n {
+		return n
+	}
+	return limit
+}
+
+func copyString(s string) string {
+	return string([]byte(s))
+}
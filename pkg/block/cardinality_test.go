@@ -0,0 +1,71 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package block
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/testutil"
+	"github.com/thanos-io/thanos/pkg/testutil/e2eutil"
+)
+
+func TestCardinalityStats(t *testing.T) {
+	ctx := context.Background()
+
+	tmpDir, err := ioutil.TempDir("", "test-cardinality")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(tmpDir)) }()
+
+	b, err := e2eutil.CreateBlock(ctx, tmpDir, []labels.Labels{
+		labels.FromStrings(labels.MetricName, "a", "pod", "1", "job", "x"),
+		labels.FromStrings(labels.MetricName, "a", "pod", "2", "job", "x"),
+		labels.FromStrings(labels.MetricName, "a", "pod", "3", "job", "x"),
+		labels.FromStrings(labels.MetricName, "b", "job", "y"),
+	}, 500, 0, 1000, nil, 0, metadata.NoneFunc)
+	testutil.Ok(t, err)
+
+	chunksDir := filepath.Join(tmpDir, b.String(), ChunksDirname)
+	files, err := ioutil.ReadDir(chunksDir)
+	testutil.Ok(t, err)
+	var segmentSizes []int64
+	for _, f := range files {
+		segmentSizes = append(segmentSizes, f.Size())
+	}
+
+	stats := NewCardinalityStats()
+	testutil.Ok(t, stats.GatherIndex(filepath.Join(tmpDir, b.String(), IndexFilename), segmentSizes))
+	testutil.Equals(t, 1, stats.Blocks)
+	testutil.Equals(t, int64(4), stats.TotalSeries)
+	testutil.Equals(t, segmentSizes[0]-chunks.SegmentHeaderSize, stats.TotalChunkBytes)
+
+	metrics := stats.TopMetrics(0)
+	testutil.Equals(t, 2, len(metrics))
+	testutil.Equals(t, "a", metrics[0].Name)
+	testutil.Equals(t, int64(3), metrics[0].Series)
+	testutil.Equals(t, "b", metrics[1].Name)
+	testutil.Equals(t, int64(1), metrics[1].Series)
+	testutil.Assert(t, metrics[0].ChunkBytes > metrics[1].ChunkBytes, "chunk bytes of a %d not bigger than of b %d", metrics[0].ChunkBytes, metrics[1].ChunkBytes)
+	testutil.Equals(t, stats.TotalChunkBytes, metrics[0].ChunkBytes+metrics[1].ChunkBytes)
+	testutil.Equals(t, "a", stats.TopMetricsByChunkBytes(1)[0].Name)
+
+	testutil.Equals(t, []LabelNameCardinality{{Name: "pod", Values: 3}, {Name: labels.MetricName, Values: 2}, {Name: "job", Values: 2}}, stats.TopLabelNames(0))
+	testutil.Equals(t, []LabelPairCardinality{
+		{Name: labels.MetricName, Value: "a", Series: 3},
+		{Name: "job", Value: "x", Series: 3},
+	}, stats.TopLabelPairs(2))
+
+	// Statistics of further blocks are added up.
+	testutil.Ok(t, stats.GatherIndex(filepath.Join(tmpDir, b.String(), IndexFilename), nil))
+	testutil.Equals(t, 2, stats.Blocks)
+	testutil.Equals(t, int64(8), stats.TotalSeries)
+	testutil.Equals(t, int64(6), stats.TopMetrics(1)[0].Series)
+	testutil.Equals(t, LabelNameCardinality{Name: "pod", Values: 3}, stats.TopLabelNames(1)[0])
+}
@@ -5,6 +5,8 @@ package block
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/binary"
 	"encoding/json"
 	"io/ioutil"
 	"os"
@@ -72,6 +74,7 @@ const (
 	// Synced label values.
 	labelExcludedMeta = "label-excluded"
 	timeExcludedMeta  = "time-excluded"
+	shardExcludedMeta = "shard-excluded"
 	tooFreshMeta      = "too-fresh"
 	duplicateMeta     = "duplicate"
 	// Blocks that are marked for deletion can be loaded as well. This is done to make sure that we load blocks that are meant to be deleted,
@@ -120,6 +123,7 @@ func NewFetcherMetrics(reg prometheus.Registerer, syncedExtraLabels, modifiedExt
 			{FailedMeta},
 			{labelExcludedMeta},
 			{timeExcludedMeta},
+			{shardExcludedMeta},
 			{duplicateMeta},
 			{MarkedForDeletionMeta},
 			{MarkedForNoCompactionMeta},
@@ -452,18 +456,8 @@ func (f *BaseFetcher) fetch(ctx context.Context, metrics *FetcherMetrics, filter
 	metrics.Synced.WithLabelValues(NoMeta).Set(resp.noMetas)
 	metrics.Synced.WithLabelValues(CorruptedMeta).Set(resp.corruptedMetas)
 
-	for _, filter := range filters {
-		// NOTE: filter can update synced metric accordingly to the reason of the exclude.
-		if err := filter.Filter(ctx, metas, metrics.Synced); err != nil {
-			return nil, nil, errors.Wrap(err, "filter metas")
-		}
-	}
-
-	for _, m := range modifiers {
-		// NOTE: modifier can update modified metric accordingly to the reason of the modification.
-		if err := m.Modify(ctx, metas, metrics.Modified); err != nil {
-			return nil, nil, errors.Wrap(err, "modify metas")
-		}
+	if err := filterAndModify(ctx, metrics, metas, nil, filters, modifiers); err != nil {
+		return nil, nil, err
 	}
 
 	metrics.Synced.WithLabelValues(LoadedMeta).Set(float64(len(metas)))
@@ -477,6 +471,31 @@ func (f *BaseFetcher) fetch(ctx context.Context, metrics *FetcherMetrics, filter
 	return metas, resp.partial, nil
 }
 
+// filterAndModify applies the given filters and modifiers to metas. Filters which are able to use markers are
+// given them, if markers are known upfront.
+func filterAndModify(ctx context.Context, metrics *FetcherMetrics, metas map[ulid.ULID]*metadata.Meta, markers *BlockMarkers, filters []MetadataFilter, modifiers []MetadataModifier) error {
+	for _, filter := range filters {
+		// NOTE: filter can update synced metric accordingly to the reason of the exclude.
+		if mf, ok := filter.(MarkersAwareFilter); ok && markers != nil {
+			if err := mf.FilterWithMarkers(ctx, metas, metrics.Synced, markers); err != nil {
+				return errors.Wrap(err, "filter metas")
+			}
+			continue
+		}
+		if err := filter.Filter(ctx, metas, metrics.Synced); err != nil {
+			return errors.Wrap(err, "filter metas")
+		}
+	}
+
+	for _, m := range modifiers {
+		// NOTE: modifier can update modified metric accordingly to the reason of the modification.
+		if err := m.Modify(ctx, metas, metrics.Modified); err != nil {
+			return errors.Wrap(err, "modify metas")
+		}
+	}
+	return nil
+}
+
 type MetaFetcher struct {
 	wrapped *BaseFetcher
 	metrics *FetcherMetrics
@@ -535,6 +554,48 @@ func (f *TimePartitionMetaFilter) Filter(_ context.Context, metas map[ulid.ULID]
 	return nil
 }
 
+var _ MetadataFilter = &HashShardedMetaFilter{}
+
+// HashShardedMetaFilter is a BaseFetcher filter that keeps only blocks assigned to a shard by the hash of their ULID.
+// The hash is the same as the one of the relabel hashmod action applied to the BlockIDLabel. Each block is assigned to
+// replicationFactor consecutive shards, starting from the one selected by the hash.
+// Not go-routine safe.
+type HashShardedMetaFilter struct {
+	shardIndex, shardTotal, replicationFactor uint64
+}
+
+// NewHashShardedMetaFilter creates HashShardedMetaFilter for the shard with the given index out of shardTotal shards.
+func NewHashShardedMetaFilter(shardIndex, shardTotal, replicationFactor uint64) (*HashShardedMetaFilter, error) {
+	if shardTotal == 0 {
+		return nil, errors.New("total number of shards must be greater than 0")
+	}
+	if shardIndex >= shardTotal {
+		return nil, errors.Errorf("shard index %d must be lower than the total number of shards %d", shardIndex, shardTotal)
+	}
+	if replicationFactor == 0 || replicationFactor > shardTotal {
+		return nil, errors.Errorf("replication factor %d must be between 1 and the total number of shards %d", replicationFactor, shardTotal)
+	}
+	return &HashShardedMetaFilter{shardIndex: shardIndex, shardTotal: shardTotal, replicationFactor: replicationFactor}, nil
+}
+
+// HashShard returns the shard, out of shardTotal shards, selected by the hash of the block ULID.
+func HashShard(id ulid.ULID, shardTotal uint64) uint64 {
+	h := md5.Sum([]byte(id.String()))
+	return binary.BigEndian.Uint64(h[md5.Size-8:]) % shardTotal
+}
+
+// Filter filters out blocks that are not assigned to the shard.
+func (f *HashShardedMetaFilter) Filter(_ context.Context, metas map[ulid.ULID]*metadata.Meta, synced *extprom.TxGaugeVec) error {
+	for id := range metas {
+		if (f.shardIndex+f.shardTotal-HashShard(id, f.shardTotal))%f.shardTotal < f.replicationFactor {
+			continue
+		}
+		synced.WithLabelValues(shardExcludedMeta).Inc()
+		delete(metas, id)
+	}
+	return nil
+}
+
 var _ MetadataFilter = &LabelShardedMetaFilter{}
 
 // LabelShardedMetaFilter represents struct that allows sharding.
@@ -569,6 +630,33 @@ func (f *LabelShardedMetaFilter) Filter(_ context.Context, metas map[ulid.ULID]*
 	return nil
 }
 
+var _ MetadataFilter = &LabelMatchersMetaFilter{}
+
+// LabelMatchersMetaFilter is a BaseFetcher filter that filters out blocks whose external labels do not match
+// all of the given matchers.
+type LabelMatchersMetaFilter struct {
+	matchers []*labels.Matcher
+}
+
+// NewLabelMatchersMetaFilter creates LabelMatchersMetaFilter.
+func NewLabelMatchersMetaFilter(matchers []*labels.Matcher) *LabelMatchersMetaFilter {
+	return &LabelMatchersMetaFilter{matchers: matchers}
+}
+
+// Filter filters out blocks that do not match the given label matchers against their external (Thanos) labels.
+func (f *LabelMatchersMetaFilter) Filter(_ context.Context, metas map[ulid.ULID]*metadata.Meta, synced *extprom.TxGaugeVec) error {
+	for id, m := range metas {
+		for _, matcher := range f.matchers {
+			if !matcher.Matches(m.Thanos.Labels[matcher.Name]) {
+				synced.WithLabelValues(labelExcludedMeta).Inc()
+				delete(metas, id)
+				break
+			}
+		}
+	}
+	return nil
+}
+
 var _ MetadataFilter = &DeduplicateFilter{}
 
 // DeduplicateFilter is a BaseFetcher filter that filters out older blocks that have exactly the same data.
@@ -773,6 +861,8 @@ func (f *ConsistencyDelayMetaFilter) Filter(_ context.Context, metas map[ulid.UL
 	return nil
 }
 
+var _ MarkersAwareFilter = &IgnoreDeletionMarkFilter{}
+
 // IgnoreDeletionMarkFilter is a filter that filters out the blocks that are marked for deletion after a given delay.
 // The delay duration is to make sure that the replacement block can be fetched before we filter out the old block.
 // Delay is not considered when computing DeletionMarkBlocks map.
@@ -833,14 +923,8 @@ func (f *IgnoreDeletionMarkFilter) Filter(ctx context.Context, metas map[ulid.UL
 					return err
 				}
 
-				// Keep track of the blocks marked for deletion and filter them out if their
-				// deletion time is greater than the configured delay.
 				mtx.Lock()
-				f.deletionMarkMap[id] = m
-				if time.Since(time.Unix(m.DeletionTime, 0)).Seconds() > f.delay.Seconds() {
-					synced.WithLabelValues(MarkedForDeletionMeta).Inc()
-					delete(metas, id)
-				}
+				f.markDeleted(id, m, metas, synced)
 				mtx.Unlock()
 			}
 
@@ -871,6 +955,29 @@ func (f *IgnoreDeletionMarkFilter) Filter(ctx context.Context, metas map[ulid.UL
 	return nil
 }
 
+// FilterWithMarkers filters out blocks that are marked for deletion after a given delay, using the given deletion marks
+// instead of reading them from the bucket.
+func (f *IgnoreDeletionMarkFilter) FilterWithMarkers(_ context.Context, metas map[ulid.ULID]*metadata.Meta, synced *extprom.TxGaugeVec, markers *BlockMarkers) error {
+	f.deletionMarkMap = make(map[ulid.ULID]*metadata.DeletionMark)
+	for id, m := range markers.DeletionMarks {
+		if _, ok := metas[id]; !ok {
+			continue
+		}
+		f.markDeleted(id, m, metas, synced)
+	}
+	return nil
+}
+
+// markDeleted keeps track of the block marked for deletion and filters it out if its
+// deletion time is greater than the configured delay.
+func (f *IgnoreDeletionMarkFilter) markDeleted(id ulid.ULID, m *metadata.DeletionMark, metas map[ulid.ULID]*metadata.Meta, synced *extprom.TxGaugeVec) {
+	f.deletionMarkMap[id] = m
+	if time.Since(time.Unix(m.DeletionTime, 0)).Seconds() > f.delay.Seconds() {
+		synced.WithLabelValues(MarkedForDeletionMeta).Inc()
+		delete(metas, id)
+	}
+}
+
 var (
 	SelectorSupportedRelabelActions = map[relabel.Action]struct{}{relabel.Keep: {}, relabel.Drop: {}, relabel.HashMod: {}}
 )
@@ -23,6 +23,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
 	"github.com/thanos-io/thanos/pkg/extprom"
@@ -360,6 +361,34 @@ func TestLabelShardedMetaFilter_Filter_Basic(t *testing.T) {
 
 }
 
+func TestLabelMatchersMetaFilter_Filter(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	f := NewLabelMatchersMetaFilter([]*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchEqual, "cluster", "B"),
+		labels.MustNewMatcher(labels.MatchNotRegexp, "replica", "r[12]"),
+	})
+
+	input := map[ulid.ULID]*metadata.Meta{
+		ULID(1): {Thanos: metadata.Thanos{Labels: map[string]string{"cluster": "B", "replica": "r0"}}},
+		ULID(2): {Thanos: metadata.Thanos{Labels: map[string]string{"cluster": "B", "replica": "r1"}}},
+		ULID(3): {Thanos: metadata.Thanos{Labels: map[string]string{"cluster": "A", "replica": "r0"}}},
+		ULID(4): {Thanos: metadata.Thanos{Labels: map[string]string{"cluster": "B"}}},
+		ULID(5): {Thanos: metadata.Thanos{Labels: map[string]string{"replica": "r0"}}},
+	}
+	expected := map[ulid.ULID]*metadata.Meta{
+		ULID(1): input[ULID(1)],
+		ULID(4): input[ULID(4)],
+	}
+
+	m := newTestFetcherMetrics()
+	testutil.Ok(t, f.Filter(ctx, input, m.Synced))
+
+	testutil.Equals(t, 3.0, promtest.ToFloat64(m.Synced.WithLabelValues(labelExcludedMeta)))
+	testutil.Equals(t, expected, input)
+}
+
 func TestLabelShardedMetaFilter_Filter_Hashmod(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
@@ -461,6 +490,53 @@ func TestLabelShardedMetaFilter_Filter_Hashmod(t *testing.T) {
 	}
 }
 
+func TestHashShardedMetaFilter_Filter(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	// Same assignment as hashmod of the block ID label with modulus 3, see TestLabelShardedMetaFilter_Filter_Hashmod.
+	shards := [][]ulid.ULID{
+		{ULID(2), ULID(6), ULID(11), ULID(13)},
+		{ULID(5), ULID(7), ULID(10), ULID(12), ULID(14), ULID(15)},
+		{ULID(1), ULID(3), ULID(4), ULID(8), ULID(9)},
+	}
+	for _, replicationFactor := range []uint64{1, 2, 3} {
+		for i := uint64(0); i < 3; i++ {
+			t.Run(fmt.Sprintf("replication-factor=%d,shard=%d", replicationFactor, i), func(t *testing.T) {
+				f, err := NewHashShardedMetaFilter(i, 3, replicationFactor)
+				testutil.Ok(t, err)
+
+				input := map[ulid.ULID]*metadata.Meta{}
+				for id := 1; id <= 15; id++ {
+					input[ULID(id)] = &metadata.Meta{}
+				}
+				expected := map[ulid.ULID]*metadata.Meta{}
+				for r := uint64(0); r < replicationFactor; r++ {
+					for _, id := range shards[(i+3-r)%3] {
+						expected[id] = input[id]
+					}
+				}
+				deleted := len(input) - len(expected)
+
+				m := newTestFetcherMetrics()
+				testutil.Ok(t, f.Filter(ctx, input, m.Synced))
+
+				testutil.Equals(t, expected, input)
+				testutil.Equals(t, float64(deleted), promtest.ToFloat64(m.Synced.WithLabelValues(shardExcludedMeta)))
+			})
+		}
+	}
+
+	_, err := NewHashShardedMetaFilter(0, 0, 1)
+	testutil.NotOk(t, err)
+	_, err = NewHashShardedMetaFilter(3, 3, 1)
+	testutil.NotOk(t, err)
+	_, err = NewHashShardedMetaFilter(0, 3, 0)
+	testutil.NotOk(t, err)
+	_, err = NewHashShardedMetaFilter(0, 3, 4)
+	testutil.NotOk(t, err)
+}
+
 func TestTimePartitionMetaFilter_Filter(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
@@ -0,0 +1,255 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package block
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/textparse"
+	"github.com/prometheus/prometheus/tsdb"
+
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/runutil"
+)
+
+const (
+	// OpenMetricsImportFormat is the OpenMetrics text format, see https://github.com/OpenObservability/OpenMetrics.
+	OpenMetricsImportFormat = "openmetrics"
+	// PrometheusImportFormat is the Prometheus text exposition format.
+	PrometheusImportFormat = "prometheus"
+	// CSVImportFormat is CSV with a header row. The timestamp column holds timestamps in milliseconds, the value column
+	// sample values and all other columns label values, e.g. "__name__,instance,timestamp,value".
+	CSVImportFormat = "csv"
+
+	csvTimestampColumn = "timestamp"
+	csvValueColumn     = "value"
+
+	// importCommitBatch is the number of samples appended before committing them.
+	importCommitBatch = 5000
+)
+
+type importSample struct {
+	lset labels.Labels
+	t    int64
+	v    float64
+}
+
+// CreateBlocksFromText parses samples in the OpenMetrics, Prometheus text or CSV format and writes them into blocks in the
+// given directory, one block per blockDuration (in milliseconds) aligned time range. Every sample must have a timestamp.
+// The input is parsed once and its samples are kept in memory until they are written.
+// The given Thanos metadata is injected into each created block.
+func CreateBlocksFromText(ctx context.Context, logger log.Logger, input []byte, format string, blockDuration int64, dir string, thanosMeta metadata.Thanos) ([]ulid.ULID, error) {
+	if blockDuration <= 0 {
+		return nil, errors.Errorf("block duration must be positive, got %d", blockDuration)
+	}
+
+	var (
+		windows map[int64][]importSample
+		err     error
+	)
+	switch format {
+	case OpenMetricsImportFormat:
+		windows, err = splitTextSamples(textparse.NewOpenMetricsParser(input), blockDuration)
+	case PrometheusImportFormat:
+		windows, err = splitTextSamples(textparse.NewPromParser(input), blockDuration)
+	case CSVImportFormat:
+		windows, err = splitCSVSamples(bytes.NewReader(input), blockDuration)
+	default:
+		return nil, errors.Errorf("unsupported import format %q", format)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "parse input")
+	}
+	if len(windows) == 0 {
+		return nil, errors.New("no samples found in input")
+	}
+
+	starts := make([]int64, 0, len(windows))
+	for start := range windows {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	ids := make([]ulid.ULID, 0, len(starts))
+	for _, start := range starts {
+		id, err := createBlockFromSamples(ctx, logger, windows[start], blockDuration, dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "create block for time range [%d, %d)", start, start+blockDuration)
+		}
+		delete(windows, start)
+
+		if _, err := metadata.InjectThanos(logger, filepath.Join(dir, id.String()), thanosMeta, nil); err != nil {
+			return nil, errors.Wrapf(err, "inject thanos meta into block %v", id)
+		}
+		level.Info(logger).Log("msg", "created block", "id", id, "mint", start, "maxt", start+blockDuration)
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// splitTextSamples returns the samples parsed from the text format grouped by the start of their block duration aligned
+// time range.
+func splitTextSamples(p textparse.Parser, blockDuration int64) (map[int64][]importSample, error) {
+	var (
+		windows = map[int64][]importSample{}
+		// Samples of the same series share their labels.
+		series = map[string]labels.Labels{}
+	)
+	for {
+		entry, err := p.Next()
+		if err == io.EOF {
+			return windows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry != textparse.EntrySeries {
+			continue
+		}
+		s, ts, v := p.Series()
+		if ts == nil {
+			return nil, errors.Errorf("sample %s has no timestamp", s)
+		}
+		lset, ok := series[string(s)]
+		if !ok {
+			p.Metric(&lset)
+			series[string(s)] = lset
+		}
+		start := *ts - mod(*ts, blockDuration)
+		windows[start] = append(windows[start], importSample{lset: lset, t: *ts, v: v})
+	}
+}
+
+// splitCSVSamples returns the samples parsed from CSV grouped by the start of their block duration aligned time range.
+func splitCSVSamples(r io.Reader, blockDuration int64) (map[int64][]importSample, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read header")
+	}
+	var (
+		tsCol, valueCol = -1, -1
+		names           = make([]string, len(header))
+	)
+	for i, name := range header {
+		switch name {
+		case csvTimestampColumn:
+			tsCol = i
+		case csvValueColumn:
+			valueCol = i
+		default:
+			if !model.LabelName(name).IsValid() {
+				return nil, errors.Errorf("invalid label name %q in header", name)
+			}
+			names[i] = name
+		}
+	}
+	if tsCol < 0 || valueCol < 0 {
+		return nil, errors.Errorf("header has to contain %q and %q columns", csvTimestampColumn, csvValueColumn)
+	}
+
+	var (
+		windows = map[int64][]importSample{}
+		series  = map[string]labels.Labels{}
+		key     []byte
+	)
+	for n := 1; ; n++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return windows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ts, err := strconv.ParseInt(record[tsCol], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse timestamp of record %d", n)
+		}
+		v, err := strconv.ParseFloat(record[valueCol], 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse value of record %d", n)
+		}
+
+		key = key[:0]
+		for i, name := range names {
+			if name == "" || record[i] == "" {
+				continue
+			}
+			key = append(append(append(key, name...), '\xff'), record[i]...)
+			key = append(key, '\xff')
+		}
+		if len(key) == 0 {
+			return nil, errors.Errorf("sample of record %d has no labels", n)
+		}
+		lset, ok := series[string(key)]
+		if !ok {
+			b := labels.NewBuilder(nil)
+			for i, name := range names {
+				if name == "" || record[i] == "" {
+					continue
+				}
+				b.Set(name, record[i])
+			}
+			lset = b.Labels()
+			series[string(key)] = lset
+		}
+		start := ts - mod(ts, blockDuration)
+		windows[start] = append(windows[start], importSample{lset: lset, t: ts, v: v})
+	}
+}
+
+// createBlockFromSamples writes the samples into a block.
+func createBlockFromSamples(ctx context.Context, logger log.Logger, samples []importSample, blockDuration int64, dir string) (_ ulid.ULID, err error) {
+	w, err := tsdb.NewBlockWriter(logger, dir, blockDuration)
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "create block writer")
+	}
+	defer runutil.CloseWithErrCapture(&err, w, "close block writer")
+
+	// Samples of a series have to be appended in time order.
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].t < samples[j].t })
+
+	app := w.Appender(ctx)
+	for i, s := range samples {
+		if _, err := app.Add(s.lset, s.t, s.v); err != nil {
+			return ulid.ULID{}, errors.Wrapf(err, "add sample %v at %d", s.lset, s.t)
+		}
+		if (i+1)%importCommitBatch == 0 {
+			if err := app.Commit(); err != nil {
+				return ulid.ULID{}, errors.Wrap(err, "commit")
+			}
+			app = w.Appender(ctx)
+		}
+	}
+	if err := app.Commit(); err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "commit")
+	}
+	return w.Flush(ctx)
+}
+
+// mod returns the non-negative remainder of t divided by d.
+func mod(t, d int64) int64 {
+	r := t % d
+	if r < 0 {
+		r += d
+	}
+	return r
+}
@@ -0,0 +1,166 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package block
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func TestCreateBlocksFromText(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "test-import")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	thanosMeta := metadata.Thanos{
+		Version: metadata.ThanosVersion1,
+		Labels:  map[string]string{"cluster": "eu1"},
+		Source:  metadata.BucketImportSource,
+	}
+
+	// OpenMetrics timestamps are in seconds, so samples are split into two hour blocks [0, 2h) and [2h, 4h).
+	input := []byte(`# TYPE http_requests counter
+http_requests_total{code="200"} 1 0
+http_requests_total{code="200"} 2 3600
+http_requests_total{code="500"} 1 7200.5
+# TYPE up gauge
+up 1 7260
+# EOF
+`)
+	ids, err := CreateBlocksFromText(ctx, log.NewNopLogger(), input, OpenMetricsImportFormat, 2*3600*1000, dir, thanosMeta)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 2, len(ids))
+
+	for i, exp := range []struct {
+		mint, maxt int64
+		series     []labels.Labels
+	}{
+		{mint: 0, maxt: 3600*1000 + 1, series: []labels.Labels{labels.FromStrings("__name__", "http_requests_total", "code", "200")}},
+		{mint: 7200500, maxt: 7260*1000 + 1, series: []labels.Labels{labels.FromStrings("__name__", "http_requests_total", "code", "500"), labels.FromStrings("__name__", "up")}},
+	} {
+		meta, err := metadata.ReadFromDir(filepath.Join(dir, ids[i].String()))
+		testutil.Ok(t, err)
+		testutil.Equals(t, exp.mint, meta.MinTime)
+		testutil.Equals(t, exp.maxt, meta.MaxTime)
+		testutil.Equals(t, thanosMeta, meta.Thanos)
+
+		b, err := tsdb.OpenBlock(nil, filepath.Join(dir, ids[i].String()), nil)
+		testutil.Ok(t, err)
+		ir, err := b.Index()
+		testutil.Ok(t, err)
+		p, err := ir.Postings(index.AllPostingsKey())
+		testutil.Ok(t, err)
+		var series []labels.Labels
+		for p.Next() {
+			var (
+				lset labels.Labels
+				chks []chunks.Meta
+			)
+			testutil.Ok(t, ir.Series(p.At(), &lset, &chks))
+			series = append(series, lset)
+		}
+		testutil.Ok(t, p.Err())
+		testutil.Equals(t, exp.series, series)
+		testutil.Ok(t, ir.Close())
+		testutil.Ok(t, b.Close())
+	}
+
+	// Samples have to have timestamps.
+	_, err = CreateBlocksFromText(ctx, log.NewNopLogger(), []byte("up 1\n"), PrometheusImportFormat, 2*3600*1000, dir, thanosMeta)
+	testutil.NotOk(t, err)
+
+	// Time ranges without samples are skipped.
+	ids, err = CreateBlocksFromText(ctx, log.NewNopLogger(), []byte("up 1 0\nup 1 36000\n# EOF\n"), OpenMetricsImportFormat, 2*3600*1000, dir, thanosMeta)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 2, len(ids))
+	for i, exp := range [][2]int64{{0, 1}, {36000 * 1000, 36000*1000 + 1}} {
+		meta, err := metadata.ReadFromDir(filepath.Join(dir, ids[i].String()))
+		testutil.Ok(t, err)
+		testutil.Equals(t, exp, [2]int64{meta.MinTime, meta.MaxTime})
+		testutil.Equals(t, uint64(1), meta.Stats.NumSamples)
+	}
+}
+
+func TestCreateBlocksFromText_CSV(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "test-import-csv")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	thanosMeta := metadata.Thanos{
+		Version: metadata.ThanosVersion1,
+		Labels:  map[string]string{"cluster": "eu1"},
+		Source:  metadata.BucketImportSource,
+	}
+
+	// Samples don't have to be in time order and empty label values are dropped.
+	input := []byte(`__name__,timestamp,code,value
+http_requests_total,7200000,200,3
+http_requests_total,0,200,1
+http_requests_total,3600000,200,2
+up,7260000,,1
+`)
+	ids, err := CreateBlocksFromText(ctx, log.NewNopLogger(), input, CSVImportFormat, 2*3600*1000, dir, thanosMeta)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 2, len(ids))
+
+	for i, exp := range []struct {
+		mint, maxt int64
+		samples    uint64
+		series     []labels.Labels
+	}{
+		{mint: 0, maxt: 3600*1000 + 1, samples: 2, series: []labels.Labels{labels.FromStrings("__name__", "http_requests_total", "code", "200")}},
+		{mint: 7200000, maxt: 7260*1000 + 1, samples: 2, series: []labels.Labels{labels.FromStrings("__name__", "http_requests_total", "code", "200"), labels.FromStrings("__name__", "up")}},
+	} {
+		meta, err := metadata.ReadFromDir(filepath.Join(dir, ids[i].String()))
+		testutil.Ok(t, err)
+		testutil.Equals(t, exp.mint, meta.MinTime)
+		testutil.Equals(t, exp.maxt, meta.MaxTime)
+		testutil.Equals(t, exp.samples, meta.Stats.NumSamples)
+
+		b, err := tsdb.OpenBlock(nil, filepath.Join(dir, ids[i].String()), nil)
+		testutil.Ok(t, err)
+		ir, err := b.Index()
+		testutil.Ok(t, err)
+		p, err := ir.Postings(index.AllPostingsKey())
+		testutil.Ok(t, err)
+		var series []labels.Labels
+		for p.Next() {
+			var (
+				lset labels.Labels
+				chks []chunks.Meta
+			)
+			testutil.Ok(t, ir.Series(p.At(), &lset, &chks))
+			series = append(series, lset)
+		}
+		testutil.Ok(t, p.Err())
+		testutil.Equals(t, exp.series, series)
+		testutil.Ok(t, ir.Close())
+		testutil.Ok(t, b.Close())
+	}
+
+	for _, input := range []string{
+		"__name__,value\nup,1\n",
+		"__name__,timestamp,value\nup,1.5,1\n",
+		"__name__,timestamp,value\nup,1,one\n",
+		"__name__,timestamp,value\n,1,1\n",
+		"1abc,timestamp,value\nup,1,1\n",
+	} {
+		_, err = CreateBlocksFromText(ctx, log.NewNopLogger(), []byte(input), CSVImportFormat, 2*3600*1000, dir, thanosMeta)
+		testutil.NotOk(t, err, input)
+	}
+}
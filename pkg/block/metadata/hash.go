@@ -46,9 +46,19 @@ func CalculateHash(p string, hf HashFunc, logger log.Logger) (ObjectHash, error)
 		}
 		defer runutil.CloseWithLogOnErr(logger, f, "closing %s", p)
 
+		return CalculateHashFromReader(f, hf)
+	}
+	return ObjectHash{}, fmt.Errorf("hash function %v is not supported", hf)
+
+}
+
+// CalculateHashFromReader calculates the hash of the given type of all data read from r, e.g. an object from the object storage.
+func CalculateHashFromReader(r io.Reader, hf HashFunc) (ObjectHash, error) {
+	switch hf {
+	case SHA256Func:
 		h := sha256.New()
 
-		if _, err := io.Copy(h, f); err != nil {
+		if _, err := io.Copy(h, r); err != nil {
 			return ObjectHash{}, errors.Wrap(err, "copying")
 		}
 
@@ -58,5 +68,4 @@ func CalculateHash(p string, hf HashFunc, logger log.Logger) (ObjectHash, error)
 		}, nil
 	}
 	return ObjectHash{}, fmt.Errorf("hash function %v is not supported", hf)
-
 }
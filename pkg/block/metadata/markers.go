@@ -23,11 +23,21 @@ const (
 	// NoCompactMarkFilename is the known json filename for optional file storing details about why block has to be excluded from compaction.
 	// If such file is present in block dir, it means the block has to excluded from compaction (both vertical and horizontal) or rewrite (e.g deletions).
 	NoCompactMarkFilename = "no-compact-mark.json"
+	// RewrittenMarkFilename is the known json filename for optional file storing details about the rewrite of the block.
+	// If such file is present in block dir, it means the block was already rewritten into a new block by bucket rewrite.
+	RewrittenMarkFilename = "rewritten-mark.json"
+	// ReplicatedMarkFilename is the known json filename for optional file storing details about the replication of the block.
+	// If such file is present in block dir, it means the block was copied from another bucket by bucket replicate.
+	ReplicatedMarkFilename = "replicated-mark.json"
 
 	// DeletionMarkVersion1 is the version of deletion-mark file supported by Thanos.
 	DeletionMarkVersion1 = 1
 	// NoCompactMarkVersion1 is the version of no-compact-mark file supported by Thanos.
 	NoCompactMarkVersion1 = 1
+	// RewrittenMarkVersion1 is the version of rewritten-mark file supported by Thanos.
+	RewrittenMarkVersion1 = 1
+	// ReplicatedMarkVersion1 is the version of replicated-mark file supported by Thanos.
+	ReplicatedMarkVersion1 = 1
 )
 
 var (
@@ -85,6 +95,36 @@ type NoCompactMark struct {
 
 func (n *NoCompactMark) markerFilename() string { return NoCompactMarkFilename }
 
+// RewrittenMark stores block id and the details of its rewrite.
+type RewrittenMark struct {
+	// ID of the tsdb block.
+	ID ulid.ULID `json:"id"`
+	// Version of the file.
+	Version int `json:"version"`
+	// NewID is the ID of the block produced by the rewrite.
+	NewID ulid.ULID `json:"new_id"`
+	// RewriteHash identifies the rewrite configuration (deletions, relabels) applied to the block.
+	RewriteHash string `json:"rewrite_hash"`
+
+	// RewriteTime is a unix timestamp of when the block was rewritten.
+	RewriteTime int64 `json:"rewrite_time"`
+}
+
+func (r *RewrittenMark) markerFilename() string { return RewrittenMarkFilename }
+
+// ReplicatedMark stores block id and when block was replicated from another bucket.
+type ReplicatedMark struct {
+	// ID of the tsdb block.
+	ID ulid.ULID `json:"id"`
+	// Version of the file.
+	Version int `json:"version"`
+
+	// ReplicateTime is a unix timestamp of when the block was replicated.
+	ReplicateTime int64 `json:"replicate_time"`
+}
+
+func (r *ReplicatedMark) markerFilename() string { return ReplicatedMarkFilename }
+
 // ReadMarker reads the given mark file from <dir>/<marker filename>.json in bucket.
 func ReadMarker(ctx context.Context, logger log.Logger, bkt objstore.InstrumentedBucketReader, dir string, marker Marker) error {
 	markerFile := path.Join(dir, marker.markerFilename())
@@ -114,6 +154,14 @@ func ReadMarker(ctx context.Context, logger log.Logger, bkt objstore.Instrumente
 		if version := marker.(*DeletionMark).Version; version != DeletionMarkVersion1 {
 			return errors.Errorf("unexpected deletion-mark file version %d, expected %d", version, DeletionMarkVersion1)
 		}
+	case RewrittenMarkFilename:
+		if version := marker.(*RewrittenMark).Version; version != RewrittenMarkVersion1 {
+			return errors.Errorf("unexpected rewritten-mark file version %d, expected %d", version, RewrittenMarkVersion1)
+		}
+	case ReplicatedMarkFilename:
+		if version := marker.(*ReplicatedMark).Version; version != ReplicatedMarkVersion1 {
+			return errors.Errorf("unexpected replicated-mark file version %d, expected %d", version, ReplicatedMarkVersion1)
+		}
 	}
 	return nil
 }
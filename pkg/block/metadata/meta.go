@@ -19,6 +19,7 @@ import (
 	"github.com/oklog/ulid"
 	"github.com/pkg/errors"
 	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/prometheus/prometheus/tsdb/fileutil"
@@ -39,6 +40,7 @@ const (
 	RulerSource           SourceType = "ruler"
 	BucketRepairSource    SourceType = "bucket.repair"
 	BucketRewriteSource   SourceType = "bucket.rewrite"
+	BucketImportSource    SourceType = "bucket.import"
 	TestSource            SourceType = "test"
 )
 
@@ -88,6 +90,17 @@ type Thanos struct {
 
 	// Rewrites is present when any rewrite (deletion, relabel etc) were applied to this block. Optional.
 	Rewrites []Rewrite `json:"rewrites,omitempty"`
+
+	// OverlapMerges is present when overlapping blocks of the same stream were merged into this block or its parents. Optional.
+	OverlapMerges []OverlapMerge `json:"overlap_merges,omitempty"`
+}
+
+// OverlapMerge describes a compaction of overlapping blocks of the same stream.
+type OverlapMerge struct {
+	// ULIDs of the overlapping blocks which were merged.
+	Blocks []ulid.ULID `json:"blocks"`
+	// ULIDs of all source head blocks of the merged blocks.
+	Sources []ulid.ULID `json:"sources"`
 }
 
 type Rewrite struct {
@@ -95,6 +108,8 @@ type Rewrite struct {
 	Sources []ulid.ULID `json:"sources,omitempty"`
 	// Deletions if applied (in order).
 	DeletionsApplied []DeletionRequest `json:"deletions_applied,omitempty"`
+	// Relabels if applied.
+	RelabelsApplied []*relabel.Config `json:"relabels_applied,omitempty"`
 }
 
 type Matchers []*labels.Matcher
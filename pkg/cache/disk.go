@@ -0,0 +1,583 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	lru "github.com/hashicorp/golang-lru/simplelru"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gopkg.in/yaml.v2"
+
+	"github.com/thanos-io/thanos/pkg/model"
+)
+
+var (
+	DefaultDiskCacheConfig = DiskCacheConfig{
+		MaxSize:             10 * 1024 * 1024 * 1024,
+		MaxItemSize:         125 * 1024 * 1024,
+		MaxAsyncConcurrency: 10,
+		MaxAsyncBufferSize:  10000,
+	}
+)
+
+const (
+	diskCacheTmpSuffix = ".tmp"
+
+	// Disk cache item layout:
+	// magic (4 bytes) | CRC32 of the rest of the item (4 bytes) | expiry in unix nanoseconds (8 bytes) |
+	// key length (4 bytes) | key | data.
+	diskCacheMagic      = 0x7DCAC4E1
+	diskCacheHeaderSize = 4 + 4 + 8 + 4
+)
+
+var diskCacheCastagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// DiskCacheConfig holds the disk cache config.
+type DiskCacheConfig struct {
+	// Directory is the local directory where cached items are stored.
+	Directory string `yaml:"directory"`
+	// MaxSize represents overall maximum number of bytes cache can contain on disk.
+	MaxSize model.Bytes `yaml:"max_size"`
+	// MaxItemSize represents maximum size of single item.
+	MaxItemSize model.Bytes `yaml:"max_item_size"`
+	// MaxAsyncConcurrency specifies the maximum number of goroutines writing items to disk.
+	MaxAsyncConcurrency int `yaml:"max_async_concurrency"`
+	// MaxAsyncBufferSize specifies the queue buffer size of items to be written to disk.
+	// Items stored while the queue is full are dropped.
+	MaxAsyncBufferSize int `yaml:"max_async_buffer_size"`
+}
+
+// DiskCache is a LRU cache storing items as files in a local directory. Cached items
+// survive restarts: the LRU is rebuilt from the directory content on startup, using files
+// modification time as recency. Every item carries a checksum which is verified on fetch,
+// corrupted items are treated as a miss and removed. Items are written to disk asynchronously.
+type DiskCache struct {
+	logger           log.Logger
+	dir              string
+	maxSizeBytes     uint64
+	maxItemSizeBytes uint64
+
+	mtx     sync.Mutex
+	curSize uint64
+	// lru maps item file names to their size on disk.
+	lru *lru.LRU
+	// evictedNames are names of items evicted while mtx is held, whose files are removed once it is released.
+	evictedNames []string
+
+	// Channel used to enqueue writes of items.
+	asyncQueue          chan func()
+	maxAsyncConcurrency int
+	// asyncMtx guards workers, the number of goroutines processing the queue. They are started
+	// when items are enqueued and exit once the queue is empty, so that no goroutines are left idle.
+	asyncMtx sync.Mutex
+	workers  int
+	// Wait group used to wait for enqueued writes.
+	pending sync.WaitGroup
+
+	evicted     prometheus.Counter
+	requests    prometheus.Counter
+	hits        prometheus.Counter
+	hitsExpired prometheus.Counter
+	corrupted   prometheus.Counter
+	added       prometheus.Counter
+	current     prometheus.Gauge
+	currentSize prometheus.Gauge
+	overflow    prometheus.Counter
+	skipped     prometheus.Counter
+}
+
+// parseDiskCacheConfig unmarshals a buffer into a DiskCacheConfig with default values.
+func parseDiskCacheConfig(conf []byte) (DiskCacheConfig, error) {
+	config := DefaultDiskCacheConfig
+	if err := yaml.Unmarshal(conf, &config); err != nil {
+		return DiskCacheConfig{}, err
+	}
+
+	return config, nil
+}
+
+// NewDiskCache creates a new thread-safe disk backed LRU cache and ensures the total cache
+// size approximately does not exceed maxBytes.
+func NewDiskCache(name string, logger log.Logger, reg prometheus.Registerer, conf []byte) (*DiskCache, error) {
+	config, err := parseDiskCacheConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDiskCacheWithConfig(name, logger, reg, config)
+}
+
+// NewDiskCacheWithConfig creates a new thread-safe disk backed LRU cache and ensures the total cache
+// size approximately does not exceed maxBytes. Items already present in the directory are loaded.
+func NewDiskCacheWithConfig(name string, logger log.Logger, reg prometheus.Registerer, config DiskCacheConfig) (*DiskCache, error) {
+	if config.Directory == "" {
+		return nil, errors.New("no directory specified for disk cache")
+	}
+	if config.MaxItemSize > config.MaxSize {
+		return nil, errors.Errorf("max item size (%v) cannot be bigger than overall cache size (%v)", config.MaxItemSize, config.MaxSize)
+	}
+	if config.MaxAsyncConcurrency <= 0 {
+		return nil, errors.New("max async concurrency must be positive")
+	}
+	if config.MaxAsyncBufferSize <= 0 {
+		return nil, errors.New("max async buffer size must be positive")
+	}
+
+	c := &DiskCache{
+		logger:              logger,
+		dir:                 config.Directory,
+		maxSizeBytes:        uint64(config.MaxSize),
+		maxItemSizeBytes:    uint64(config.MaxItemSize),
+		asyncQueue:          make(chan func(), config.MaxAsyncBufferSize),
+		maxAsyncConcurrency: config.MaxAsyncConcurrency,
+	}
+
+	c.evicted = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_disk_items_evicted_total",
+		Help:        "Total number of items that were evicted from the disk cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+
+	c.added = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_disk_items_added_total",
+		Help:        "Total number of items that were added to the disk cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+
+	c.requests = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_disk_requests_total",
+		Help:        "Total number of requests to the disk cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+
+	c.hitsExpired = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_disk_hits_on_expired_data_total",
+		Help:        "Total number of requests to the disk cache that were a hit but needed to be evicted due to TTL.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+
+	c.corrupted = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_disk_corrupted_items_total",
+		Help:        "Total number of items read from the disk cache that failed checksum validation.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+
+	c.overflow = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_disk_items_overflowed_total",
+		Help:        "Total number of items that could not be added to the disk cache due to being too big.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+
+	c.skipped = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_disk_items_skipped_total",
+		Help:        "Total number of items that were not added to the disk cache because the async buffer was full.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+
+	c.hits = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_disk_hits_total",
+		Help:        "Total number of requests to the disk cache that were a hit.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+
+	c.current = promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+		Name:        "thanos_cache_disk_items",
+		Help:        "Current number of items in the disk cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+
+	c.currentSize = promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+		Name:        "thanos_cache_disk_items_size_bytes",
+		Help:        "Current byte size of items files in the disk cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+
+	_ = promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "thanos_cache_disk_max_size_bytes",
+		Help:        "Maximum number of bytes to be held in the disk cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	}, func() float64 {
+		return float64(c.maxSizeBytes)
+	})
+	_ = promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "thanos_cache_disk_max_item_size_bytes",
+		Help:        "Maximum number of bytes for single entry to be held in the disk cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	}, func() float64 {
+		return float64(c.maxItemSizeBytes)
+	})
+
+	// Initialize LRU cache with a high size limit since we will manage evictions ourselves
+	// based on stored size using `RemoveOldest` method.
+	l, err := lru.NewLRU(maxInt, c.onEvict)
+	if err != nil {
+		return nil, err
+	}
+	c.lru = l
+
+	if err := c.load(); err != nil {
+		return nil, errors.Wrap(err, "load disk cache")
+	}
+
+	level.Info(logger).Log(
+		"msg", "created disk cache",
+		"dir", c.dir,
+		"maxItemSizeBytes", c.maxItemSizeBytes,
+		"maxSizeBytes", c.maxSizeBytes,
+		"loadedItems", c.lru.Len(),
+		"loadedSizeBytes", c.curSize,
+	)
+	return c, nil
+}
+
+// load populates the LRU with items found in the cache directory, the least recently
+// modified first. Leftovers of interrupted writes are removed.
+func (c *DiskCache) load() error {
+	if err := os.MkdirAll(c.dir, os.ModePerm); err != nil {
+		return errors.Wrap(err, "create dir")
+	}
+
+	type item struct {
+		name    string
+		size    uint64
+		modTime time.Time
+	}
+	var items []item
+
+	if err := filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if strings.HasSuffix(path, diskCacheTmpSuffix) {
+			return os.Remove(path)
+		}
+		if !isDiskCacheFileName(info.Name()) {
+			return nil
+		}
+		items = append(items, item{name: info.Name(), size: uint64(info.Size()), modTime: info.ModTime()})
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "walk dir")
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].modTime.Before(items[j].modTime)
+	})
+
+	c.mtx.Lock()
+	defer c.unlock()
+
+	for _, it := range items {
+		c.lru.Add(it.name, it.size)
+		c.current.Inc()
+		c.currentSize.Add(float64(it.size))
+		c.curSize += it.size
+	}
+	// The cache may have been restarted with a lower max size.
+	for c.curSize > c.maxSizeBytes {
+		if _, _, ok := c.lru.RemoveOldest(); !ok {
+			break
+		}
+	}
+	return nil
+}
+
+// onEvict is called by the LRU with mtx held. The item file is removed by unlock, to not block other operations on disk I/O.
+func (c *DiskCache) onEvict(key, val interface{}) {
+	size := val.(uint64)
+
+	c.evictedNames = append(c.evictedNames, key.(string))
+
+	c.evicted.Inc()
+	c.current.Dec()
+	c.currentSize.Sub(float64(size))
+
+	c.curSize -= size
+}
+
+// unlock releases mtx and removes files of the items evicted while it was held.
+func (c *DiskCache) unlock() {
+	names := c.evictedNames
+	c.evictedNames = nil
+	c.mtx.Unlock()
+
+	for _, name := range names {
+		c.removeFile(name)
+	}
+}
+
+func (c *DiskCache) removeFile(name string) {
+	if err := os.Remove(c.path(name)); err != nil && !os.IsNotExist(err) {
+		level.Warn(c.logger).Log("msg", "failed to remove evicted item from disk cache", "err", err)
+	}
+}
+
+// diskCacheFileName returns the name of the file holding the item identified by key.
+func diskCacheFileName(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+func isDiskCacheFileName(name string) bool {
+	b, err := hex.DecodeString(name)
+	return err == nil && len(b) == sha256.Size
+}
+
+// path returns the path of the given item file. Files are spread across sub-directories
+// to avoid having huge directories.
+func (c *DiskCache) path(name string) string {
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+func (c *DiskCache) get(key string) ([]byte, bool) {
+	c.requests.Inc()
+	name := diskCacheFileName(key)
+
+	c.mtx.Lock()
+	_, ok := c.lru.Get(name)
+	c.mtx.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	path := c.path(name)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		// The item may be still being written or just evicted.
+		if !os.IsNotExist(err) {
+			level.Warn(c.logger).Log("msg", "failed to read item from disk cache", "err", err)
+		}
+		return nil, false
+	}
+
+	data, expiryTime, err := decodeDiskCacheItem(key, b)
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "removing corrupted item from disk cache", "file", path, "err", err)
+		c.corrupted.Inc()
+		c.remove(name)
+		return nil, false
+	}
+	// If the present time is greater than the TTL for the object from cache, the object will be
+	// removed from the cache and a nil will be returned.
+	now := time.Now()
+	if now.After(expiryTime) {
+		c.hitsExpired.Inc()
+		c.remove(name)
+		return nil, false
+	}
+
+	// Persist the recency of the item, so that the LRU order survives restarts.
+	if err := os.Chtimes(path, now, now); err != nil && !os.IsNotExist(err) {
+		level.Debug(c.logger).Log("msg", "failed to update disk cache item modification time", "err", err)
+	}
+
+	c.hits.Inc()
+	return data, true
+}
+
+func (c *DiskCache) remove(name string) {
+	c.mtx.Lock()
+	defer c.unlock()
+
+	c.lru.Remove(name)
+}
+
+func (c *DiskCache) set(key string, val []byte, expiryTime time.Time) {
+	name := diskCacheFileName(key)
+	b := encodeDiskCacheItem(key, val, expiryTime)
+	size := uint64(len(b))
+
+	c.mtx.Lock()
+	if _, ok := c.lru.Get(name); ok {
+		c.unlock()
+		return
+	}
+
+	if !c.ensureFits(size) {
+		c.unlock()
+		c.overflow.Inc()
+		return
+	}
+
+	// Reserve the space before writing the file, so that concurrent writers don't exceed the max size.
+	c.lru.Add(name, size)
+	c.added.Inc()
+	c.current.Inc()
+	c.currentSize.Add(float64(size))
+	c.curSize += size
+	c.unlock()
+
+	if err := c.write(name, b); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to write item to disk cache", "err", err)
+		c.remove(name)
+		return
+	}
+
+	// The item may have been evicted while being written: make sure we don't leave it orphaned.
+	c.mtx.Lock()
+	evicted := !c.lru.Contains(name)
+	c.unlock()
+	if evicted {
+		c.removeFile(name)
+	}
+}
+
+// write atomically writes the item file.
+func (c *DiskCache) write(name string, b []byte) (err error) {
+	path := c.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return errors.Wrap(err, "create dir")
+	}
+
+	tmp := path + diskCacheTmpSuffix
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return errors.Wrap(err, "write file")
+	}
+	return errors.Wrap(os.Rename(tmp, path), "rename file")
+}
+
+// ensureFits tries to make sure that the passed item will fit into the LRU cache.
+// Returns true if it will fit.
+func (c *DiskCache) ensureFits(size uint64) bool {
+	if size > c.maxSizeBytes {
+		level.Debug(c.logger).Log(
+			"msg", "item bigger than maxSizeBytes. Ignoring..",
+			"maxItemSizeBytes", c.maxItemSizeBytes,
+			"maxSizeBytes", c.maxSizeBytes,
+			"curSize", c.curSize,
+			"itemSize", size,
+		)
+		return false
+	}
+
+	for c.curSize+size > c.maxSizeBytes {
+		if _, _, ok := c.lru.RemoveOldest(); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Store enqueues the items to be written to disk. Items are dropped if the async buffer is full.
+func (c *DiskCache) Store(ctx context.Context, data map[string][]byte, ttl time.Duration) {
+	expiryTime := time.Now().Add(ttl)
+	for key, val := range data {
+		if uint64(len(val)) > c.maxItemSizeBytes {
+			c.overflow.Inc()
+			continue
+		}
+
+		key, val := key, val
+		if !c.enqueueAsync(func() { c.set(key, val, expiryTime) }) {
+			c.skipped.Inc()
+			level.Debug(c.logger).Log("msg", "failed to store item to disk cache because the async buffer is full", "size", len(c.asyncQueue))
+		}
+	}
+}
+
+// enqueueAsync enqueues the operation and starts a worker if needed. Returns false if the async buffer is full.
+func (c *DiskCache) enqueueAsync(op func()) bool {
+	c.asyncMtx.Lock()
+	defer c.asyncMtx.Unlock()
+
+	c.pending.Add(1)
+	select {
+	case c.asyncQueue <- op:
+	default:
+		c.pending.Done()
+		return false
+	}
+	if c.workers < c.maxAsyncConcurrency {
+		c.workers++
+		go c.asyncQueueProcessLoop()
+	}
+	return true
+}
+
+func (c *DiskCache) asyncQueueProcessLoop() {
+	for {
+		select {
+		case op := <-c.asyncQueue:
+			op()
+			c.pending.Done()
+		default:
+			c.asyncMtx.Lock()
+			// Operations are enqueued with asyncMtx held, so none can be missed.
+			if len(c.asyncQueue) == 0 {
+				c.workers--
+				c.asyncMtx.Unlock()
+				return
+			}
+			c.asyncMtx.Unlock()
+		}
+	}
+}
+
+// Fetch fetches multiple keys and returns a map containing cache hits
+// In case of error, it logs and return an empty cache hits map.
+func (c *DiskCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	results := make(map[string][]byte)
+	for _, key := range keys {
+		if b, ok := c.get(key); ok {
+			results[key] = b
+		}
+	}
+	return results
+}
+
+func encodeDiskCacheItem(key string, val []byte, expiryTime time.Time) []byte {
+	b := make([]byte, diskCacheHeaderSize+len(key)+len(val))
+	binary.BigEndian.PutUint32(b[0:4], diskCacheMagic)
+	binary.BigEndian.PutUint64(b[8:16], uint64(expiryTime.UnixNano()))
+	binary.BigEndian.PutUint32(b[16:20], uint32(len(key)))
+	copy(b[diskCacheHeaderSize:], key)
+	copy(b[diskCacheHeaderSize+len(key):], val)
+	binary.BigEndian.PutUint32(b[4:8], crc32.Checksum(b[8:], diskCacheCastagnoli))
+	return b
+}
+
+// decodeDiskCacheItem validates the item read from disk for the given key and returns its data and expiry time.
+func decodeDiskCacheItem(key string, b []byte) ([]byte, time.Time, error) {
+	if len(b) < diskCacheHeaderSize {
+		return nil, time.Time{}, errors.Errorf("item too short: %d bytes", len(b))
+	}
+	if m := binary.BigEndian.Uint32(b[0:4]); m != diskCacheMagic {
+		return nil, time.Time{}, errors.Errorf("invalid magic number %x", m)
+	}
+	if exp, act := binary.BigEndian.Uint32(b[4:8]), crc32.Checksum(b[8:], diskCacheCastagnoli); exp != act {
+		return nil, time.Time{}, errors.Errorf("checksum mismatch: expected %x, got %x", exp, act)
+	}
+	keyLen := int(binary.BigEndian.Uint32(b[16:20]))
+	if len(b) < diskCacheHeaderSize+keyLen {
+		return nil, time.Time{}, errors.Errorf("invalid key length %d", keyLen)
+	}
+	if k := string(b[diskCacheHeaderSize : diskCacheHeaderSize+keyLen]); k != key {
+		return nil, time.Time{}, errors.Errorf("key mismatch: expected %q, got %q", key, k)
+	}
+	return b[diskCacheHeaderSize+keyLen:], time.Unix(0, int64(binary.BigEndian.Uint64(b[8:16]))), nil
+}
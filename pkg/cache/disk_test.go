@@ -0,0 +1,227 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/thanos-io/thanos/pkg/model"
+	"github.com/thanos-io/thanos/pkg/runutil"
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func newTestDiskCache(t *testing.T, dir string, maxSize, maxItemSize uint64) *DiskCache {
+	config := DefaultDiskCacheConfig
+	config.Directory = dir
+	config.MaxSize = model.Bytes(maxSize)
+	config.MaxItemSize = model.Bytes(maxItemSize)
+	c, err := NewDiskCacheWithConfig("test", log.NewNopLogger(), nil, config)
+	testutil.Ok(t, err)
+	return c
+}
+
+// store stores the items into the cache and waits for them to be written.
+func store(c *DiskCache, data map[string][]byte, ttl time.Duration) {
+	c.Store(context.Background(), data, ttl)
+	c.pending.Wait()
+}
+
+func TestDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_disk_cache")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	ctx := context.Background()
+	c := newTestDiskCache(t, dir, 1024*1024, 1024)
+
+	store(c, map[string][]byte{
+		"key1":    []byte("value1"),
+		"key/2":   []byte("value2"),
+		"too-big": make([]byte, 2048),
+	}, time.Hour)
+	store(c, map[string][]byte{"expiring": []byte("value3")}, -time.Second)
+
+	hits := c.Fetch(ctx, []string{"key1", "key/2", "key3", "too-big", "expiring"})
+	testutil.Equals(t, map[string][]byte{"key1": []byte("value1"), "key/2": []byte("value2")}, hits)
+
+	testutil.Equals(t, 5.0, prom_testutil.ToFloat64(c.requests))
+	testutil.Equals(t, 2.0, prom_testutil.ToFloat64(c.hits))
+	testutil.Equals(t, 1.0, prom_testutil.ToFloat64(c.overflow))
+	testutil.Equals(t, 1.0, prom_testutil.ToFloat64(c.hitsExpired))
+}
+
+func TestDiskCache_Expiry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_disk_cache")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	ctx := context.Background()
+	c := newTestDiskCache(t, dir, 1024*1024, 1024)
+
+	store(c, map[string][]byte{"key1": []byte("value1")}, 100*time.Millisecond)
+	testutil.Equals(t, map[string][]byte{"key1": []byte("value1")}, c.Fetch(ctx, []string{"key1"}))
+
+	time.Sleep(200 * time.Millisecond)
+	testutil.Equals(t, map[string][]byte{}, c.Fetch(ctx, []string{"key1"}))
+	testutil.Equals(t, 1.0, prom_testutil.ToFloat64(c.hitsExpired))
+	testutil.Equals(t, 0.0, prom_testutil.ToFloat64(c.current))
+
+	// The item file is removed.
+	_, err = os.Stat(c.path(diskCacheFileName("key1")))
+	testutil.Assert(t, os.IsNotExist(err), "expected item file to be removed, got %v", err)
+}
+
+func TestDiskCache_Eviction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_disk_cache")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	ctx := context.Background()
+	// Each item takes the header, a 4 bytes key and 100 bytes of data: only two fit.
+	itemSize := uint64(diskCacheHeaderSize + 4 + 100)
+	c := newTestDiskCache(t, dir, 2*itemSize+10, 100)
+
+	store(c, map[string][]byte{"key1": make([]byte, 100)}, time.Hour)
+	store(c, map[string][]byte{"key2": make([]byte, 100)}, time.Hour)
+	// Make key1 the most recently used.
+	testutil.Equals(t, 1, len(c.Fetch(ctx, []string{"key1"})))
+	store(c, map[string][]byte{"key3": make([]byte, 100)}, time.Hour)
+
+	testutil.Equals(t, []string{"key1", "key3"}, sortedKeys(c.Fetch(ctx, []string{"key1", "key2", "key3"})))
+	testutil.Equals(t, 1.0, prom_testutil.ToFloat64(c.evicted))
+	testutil.Equals(t, 2.0, prom_testutil.ToFloat64(c.current))
+	testutil.Equals(t, float64(2*itemSize), prom_testutil.ToFloat64(c.currentSize))
+
+	_, err = os.Stat(c.path(diskCacheFileName("key2")))
+	testutil.Assert(t, os.IsNotExist(err), "expected evicted item file to be removed, got %v", err)
+}
+
+func TestDiskCache_PersistsAcrossRestarts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_disk_cache")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	ctx := context.Background()
+	itemSize := uint64(diskCacheHeaderSize + 4 + 100)
+	c := newTestDiskCache(t, dir, 3*itemSize, 100)
+
+	store(c, map[string][]byte{"key1": make([]byte, 100)}, time.Hour)
+	store(c, map[string][]byte{"key2": make([]byte, 100)}, time.Hour)
+	store(c, map[string][]byte{"key3": make([]byte, 100)}, time.Hour)
+
+	// Make sure the recency order is key2, key3, key1 regardless of the file system time granularity.
+	now := time.Now()
+	testutil.Ok(t, os.Chtimes(c.path(diskCacheFileName("key2")), now.Add(-3*time.Minute), now.Add(-3*time.Minute)))
+	testutil.Ok(t, os.Chtimes(c.path(diskCacheFileName("key3")), now.Add(-2*time.Minute), now.Add(-2*time.Minute)))
+	testutil.Ok(t, os.Chtimes(c.path(diskCacheFileName("key1")), now.Add(-1*time.Minute), now.Add(-1*time.Minute)))
+
+	// Leftover of an interrupted write.
+	tmp := c.path(diskCacheFileName("key4")) + diskCacheTmpSuffix
+	testutil.Ok(t, os.MkdirAll(filepath.Dir(tmp), os.ModePerm))
+	testutil.Ok(t, ioutil.WriteFile(tmp, []byte("partial"), 0600))
+
+	// Restart with a smaller cache: the least recently used item is evicted.
+	c = newTestDiskCache(t, dir, 2*itemSize, 100)
+	testutil.Equals(t, 2.0, prom_testutil.ToFloat64(c.current))
+	testutil.Equals(t, []string{"key1", "key3"}, sortedKeys(c.Fetch(ctx, []string{"key1", "key2", "key3"})))
+
+	_, err = os.Stat(tmp)
+	testutil.Assert(t, os.IsNotExist(err), "expected temporary file to be removed, got %v", err)
+}
+
+func TestDiskCache_Corrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_disk_cache")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	ctx := context.Background()
+	c := newTestDiskCache(t, dir, 1024*1024, 1024)
+	store(c, map[string][]byte{"key1": []byte("value1"), "key2": []byte("value2")}, time.Hour)
+
+	// Flip the last byte of key1 data.
+	path := c.path(diskCacheFileName("key1"))
+	b, err := ioutil.ReadFile(path)
+	testutil.Ok(t, err)
+	b[len(b)-1]++
+	testutil.Ok(t, ioutil.WriteFile(path, b, 0600))
+
+	testutil.Equals(t, map[string][]byte{"key2": []byte("value2")}, c.Fetch(ctx, []string{"key1", "key2"}))
+	testutil.Equals(t, 1.0, prom_testutil.ToFloat64(c.corrupted))
+	testutil.Equals(t, 1.0, prom_testutil.ToFloat64(c.current))
+
+	_, err = os.Stat(path)
+	testutil.Assert(t, os.IsNotExist(err), "expected corrupted item file to be removed, got %v", err)
+}
+
+func TestNewDiskCache(t *testing.T) {
+	// Should return error when no directory is configured.
+	_, err := NewDiskCache("test", log.NewNopLogger(), nil, []byte(`max_size: 1MB`))
+	testutil.NotOk(t, err)
+
+	// Should return error when the max size of the cache is smaller than the max size of an item.
+	_, err = NewDiskCache("test", log.NewNopLogger(), nil, []byte(`
+directory: /tmp/unused
+max_size: 2KB
+max_item_size: 1MB
+`))
+	testutil.NotOk(t, err)
+
+	// Should return error when the max async concurrency is not positive.
+	_, err = NewDiskCache("test", log.NewNopLogger(), nil, []byte(`
+directory: /tmp/unused
+max_async_concurrency: 0
+`))
+	testutil.NotOk(t, err)
+}
+
+func TestDiskCache_AsyncBufferFull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_disk_cache")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	ctx := context.Background()
+	config := DefaultDiskCacheConfig
+	config.Directory = dir
+	config.MaxAsyncConcurrency = 1
+	config.MaxAsyncBufferSize = 1
+	c, err := NewDiskCacheWithConfig("test", log.NewNopLogger(), nil, config)
+	testutil.Ok(t, err)
+
+	// Block the only worker, so that a single item fits in the buffer.
+	block := make(chan struct{})
+	testutil.Assert(t, c.enqueueAsync(func() { <-block }), "expected blocking operation to be enqueued")
+	testutil.Ok(t, runutil.Retry(time.Millisecond, ctx.Done(), func() error {
+		if len(c.asyncQueue) > 0 {
+			return errors.New("worker is not blocked yet")
+		}
+		return nil
+	}))
+
+	c.Store(ctx, map[string][]byte{"key1": []byte("value1")}, time.Hour)
+	c.Store(ctx, map[string][]byte{"key2": []byte("value2")}, time.Hour)
+	close(block)
+	c.pending.Wait()
+
+	testutil.Equals(t, map[string][]byte{"key1": []byte("value1")}, c.Fetch(ctx, []string{"key1", "key2"}))
+	testutil.Equals(t, 1.0, prom_testutil.ToFloat64(c.skipped))
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
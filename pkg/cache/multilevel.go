@@ -0,0 +1,61 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// MultiLevelCache chains multiple caches, from the fastest to the slowest one.
+// Fetch looks up each level in turn for the keys still missing, and back-fills hits
+// into the faster levels. Store writes to all levels.
+type MultiLevelCache struct {
+	levels      []Cache
+	backfillTTL time.Duration
+}
+
+// NewMultiLevelCache returns a cache made of the given levels. Since the original TTL of an item
+// is unknown when it's found in a slower level, items are back-filled using backfillTTL.
+func NewMultiLevelCache(backfillTTL time.Duration, levels ...Cache) *MultiLevelCache {
+	return &MultiLevelCache{levels: levels, backfillTTL: backfillTTL}
+}
+
+func (c *MultiLevelCache) Store(ctx context.Context, data map[string][]byte, ttl time.Duration) {
+	for _, l := range c.levels {
+		l.Store(ctx, data, ttl)
+	}
+}
+
+// Fetch fetches multiple keys and returns a map containing cache hits from any level.
+func (c *MultiLevelCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	results := make(map[string][]byte, len(keys))
+	missing := keys
+
+	for i, l := range c.levels {
+		if len(missing) == 0 {
+			break
+		}
+
+		hits := l.Fetch(ctx, missing)
+		if len(hits) == 0 {
+			continue
+		}
+		for k, v := range hits {
+			results[k] = v
+		}
+		for _, upper := range c.levels[:i] {
+			upper.Store(ctx, hits, c.backfillTTL)
+		}
+
+		stillMissing := make([]string, 0, len(missing)-len(hits))
+		for _, k := range missing {
+			if _, ok := hits[k]; !ok {
+				stillMissing = append(stillMissing, k)
+			}
+		}
+		missing = stillMissing
+	}
+	return results
+}
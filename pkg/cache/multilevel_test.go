@@ -0,0 +1,49 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func TestMultiLevelCache(t *testing.T) {
+	ctx := context.Background()
+	conf := []byte(`
+max_size: 1MB
+max_item_size: 2KB
+`)
+	l1, err := NewInMemoryCache("l1", log.NewNopLogger(), nil, conf)
+	testutil.Ok(t, err)
+	l2, err := NewInMemoryCache("l2", log.NewNopLogger(), nil, conf)
+	testutil.Ok(t, err)
+
+	c := NewMultiLevelCache(time.Hour, l1, l2)
+
+	// Store writes to all levels.
+	c.Store(ctx, map[string][]byte{"key1": []byte("value1")}, time.Hour)
+	testutil.Equals(t, map[string][]byte{"key1": []byte("value1")}, l1.Fetch(ctx, []string{"key1"}))
+	testutil.Equals(t, map[string][]byte{"key1": []byte("value1")}, l2.Fetch(ctx, []string{"key1"}))
+
+	// Items only present in the slower level are returned and back-filled into the faster one.
+	l2.Store(ctx, map[string][]byte{"key2": []byte("value2")}, time.Hour)
+	testutil.Equals(t, map[string][]byte{}, l1.Fetch(ctx, []string{"key2"}))
+
+	testutil.Equals(t, map[string][]byte{
+		"key1": []byte("value1"),
+		"key2": []byte("value2"),
+	}, c.Fetch(ctx, []string{"key1", "key2", "key3"}))
+	testutil.Equals(t, map[string][]byte{"key2": []byte("value2")}, l1.Fetch(ctx, []string{"key2"}))
+
+	// Keys found in the faster level are not requested to the slower one.
+	before := prom_testutil.ToFloat64(l2.requests)
+	c.Fetch(ctx, []string{"key1", "key2"})
+	testutil.Equals(t, before, prom_testutil.ToFloat64(l2.requests))
+}
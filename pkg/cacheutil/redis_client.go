@@ -0,0 +1,529 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package cacheutil
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	config_util "github.com/prometheus/common/config"
+	"gopkg.in/yaml.v2"
+
+	"github.com/thanos-io/thanos/pkg/extprom"
+	"github.com/thanos-io/thanos/pkg/gate"
+	"github.com/thanos-io/thanos/pkg/model"
+)
+
+var (
+	errRedisAsyncBufferFull                = errors.New("the async buffer is full")
+	errRedisConfigNoAddrs                  = errors.New("no redis addresses provided")
+	errRedisMaxAsyncConcurrencyNotPositive = errors.New("max async concurrency must be positive")
+	errRedisMaxSetMultiBatchSizeNegative   = errors.New("max set multi batch size must not be negative")
+
+	defaultRedisClientConfig = RedisClientConfig{
+		DialTimeout:            5 * time.Second,
+		ReadTimeout:            3 * time.Second,
+		WriteTimeout:           3 * time.Second,
+		PoolSize:               100,
+		MinIdleConnections:     10,
+		IdleTimeout:            5 * time.Minute,
+		MaxAsyncConcurrency:    20,
+		MaxAsyncBufferSize:     10000,
+		MaxGetMultiConcurrency: 100,
+		MaxGetMultiBatchSize:   100,
+		MaxSetMultiBatchSize:   100,
+	}
+)
+
+// RedisClient is a high level client to interact with redis.
+type RedisClient interface {
+	// GetMulti fetches multiple keys at once from redis. In case of error,
+	// an empty map is returned and the error tracked/logged.
+	GetMulti(ctx context.Context, keys []string) map[string][]byte
+
+	// SetAsync enqueues an asynchronous operation to store a key into redis.
+	// Returns an error in case it fails to enqueue the operation. In case the
+	// underlying async operation will fail, the error will be tracked/logged.
+	SetAsync(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Stop client and release underlying resources.
+	Stop()
+}
+
+// RedisClientConfig is the config accepted by RedisClient.
+type RedisClientConfig struct {
+	// Addresses specifies the list of redis addresses. A single address connects to a
+	// standalone server, multiple addresses to a Redis Cluster. When MasterName is set,
+	// the addresses are the ones of the Redis Sentinels.
+	Addresses []string `yaml:"addresses"`
+
+	// Cluster enables Redis Cluster mode, even if a single address is provided.
+	Cluster bool `yaml:"cluster"`
+
+	// MasterName specifies the Redis Sentinel master name.
+	MasterName string `yaml:"master_name"`
+
+	// Username and Password specify the credentials used to connect to redis.
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	// SentinelPassword specifies the password used to connect to Redis Sentinels.
+	SentinelPassword string `yaml:"sentinel_password"`
+
+	// DB specifies the database to be selected after connecting to the server.
+	// Not supported in Redis Cluster mode.
+	DB int `yaml:"db"`
+
+	// DialTimeout, ReadTimeout and WriteTimeout specify the socket timeouts.
+	DialTimeout  time.Duration `yaml:"dial_timeout"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+
+	// PoolSize specifies the maximum number of connections per redis node.
+	PoolSize int `yaml:"pool_size"`
+
+	// MinIdleConnections specifies the minimum number of idle connections per redis node.
+	MinIdleConnections int `yaml:"min_idle_connections"`
+
+	// IdleTimeout specifies the amount of time after which idle connections are closed.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+
+	// MaxConnectionAge specifies the connection age at which connections are closed.
+	// If set to 0, connections are not closed based on age.
+	MaxConnectionAge time.Duration `yaml:"max_connection_age"`
+
+	// MaxAsyncConcurrency specifies the maximum number of SetAsync goroutines.
+	MaxAsyncConcurrency int `yaml:"max_async_concurrency"`
+
+	// MaxAsyncBufferSize specifies the queue buffer size for SetAsync operations.
+	MaxAsyncBufferSize int `yaml:"max_async_buffer_size"`
+
+	// MaxGetMultiConcurrency specifies the maximum number of concurrent GetMulti() operations.
+	// If set to 0, concurrency is unlimited.
+	MaxGetMultiConcurrency int `yaml:"max_get_multi_concurrency"`
+
+	// MaxGetMultiBatchSize specifies the maximum number of keys fetched by a single pipeline.
+	// If more keys are specified, internally keys are splitted into multiple batches and fetched
+	// concurrently, honoring MaxGetMultiConcurrency parallelism. If set to 0, the max batch size is unlimited.
+	MaxGetMultiBatchSize int `yaml:"max_get_multi_batch_size"`
+
+	// MaxSetMultiBatchSize specifies the maximum number of enqueued SetAsync operations
+	// sent to redis by a single pipeline. If set to 0, the max batch size is unlimited.
+	MaxSetMultiBatchSize int `yaml:"max_set_multi_batch_size"`
+
+	// MaxItemSize specifies the maximum size of an item stored in redis.
+	// Items bigger than MaxItemSize are skipped.
+	// If set to 0, no maximum size is enforced.
+	MaxItemSize model.Bytes `yaml:"max_item_size"`
+
+	// TLSEnabled enables TLS connections to redis.
+	TLSEnabled bool `yaml:"tls_enabled"`
+
+	// TLSConfig configures TLS connections to redis.
+	TLSConfig TLSConfig `yaml:"tls_config"`
+}
+
+// TLSConfig configures TLS connections.
+type TLSConfig struct {
+	// The CA cert to use for the targets.
+	CAFile string `yaml:"ca_file"`
+	// The client cert file for the targets.
+	CertFile string `yaml:"cert_file"`
+	// The client key file for the targets.
+	KeyFile string `yaml:"key_file"`
+	// Used to verify the hostname for the targets.
+	ServerName string `yaml:"server_name"`
+	// Disable target certificate validation.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+}
+
+func (c *RedisClientConfig) validate() error {
+	if len(c.Addresses) == 0 {
+		return errRedisConfigNoAddrs
+	}
+
+	// Set async only available when MaxAsyncConcurrency > 0.
+	if c.MaxAsyncConcurrency <= 0 {
+		return errRedisMaxAsyncConcurrencyNotPositive
+	}
+
+	if c.MaxSetMultiBatchSize < 0 {
+		return errRedisMaxSetMultiBatchSizeNegative
+	}
+
+	return nil
+}
+
+// parseRedisClientConfig unmarshals a buffer into a RedisClientConfig with default values.
+func parseRedisClientConfig(conf []byte) (RedisClientConfig, error) {
+	config := defaultRedisClientConfig
+	if err := yaml.Unmarshal(conf, &config); err != nil {
+		return RedisClientConfig{}, err
+	}
+
+	return config, nil
+}
+
+type redisSetOp struct {
+	key   string
+	value []byte
+	ttl   time.Duration
+}
+
+type redisClient struct {
+	logger log.Logger
+	config RedisClientConfig
+	client redis.UniversalClient
+
+	// Channel used to notify internal goroutines when they should quit.
+	stop chan struct{}
+
+	// Channel used to enqueue async set operations.
+	asyncQueue chan redisSetOp
+
+	// Gate used to enforce the max number of concurrent GetMulti() operations.
+	getMultiGate gate.Gate
+
+	// Wait group used to wait all workers on stopping.
+	workers sync.WaitGroup
+
+	// Tracked metrics.
+	clientInfo prometheus.GaugeFunc
+	operations *prometheus.CounterVec
+	failures   *prometheus.CounterVec
+	skipped    *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+}
+
+// NewRedisClient makes a new RedisClient.
+func NewRedisClient(logger log.Logger, name string, conf []byte, reg prometheus.Registerer) (*redisClient, error) {
+	config, err := parseRedisClientConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRedisClientWithConfig(logger, name, config, reg)
+}
+
+// NewRedisClientWithConfig makes a new RedisClient.
+func NewRedisClientWithConfig(logger log.Logger, name string, config RedisClientConfig, reg prometheus.Registerer) (*redisClient, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            config.Addresses,
+		MasterName:       config.MasterName,
+		Username:         config.Username,
+		Password:         config.Password,
+		SentinelPassword: config.SentinelPassword,
+		DB:               config.DB,
+		DialTimeout:      config.DialTimeout,
+		ReadTimeout:      config.ReadTimeout,
+		WriteTimeout:     config.WriteTimeout,
+		PoolSize:         config.PoolSize,
+		MinIdleConns:     config.MinIdleConnections,
+		IdleTimeout:      config.IdleTimeout,
+		MaxConnAge:       config.MaxConnectionAge,
+	}
+	if config.TLSEnabled {
+		tlsConfig, err := config_util.NewTLSConfig(&config_util.TLSConfig{
+			CAFile:             config.TLSConfig.CAFile,
+			CertFile:           config.TLSConfig.CertFile,
+			KeyFile:            config.TLSConfig.KeyFile,
+			ServerName:         config.TLSConfig.ServerName,
+			InsecureSkipVerify: config.TLSConfig.InsecureSkipVerify,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "create TLS config")
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	if reg != nil {
+		reg = prometheus.WrapRegistererWith(prometheus.Labels{"name": name}, reg)
+	}
+	return newRedisClient(logger, newRedisUniversalClient(config, opts), config, reg), nil
+}
+
+// newRedisUniversalClient returns a Sentinel backed client if a master name is configured, a Redis Cluster
+// client if cluster mode is enabled or multiple addresses are configured, a single node client otherwise.
+func newRedisUniversalClient(config RedisClientConfig, opts *redis.UniversalOptions) redis.UniversalClient {
+	switch {
+	case config.MasterName != "":
+		return redis.NewFailoverClient(opts.Failover())
+	case config.Cluster || len(config.Addresses) > 1:
+		return redis.NewClusterClient(opts.Cluster())
+	default:
+		return redis.NewClient(opts.Simple())
+	}
+}
+
+func newRedisClient(logger log.Logger, client redis.UniversalClient, config RedisClientConfig, reg prometheus.Registerer) *redisClient {
+	c := &redisClient{
+		logger:     logger,
+		config:     config,
+		client:     client,
+		asyncQueue: make(chan redisSetOp, config.MaxAsyncBufferSize),
+		stop:       make(chan struct{}, 1),
+		getMultiGate: gate.New(
+			extprom.WrapRegistererWithPrefix("thanos_redis_getmulti_", reg),
+			config.MaxGetMultiConcurrency,
+		),
+	}
+
+	c.clientInfo = promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "thanos_redis_client_info",
+		Help: "A metric with a constant '1' value labeled by configuration options from which redis client was configured.",
+		ConstLabels: prometheus.Labels{
+			"cluster":                   strconv.FormatBool(config.Cluster || (config.MasterName == "" && len(config.Addresses) > 1)),
+			"sentinel":                  strconv.FormatBool(config.MasterName != ""),
+			"tls_enabled":               strconv.FormatBool(config.TLSEnabled),
+			"dial_timeout":              config.DialTimeout.String(),
+			"read_timeout":              config.ReadTimeout.String(),
+			"write_timeout":             config.WriteTimeout.String(),
+			"pool_size":                 strconv.Itoa(config.PoolSize),
+			"max_async_concurrency":     strconv.Itoa(config.MaxAsyncConcurrency),
+			"max_async_buffer_size":     strconv.Itoa(config.MaxAsyncBufferSize),
+			"max_item_size":             strconv.FormatUint(uint64(config.MaxItemSize), 10),
+			"max_get_multi_concurrency": strconv.Itoa(config.MaxGetMultiConcurrency),
+			"max_get_multi_batch_size":  strconv.Itoa(config.MaxGetMultiBatchSize),
+			"max_set_multi_batch_size":  strconv.Itoa(config.MaxSetMultiBatchSize),
+		},
+	},
+		func() float64 { return 1 },
+	)
+
+	c.operations = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_redis_operations_total",
+		Help: "Total number of operations against redis.",
+	}, []string{"operation"})
+	c.operations.WithLabelValues(opGetMulti)
+	c.operations.WithLabelValues(opSet)
+
+	c.failures = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_redis_operation_failures_total",
+		Help: "Total number of operations against redis that failed.",
+	}, []string{"operation", "reason"})
+	c.failures.WithLabelValues(opGetMulti, reasonTimeout)
+	c.failures.WithLabelValues(opGetMulti, reasonNetworkError)
+	c.failures.WithLabelValues(opGetMulti, reasonOther)
+	c.failures.WithLabelValues(opSet, reasonTimeout)
+	c.failures.WithLabelValues(opSet, reasonNetworkError)
+	c.failures.WithLabelValues(opSet, reasonOther)
+
+	c.skipped = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_redis_operation_skipped_total",
+		Help: "Total number of operations against redis that have been skipped.",
+	}, []string{"operation", "reason"})
+	c.skipped.WithLabelValues(opSet, reasonMaxItemSize)
+	c.skipped.WithLabelValues(opSet, reasonAsyncBufferFull)
+
+	c.duration = promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "thanos_redis_operation_duration_seconds",
+		Help:    "Duration of operations against redis.",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.2, 0.5, 1, 3, 6, 10},
+	}, []string{"operation"})
+	c.duration.WithLabelValues(opGetMulti)
+	c.duration.WithLabelValues(opSet)
+
+	// Start a number of goroutines - processing async operations - equal
+	// to the max concurrency we have.
+	c.workers.Add(c.config.MaxAsyncConcurrency)
+	for i := 0; i < c.config.MaxAsyncConcurrency; i++ {
+		go c.asyncQueueProcessLoop()
+	}
+
+	return c
+}
+
+func (c *redisClient) Stop() {
+	close(c.stop)
+
+	// Wait until all workers have terminated.
+	c.workers.Wait()
+
+	if err := c.client.Close(); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to close redis client", "err", err)
+	}
+}
+
+func (c *redisClient) SetAsync(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	// Skip hitting redis at all if the item is bigger than the max allowed size.
+	if c.config.MaxItemSize > 0 && uint64(len(value)) > uint64(c.config.MaxItemSize) {
+		c.skipped.WithLabelValues(opSet, reasonMaxItemSize).Inc()
+		return nil
+	}
+
+	select {
+	case c.asyncQueue <- redisSetOp{key: key, value: value, ttl: ttl}:
+		return nil
+	default:
+		c.skipped.WithLabelValues(opSet, reasonAsyncBufferFull).Inc()
+		level.Debug(c.logger).Log("msg", "failed to store item to redis because the async buffer is full", "err", errRedisAsyncBufferFull, "size", len(c.asyncQueue))
+		return nil
+	}
+}
+
+// setMulti stores the given items in a single pipeline.
+func (c *redisClient) setMulti(ops []redisSetOp) {
+	start := time.Now()
+	c.operations.WithLabelValues(opSet).Add(float64(len(ops)))
+
+	pipe := c.client.Pipeline()
+	for _, op := range ops {
+		pipe.Set(context.Background(), op.key, op.value, op.ttl)
+	}
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		level.Debug(c.logger).Log("msg", "failed to store items to redis", "numKeys", len(ops), "firstKey", ops[0].key, "err", err)
+		c.trackError(opSet, err)
+		return
+	}
+	c.duration.WithLabelValues(opSet).Observe(time.Since(start).Seconds())
+}
+
+func (c *redisClient) GetMulti(ctx context.Context, keys []string) map[string][]byte {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	batchSize := c.config.MaxGetMultiBatchSize
+	if batchSize <= 0 || len(keys) <= batchSize {
+		hits, err := c.getMultiSingle(ctx, keys)
+		if err != nil {
+			level.Warn(c.logger).Log("msg", "failed to fetch items from redis", "numKeys", len(keys), "firstKey", keys[0], "err", err)
+		}
+		return hits
+	}
+
+	// Spawn a goroutine for each batch request. The max concurrency will be
+	// enforced by getMultiSingle().
+	var (
+		mtx     sync.Mutex
+		wg      sync.WaitGroup
+		hits    = make(map[string][]byte, len(keys))
+		lastErr error
+	)
+	for batchStart := 0; batchStart < len(keys); batchStart += batchSize {
+		batchEnd := batchStart + batchSize
+		if batchEnd > len(keys) {
+			batchEnd = len(keys)
+		}
+		batchKeys := keys[batchStart:batchEnd]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			batchHits, err := c.getMultiSingle(ctx, batchKeys)
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				lastErr = err
+			}
+			for k, v := range batchHits {
+				hits[k] = v
+			}
+		}()
+	}
+	wg.Wait()
+
+	// In case some batch requests failed and other succeeded, we prefer to log it
+	// and move on, given returning some results from the cache is better than
+	// returning nothing.
+	if lastErr != nil {
+		level.Warn(c.logger).Log("msg", "failed to fetch items from redis", "numKeys", len(keys), "firstKey", keys[0], "err", lastErr)
+	}
+	return hits
+}
+
+// getMultiSingle fetches the given keys in a single pipeline. With Redis Cluster, the pipeline
+// is split by the client across the nodes owning the keys.
+func (c *redisClient) getMultiSingle(ctx context.Context, keys []string) (map[string][]byte, error) {
+	// Wait until we get a free slot from the gate, if the max
+	// concurrency should be enforced.
+	if c.config.MaxGetMultiConcurrency > 0 {
+		if err := c.getMultiGate.Start(ctx); err != nil {
+			return nil, errors.Wrapf(err, "failed to wait for turn")
+		}
+		defer c.getMultiGate.Done()
+	}
+
+	start := time.Now()
+	c.operations.WithLabelValues(opGetMulti).Inc()
+
+	pipe := c.client.Pipeline()
+	cmds := make([]*redis.StringCmd, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, pipe.Get(ctx, key))
+	}
+	// Missing keys are reported as redis.Nil errors by their own command.
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		level.Debug(c.logger).Log("msg", "failed to get multiple items from redis", "err", err)
+		c.trackError(opGetMulti, err)
+		return nil, err
+	}
+
+	hits := make(map[string][]byte, len(keys))
+	for i, cmd := range cmds {
+		b, err := cmd.Bytes()
+		if err != nil {
+			continue
+		}
+		hits[keys[i]] = b
+	}
+	c.duration.WithLabelValues(opGetMulti).Observe(time.Since(start).Seconds())
+	return hits, nil
+}
+
+func (c *redisClient) trackError(op string, err error) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		c.failures.WithLabelValues(op, reasonTimeout).Inc()
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			c.failures.WithLabelValues(op, reasonTimeout).Inc()
+		} else {
+			c.failures.WithLabelValues(op, reasonNetworkError).Inc()
+		}
+	default:
+		c.failures.WithLabelValues(op, reasonOther).Inc()
+	}
+}
+
+// asyncQueueProcessLoop stores enqueued items, draining the queue to send up
+// to MaxSetMultiBatchSize items in a single pipeline.
+func (c *redisClient) asyncQueueProcessLoop() {
+	defer c.workers.Done()
+
+	for {
+		select {
+		case op := <-c.asyncQueue:
+			ops := []redisSetOp{op}
+		drain:
+			for c.config.MaxSetMultiBatchSize <= 0 || len(ops) < c.config.MaxSetMultiBatchSize {
+				select {
+				case op := <-c.asyncQueue:
+					ops = append(ops, op)
+				default:
+					break drain
+				}
+			}
+			c.setMulti(ops)
+		case <-c.stop:
+			return
+		}
+	}
+}
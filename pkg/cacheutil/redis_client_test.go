@@ -0,0 +1,152 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package cacheutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/thanos-io/thanos/pkg/model"
+	"github.com/thanos-io/thanos/pkg/runutil"
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func TestRedisClientConfig_validate(t *testing.T) {
+	tests := map[string]struct {
+		config   RedisClientConfig
+		expected error
+	}{
+		"should pass on valid config": {
+			config: RedisClientConfig{
+				Addresses:           []string{"127.0.0.1:6379"},
+				MaxAsyncConcurrency: 1,
+			},
+			expected: nil,
+		},
+		"should fail on no addresses": {
+			config: RedisClientConfig{
+				Addresses:           []string{},
+				MaxAsyncConcurrency: 1,
+			},
+			expected: errRedisConfigNoAddrs,
+		},
+		"should fail on max_async_concurrency <= 0": {
+			config: RedisClientConfig{
+				Addresses:           []string{"127.0.0.1:6379"},
+				MaxAsyncConcurrency: 0,
+			},
+			expected: errRedisMaxAsyncConcurrencyNotPositive,
+		},
+		"should fail on max_set_multi_batch_size < 0": {
+			config: RedisClientConfig{
+				Addresses:            []string{"127.0.0.1:6379"},
+				MaxAsyncConcurrency:  1,
+				MaxSetMultiBatchSize: -1,
+			},
+			expected: errRedisMaxSetMultiBatchSizeNegative,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testutil.Equals(t, testData.expected, testData.config.validate())
+		})
+	}
+}
+
+func TestNewRedisClient(t *testing.T) {
+	// Should return error on empty YAML config.
+	_, err := NewRedisClient(log.NewNopLogger(), "test", []byte{}, nil)
+	testutil.NotOk(t, err)
+
+	// Should return error on invalid YAML config.
+	_, err = NewRedisClient(log.NewNopLogger(), "test", []byte("invalid"), nil)
+	testutil.NotOk(t, err)
+
+	// Should return error on unreadable TLS CA file.
+	_, err = NewRedisClient(log.NewNopLogger(), "test", []byte(`
+addresses: [127.0.0.1:6379]
+tls_enabled: true
+tls_config:
+  ca_file: /not/existing
+`), nil)
+	testutil.NotOk(t, err)
+
+	// Should instance a redis client with minimum YAML config.
+	c, err := NewRedisClient(log.NewNopLogger(), "test", []byte(`addresses: [127.0.0.1:6379]`), nil)
+	testutil.Ok(t, err)
+	defer c.Stop()
+
+	testutil.Equals(t, defaultRedisClientConfig.ReadTimeout, c.config.ReadTimeout)
+	testutil.Equals(t, defaultRedisClientConfig.MaxAsyncConcurrency, c.config.MaxAsyncConcurrency)
+	testutil.Equals(t, defaultRedisClientConfig.MaxGetMultiBatchSize, c.config.MaxGetMultiBatchSize)
+}
+
+func newTestRedisClient(t *testing.T, config RedisClientConfig) (*redisClient, *miniredis.Miniredis) {
+	s, err := miniredis.Run()
+	testutil.Ok(t, err)
+
+	config.Addresses = []string{s.Addr()}
+	c, err := NewRedisClientWithConfig(log.NewNopLogger(), "test", config, prometheus.NewRegistry())
+	testutil.Ok(t, err)
+	return c, s
+}
+
+func TestRedisClient_SetAsyncAndGetMulti(t *testing.T) {
+	config := defaultRedisClientConfig
+	config.MaxGetMultiBatchSize = 2
+	config.MaxItemSize = model.Bytes(10)
+
+	c, s := newTestRedisClient(t, config)
+	defer s.Close()
+	defer c.Stop()
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		testutil.Ok(t, c.SetAsync(ctx, fmt.Sprintf("key-%d", i), []byte(fmt.Sprintf("value-%d", i)), time.Hour))
+	}
+	// Skipped because bigger than the max item size.
+	testutil.Ok(t, c.SetAsync(ctx, "key-big", []byte("value-too-big"), time.Hour))
+
+	retryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	testutil.Ok(t, runutil.Retry(10*time.Millisecond, retryCtx.Done(), func() error {
+		if n := len(s.Keys()); n != 5 {
+			return fmt.Errorf("expected 5 keys, got %d", n)
+		}
+		return nil
+	}))
+	s.CheckGet(t, "key-3", "value-3")
+	testutil.Assert(t, s.TTL("key-3") > 0, "expected TTL to be set")
+
+	hits := c.GetMulti(ctx, []string{"key-0", "key-1", "key-2", "key-3", "key-4", "key-big", "missing"})
+	testutil.Equals(t, map[string][]byte{
+		"key-0": []byte("value-0"),
+		"key-1": []byte("value-1"),
+		"key-2": []byte("value-2"),
+		"key-3": []byte("value-3"),
+		"key-4": []byte("value-4"),
+	}, hits)
+
+	testutil.Equals(t, 1.0, prom_testutil.ToFloat64(c.skipped.WithLabelValues(opSet, reasonMaxItemSize)))
+	// 7 keys fetched in batches of 2.
+	testutil.Equals(t, 4.0, prom_testutil.ToFloat64(c.operations.WithLabelValues(opGetMulti)))
+	testutil.Equals(t, 5.0, prom_testutil.ToFloat64(c.operations.WithLabelValues(opSet)))
+}
+
+func TestRedisClient_GetMultiFailure(t *testing.T) {
+	c, s := newTestRedisClient(t, defaultRedisClientConfig)
+	defer c.Stop()
+
+	s.Close()
+	testutil.Equals(t, 0, len(c.GetMulti(context.Background(), []string{"key-0"})))
+	testutil.Equals(t, 1.0, prom_testutil.ToFloat64(c.failures.WithLabelValues(opGetMulti, reasonNetworkError)))
+}
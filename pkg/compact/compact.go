@@ -26,6 +26,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/runutil"
 
 	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/bloom"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
 	"github.com/thanos-io/thanos/pkg/compact/downsample"
 	"github.com/thanos-io/thanos/pkg/errutil"
@@ -106,20 +107,18 @@ func NewSyncer(logger log.Logger, reg prometheus.Registerer, bkt objstore.Bucket
 	}, nil
 }
 
-// UntilNextDownsampling calculates how long it will take until the next downsampling operation.
+// UntilNextDownsampling calculates how long it will take until the next downsampling operation for the given levels.
 // Returns an error if there will be no downsampling.
-func UntilNextDownsampling(m *metadata.Meta) (time.Duration, error) {
-	timeRange := time.Duration((m.MaxTime - m.MinTime) * int64(time.Millisecond))
-	switch m.Thanos.Downsample.Resolution {
-	case downsample.ResLevel2:
+func UntilNextDownsampling(m *metadata.Meta, levels downsample.Levels) (time.Duration, error) {
+	next, ok := levels.Next(m.Thanos.Downsample.Resolution)
+	if !ok {
+		if !levels.Contains(m.Thanos.Downsample.Resolution) {
+			return time.Duration(0), errors.Errorf("invalid resolution %v", m.Thanos.Downsample.Resolution)
+		}
 		return time.Duration(0), errors.New("no downsampling")
-	case downsample.ResLevel1:
-		return time.Duration(downsample.DownsampleRange1*time.Millisecond) - timeRange, nil
-	case downsample.ResLevel0:
-		return time.Duration(downsample.DownsampleRange0*time.Millisecond) - timeRange, nil
-	default:
-		panic(errors.Errorf("invalid resolution %v", m.Thanos.Downsample.Resolution))
 	}
+	timeRange := time.Duration((m.MaxTime - m.MinTime) * int64(time.Millisecond))
+	return time.Duration(next.MinSourceRange)*time.Millisecond - timeRange, nil
 }
 
 // SyncMetas synchronizes local state of block metas with what we have in the bucket.
@@ -226,6 +225,8 @@ type DefaultGrouper struct {
 	logger                   log.Logger
 	acceptMalformedIndex     bool
 	enableVerticalCompaction bool
+	mergeOverlaps            bool
+	enableBloomFilters       bool
 	compactions              *prometheus.CounterVec
 	compactionRunsStarted    *prometheus.CounterVec
 	compactionRunsCompleted  *prometheus.CounterVec
@@ -236,12 +237,16 @@ type DefaultGrouper struct {
 	hashFunc                 metadata.HashFunc
 }
 
-// NewDefaultGrouper makes a new DefaultGrouper.
+// NewDefaultGrouper makes a new DefaultGrouper. If mergeOverlaps is true, overlapping blocks of the same group are merged
+// instead of halting the compactor, while other blocks are still compacted without overlaps. If enableBloomFilters is true,
+// compacted blocks are uploaded together with bloom filters of their label pairs.
 func NewDefaultGrouper(
 	logger log.Logger,
 	bkt objstore.Bucket,
 	acceptMalformedIndex bool,
 	enableVerticalCompaction bool,
+	mergeOverlaps bool,
+	enableBloomFilters bool,
 	reg prometheus.Registerer,
 	blocksMarkedForDeletion prometheus.Counter,
 	garbageCollectedBlocks prometheus.Counter,
@@ -252,6 +257,8 @@ func NewDefaultGrouper(
 		logger:                   logger,
 		acceptMalformedIndex:     acceptMalformedIndex,
 		enableVerticalCompaction: enableVerticalCompaction,
+		mergeOverlaps:            mergeOverlaps,
+		enableBloomFilters:       enableBloomFilters,
 		compactions: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name: "thanos_compact_group_compactions_total",
 			Help: "Total number of group compaction attempts that resulted in a new block.",
@@ -295,6 +302,8 @@ func (g *DefaultGrouper) Groups(blocks map[ulid.ULID]*metadata.Meta) (res []*Gro
 				m.Thanos.Downsample.Resolution,
 				g.acceptMalformedIndex,
 				g.enableVerticalCompaction,
+				g.mergeOverlaps,
+				g.enableBloomFilters,
 				g.compactions.WithLabelValues(groupKey),
 				g.compactionRunsStarted.WithLabelValues(groupKey),
 				g.compactionRunsCompleted.WithLabelValues(groupKey),
@@ -332,6 +341,8 @@ type Group struct {
 	metasByMinTime              []*metadata.Meta
 	acceptMalformedIndex        bool
 	enableVerticalCompaction    bool
+	mergeOverlaps               bool
+	enableBloomFilters          bool
 	compactions                 prometheus.Counter
 	compactionRunsStarted       prometheus.Counter
 	compactionRunsCompleted     prometheus.Counter
@@ -351,6 +362,8 @@ func NewGroup(
 	resolution int64,
 	acceptMalformedIndex bool,
 	enableVerticalCompaction bool,
+	mergeOverlaps bool,
+	enableBloomFilters bool,
 	compactions prometheus.Counter,
 	compactionRunsStarted prometheus.Counter,
 	compactionRunsCompleted prometheus.Counter,
@@ -371,6 +384,8 @@ func NewGroup(
 		resolution:                  resolution,
 		acceptMalformedIndex:        acceptMalformedIndex,
 		enableVerticalCompaction:    enableVerticalCompaction,
+		mergeOverlaps:               mergeOverlaps,
+		enableBloomFilters:          enableBloomFilters,
 		compactions:                 compactions,
 		compactionRunsStarted:       compactionRunsStarted,
 		compactionRunsCompleted:     compactionRunsCompleted,
@@ -626,6 +641,45 @@ func (cg *Group) areBlocksOverlapping(include *metadata.Meta, exclude ...*metada
 	return nil
 }
 
+// isBlockOverlapping returns error if the given block overlaps with any block of the group except the excluded ones.
+func (cg *Group) isBlockOverlapping(m *metadata.Meta, exclude ...*metadata.Meta) error {
+	excludeMap := map[ulid.ULID]struct{}{}
+	for _, meta := range exclude {
+		excludeMap[meta.ULID] = struct{}{}
+	}
+
+	for _, o := range cg.metasByMinTime {
+		if _, ok := excludeMap[o.ULID]; ok {
+			continue
+		}
+		if m.MinTime < o.MaxTime && o.MinTime < m.MaxTime {
+			return errors.Errorf("block %s [%d, %d) overlaps with %s [%d, %d)", m.ULID, m.MinTime, m.MaxTime, o.ULID, o.MinTime, o.MaxTime)
+		}
+	}
+	return nil
+}
+
+// planOverlapMerge returns the overlap merge of the given blocks sorted by min time, or nil if they do not overlap.
+func planOverlapMerge(metasByMinTime []*metadata.Meta) *metadata.OverlapMerge {
+	metas := make([]tsdb.BlockMeta, 0, len(metasByMinTime))
+	for _, m := range metasByMinTime {
+		metas = append(metas, m.BlockMeta)
+	}
+	if len(tsdb.OverlappingBlocks(metas)) == 0 {
+		return nil
+	}
+
+	merge := &metadata.OverlapMerge{}
+	for _, m := range metasByMinTime {
+		merge.Blocks = append(merge.Blocks, m.ULID)
+		merge.Sources = append(merge.Sources, m.Compaction.Sources...)
+	}
+	sort.Slice(merge.Sources, func(i, j int) bool {
+		return merge.Sources[i].Compare(merge.Sources[j]) < 0
+	})
+	return merge
+}
+
 // RepairIssue347 repairs the https://github.com/prometheus/tsdb/issues/347 issue when having issue347Error.
 func RepairIssue347(ctx context.Context, logger log.Logger, bkt objstore.Bucket, blocksMarkedForDeletion prometheus.Counter, issue347Err error) error {
 	ie, ok := errors.Cause(issue347Err).(Issue347Error)
@@ -693,7 +747,7 @@ func (cg *Group) compact(ctx context.Context, dir string, planner Planner, comp
 	if err := cg.areBlocksOverlapping(nil); err != nil {
 		// TODO(bwplotka): It would really nice if we could still check for other overlaps than replica. In fact this should be checked
 		// in syncer itself. Otherwise with vertical compaction enabled we will sacrifice this important check.
-		if !cg.enableVerticalCompaction {
+		if !cg.enableVerticalCompaction && !cg.mergeOverlaps {
 			return false, ulid.ULID{}, halt(errors.Wrap(err, "pre compaction overlap check"))
 		}
 
@@ -711,6 +765,13 @@ func (cg *Group) compact(ctx context.Context, dir string, planner Planner, comp
 
 	level.Info(cg.logger).Log("msg", "compaction available and planned; downloading blocks", "plan", fmt.Sprintf("%v", toCompact))
 
+	// Without vertical compaction, only overlaps within the plan are merged. Planner always plans overlapping blocks first.
+	var overlapMerge *metadata.OverlapMerge
+	if overlappingBlocks && !cg.enableVerticalCompaction {
+		overlapMerge = planOverlapMerge(toCompact)
+		overlappingBlocks = overlapMerge != nil
+	}
+
 	// Due to #183 we verify that none of the blocks in the plan have overlapping sources.
 	// This is one potential source of how we could end up with duplicated chunks.
 	uniqueSources := map[ulid.ULID]struct{}{}
@@ -782,11 +843,20 @@ func (cg *Group) compact(ctx context.Context, dir string, planner Planner, comp
 	bdir := filepath.Join(dir, compID.String())
 	index := filepath.Join(bdir, block.IndexFilename)
 
+	var overlapMerges []metadata.OverlapMerge
+	for _, meta := range toCompact {
+		overlapMerges = append(overlapMerges, meta.Thanos.OverlapMerges...)
+	}
+	if overlapMerge != nil {
+		overlapMerges = append(overlapMerges, *overlapMerge)
+	}
+
 	newMeta, err := metadata.InjectThanos(cg.logger, bdir, metadata.Thanos{
-		Labels:       cg.labels.Map(),
-		Downsample:   metadata.ThanosDownsample{Resolution: cg.resolution},
-		Source:       metadata.CompactorSource,
-		SegmentFiles: block.GetSegmentFiles(bdir),
+		Labels:        cg.labels.Map(),
+		Downsample:    metadata.ThanosDownsample{Resolution: cg.resolution},
+		Source:        metadata.CompactorSource,
+		SegmentFiles:  block.GetSegmentFiles(bdir),
+		OverlapMerges: overlapMerges,
 	}, nil)
 	if err != nil {
 		return false, ulid.ULID{}, errors.Wrapf(err, "failed to finalize the block %s", bdir)
@@ -804,11 +874,22 @@ func (cg *Group) compact(ctx context.Context, dir string, planner Planner, comp
 	// Ensure the output block is not overlapping with anything else,
 	// unless vertical compaction is enabled.
 	if !cg.enableVerticalCompaction {
-		if err := cg.areBlocksOverlapping(newMeta, toCompact...); err != nil {
+		check := cg.areBlocksOverlapping
+		if cg.mergeOverlaps {
+			// Other overlaps in the group are merged by following compactions.
+			check = cg.isBlockOverlapping
+		}
+		if err := check(newMeta, toCompact...); err != nil {
 			return false, ulid.ULID{}, halt(errors.Wrapf(err, "resulted compacted block %s overlaps with something", bdir))
 		}
 	}
 
+	if cg.enableBloomFilters {
+		if err := bloom.WriteBlockFilter(cg.logger, bdir, bloom.DefaultFalsePositiveRate); err != nil {
+			return false, ulid.ULID{}, errors.Wrapf(err, "write bloom filter of block %s", bdir)
+		}
+	}
+
 	begin = time.Now()
 
 	if err := block.Upload(ctx, cg.logger, cg.bkt, bdir, cg.hashFunc); err != nil {
@@ -853,9 +934,10 @@ type BucketCompactor struct {
 	compactDir  string
 	bkt         objstore.Bucket
 	concurrency int
+	progress    *Progress
 }
 
-// NewBucketCompactor creates a new bucket compactor.
+// NewBucketCompactor creates a new bucket compactor. Progress is optional and can be nil.
 func NewBucketCompactor(
 	logger log.Logger,
 	sy *Syncer,
@@ -865,6 +947,7 @@ func NewBucketCompactor(
 	compactDir string,
 	bkt objstore.Bucket,
 	concurrency int,
+	progress *Progress,
 ) (*BucketCompactor, error) {
 	if concurrency <= 0 {
 		return nil, errors.Errorf("invalid concurrency level (%d), concurrency level must be > 0", concurrency)
@@ -878,6 +961,7 @@ func NewBucketCompactor(
 		compactDir:  compactDir,
 		bkt:         bkt,
 		concurrency: concurrency,
+		progress:    progress,
 	}, nil
 }
 
@@ -914,7 +998,9 @@ func (c *BucketCompactor) Compact(ctx context.Context) (rerr error) {
 			go func() {
 				defer wg.Done()
 				for g := range groupChan {
-					shouldRerunGroup, _, err := g.Compact(workCtx, c.compactDir, c.planner, c.comp)
+					c.progress.groupStarted(g.Key())
+					shouldRerunGroup, compID, err := g.Compact(workCtx, c.compactDir, c.planner, c.comp)
+					c.progress.groupFinished(g.Key(), err == nil && compID != (ulid.ULID{}))
 					if err == nil {
 						if shouldRerunGroup {
 							mtx.Lock()
@@ -964,6 +1050,10 @@ func (c *BucketCompactor) Compact(ctx context.Context) (rerr error) {
 			level.Warn(c.logger).Log("msg", "failed deleting non-compaction group directories/files, some disk space usage might have leaked. Continuing", "err", err, "dir", c.compactDir)
 		}
 
+		if err := c.progress.startCompactionPass(ctx, groups); err != nil {
+			level.Warn(c.logger).Log("msg", "failed to estimate compaction backlog. Continuing", "err", err)
+		}
+
 		level.Info(c.logger).Log("msg", "start of compactions")
 
 		// Send all groups found during this pass to the compaction workers.
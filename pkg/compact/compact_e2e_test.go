@@ -135,7 +135,7 @@ func TestSyncer_GarbageCollect_e2e(t *testing.T) {
 		testutil.Ok(t, sy.GarbageCollect(ctx))
 
 		// Only the level 3 block, the last source block in both resolutions should be left.
-		grouper := NewDefaultGrouper(nil, bkt, false, false, nil, blocksMarkedForDeletion, garbageCollectedBlocks, metadata.NoneFunc)
+		grouper := NewDefaultGrouper(nil, bkt, false, false, false, false, nil, blocksMarkedForDeletion, garbageCollectedBlocks, metadata.NoneFunc)
 		groups, err := grouper.Groups(sy.Metas())
 		testutil.Ok(t, err)
 
@@ -199,8 +199,8 @@ func TestGroup_Compact_e2e(t *testing.T) {
 
 		planner := NewTSDBBasedPlanner(logger, []int64{1000, 3000})
 
-		grouper := NewDefaultGrouper(logger, bkt, false, false, reg, blocksMarkedForDeletion, garbageCollectedBlocks, metadata.NoneFunc)
-		bComp, err := NewBucketCompactor(logger, sy, grouper, planner, comp, dir, bkt, 2)
+		grouper := NewDefaultGrouper(logger, bkt, false, false, false, false, reg, blocksMarkedForDeletion, garbageCollectedBlocks, metadata.NoneFunc)
+		bComp, err := NewBucketCompactor(logger, sy, grouper, planner, comp, dir, bkt, 2, nil)
 		testutil.Ok(t, err)
 
 		// Compaction on empty should not fail.
@@ -527,3 +527,58 @@ func listBlocksMarkedForDeletion(ctx context.Context, bkt objstore.Bucket) ([]ul
 	})
 	return rem, err
 }
+
+func TestGroup_Compact_MergeOverlaps_e2e(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	dir, err := ioutil.TempDir("", "test-compact-merge-overlaps")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	series := []labels.Labels{{{Name: "a", Value: "1"}}, {{Name: "a", Value: "2"}}}
+	extLset := labels.Labels{{Name: "receive", Value: "1"}}
+	specs := []blockgenSpec{
+		// Two separate overlaps within the same stream.
+		{numSamples: 100, mint: 0, maxt: 1000, series: series, extLset: extLset},
+		{numSamples: 100, mint: 500, maxt: 1500, series: series, extLset: extLset},
+		{numSamples: 100, mint: 2000, maxt: 3000, series: series, extLset: extLset},
+		{numSamples: 100, mint: 2500, maxt: 3500, series: series, extLset: extLset},
+	}
+
+	for _, mergeOverlaps := range []bool{false, true} {
+		bkt := objstore.NewInMemBucket()
+		metas := createAndUpload(t, bkt, specs)
+
+		comp, err := tsdb.NewLeveledCompactor(ctx, nil, log.NewNopLogger(), []int64{1000, 3000}, nil)
+		testutil.Ok(t, err)
+		planner := NewTSDBBasedPlanner(log.NewNopLogger(), []int64{1000, 3000})
+
+		stubCounter := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+		grouper := NewDefaultGrouper(log.NewNopLogger(), bkt, false, false, mergeOverlaps, false, nil, stubCounter, stubCounter, metadata.NoneFunc)
+		groups, err := grouper.Groups(map[ulid.ULID]*metadata.Meta{
+			metas[0].ULID: metas[0], metas[1].ULID: metas[1], metas[2].ULID: metas[2], metas[3].ULID: metas[3],
+		})
+		testutil.Ok(t, err)
+		testutil.Equals(t, 1, len(groups))
+
+		_, compID, err := groups[0].Compact(ctx, dir, planner, comp)
+		if !mergeOverlaps {
+			testutil.Assert(t, IsHaltError(err), "expected halt error, got %v", err)
+			continue
+		}
+		testutil.Ok(t, err)
+
+		rd, err := bkt.Get(ctx, path.Join(compID.String(), metadata.MetaFilename))
+		testutil.Ok(t, err)
+		meta, err := metadata.Read(rd)
+		testutil.Ok(t, err)
+
+		testutil.Equals(t, int64(0), meta.MinTime)
+		testutil.Equals(t, int64(1500), meta.MaxTime)
+		testutil.Equals(t, []metadata.OverlapMerge{{
+			Blocks:  []ulid.ULID{metas[0].ULID, metas[1].ULID},
+			Sources: []ulid.ULID{metas[0].ULID, metas[1].ULID},
+		}}, meta.Thanos.OverlapMerges)
+	}
+}
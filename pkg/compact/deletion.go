@@ -0,0 +1,508 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package compact
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"github.com/prometheus/prometheus/tsdb/tombstones"
+
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+	"github.com/thanos-io/thanos/pkg/compactv2"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/runutil"
+)
+
+const (
+	// DeletionRequestsDir is the bucket directory holding series deletion requests, one JSON object per request.
+	DeletionRequestsDir = "deletion-requests"
+	// DeletionRequestVersion1 is the version of deletion request file supported by Thanos.
+	DeletionRequestVersion1 = 1
+)
+
+// DeletionRequestStatus is the processing status of a series deletion request.
+type DeletionRequestStatus string
+
+const (
+	// DeletionRequestPending means that no block was rewritten for the request yet.
+	DeletionRequestPending DeletionRequestStatus = "pending"
+	// DeletionRequestInProgress means that some of the affected blocks were rewritten already.
+	DeletionRequestInProgress DeletionRequestStatus = "in-progress"
+	// DeletionRequestDone means that all affected blocks were rewritten. Requests with skipped blocks are never done.
+	DeletionRequestDone DeletionRequestStatus = "done"
+)
+
+// DeletionRequest is a request to delete series matching the matchers within the time range, persisted in the bucket.
+type DeletionRequest struct {
+	// ID of the request.
+	ID ulid.ULID `json:"id"`
+	// Version of the file.
+	Version int `json:"version"`
+
+	// Matchers is the series selector of series to delete, e.g. {__name__="up", secret="leaked"}.
+	Matchers string `json:"matchers"`
+	// MinTime and MaxTime are the inclusive time range of samples to delete, in milliseconds.
+	MinTime int64 `json:"min_time"`
+	MaxTime int64 `json:"max_time"`
+	// CreationTime is a unix timestamp of when the request was created.
+	CreationTime int64 `json:"creation_time"`
+
+	Status DeletionRequestStatus `json:"status"`
+	// RewrittenBlocks are the blocks produced by applying the request. Blocks compacted only from them do not contain the deleted series.
+	RewrittenBlocks []ulid.ULID `json:"rewritten_blocks,omitempty"`
+	// UnaffectedBlocks are the blocks overlapping the request which turned out not to contain any deleted samples.
+	UnaffectedBlocks []ulid.ULID `json:"unaffected_blocks,omitempty"`
+	// SkippedBlocks are the blocks containing deleted samples which can't be rewritten, because they are downsampled
+	// or marked for no compaction. The deleted series are still queryable from them, so the request is not done until
+	// they are gone, e.g. removed by retention.
+	SkippedBlocks []ulid.ULID `json:"skipped_blocks,omitempty"`
+	// FinishTime is a unix timestamp of when the request was done.
+	FinishTime int64 `json:"finish_time,omitempty"`
+	// LastError is the error of the last failed attempt to apply the request.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Deletion returns the request in the form accepted by compactv2.DeletionModifier.
+func (r *DeletionRequest) Deletion() (metadata.DeletionRequest, error) {
+	matchers, err := parser.ParseMetricSelector(r.Matchers)
+	if err != nil {
+		return metadata.DeletionRequest{}, errors.Wrapf(err, "parse matchers %v", r.Matchers)
+	}
+	return metadata.DeletionRequest{
+		Matchers:  matchers,
+		Intervals: tombstones.Intervals{{Mint: r.MinTime, Maxt: r.MaxTime}},
+	}, nil
+}
+
+// affects returns true if the block may contain series deleted by the request. Blocks in done were already
+// rewritten or checked for the request.
+func (r *DeletionRequest) affects(m *metadata.Meta, done map[ulid.ULID]struct{}) bool {
+	// Block's max time is exclusive, while request's time range is inclusive.
+	if m.MinTime > r.MaxTime || m.MaxTime <= r.MinTime {
+		return false
+	}
+	if _, ok := done[m.ULID]; ok {
+		return false
+	}
+	for _, s := range m.Compaction.Sources {
+		if _, ok := done[s]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// deletionForBlock returns the deletion to apply to series of a block with the given external labels. Matchers on
+// external labels are not part of the returned deletion, as series inside the block don't have them. It returns false
+// if the external labels of the block don't match the deletion, so none of its series are deleted.
+func deletionForBlock(deletion metadata.DeletionRequest, extLset map[string]string) (metadata.DeletionRequest, bool) {
+	res := metadata.DeletionRequest{Intervals: deletion.Intervals}
+	for _, m := range deletion.Matchers {
+		v, ok := extLset[m.Name]
+		if !ok {
+			res.Matchers = append(res.Matchers, m)
+			continue
+		}
+		if !m.Matches(v) {
+			return metadata.DeletionRequest{}, false
+		}
+	}
+	return res, true
+}
+
+// DeletionRequests stores series deletion requests in the bucket.
+type DeletionRequests struct {
+	logger log.Logger
+	bkt    objstore.Bucket
+}
+
+// NewDeletionRequests creates DeletionRequests.
+func NewDeletionRequests(logger log.Logger, bkt objstore.Bucket) *DeletionRequests {
+	return &DeletionRequests{logger: logger, bkt: bkt}
+}
+
+// Add validates and persists a new deletion request of series matching the matchers within the given time range.
+func (d *DeletionRequests) Add(ctx context.Context, matchers string, minTime, maxTime int64) (*DeletionRequest, error) {
+	if minTime > maxTime {
+		return nil, errors.Errorf("min time %d is after max time %d", minTime, maxTime)
+	}
+	r := &DeletionRequest{
+		ID:           ulid.MustNew(ulid.Now(), rand.Reader),
+		Version:      DeletionRequestVersion1,
+		Matchers:     matchers,
+		MinTime:      minTime,
+		MaxTime:      maxTime,
+		CreationTime: time.Now().Unix(),
+		Status:       DeletionRequestPending,
+	}
+	if _, err := r.Deletion(); err != nil {
+		return nil, err
+	}
+	if err := d.upload(ctx, r); err != nil {
+		return nil, err
+	}
+	level.Info(d.logger).Log("msg", "deletion request added", "id", r.ID, "matchers", r.Matchers, "minTime", r.MinTime, "maxTime", r.MaxTime)
+	return r, nil
+}
+
+// List returns all deletion requests from the bucket, ordered by their creation.
+func (d *DeletionRequests) List(ctx context.Context) ([]*DeletionRequest, error) {
+	var reqs []*DeletionRequest
+	if err := d.bkt.Iter(ctx, DeletionRequestsDir, func(name string) error {
+		if !strings.HasSuffix(name, ".json") {
+			return nil
+		}
+		rc, err := d.bkt.Get(ctx, name)
+		if err != nil {
+			return errors.Wrapf(err, "get deletion request %s", name)
+		}
+		defer runutil.CloseWithLogOnErr(d.logger, rc, "close deletion request reader")
+
+		r := &DeletionRequest{}
+		if err := json.NewDecoder(rc).Decode(r); err != nil {
+			return errors.Wrapf(err, "decode deletion request %s", name)
+		}
+		if r.Version != DeletionRequestVersion1 {
+			return errors.Errorf("unexpected deletion request %s version %d, expected %d", name, r.Version, DeletionRequestVersion1)
+		}
+		reqs = append(reqs, r)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "iterate deletion requests")
+	}
+	sort.Slice(reqs, func(i, j int) bool {
+		return reqs[i].ID.Compare(reqs[j].ID) < 0
+	})
+	return reqs, nil
+}
+
+func (d *DeletionRequests) upload(ctx context.Context, r *DeletionRequest) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "json encode deletion request")
+	}
+	name := path.Join(DeletionRequestsDir, r.ID.String()+".json")
+	if err := d.bkt.Upload(ctx, name, bytes.NewReader(b)); err != nil {
+		return errors.Wrapf(err, "upload deletion request %s", name)
+	}
+	return nil
+}
+
+// DeletionRequestsProcessor applies the series deletion requests by rewriting the affected blocks and marking the
+// source blocks for deletion.
+type DeletionRequestsProcessor struct {
+	logger             log.Logger
+	bkt                objstore.Bucket
+	requests           *DeletionRequests
+	dir                string
+	hashFunc           metadata.HashFunc
+	chunkPool          chunkenc.Pool
+	deletionMarkFilter *block.IgnoreDeletionMarkFilter
+	noCompactFilter    *GatherNoCompactionMarkFilter
+
+	pending                 prometheus.Gauge
+	blocksRewritten         prometheus.Counter
+	failures                prometheus.Counter
+	blocksMarkedForDeletion prometheus.Counter
+}
+
+// NewDeletionRequestsProcessor creates DeletionRequestsProcessor. Blocks marked for deletion or no compaction by the given
+// filters are not rewritten.
+func NewDeletionRequestsProcessor(
+	logger log.Logger,
+	reg prometheus.Registerer,
+	bkt objstore.Bucket,
+	dir string,
+	deletionMarkFilter *block.IgnoreDeletionMarkFilter,
+	noCompactFilter *GatherNoCompactionMarkFilter,
+	hashFunc metadata.HashFunc,
+	blocksMarkedForDeletion prometheus.Counter,
+) *DeletionRequestsProcessor {
+	return &DeletionRequestsProcessor{
+		logger:             logger,
+		bkt:                bkt,
+		requests:           NewDeletionRequests(logger, bkt),
+		dir:                dir,
+		hashFunc:           hashFunc,
+		chunkPool:          downsample.NewPool(),
+		deletionMarkFilter: deletionMarkFilter,
+		noCompactFilter:    noCompactFilter,
+
+		pending: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "thanos_compact_deletion_requests_pending",
+			Help: "Number of series deletion requests which are not done yet.",
+		}),
+		blocksRewritten: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "thanos_compact_deletion_requests_blocks_rewritten_total",
+			Help: "Total number of blocks rewritten to apply series deletion requests.",
+		}),
+		failures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "thanos_compact_deletion_requests_failures_total",
+			Help: "Total number of failed attempts to apply series deletion requests.",
+		}),
+		blocksMarkedForDeletion: blocksMarkedForDeletion,
+	}
+}
+
+// Process applies all deletion requests which are not done yet to the given blocks.
+func (p *DeletionRequestsProcessor) Process(ctx context.Context, metas map[ulid.ULID]*metadata.Meta) error {
+	reqs, err := p.requests.List(ctx)
+	if err != nil {
+		return retry(err)
+	}
+
+	pending := 0
+	for _, r := range reqs {
+		if r.Status == DeletionRequestDone {
+			continue
+		}
+		if err := p.process(ctx, r, metas); err != nil {
+			p.failures.Inc()
+			r.LastError = err.Error()
+			if uerr := p.requests.upload(ctx, r); uerr != nil {
+				level.Warn(p.logger).Log("msg", "failed to update deletion request", "id", r.ID, "err", uerr)
+			}
+			return errors.Wrapf(err, "apply deletion request %v", r.ID)
+		}
+		if r.Status != DeletionRequestDone {
+			pending++
+		}
+	}
+	p.pending.Set(float64(pending))
+	return nil
+}
+
+func (p *DeletionRequestsProcessor) process(ctx context.Context, r *DeletionRequest, metas map[ulid.ULID]*metadata.Meta) error {
+	deletion, err := r.Deletion()
+	if err != nil {
+		return err
+	}
+
+	done := make(map[ulid.ULID]struct{}, len(r.RewrittenBlocks)+len(r.UnaffectedBlocks))
+	for _, id := range r.RewrittenBlocks {
+		done[id] = struct{}{}
+	}
+	for _, id := range r.UnaffectedBlocks {
+		done[id] = struct{}{}
+	}
+	var (
+		deletionMarked  = p.deletionMarkFilter.DeletionMarkBlocks()
+		noCompactMarked = p.noCompactFilter.NoCompactMarkedBlocks()
+		affected        []*metadata.Meta
+	)
+	for id, m := range metas {
+		if _, ok := deletionMarked[id]; ok {
+			continue
+		}
+		if !r.affects(m, done) {
+			continue
+		}
+		if _, ok := deletionForBlock(deletion, m.Thanos.Labels); !ok {
+			continue
+		}
+		affected = append(affected, m)
+	}
+	sort.Slice(affected, func(i, j int) bool {
+		return affected[i].ULID.Compare(affected[j].ULID) < 0
+	})
+
+	// Skipped blocks are determined again on each attempt, as they can be gone or unmarked in the meantime.
+	r.SkippedBlocks = nil
+	rewrittenBlocks := 0
+	for _, m := range affected {
+		// Downsampled blocks can't be rewritten, as it would re-encode aggregated chunks as raw chunks.
+		_, noCompact := noCompactMarked[m.ULID]
+		canRewrite := !noCompact && m.Thanos.Downsample.Resolution == 0
+
+		blockDeletion, _ := deletionForBlock(deletion, m.Thanos.Labels)
+		newID, ok, err := p.rewrite(ctx, m, blockDeletion, canRewrite)
+		if err != nil {
+			return errors.Wrapf(err, "rewrite block %v", m.ULID)
+		}
+		if !ok {
+			level.Info(p.logger).Log("msg", "block does not contain samples of deletion request", "block", m.ULID, "request", r.ID)
+			r.UnaffectedBlocks = append(r.UnaffectedBlocks, m.ULID)
+			if err := p.requests.upload(ctx, r); err != nil {
+				return retry(err)
+			}
+			continue
+		}
+		if !canRewrite {
+			level.Warn(p.logger).Log("msg", "block containing samples of deletion request can't be rewritten, as it is downsampled or marked for no compaction",
+				"block", m.ULID, "request", r.ID)
+			r.SkippedBlocks = append(r.SkippedBlocks, m.ULID)
+			continue
+		}
+		p.blocksRewritten.Inc()
+		rewrittenBlocks++
+
+		r.Status = DeletionRequestInProgress
+		r.RewrittenBlocks = append(r.RewrittenBlocks, newID)
+		// Persist the progress before the source block is gone, so the new block is not rewritten again in case of a crash.
+		if err := p.requests.upload(ctx, r); err != nil {
+			return retry(err)
+		}
+		if err := block.MarkForDeletion(ctx, p.logger, p.bkt, m.ULID, fmt.Sprintf("rewritten into %v for deletion request %v", newID, r.ID), p.blocksMarkedForDeletion); err != nil {
+			return retry(errors.Wrapf(err, "mark block %v for deletion", m.ULID))
+		}
+	}
+
+	r.LastError = ""
+	if len(r.SkippedBlocks) > 0 {
+		if err := p.requests.upload(ctx, r); err != nil {
+			return retry(err)
+		}
+		level.Warn(p.logger).Log("msg", "deletion request not done, as some blocks can't be rewritten", "id", r.ID, "rewrittenBlocks", rewrittenBlocks, "skippedBlocks", len(r.SkippedBlocks))
+		return nil
+	}
+
+	r.Status = DeletionRequestDone
+	r.FinishTime = time.Now().Unix()
+	if err := p.requests.upload(ctx, r); err != nil {
+		return retry(err)
+	}
+	level.Info(p.logger).Log("msg", "deletion request done", "id", r.ID, "rewrittenBlocks", rewrittenBlocks)
+	return nil
+}
+
+// rewrite rewrites the block without the deleted series and uploads it. It returns the ID of the new block, or false
+// if the block does not contain any deleted samples, in which case nothing is uploaded. If write is false, the block
+// is only checked for deleted samples.
+func (p *DeletionRequestsProcessor) rewrite(ctx context.Context, m *metadata.Meta, deletion metadata.DeletionRequest, write bool) (ulid.ULID, bool, error) {
+	if err := os.RemoveAll(p.dir); err != nil {
+		return ulid.ULID{}, false, errors.Wrap(err, "clean working directory")
+	}
+	defer func() {
+		if err := os.RemoveAll(p.dir); err != nil {
+			level.Warn(p.logger).Log("msg", "failed to clean working directory", "dir", p.dir, "err", err)
+		}
+	}()
+
+	bdir := filepath.Join(p.dir, m.ULID.String())
+	if err := block.Download(ctx, p.logger, p.bkt, m.ULID, bdir); err != nil {
+		return ulid.ULID{}, false, retry(errors.Wrapf(err, "download block %v", m.ULID))
+	}
+	meta, err := metadata.ReadFromDir(bdir)
+	if err != nil {
+		return ulid.ULID{}, false, errors.Wrapf(err, "read meta of %v", m.ULID)
+	}
+	b, err := tsdb.OpenBlock(p.logger, bdir, p.chunkPool)
+	if err != nil {
+		return ulid.ULID{}, false, errors.Wrapf(err, "open block %v", m.ULID)
+	}
+	defer runutil.CloseWithLogOnErr(p.logger, b, "close block %v", m.ULID)
+
+	ok, err := hasDeletedSamples(b, deletion)
+	if err != nil {
+		return ulid.ULID{}, false, errors.Wrapf(err, "check series of %v", m.ULID)
+	}
+	if !ok || !write {
+		return ulid.ULID{}, ok, nil
+	}
+
+	newID := ulid.MustNew(ulid.Now(), rand.Reader)
+	meta.ULID = newID
+	meta.Thanos.Rewrites = append(meta.Thanos.Rewrites, metadata.Rewrite{
+		Sources:          meta.Compaction.Sources,
+		DeletionsApplied: []metadata.DeletionRequest{deletion},
+	})
+	meta.Compaction.Sources = []ulid.ULID{newID}
+	meta.Thanos.Source = metadata.CompactorSource
+
+	newDir := filepath.Join(p.dir, newID.String())
+	if err := os.MkdirAll(newDir, os.ModePerm); err != nil {
+		return ulid.ULID{}, false, err
+	}
+	d, err := block.NewDiskWriter(ctx, p.logger, newDir)
+	if err != nil {
+		return ulid.ULID{}, false, err
+	}
+
+	level.Info(p.logger).Log("msg", "rewriting block for deletion request", "source", m.ULID, "new", newID)
+	comp := compactv2.New(p.dir, p.logger, compactv2.NewChangeLog(ioutil.Discard), p.chunkPool)
+	if err := comp.WriteSeries(ctx, []block.Reader{b}, d, compactv2.NewProgressLogger(p.logger, int(b.Meta().Stats.NumSeries)), compactv2.WithDeletionModifier(deletion)); err != nil {
+		return ulid.ULID{}, false, errors.Wrapf(err, "write series from %v to %v", m.ULID, newID)
+	}
+	meta.Stats, err = d.Flush()
+	if err != nil {
+		return ulid.ULID{}, false, errors.Wrap(err, "flush")
+	}
+	if meta.Stats.NumSamples == b.Meta().Stats.NumSamples && meta.Stats.NumSeries == b.Meta().Stats.NumSeries {
+		return ulid.ULID{}, false, nil
+	}
+	if err := meta.WriteToDir(p.logger, newDir); err != nil {
+		return ulid.ULID{}, false, err
+	}
+	if err := block.VerifyIndex(p.logger, filepath.Join(newDir, block.IndexFilename), meta.MinTime, meta.MaxTime); err != nil {
+		return ulid.ULID{}, false, halt(errors.Wrapf(err, "invalid result block %s", newDir))
+	}
+
+	if err := block.Upload(ctx, p.logger, p.bkt, newDir, p.hashFunc); err != nil {
+		return ulid.ULID{}, false, retry(errors.Wrapf(err, "upload of %s failed", newID))
+	}
+	return newID, true, nil
+}
+
+// hasDeletedSamples returns true if the block contains a series matching the deletion with a chunk overlapping
+// its time range.
+func hasDeletedSamples(b *tsdb.Block, deletion metadata.DeletionRequest) (_ bool, err error) {
+	ir, err := b.Index()
+	if err != nil {
+		return false, errors.Wrap(err, "open index")
+	}
+	defer runutil.CloseWithErrCapture(&err, ir, "close index")
+
+	var postings index.Postings
+	if len(deletion.Matchers) == 0 {
+		postings, err = ir.Postings(index.AllPostingsKey())
+	} else {
+		postings, err = tsdb.PostingsForMatchers(ir, deletion.Matchers...)
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "get postings")
+	}
+
+	var (
+		lset labels.Labels
+		chks []chunks.Meta
+	)
+	for postings.Next() {
+		if err := ir.Series(postings.At(), &lset, &chks); err != nil {
+			return false, errors.Wrapf(err, "get series %d", postings.At())
+		}
+		for _, c := range chks {
+			for _, in := range deletion.Intervals {
+				if c.MinTime <= in.Maxt && c.MaxTime >= in.Mint {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, errors.Wrap(postings.Err(), "iterate postings")
+}
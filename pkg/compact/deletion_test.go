@@ -0,0 +1,139 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package compact
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func TestDeletionRequestsProcessor_Process(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	dir, err := ioutil.TempDir("", "test-deletion-requests")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	logger := log.NewNopLogger()
+	bkt := objstore.WithNoopInstr(objstore.NewInMemBucket())
+	series := []labels.Labels{{{Name: "a", Value: "1"}}, {{Name: "a", Value: "2"}}}
+	metas := createAndUpload(t, bkt, []blockgenSpec{
+		{numSamples: 100, mint: 0, maxt: 1000, series: series, extLset: labels.Labels{{Name: "e1", Value: "1"}}},
+		{numSamples: 100, mint: 2000, maxt: 3000, series: series, extLset: labels.Labels{{Name: "e1", Value: "1"}}},
+		// Block without matching series.
+		{numSamples: 100, mint: 0, maxt: 1000, series: series[:1], extLset: labels.Labels{{Name: "e1", Value: "1"}, {Name: "e2", Value: "1"}}},
+		// Block with external labels not matching the request.
+		{numSamples: 100, mint: 0, maxt: 1000, series: series, extLset: labels.Labels{{Name: "e1", Value: "2"}}},
+		// Downsampled block with matching series.
+		{numSamples: 100, mint: 0, maxt: 1000, series: series, extLset: labels.Labels{{Name: "e1", Value: "1"}}, res: 1000},
+		// Block where the label of the second request is missing in some series.
+		{numSamples: 100, mint: 4000, maxt: 5000, series: []labels.Labels{
+			{{Name: "a", Value: "1"}},
+			{{Name: "a", Value: "2"}, {Name: "b", Value: "x"}},
+			{{Name: "a", Value: "3"}},
+		}, extLset: labels.Labels{{Name: "e1", Value: "3"}}},
+	})
+
+	requests := NewDeletionRequests(logger, bkt)
+	_, err = requests.Add(ctx, "{a=", 0, 500)
+	testutil.NotOk(t, err)
+	req, err := requests.Add(ctx, `{a="2", e1="1"}`, 0, 1500)
+	testutil.Ok(t, err)
+	req2, err := requests.Add(ctx, `{b="x"}`, 4000, 5000)
+	testutil.Ok(t, err)
+
+	deletionMarkFilter := block.NewIgnoreDeletionMarkFilter(logger, bkt, 0, 1)
+	noCompactFilter := NewGatherNoCompactionMarkFilter(logger, bkt)
+	fetcher, err := block.NewMetaFetcher(logger, 1, bkt, "", nil, []block.MetadataFilter{deletionMarkFilter, noCompactFilter}, nil)
+	testutil.Ok(t, err)
+	p := NewDeletionRequestsProcessor(logger, nil, bkt, filepath.Join(dir, "deletion"), deletionMarkFilter, noCompactFilter, metadata.NoneFunc,
+		promauto.With(nil).NewCounter(prometheus.CounterOpts{}))
+
+	listRequests := func() map[ulid.ULID]*DeletionRequest {
+		reqs, err := requests.List(ctx)
+		testutil.Ok(t, err)
+		res := map[ulid.ULID]*DeletionRequest{}
+		for _, r := range reqs {
+			res[r.ID] = r
+		}
+		testutil.Equals(t, 2, len(res))
+		return res
+	}
+	readMeta := func(id ulid.ULID) *metadata.Meta {
+		rd, err := bkt.Get(ctx, path.Join(id.String(), metadata.MetaFilename))
+		testutil.Ok(t, err)
+		m, err := metadata.Read(rd)
+		testutil.Ok(t, err)
+		return m
+	}
+
+	fetched, _, err := fetcher.Fetch(ctx)
+	testutil.Ok(t, err)
+	testutil.Ok(t, p.Process(ctx, fetched))
+	testutil.Equals(t, 2.0, promtest.ToFloat64(p.blocksRewritten))
+	testutil.Equals(t, 1.0, promtest.ToFloat64(p.pending))
+
+	// The downsampled block can't be rewritten, so the first request is not done.
+	reqs := listRequests()
+	testutil.Equals(t, DeletionRequestInProgress, reqs[req.ID].Status)
+	testutil.Equals(t, 1, len(reqs[req.ID].RewrittenBlocks))
+	testutil.Equals(t, []ulid.ULID{metas[2].ULID}, reqs[req.ID].UnaffectedBlocks)
+	testutil.Equals(t, []ulid.ULID{metas[4].ULID}, reqs[req.ID].SkippedBlocks)
+	testutil.Equals(t, DeletionRequestDone, reqs[req2.ID].Status)
+	testutil.Equals(t, 1, len(reqs[req2.ID].RewrittenBlocks))
+
+	// Only blocks containing series matching the requests are rewritten, without the deleted series.
+	for i, m := range metas {
+		exists, err := bkt.Exists(ctx, path.Join(m.ULID.String(), metadata.DeletionMarkFilename))
+		testutil.Ok(t, err)
+		testutil.Equals(t, i == 0 || i == 5, exists, "deletion mark of block %d", i)
+	}
+
+	newMeta := readMeta(reqs[req.ID].RewrittenBlocks[0])
+	testutil.Equals(t, []ulid.ULID{newMeta.ULID}, newMeta.Compaction.Sources)
+	testutil.Equals(t, metas[0].Stats.NumSeries-1, newMeta.Stats.NumSeries)
+	testutil.Equals(t, metas[0].Stats.NumSamples-100, newMeta.Stats.NumSamples)
+
+	// Series without the matched label are kept.
+	newMeta = readMeta(reqs[req2.ID].RewrittenBlocks[0])
+	testutil.Equals(t, metas[5].Stats.NumSeries-1, newMeta.Stats.NumSeries)
+	testutil.Equals(t, metas[5].Stats.NumSamples-100, newMeta.Stats.NumSamples)
+
+	// Once the skipped block is gone, the request is done without rewriting blocks again.
+	testutil.Ok(t, block.MarkForDeletion(ctx, logger, bkt, metas[4].ULID, "", promauto.With(nil).NewCounter(prometheus.CounterOpts{})))
+	fetched, _, err = fetcher.Fetch(ctx)
+	testutil.Ok(t, err)
+	testutil.Ok(t, p.Process(ctx, fetched))
+	testutil.Equals(t, 2.0, promtest.ToFloat64(p.blocksRewritten))
+	testutil.Equals(t, 0.0, promtest.ToFloat64(p.pending))
+
+	reqs = listRequests()
+	testutil.Equals(t, DeletionRequestDone, reqs[req.ID].Status)
+	testutil.Equals(t, 0, len(reqs[req.ID].SkippedBlocks))
+
+	// Done requests are not applied again.
+	fetched, _, err = fetcher.Fetch(ctx)
+	testutil.Ok(t, err)
+	testutil.Ok(t, p.Process(ctx, fetched))
+	testutil.Equals(t, 2.0, promtest.ToFloat64(p.blocksRewritten))
+	testutil.Equals(t, 0.0, promtest.ToFloat64(p.pending))
+}
@@ -93,6 +93,15 @@ func Downsample(
 	}
 	defer runutil.CloseWithErrCapture(&err, streamedBlockWriter, "close stream block writer")
 
+	// Bucket series of classic histograms in raw data need consistent counter resets.
+	var histResets *histogramResets
+	if origMeta.Thanos.Downsample.Resolution == 0 {
+		histResets, err = newHistogramResets(indexr, chunkr)
+		if err != nil {
+			return id, errors.Wrap(err, "find histogram bucket series")
+		}
+	}
+
 	postings, err := indexr.Postings(index.AllPostingsKey())
 	if err != nil {
 		return id, errors.Wrap(err, "get all postings list")
@@ -143,7 +152,11 @@ func Downsample(
 					return id, errors.Wrapf(err, "expand chunk %d, series %d", c.Ref, postings.At())
 				}
 			}
-			if err := streamedBlockWriter.WriteSeries(lset, downsampleRaw(all, resolution)); err != nil {
+			resets, err := histResets.resetsFor(postings.At())
+			if err != nil {
+				return id, errors.Wrapf(err, "get histogram counter resets, series: %d", postings.At())
+			}
+			if err := streamedBlockWriter.WriteSeries(lset, downsampleRaw(all, resolution, resets)); err != nil {
 				return id, errors.Wrapf(err, "downsample raw data, series: %d", postings.At())
 			}
 		} else {
@@ -234,9 +247,11 @@ func (a *aggregator) reset() {
 	a.max = -math.MaxFloat64
 }
 
-func (a *aggregator) add(v float64) {
+// add adds the value to the aggregates. If reset is true, a counter reset right before the value is assumed
+// even if the value did not decrease.
+func (a *aggregator) add(v float64, reset bool) {
 	if a.total > 0 {
-		if v < a.last {
+		if reset || v < a.last {
 			// Counter reset, correct the value.
 			a.counter += v
 			a.resets++
@@ -315,7 +330,8 @@ func (b *aggrChunkBuilder) encode() chunks.Meta {
 }
 
 // downsampleRaw create a series of aggregation chunks for the given sample data.
-func downsampleRaw(data []sample, resolution int64) []chunks.Meta {
+// Resets are timestamps of counter resets which have to be applied on top of the ones detected in the data.
+func downsampleRaw(data []sample, resolution int64, resets counterResets) []chunks.Meta {
 	if len(data) == 0 {
 		return nil
 	}
@@ -324,10 +340,10 @@ func downsampleRaw(data []sample, resolution int64) []chunks.Meta {
 	// We assume a raw resolution of 1 minute. In practice it will often be lower
 	// but this is sufficient for our heuristic to produce well-sized chunks.
 	numChunks := targetChunkCount(mint, maxt, 1*60*1000, resolution, len(data))
-	return downsampleRawLoop(data, resolution, numChunks)
+	return downsampleRawLoop(data, resolution, numChunks, resets)
 }
 
-func downsampleRawLoop(data []sample, resolution int64, numChunks int) []chunks.Meta {
+func downsampleRawLoop(data []sample, resolution int64, numChunks int, resets counterResets) []chunks.Meta {
 	batchSize := (len(data) / numChunks) + 1
 	chks := make([]chunks.Meta, 0, numChunks)
 
@@ -343,6 +359,14 @@ func downsampleRawLoop(data []sample, resolution int64, numChunks int) []chunks.
 		for ; j < len(data) && data[j].t <= curW; j++ {
 		}
 
+		// Counter resets between chunks are detected by comparing raw values only, so we never start
+		// a new chunk with a forced reset. Grab the downsampling window of such sample as well instead.
+		for j < len(data) && resets.between(data[j-1].t, data[j].t) {
+			curW = currentWindow(data[j].t, resolution)
+			for ; j < len(data) && data[j].t <= curW; j++ {
+			}
+		}
+
 		batch := data[:j]
 		data = data[j:]
 
@@ -351,7 +375,7 @@ func downsampleRawLoop(data []sample, resolution int64, numChunks int) []chunks.
 		// Encode first raw value; see ApplyCounterResetsSeriesIterator.
 		ab.apps[AggrCounter].Append(batch[0].t, batch[0].v)
 
-		lastT := downsampleBatch(batch, resolution, ab.add)
+		lastT := downsampleBatch(batch, resolution, resets, ab.add)
 
 		// Encode last raw value; see ApplyCounterResetsSeriesIterator.
 		ab.apps[AggrCounter].Append(lastT, batch[len(batch)-1].v)
@@ -363,12 +387,13 @@ func downsampleRawLoop(data []sample, resolution int64, numChunks int) []chunks.
 }
 
 // downsampleBatch aggregates the data over the given resolution and calls add each time
-// the end of a resolution was reached.
-func downsampleBatch(data []sample, resolution int64, add func(int64, *aggregator)) int64 {
+// the end of a resolution was reached. Counter resets are applied on top of the ones detected in the data.
+func downsampleBatch(data []sample, resolution int64, resets counterResets, add func(int64, *aggregator)) int64 {
 	var (
 		aggr  aggregator
 		nextT = int64(-1)
 		lastT = data[len(data)-1].t
+		prevT = int64(math.MinInt64)
 	)
 	// Fill up one aggregate chunk with up to m samples.
 	for _, s := range data {
@@ -389,7 +414,8 @@ func downsampleBatch(data []sample, resolution int64, add func(int64, *aggregato
 				nextT = lastT
 			}
 		}
-		aggr.add(s.v)
+		aggr.add(s.v, prevT != math.MinInt64 && resets.between(prevT, s.t))
+		prevT = s.t
 	}
 	// Add the last sample.
 	add(nextT, &aggr)
@@ -480,7 +506,7 @@ func downsampleAggrBatch(chks []*AggrChunk, buf *[]sample, resolution int64) (ch
 		ab.chunks[at] = chunkenc.NewXORChunk()
 		ab.apps[at], _ = ab.chunks[at].Appender()
 
-		downsampleBatch(*buf, resolution, func(t int64, a *aggregator) {
+		downsampleBatch(*buf, resolution, nil, func(t int64, a *aggregator) {
 			if t < mint {
 				mint = t
 			} else if t > maxt {
@@ -541,7 +567,7 @@ func downsampleAggrBatch(chks []*AggrChunk, buf *[]sample, resolution int64) (ch
 	// Retain first raw value; see ApplyCounterResetsSeriesIterator.
 	ab.apps[AggrCounter].Append((*buf)[0].t, (*buf)[0].v)
 
-	lastT := downsampleBatch(*buf, resolution, func(t int64, a *aggregator) {
+	lastT := downsampleBatch(*buf, resolution, nil, func(t int64, a *aggregator) {
 		if t < mint {
 			mint = t
 		} else if t > maxt {
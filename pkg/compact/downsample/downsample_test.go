@@ -129,7 +129,7 @@ func TestDownsampleCounterBoundaryReset(t *testing.T) {
 	doTest := func(t *testing.T, test *test) {
 		// Asking for more chunks than raw samples ensures that downsampleRawLoop
 		// will create chunks with samples from a single window.
-		cm := downsampleRawLoop(test.raw, test.rawAggrResolution, len(test.raw)+1)
+		cm := downsampleRawLoop(test.raw, test.rawAggrResolution, len(test.raw)+1, nil)
 		testutil.Equals(t, test.expectedRawAggrChunks, len(cm))
 
 		rawAggrChunks := toAggrChunks(t, cm)
@@ -505,6 +505,67 @@ func TestDownsample(t *testing.T) {
 	}
 }
 
+func TestDownsample_HistogramBucketResets(t *testing.T) {
+	logger := log.NewLogfmtLogger(os.Stderr)
+
+	dir, err := ioutil.TempDir("", "downsample-histogram")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	// Process restarted at 80 and only the +Inf bucket went down. The le="1" bucket has to be reset as well.
+	in := map[string][]sample{
+		"1":    {{20, 1}, {40, 2}, {60, 3}, {80, 3}, {120, 4}, {140, 5}},
+		"+Inf": {{20, 2}, {40, 4}, {60, 6}, {80, 1}, {120, 3}, {140, 5}},
+	}
+	mb := newMemBlock()
+	for le, samples := range in {
+		ser := chunksToSeriesIteratable(t, [][]sample{samples}, nil)
+		ser.lset = labels.FromStrings("__name__", "a_bucket", "le", le)
+		mb.addSeries(ser)
+	}
+	// Series with the same samples, which is not a histogram bucket, is not affected.
+	ser := chunksToSeriesIteratable(t, [][]sample{in["1"]}, nil)
+	mb.addSeries(ser)
+
+	id, err := Downsample(logger, &metadata.Meta{}, mb, dir, 100)
+	testutil.Ok(t, err)
+
+	indexr, err := index.NewFileReader(filepath.Join(dir, id.String(), block.IndexFilename))
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, indexr.Close()) }()
+
+	chunkr, err := chunks.NewDirReader(filepath.Join(dir, id.String(), block.ChunksDirname), NewPool())
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, chunkr.Close()) }()
+
+	pall, err := indexr.Postings(index.AllPostingsKey())
+	testutil.Ok(t, err)
+
+	got := map[string][]sample{}
+	for pall.Next() {
+		var lset labels.Labels
+		var chks []chunks.Meta
+		testutil.Ok(t, indexr.Series(pall.At(), &lset, &chks))
+		testutil.Equals(t, 1, len(chks))
+
+		chk, err := chunkr.Chunk(chks[0].Ref)
+		testutil.Ok(t, err)
+		c, err := chk.(*AggrChunk).Get(AggrCounter)
+		testutil.Ok(t, err)
+
+		var buf []sample
+		testutil.Ok(t, expandChunkIterator(c.Iterator(nil), &buf))
+		got[lset.String()] = buf
+	}
+	testutil.Ok(t, pall.Err())
+
+	testutil.Equals(t, map[string][]sample{
+		`{__name__="a"}`:                   {{20, 1}, {99, 3}, {140, 5}, {140, 5}},
+		`{__name__="a_bucket", le="1"}`:    {{20, 1}, {99, 6}, {140, 8}, {140, 5}},
+		`{__name__="a_bucket", le="+Inf"}`: {{20, 2}, {99, 7}, {140, 11}, {140, 5}},
+	}, got)
+}
+
 func chunksToSeriesIteratable(t *testing.T, inRaw [][]sample, inAggr []map[AggrType][]sample) *series {
 	if len(inRaw) > 0 && len(inAggr) > 0 {
 		t.Fatalf("test must not have raw and aggregate input data at once")
@@ -855,15 +916,42 @@ func (b *memBlock) Meta() tsdb.BlockMeta {
 }
 
 func (b *memBlock) Postings(name string, val ...string) (index.Postings, error) {
-	allName, allVal := index.AllPostingsKey()
-
-	if name != allName || val[0] != allVal {
-		return nil, errors.New("unexpected call to Postings() that is not AllVall")
-	}
 	sort.Slice(b.postings, func(i, j int) bool {
 		return labels.Compare(b.series[b.postings[i]].lset, b.series[b.postings[j]].lset) < 0
 	})
-	return index.NewListPostings(b.postings), nil
+
+	allName, allVal := index.AllPostingsKey()
+	if name == allName && len(val) == 1 && val[0] == allVal {
+		return index.NewListPostings(b.postings), nil
+	}
+
+	var res []uint64
+	for _, id := range b.postings {
+		v := b.series[id].lset.Get(name)
+		for _, want := range val {
+			if v != "" && v == want {
+				res = append(res, id)
+				break
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return index.NewListPostings(res), nil
+}
+
+func (b *memBlock) SortedLabelValues(name string, _ ...*labels.Matcher) ([]string, error) {
+	vals := map[string]struct{}{}
+	for _, s := range b.series {
+		if v := s.lset.Get(name); v != "" {
+			vals[v] = struct{}{}
+		}
+	}
+	res := make([]string, 0, len(vals))
+	for v := range vals {
+		res = append(res, v)
+	}
+	sort.Strings(res)
+	return res, nil
 }
 
 func (b *memBlock) Series(id uint64, lset *labels.Labels, chks *[]chunks.Meta) error {
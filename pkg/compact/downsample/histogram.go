@@ -0,0 +1,158 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package downsample
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+)
+
+const (
+	histogramBucketSuffix = "_bucket"
+	histogramBucketLabel  = "le"
+)
+
+// histogramResets tracks counter resets of classic histograms, so that all bucket series of a
+// histogram are reset-adjusted consistently. If any bucket of a histogram reset (e.g. the process restarted),
+// all buckets are treated as reset at the same time even if their values did not decrease.
+// Otherwise each bucket is adjusted independently and quantiles calculated over downsampled data are off.
+type histogramResets struct {
+	indexr tsdb.IndexReader
+	chunkr tsdb.ChunkReader
+
+	// histograms maps series IDs of bucket series to the histogram they belong to.
+	histograms map[uint64]*histogram
+
+	lset    labels.Labels
+	chks    []chunks.Meta
+	buf     []sample
+	reuseIt chunkenc.Iterator
+}
+
+type histogram struct {
+	series []uint64 // IDs of all bucket series of the histogram.
+	// Number of bucket series which were not requested yet. Resets are dropped once it reaches zero.
+	remaining int
+	loaded    bool
+	resets    []int64 // Sorted timestamps of counter resets in any of the buckets.
+}
+
+// newHistogramResets finds bucket series of all classic histograms in the block.
+func newHistogramResets(indexr tsdb.IndexReader, chunkr tsdb.ChunkReader) (*histogramResets, error) {
+	h := &histogramResets{
+		indexr:     indexr,
+		chunkr:     chunkr,
+		histograms: map[uint64]*histogram{},
+	}
+
+	values, err := indexr.SortedLabelValues(histogramBucketLabel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get values of label %s", histogramBucketLabel)
+	}
+	if len(values) == 0 {
+		return h, nil
+	}
+	postings, err := indexr.Postings(histogramBucketLabel, values...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get postings of label %s", histogramBucketLabel)
+	}
+
+	byLabels := map[string]*histogram{}
+	for postings.Next() {
+		id := postings.At()
+		if err := indexr.Series(id, &h.lset, &h.chks); err != nil {
+			return nil, errors.Wrapf(err, "get series %d", id)
+		}
+		if !strings.HasSuffix(h.lset.Get(labels.MetricName), histogramBucketSuffix) {
+			continue
+		}
+		key := labels.NewBuilder(h.lset).Del(histogramBucketLabel).Labels().String()
+		hist, ok := byLabels[key]
+		if !ok {
+			hist = &histogram{}
+			byLabels[key] = hist
+		}
+		hist.series = append(hist.series, id)
+		hist.remaining++
+		h.histograms[id] = hist
+	}
+	if postings.Err() != nil {
+		return nil, errors.Wrap(postings.Err(), "iterate postings")
+	}
+	return h, nil
+}
+
+// resetsFor returns sorted timestamps of counter resets of the histogram the given series belongs to.
+// It returns nil if the series is not a histogram bucket. Each series is expected to be requested only once.
+func (h *histogramResets) resetsFor(id uint64) ([]int64, error) {
+	hist, ok := h.histograms[id]
+	if !ok {
+		return nil, nil
+	}
+	delete(h.histograms, id)
+	hist.remaining--
+
+	if !hist.loaded {
+		resets := map[int64]struct{}{}
+		for _, sid := range hist.series {
+			if err := h.collectResets(sid, resets); err != nil {
+				return nil, errors.Wrapf(err, "collect counter resets of series %d", sid)
+			}
+		}
+		hist.resets = make([]int64, 0, len(resets))
+		for t := range resets {
+			hist.resets = append(hist.resets, t)
+		}
+		sort.Slice(hist.resets, func(i, j int) bool { return hist.resets[i] < hist.resets[j] })
+		hist.loaded = true
+	}
+
+	resets := hist.resets
+	if hist.remaining == 0 {
+		// Release memory once all buckets are processed.
+		hist.resets = nil
+	}
+	return resets, nil
+}
+
+func (h *histogramResets) collectResets(id uint64, resets map[int64]struct{}) error {
+	if err := h.indexr.Series(id, &h.lset, &h.chks); err != nil {
+		return errors.Wrap(err, "get series")
+	}
+	h.buf = h.buf[:0]
+	for _, c := range h.chks {
+		chk, err := h.chunkr.Chunk(c.Ref)
+		if err != nil {
+			return errors.Wrapf(err, "get chunk %d", c.Ref)
+		}
+		h.reuseIt = chk.Iterator(h.reuseIt)
+		if err := expandChunkIterator(h.reuseIt, &h.buf); err != nil {
+			return errors.Wrapf(err, "expand chunk %d", c.Ref)
+		}
+	}
+	for i := 1; i < len(h.buf); i++ {
+		if h.buf[i].v < h.buf[i-1].v {
+			resets[h.buf[i].t] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// counterResets is a sorted list of timestamps of forced counter resets.
+type counterResets []int64
+
+// between returns true if there is a reset in the (mint, maxt] range.
+func (r counterResets) between(mint, maxt int64) bool {
+	if len(r) == 0 {
+		return false
+	}
+	i := sort.Search(len(r), func(i int) bool { return r[i] > mint })
+	return i < len(r) && r[i] <= maxt
+}
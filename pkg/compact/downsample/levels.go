@@ -0,0 +1,130 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package downsample
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+// Level describes a single downsampling resolution level.
+type Level struct {
+	// Resolution of the samples in this level in milliseconds.
+	Resolution int64
+	// MinSourceRange is the minimum time range (in milliseconds) a block of the previous level
+	// has to span before it is downsampled into this level.
+	MinSourceRange int64
+}
+
+func (l Level) String() string {
+	return fmt.Sprintf("%s:%s", model.Duration(time.Duration(l.Resolution)*time.Millisecond), model.Duration(time.Duration(l.MinSourceRange)*time.Millisecond))
+}
+
+// Levels is an ordered set of downsampling levels starting with raw data.
+type Levels []Level
+
+// DefaultLevels are the standard downsampling levels in Thanos: raw data is downsampled to 5m after 40h
+// and 5m data to 1h after 10d.
+var DefaultLevels = Levels{
+	{Resolution: ResLevel0},
+	{Resolution: ResLevel1, MinSourceRange: DownsampleRange0},
+	{Resolution: ResLevel2, MinSourceRange: DownsampleRange1},
+}
+
+// ParseLevels parses downsampling levels in the "<resolution>:<min-source-range>" form, e.g. "5m:40h".
+// The raw level is always implied and must not be specified.
+func ParseLevels(ss []string) (Levels, error) {
+	levels := Levels{{Resolution: ResLevel0}}
+	for _, s := range ss {
+		parts := strings.Split(s, ":")
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid downsampling level %q, expected <resolution>:<min-source-range>", s)
+		}
+		res, err := model.ParseDuration(parts[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse resolution of downsampling level %q", s)
+		}
+		minRange, err := model.ParseDuration(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse minimum source range of downsampling level %q", s)
+		}
+		levels = append(levels, Level{
+			Resolution:     time.Duration(res).Milliseconds(),
+			MinSourceRange: time.Duration(minRange).Milliseconds(),
+		})
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].Resolution < levels[j].Resolution
+	})
+	if err := levels.Validate(); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+// Validate checks if levels start with raw data and have strictly increasing resolutions and source ranges.
+func (ls Levels) Validate() error {
+	if len(ls) == 0 || ls[0].Resolution != ResLevel0 {
+		return errors.New("downsampling levels have to start with raw resolution")
+	}
+	for i := 1; i < len(ls); i++ {
+		if ls[i].Resolution <= ls[i-1].Resolution {
+			return errors.Errorf("downsampling level %s: resolution has to be greater than %s", ls[i], ls[i-1])
+		}
+		if ls[i].MinSourceRange <= ls[i].Resolution {
+			return errors.Errorf("downsampling level %s: minimum source range has to be greater than resolution", ls[i])
+		}
+		if ls[i].MinSourceRange < ls[i-1].MinSourceRange {
+			return errors.Errorf("downsampling level %s: minimum source range has to be not lower than %s", ls[i], ls[i-1])
+		}
+	}
+	return nil
+}
+
+// Resolutions returns resolutions of all levels in increasing order, starting with raw resolution.
+func (ls Levels) Resolutions() []int64 {
+	res := make([]int64, 0, len(ls))
+	for _, l := range ls {
+		res = append(res, l.Resolution)
+	}
+	return res
+}
+
+// Contains returns true if there is a level with the given resolution.
+func (ls Levels) Contains(resolution int64) bool {
+	for _, l := range ls {
+		if l.Resolution == resolution {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the level that blocks of the given resolution are downsampled into.
+// It returns false if the given resolution is the last or an unknown one.
+func (ls Levels) Next(resolution int64) (Level, bool) {
+	for i := 0; i < len(ls)-1; i++ {
+		if ls[i].Resolution == resolution {
+			return ls[i+1], true
+		}
+	}
+	return Level{}, false
+}
+
+// Strings returns levels in the form accepted by ParseLevels.
+func (ls Levels) Strings() []string {
+	res := make([]string, 0, len(ls))
+	for _, l := range ls {
+		if l.Resolution == ResLevel0 {
+			continue
+		}
+		res = append(res, l.String())
+	}
+	return res
+}
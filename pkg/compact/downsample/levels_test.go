@@ -0,0 +1,75 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package downsample
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func TestParseLevels(t *testing.T) {
+	for _, tcase := range []struct {
+		input    []string
+		expected Levels
+		err      bool
+	}{
+		{
+			input:    nil,
+			expected: Levels{{Resolution: ResLevel0}},
+		},
+		{
+			input:    []string{"5m:40h", "1h:10d"},
+			expected: DefaultLevels,
+		},
+		{
+			// Order of flags does not matter.
+			input: []string{"6h:14d", "1m:20h", "15m:7d"},
+			expected: Levels{
+				{Resolution: ResLevel0},
+				{Resolution: time.Minute.Milliseconds(), MinSourceRange: 20 * time.Hour.Milliseconds()},
+				{Resolution: 15 * time.Minute.Milliseconds(), MinSourceRange: 7 * 24 * time.Hour.Milliseconds()},
+				{Resolution: 6 * time.Hour.Milliseconds(), MinSourceRange: 14 * 24 * time.Hour.Milliseconds()},
+			},
+		},
+		{input: []string{"5m"}, err: true},
+		{input: []string{"5m:abc"}, err: true},
+		{input: []string{"0s:40h"}, err: true},
+		{input: []string{"5m:40h", "5m:10d"}, err: true},
+		{input: []string{"1h:1h"}, err: true},
+		{input: []string{"5m:10d", "1h:40h"}, err: true},
+	} {
+		t.Run("", func(t *testing.T) {
+			levels, err := ParseLevels(tcase.input)
+			if tcase.err {
+				testutil.NotOk(t, err)
+				return
+			}
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.expected, levels)
+		})
+	}
+}
+
+func TestLevels_Next(t *testing.T) {
+	next, ok := DefaultLevels.Next(ResLevel0)
+	testutil.Assert(t, ok)
+	testutil.Equals(t, DefaultLevels[1], next)
+
+	next, ok = DefaultLevels.Next(ResLevel1)
+	testutil.Assert(t, ok)
+	testutil.Equals(t, DefaultLevels[2], next)
+
+	_, ok = DefaultLevels.Next(ResLevel2)
+	testutil.Assert(t, !ok)
+
+	_, ok = DefaultLevels.Next(time.Minute.Milliseconds())
+	testutil.Assert(t, !ok)
+
+	testutil.Equals(t, []string{"5m:1d16h", "1h:10d"}, DefaultLevels.Strings())
+	levels, err := ParseLevels(DefaultLevels.Strings())
+	testutil.Ok(t, err)
+	testutil.Equals(t, DefaultLevels, levels)
+}
@@ -0,0 +1,309 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package compact
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+)
+
+// IterationPlan describes the work a single compactor iteration would do. It is built from block metadata only,
+// so the work which fails, or is excluded while executing (e.g. due to the index size limit), is still planned.
+type IterationPlan struct {
+	Groups      []PlannedGroup      `json:"groups"`
+	Compactions []PlannedCompaction `json:"compactions"`
+	Downsamples []PlannedDownsample `json:"downsamples"`
+	Deletions   []PlannedDeletion   `json:"deletions"`
+}
+
+// PlannedGroup is a compaction group as found by the grouper.
+type PlannedGroup struct {
+	Key        string            `json:"key"`
+	Labels     map[string]string `json:"labels"`
+	Resolution int64             `json:"resolution"`
+	Blocks     int               `json:"blocks"`
+	MinTime    int64             `json:"minTime"`
+	MaxTime    int64             `json:"maxTime"`
+}
+
+// PlannedCompaction is a compaction of blocks into a single new block.
+type PlannedCompaction struct {
+	Group  string      `json:"group"`
+	Blocks []ulid.ULID `json:"blocks"`
+	// Result is the ID of the simulated output block. It is referenced by the following planned work
+	// and does not match the ID of the block produced by the compactor.
+	Result  ulid.ULID `json:"result"`
+	MinTime int64     `json:"minTime"`
+	MaxTime int64     `json:"maxTime"`
+	Level   int       `json:"level"`
+}
+
+// PlannedDownsample is a downsampling of a block to the next resolution.
+type PlannedDownsample struct {
+	Group      string    `json:"group"`
+	Block      ulid.ULID `json:"block"`
+	Result     ulid.ULID `json:"result"`
+	Resolution int64     `json:"resolution"`
+	MinTime    int64     `json:"minTime"`
+	MaxTime    int64     `json:"maxTime"`
+}
+
+// PlannedDeletion is a block marked for deletion due to the retention policy.
+type PlannedDeletion struct {
+	Block      ulid.ULID     `json:"block"`
+	Resolution int64         `json:"resolution"`
+	MaxTime    int64         `json:"maxTime"`
+	Retention  time.Duration `json:"retention"`
+}
+
+// PlanIteration returns the work the compactor would do in the next iteration over the given blocks, without executing it.
+// Downsampling is not planned if less than two levels are given.
+func PlanIteration(
+	ctx context.Context,
+	grouper Grouper,
+	planner Planner,
+	metas map[ulid.ULID]*metadata.Meta,
+	levels downsample.Levels,
+	retentionByResolution map[ResolutionLevel]time.Duration,
+	now time.Time,
+) (*IterationPlan, error) {
+	groups, err := grouper.Groups(metas)
+	if err != nil {
+		return nil, errors.Wrap(err, "build compaction groups")
+	}
+
+	plan := &IterationPlan{}
+	for _, g := range groups {
+		plan.Groups = append(plan.Groups, PlannedGroup{
+			Key:        g.Key(),
+			Labels:     g.Labels().Map(),
+			Resolution: g.Resolution(),
+			Blocks:     len(g.IDs()),
+			MinTime:    g.MinTime(),
+			MaxTime:    g.MaxTime(),
+		})
+	}
+
+	var compacted map[ulid.ULID]*metadata.Meta
+	plan.Compactions, compacted, err = PlanCompactions(ctx, planner, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	var downsampled map[ulid.ULID]*metadata.Meta
+	plan.Downsamples, downsampled = PlanDownsamples(compacted, levels)
+	plan.Deletions = PlanRetention(downsampled, retentionByResolution, now)
+	return plan, nil
+}
+
+// PlanCompactions simulates compactions of the given groups until the planner has nothing left to do. It returns
+// the planned compactions in order and the metadata of all blocks after the compactions are done.
+func PlanCompactions(ctx context.Context, planner Planner, groups []*Group) ([]PlannedCompaction, map[ulid.ULID]*metadata.Meta, error) {
+	var (
+		entropy = newPlanEntropy()
+		planned []PlannedCompaction
+		res     = map[ulid.ULID]*metadata.Meta{}
+	)
+	for _, g := range groups {
+		g.mtx.Lock()
+		metas := make([]*metadata.Meta, len(g.metasByMinTime))
+		copy(metas, g.metasByMinTime)
+		g.mtx.Unlock()
+
+		for len(metas) > 0 {
+			toCompact, err := planner.Plan(ctx, metas)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "plan compaction of group %s", g.Key())
+			}
+			if len(toCompact) == 0 {
+				break
+			}
+
+			out := simulateCompaction(toCompact, entropy)
+			c := PlannedCompaction{
+				Group:   g.Key(),
+				Result:  out.ULID,
+				MinTime: out.MinTime,
+				MaxTime: out.MaxTime,
+				Level:   out.Compaction.Level,
+			}
+			compactedIDs := map[ulid.ULID]struct{}{}
+			for _, m := range toCompact {
+				c.Blocks = append(c.Blocks, m.ULID)
+				compactedIDs[m.ULID] = struct{}{}
+			}
+			planned = append(planned, c)
+
+			remaining := metas[:0]
+			for _, m := range metas {
+				if _, ok := compactedIDs[m.ULID]; !ok {
+					remaining = append(remaining, m)
+				}
+			}
+			metas = append(remaining, out)
+			sort.Slice(metas, func(i, j int) bool {
+				return metas[i].MinTime < metas[j].MinTime
+			})
+		}
+		for _, m := range metas {
+			res[m.ULID] = m
+		}
+	}
+	return planned, res, nil
+}
+
+// simulateCompaction returns metadata of the block produced by compacting the given blocks.
+func simulateCompaction(metas []*metadata.Meta, entropy io.Reader) *metadata.Meta {
+	out := &metadata.Meta{
+		BlockMeta: metas[0].BlockMeta,
+		Thanos:    metas[0].Thanos,
+	}
+	out.ULID = ulid.MustNew(ulid.Now(), entropy)
+	out.Stats = metas[0].Stats
+	out.Stats.NumTombstones = 0
+	out.Thanos.Files = nil
+	out.Compaction.Sources = nil
+	out.Compaction.Parents = nil
+
+	sources := map[ulid.ULID]struct{}{}
+	for i, m := range metas {
+		if m.MinTime < out.MinTime {
+			out.MinTime = m.MinTime
+		}
+		if m.MaxTime > out.MaxTime {
+			out.MaxTime = m.MaxTime
+		}
+		if m.Compaction.Level > out.Compaction.Level {
+			out.Compaction.Level = m.Compaction.Level
+		}
+		if i > 0 {
+			out.Stats.NumSeries += m.Stats.NumSeries
+			out.Stats.NumSamples += m.Stats.NumSamples
+			out.Stats.NumChunks += m.Stats.NumChunks
+		}
+		for _, s := range m.Compaction.Sources {
+			sources[s] = struct{}{}
+		}
+		out.Compaction.Parents = append(out.Compaction.Parents, tsdb.BlockDesc{ULID: m.ULID, MinTime: m.MinTime, MaxTime: m.MaxTime})
+	}
+	out.Compaction.Level++
+	out.Compaction.Failed = false
+	for s := range sources {
+		out.Compaction.Sources = append(out.Compaction.Sources, s)
+	}
+	sort.Slice(out.Compaction.Sources, func(i, j int) bool {
+		return out.Compaction.Sources[i].Compare(out.Compaction.Sources[j]) < 0
+	})
+	return out
+}
+
+// PlanDownsamples returns the downsamplings the compactor would do for the given blocks, in the same way as
+// the compactor does them: one pass per downsampling level. It also returns the metadata of all blocks after
+// the downsamplings are done.
+func PlanDownsamples(metas map[ulid.ULID]*metadata.Meta, levels downsample.Levels) ([]PlannedDownsample, map[ulid.ULID]*metadata.Meta) {
+	res := make(map[ulid.ULID]*metadata.Meta, len(metas))
+	for id, m := range metas {
+		res[id] = m
+	}
+	if len(levels) < 2 {
+		return nil, res
+	}
+
+	var (
+		entropy = newPlanEntropy()
+		planned []PlannedDownsample
+	)
+	for pass := 1; pass < len(levels); pass++ {
+		// Blocks are not downsampled again if a downsampled block with the same sources already exists.
+		sources := make(map[int64]map[ulid.ULID]struct{}, len(levels))
+		for _, l := range levels[1:] {
+			sources[l.Resolution] = map[ulid.ULID]struct{}{}
+		}
+		for _, m := range res {
+			s, ok := sources[m.Thanos.Downsample.Resolution]
+			if !ok || m.Thanos.Downsample.Resolution == downsample.ResLevel0 {
+				continue
+			}
+			for _, id := range m.Compaction.Sources {
+				s[id] = struct{}{}
+			}
+		}
+
+		ids := make([]ulid.ULID, 0, len(res))
+		for id := range res {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return ids[i].Compare(ids[j]) < 0
+		})
+
+		for _, id := range ids {
+			m := res[id]
+			next, ok := levels.Next(m.Thanos.Downsample.Resolution)
+			if !ok {
+				continue
+			}
+			missing := false
+			for _, s := range m.Compaction.Sources {
+				if _, ok := sources[next.Resolution][s]; !ok {
+					missing = true
+					break
+				}
+			}
+			if !missing || m.MaxTime-m.MinTime < next.MinSourceRange {
+				continue
+			}
+
+			out := &metadata.Meta{BlockMeta: m.BlockMeta, Thanos: m.Thanos}
+			out.ULID = ulid.MustNew(ulid.Now(), entropy)
+			out.Thanos.Files = nil
+			out.Thanos.Downsample.Resolution = next.Resolution
+			res[out.ULID] = out
+
+			planned = append(planned, PlannedDownsample{
+				Group:      DefaultGroupKey(m.Thanos),
+				Block:      m.ULID,
+				Result:     out.ULID,
+				Resolution: next.Resolution,
+				MinTime:    m.MinTime,
+				MaxTime:    m.MaxTime,
+			})
+		}
+	}
+	return planned, res
+}
+
+// PlanRetention returns the blocks which would be marked for deletion by ApplyRetentionPolicyByResolution at the given time.
+func PlanRetention(metas map[ulid.ULID]*metadata.Meta, retentionByResolution map[ResolutionLevel]time.Duration, now time.Time) []PlannedDeletion {
+	var planned []PlannedDeletion
+	for id, m := range metas {
+		retention, ok := exceedsRetention(m, retentionByResolution, now)
+		if !ok {
+			continue
+		}
+		planned = append(planned, PlannedDeletion{
+			Block:      id,
+			Resolution: m.Thanos.Downsample.Resolution,
+			MaxTime:    m.MaxTime,
+			Retention:  retention,
+		})
+	}
+	sort.Slice(planned, func(i, j int) bool {
+		return planned[i].Block.Compare(planned[j].Block) < 0
+	})
+	return planned
+}
+
+func newPlanEntropy() io.Reader {
+	return ulid.Monotonic(rand.New(rand.NewSource(time.Now().UnixNano())), 0)
+}
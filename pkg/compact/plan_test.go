@@ -0,0 +1,126 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package compact
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func TestPlanIteration(t *testing.T) {
+	newMeta := func(id uint64, mint, maxt int64, lbls map[string]string) *metadata.Meta {
+		uid := ulid.MustNew(id, nil)
+		return &metadata.Meta{
+			BlockMeta: tsdb.BlockMeta{
+				ULID:       uid,
+				MinTime:    mint,
+				MaxTime:    maxt,
+				Compaction: tsdb.BlockMetaCompaction{Level: 1, Sources: []ulid.ULID{uid}},
+			},
+			Thanos: metadata.Thanos{Labels: lbls},
+		}
+	}
+
+	metas := map[ulid.ULID]*metadata.Meta{}
+	for _, m := range []*metadata.Meta{
+		newMeta(1, 0, 20, map[string]string{"a": "1"}),
+		newMeta(2, 20, 40, map[string]string{"a": "1"}),
+		newMeta(3, 40, 60, map[string]string{"a": "1"}),
+		newMeta(4, 60, 80, map[string]string{"a": "1"}),
+		newMeta(5, 0, 20, map[string]string{"a": "2"}),
+	} {
+		metas[m.ULID] = m
+	}
+
+	stubCounter := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+	grouper := NewDefaultGrouper(log.NewNopLogger(), nil, false, false, false, false, nil, stubCounter, stubCounter, metadata.NoneFunc)
+	levels := downsample.Levels{{Resolution: downsample.ResLevel0}, {Resolution: 10, MinSourceRange: 60}}
+
+	plan, err := PlanIteration(
+		context.Background(),
+		grouper,
+		NewTSDBBasedPlanner(log.NewNopLogger(), []int64{20, 60, 180}),
+		metas,
+		levels,
+		map[ResolutionLevel]time.Duration{ResolutionLevelRaw: time.Hour},
+		time.Now(),
+	)
+	testutil.Ok(t, err)
+
+	testutil.Equals(t, 2, len(plan.Groups))
+	testutil.Equals(t, 5, plan.Groups[0].Blocks+plan.Groups[1].Blocks)
+
+	// Only the first three blocks of the first group are compacted, the last block is never planned.
+	testutil.Equals(t, 1, len(plan.Compactions))
+	c := plan.Compactions[0]
+	testutil.Equals(t, []ulid.ULID{ulid.MustNew(1, nil), ulid.MustNew(2, nil), ulid.MustNew(3, nil)}, c.Blocks)
+	testutil.Equals(t, int64(0), c.MinTime)
+	testutil.Equals(t, int64(60), c.MaxTime)
+	testutil.Equals(t, 2, c.Level)
+
+	// Compacted block is big enough to be downsampled.
+	testutil.Equals(t, []PlannedDownsample{{
+		Group:      DefaultGroupKey(metadata.Thanos{Labels: map[string]string{"a": "1"}}),
+		Block:      c.Result,
+		Result:     plan.Downsamples[0].Result,
+		Resolution: 10,
+		MinTime:    0,
+		MaxTime:    60,
+	}}, plan.Downsamples)
+
+	// All raw blocks exceed the retention, downsampled one is retained.
+	var deleted []ulid.ULID
+	for _, d := range plan.Deletions {
+		testutil.Equals(t, int64(downsample.ResLevel0), d.Resolution)
+		testutil.Equals(t, time.Hour, d.Retention)
+		deleted = append(deleted, d.Block)
+	}
+	testutil.Equals(t, 3, len(deleted))
+	for _, id := range []ulid.ULID{c.Result, ulid.MustNew(4, nil), ulid.MustNew(5, nil)} {
+		found := false
+		for _, d := range deleted {
+			found = found || d == id
+		}
+		testutil.Assert(t, found, "expected %v to be deleted", id)
+	}
+}
+
+func TestPlanDownsamples_AlreadyDownsampled(t *testing.T) {
+	raw := &metadata.Meta{
+		BlockMeta: tsdb.BlockMeta{
+			ULID:       ulid.MustNew(1, nil),
+			MinTime:    0,
+			MaxTime:    100,
+			Compaction: tsdb.BlockMetaCompaction{Level: 2, Sources: []ulid.ULID{ulid.MustNew(10, nil), ulid.MustNew(11, nil)}},
+		},
+	}
+	downsampled := &metadata.Meta{
+		BlockMeta: raw.BlockMeta,
+		Thanos:    metadata.Thanos{Downsample: metadata.ThanosDownsample{Resolution: 10}},
+	}
+	downsampled.ULID = ulid.MustNew(2, nil)
+
+	levels := downsample.Levels{{Resolution: downsample.ResLevel0}, {Resolution: 10, MinSourceRange: 60}, {Resolution: 50, MinSourceRange: 100}}
+	planned, metas := PlanDownsamples(map[ulid.ULID]*metadata.Meta{raw.ULID: raw, downsampled.ULID: downsampled}, levels)
+
+	// Raw block is already downsampled, only the downsampled one is planned for the next level.
+	testutil.Equals(t, 1, len(planned))
+	testutil.Equals(t, downsampled.ULID, planned[0].Block)
+	testutil.Equals(t, int64(50), planned[0].Resolution)
+	testutil.Equals(t, 3, len(metas))
+	testutil.Equals(t, int64(50), metas[planned[0].Result].Thanos.Downsample.Resolution)
+
+	planned, _ = PlanDownsamples(map[ulid.ULID]*metadata.Meta{raw.ULID: raw}, nil)
+	testutil.Equals(t, 0, len(planned))
+}
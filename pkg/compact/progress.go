@@ -0,0 +1,295 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package compact
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+)
+
+// Phases of a compactor iteration.
+const (
+	PhaseIdle         = "idle"
+	PhaseCompaction   = "compaction"
+	PhaseDownsampling = "downsampling"
+	PhaseRetention    = "retention"
+)
+
+// Progress tracks the work of the compactor. It reports the backlog of the current iteration and estimates the time
+// needed to work it down, based on the average duration of compactions and downsamplings done so far.
+// It is safe to use concurrently. Methods updating the progress are no-op on nil Progress.
+type Progress struct {
+	// Planner used to estimate the backlog. It must not have side effects, as it is called on simulated blocks.
+	planner Planner
+	levels  downsample.Levels
+
+	mtx                 sync.Mutex
+	phase               string
+	iterationStartedAt  time.Time
+	iterationFinishedAt time.Time
+	currentGroups       map[string]time.Time
+	todoCompactions     int
+	todoDownsamples     int
+	oldestPending       int64
+	compactionsDone     int
+	downsamplesDone     int
+
+	// Totals since start used to estimate the remaining time.
+	compactionsTime  time.Duration
+	compactionsCount int
+	downsamplesTime  time.Duration
+	downsamplesCount int
+}
+
+// ProgressStatus is a snapshot of the compactor progress.
+type ProgressStatus struct {
+	Phase string `json:"phase"`
+	// IterationStartedAt is the start of the current, or the last iteration if idle.
+	IterationStartedAt time.Time `json:"iterationStartedAt"`
+	// IterationFinishedAt is the end of the last finished iteration.
+	IterationFinishedAt time.Time `json:"iterationFinishedAt"`
+	// CurrentGroups are the groups being compacted at the moment.
+	CurrentGroups []ProgressGroup `json:"currentGroups"`
+
+	// TodoCompactions and TodoDownsamples is the work left in the current iteration.
+	TodoCompactions int `json:"todoCompactions"`
+	TodoDownsamples int `json:"todoDownsamples"`
+	// OldestPendingBlockTime is the min time of the oldest block waiting for compaction or downsampling.
+	// It is nil if there is no work left.
+	OldestPendingBlockTime *time.Time `json:"oldestPendingBlockTime,omitempty"`
+
+	CompactionsDone int `json:"compactionsDone"`
+	DownsamplesDone int `json:"downsamplesDone"`
+
+	// EstimatedSecondsToCatchUp is the estimated time needed to do the work left. It is nil if there is work left
+	// but no compaction or downsampling was done yet to base the estimate on.
+	EstimatedSecondsToCatchUp *float64 `json:"estimatedSecondsToCatchUp,omitempty"`
+}
+
+// ProgressGroup is a group being compacted.
+type ProgressGroup struct {
+	Key       string    `json:"key"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// NewProgress returns a new Progress. Downsampling backlog is not tracked if less than two levels are given.
+func NewProgress(reg prometheus.Registerer, planner Planner, levels downsample.Levels) *Progress {
+	p := &Progress{
+		planner:       planner,
+		levels:        levels,
+		phase:         PhaseIdle,
+		currentGroups: map[string]time.Time{},
+		oldestPending: math.MaxInt64,
+	}
+	_ = promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "thanos_compact_todo_compactions",
+		Help: "Number of compactions left to do in the current iteration of the compactor.",
+	}, func() float64 {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		return float64(p.todoCompactions)
+	})
+	_ = promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "thanos_compact_todo_downsamples",
+		Help: "Number of blocks left to downsample in the current iteration of the compactor.",
+	}, func() float64 {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		return float64(p.todoDownsamples)
+	})
+	return p
+}
+
+// StartIteration marks the start of a compactor iteration.
+func (p *Progress) StartIteration() {
+	if p == nil {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.phase = PhaseCompaction
+	p.iterationStartedAt = time.Now()
+	p.compactionsDone = 0
+	p.downsamplesDone = 0
+}
+
+// FinishIteration marks the end of a compactor iteration.
+func (p *Progress) FinishIteration() {
+	if p == nil {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.phase = PhaseIdle
+	p.iterationFinishedAt = time.Now()
+	p.todoCompactions = 0
+	p.todoDownsamples = 0
+	p.oldestPending = math.MaxInt64
+}
+
+// StartDownsampling marks the start of the downsampling phase and estimates the downsampling backlog of the given blocks.
+func (p *Progress) StartDownsampling(metas map[ulid.ULID]*metadata.Meta) {
+	if p == nil {
+		return
+	}
+	downsamples, _ := PlanDownsamples(metas, p.levels)
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.phase = PhaseDownsampling
+	p.todoCompactions = 0
+	p.todoDownsamples = len(downsamples)
+	p.oldestPending = math.MaxInt64
+	for _, d := range downsamples {
+		if d.MinTime < p.oldestPending {
+			p.oldestPending = d.MinTime
+		}
+	}
+}
+
+// DownsampleDone records a single finished downsampling.
+func (p *Progress) DownsampleDone(took time.Duration) {
+	if p == nil {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.downsamplesDone++
+	p.downsamplesTime += took
+	p.downsamplesCount++
+	if p.todoDownsamples > 0 {
+		p.todoDownsamples--
+	}
+}
+
+// StartRetention marks the start of the retention phase.
+func (p *Progress) StartRetention() {
+	if p == nil {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.phase = PhaseRetention
+	p.todoCompactions = 0
+	p.todoDownsamples = 0
+	p.oldestPending = math.MaxInt64
+}
+
+// startCompactionPass estimates the compaction and downsampling backlog of the given groups.
+func (p *Progress) startCompactionPass(ctx context.Context, groups []*Group) error {
+	if p == nil {
+		return nil
+	}
+	compactions, metas, err := PlanCompactions(ctx, p.planner, groups)
+	if err != nil {
+		return errors.Wrap(err, "plan compactions")
+	}
+	downsamples, _ := PlanDownsamples(metas, p.levels)
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.phase = PhaseCompaction
+	p.todoCompactions = len(compactions)
+	p.todoDownsamples = len(downsamples)
+	p.oldestPending = math.MaxInt64
+	for _, c := range compactions {
+		if c.MinTime < p.oldestPending {
+			p.oldestPending = c.MinTime
+		}
+	}
+	for _, d := range downsamples {
+		if d.MinTime < p.oldestPending {
+			p.oldestPending = d.MinTime
+		}
+	}
+	return nil
+}
+
+func (p *Progress) groupStarted(key string) {
+	if p == nil {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.currentGroups[key] = time.Now()
+}
+
+// groupFinished records the end of a group compaction, which compacted blocks if compacted is true.
+func (p *Progress) groupFinished(key string, compacted bool) {
+	if p == nil {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	started, ok := p.currentGroups[key]
+	delete(p.currentGroups, key)
+	if !ok || !compacted {
+		return
+	}
+	p.compactionsDone++
+	p.compactionsTime += time.Since(started)
+	p.compactionsCount++
+	if p.todoCompactions > 0 {
+		p.todoCompactions--
+	}
+}
+
+// Status returns the current progress.
+func (p *Progress) Status() ProgressStatus {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	s := ProgressStatus{
+		Phase:               p.phase,
+		IterationStartedAt:  p.iterationStartedAt,
+		IterationFinishedAt: p.iterationFinishedAt,
+		CurrentGroups:       make([]ProgressGroup, 0, len(p.currentGroups)),
+		TodoCompactions:     p.todoCompactions,
+		TodoDownsamples:     p.todoDownsamples,
+		CompactionsDone:     p.compactionsDone,
+		DownsamplesDone:     p.downsamplesDone,
+	}
+	for key, started := range p.currentGroups {
+		s.CurrentGroups = append(s.CurrentGroups, ProgressGroup{Key: key, StartedAt: started})
+	}
+	sort.Slice(s.CurrentGroups, func(i, j int) bool {
+		return s.CurrentGroups[i].Key < s.CurrentGroups[j].Key
+	})
+	if p.oldestPending != math.MaxInt64 {
+		t := time.Unix(0, p.oldestPending*int64(time.Millisecond)).UTC()
+		s.OldestPendingBlockTime = &t
+	}
+
+	if (p.todoCompactions > 0 && p.compactionsCount == 0) || (p.todoDownsamples > 0 && p.downsamplesCount == 0) {
+		return s
+	}
+	var eta time.Duration
+	if p.todoCompactions > 0 {
+		eta += time.Duration(p.todoCompactions) * p.compactionsTime / time.Duration(p.compactionsCount)
+	}
+	if p.todoDownsamples > 0 {
+		eta += time.Duration(p.todoDownsamples) * p.downsamplesTime / time.Duration(p.downsamplesCount)
+	}
+	secs := eta.Seconds()
+	s.EstimatedSecondsToCatchUp = &secs
+	return s
+}
@@ -0,0 +1,62 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package compact
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func TestProgress(t *testing.T) {
+	g, err := NewGroup(nil, nil, "group", labels.Labels{}, 0, false, false, false, false, nil, nil, nil, nil, nil, nil, nil, metadata.NoneFunc)
+	testutil.Ok(t, err)
+	for i := 0; i < 7; i++ {
+		id := ulid.MustNew(uint64(i), nil)
+		testutil.Ok(t, g.Add(&metadata.Meta{BlockMeta: tsdb.BlockMeta{
+			ULID:       id,
+			MinTime:    int64(i * 20),
+			MaxTime:    int64((i + 1) * 20),
+			Compaction: tsdb.BlockMetaCompaction{Level: 1, Sources: []ulid.ULID{id}},
+		}}))
+	}
+
+	p := NewProgress(nil, NewTSDBBasedPlanner(log.NewNopLogger(), []int64{20, 60}), nil)
+	testutil.Equals(t, PhaseIdle, p.Status().Phase)
+
+	p.StartIteration()
+	testutil.Ok(t, p.startCompactionPass(context.Background(), []*Group{g}))
+
+	s := p.Status()
+	testutil.Equals(t, PhaseCompaction, s.Phase)
+	testutil.Equals(t, 2, s.TodoCompactions)
+	testutil.Equals(t, time.Unix(0, 0).UTC(), *s.OldestPendingBlockTime)
+	// Nothing was compacted yet, so there is nothing to base the estimate on.
+	testutil.Assert(t, s.EstimatedSecondsToCatchUp == nil, "expected no estimate")
+
+	p.groupStarted(g.Key())
+	testutil.Equals(t, []ProgressGroup{{Key: g.Key(), StartedAt: p.currentGroups[g.Key()]}}, p.Status().CurrentGroups)
+	p.currentGroups[g.Key()] = time.Now().Add(-time.Minute)
+	p.groupFinished(g.Key(), true)
+
+	s = p.Status()
+	testutil.Equals(t, 0, len(s.CurrentGroups))
+	testutil.Equals(t, 1, s.TodoCompactions)
+	testutil.Equals(t, 1, s.CompactionsDone)
+	testutil.Assert(t, *s.EstimatedSecondsToCatchUp >= 60, "expected estimate of at least one compaction, got %v", *s.EstimatedSecondsToCatchUp)
+
+	p.FinishIteration()
+	s = p.Status()
+	testutil.Equals(t, PhaseIdle, s.Phase)
+	testutil.Equals(t, 0, s.TodoCompactions)
+	testutil.Assert(t, s.OldestPendingBlockTime == nil, "expected no pending blocks")
+	testutil.Equals(t, 0.0, *s.EstimatedSecondsToCatchUp)
+}
@@ -29,20 +29,28 @@ func ApplyRetentionPolicyByResolution(
 	blocksMarkedForDeletion prometheus.Counter,
 ) error {
 	level.Info(logger).Log("msg", "start optional retention")
+	now := time.Now()
 	for id, m := range metas {
-		retentionDuration := retentionByResolution[ResolutionLevel(m.Thanos.Downsample.Resolution)]
-		if retentionDuration.Seconds() == 0 {
+		retentionDuration, ok := exceedsRetention(m, retentionByResolution, now)
+		if !ok {
 			continue
 		}
 
-		maxTime := time.Unix(m.MaxTime/1000, 0)
-		if time.Now().After(maxTime.Add(retentionDuration)) {
-			level.Info(logger).Log("msg", "applying retention: marking block for deletion", "id", id, "maxTime", maxTime.String())
-			if err := block.MarkForDeletion(ctx, logger, bkt, id, fmt.Sprintf("block exceeding retention of %v", retentionDuration), blocksMarkedForDeletion); err != nil {
-				return errors.Wrap(err, "delete block")
-			}
+		level.Info(logger).Log("msg", "applying retention: marking block for deletion", "id", id, "maxTime", time.Unix(m.MaxTime/1000, 0).String())
+		if err := block.MarkForDeletion(ctx, logger, bkt, id, fmt.Sprintf("block exceeding retention of %v", retentionDuration), blocksMarkedForDeletion); err != nil {
+			return errors.Wrap(err, "delete block")
 		}
 	}
 	level.Info(logger).Log("msg", "optional retention apply done")
 	return nil
 }
+
+// exceedsRetention returns the retention of the block's resolution and true if the block exceeds it at the given time.
+func exceedsRetention(m *metadata.Meta, retentionByResolution map[ResolutionLevel]time.Duration, now time.Time) (time.Duration, bool) {
+	retentionDuration := retentionByResolution[ResolutionLevel(m.Thanos.Downsample.Resolution)]
+	if retentionDuration.Seconds() == 0 {
+		return 0, false
+	}
+	maxTime := time.Unix(m.MaxTime/1000, 0)
+	return retentionDuration, now.After(maxTime.Add(retentionDuration))
+}
@@ -47,13 +47,16 @@ func (s *lazyPopulateChunkSeriesSet) Next() bool {
 			continue
 		}
 
-		for i := range s.bufChks {
-			s.bufChks[i].Chunk = &lazyPopulatableChunk{cr: s.sReader.cr, m: &s.bufChks[i]}
+		// Chunks are copied, so the series stays valid after moving to the next one (e.g. when buffered by modifiers).
+		chks := make([]chunks.Meta, len(s.bufChks))
+		copy(chks, s.bufChks)
+		for i := range chks {
+			chks[i].Chunk = &lazyPopulatableChunk{cr: s.sReader.cr, m: &chks[i]}
 		}
 		s.curr = &storage.ChunkSeriesEntry{
 			Lset: make(labels.Labels, len(s.bufLbls)),
 			ChunkIteratorFn: func() chunks.Iterator {
-				return storage.NewListChunkSeriesIterator(s.bufChks...)
+				return storage.NewListChunkSeriesIterator(chks...)
 			},
 		}
 		// TODO: Do we need to copy this?
@@ -17,7 +17,9 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/oklog/ulid"
 	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
 	"github.com/prometheus/prometheus/tsdb/chunks"
@@ -306,6 +308,92 @@ func TestCompactor_WriteSeries_e2e(t *testing.T) {
 				NumChunks:  2,
 			},
 		},
+		{
+			name: "1 blocks + relabel modifier, rename label, drop series and merge colliding series",
+			input: [][]seriesSamples{
+				{
+					{lset: labels.Labels{{Name: "a", Value: "1"}, {Name: "cluster", Value: "eu"}},
+						chunks: [][]sample{{{0, 0}, {1, 1}, {2, 2}}}},
+					{lset: labels.Labels{{Name: "a", Value: "1"}, {Name: "cluster", Value: "europe"}},
+						chunks: [][]sample{{{10, 10}, {11, 11}, {20, 20}}}},
+					{lset: labels.Labels{{Name: "a", Value: "2"}, {Name: "cluster", Value: "us"}},
+						chunks: [][]sample{{{0, 0}, {1, 1}, {2, 2}}}},
+					{lset: labels.Labels{{Name: "a", Value: "3"}, {Name: "cluster", Value: "us"}},
+						chunks: [][]sample{{{0, 0}, {1, 1}, {2, 2}}}},
+				},
+			},
+			modifiers: []Modifier{WithRelabelModifier(
+				&relabel.Config{
+					Action:       relabel.Replace,
+					SourceLabels: model.LabelNames{"cluster"},
+					Regex:        relabel.MustNewRegexp("europe"),
+					TargetLabel:  "cluster",
+					Replacement:  "eu",
+				},
+				&relabel.Config{
+					Action:       relabel.Drop,
+					SourceLabels: model.LabelNames{"a"},
+					Regex:        relabel.MustNewRegexp("2"),
+				},
+				&relabel.Config{
+					Action:       relabel.Replace,
+					SourceLabels: model.LabelNames{"a"},
+					Regex:        relabel.MustNewRegexp("(3)"),
+					TargetLabel:  "b",
+					Replacement:  "$1",
+				},
+			)},
+			expected: []seriesSamples{
+				{lset: labels.Labels{{Name: "a", Value: "1"}, {Name: "cluster", Value: "eu"}},
+					chunks: [][]sample{{{0, 0}, {1, 1}, {2, 2}}, {{10, 10}, {11, 11}, {20, 20}}}},
+				{lset: labels.Labels{{Name: "a", Value: "3"}, {Name: "b", Value: "3"}, {Name: "cluster", Value: "us"}},
+					chunks: [][]sample{{{0, 0}, {1, 1}, {2, 2}}}},
+			},
+			expectedChanges: "Relabelled {a=\"1\", cluster=\"europe\"} {a=\"1\", cluster=\"eu\"}\n" +
+				"Deleted {a=\"2\", cluster=\"us\"} [{0 2}]\n" +
+				"Relabelled {a=\"3\", cluster=\"us\"} {a=\"3\", b=\"3\", cluster=\"us\"}\n",
+			expectedStats: tsdb.BlockStats{
+				NumSamples: 9,
+				NumSeries:  2,
+				NumChunks:  3,
+			},
+		},
+		{
+			name: "1 blocks + delete and relabel modifier, overlapping colliding series",
+			input: [][]seriesSamples{
+				{
+					{lset: labels.Labels{{Name: "__name__", Value: "old_metric"}, {Name: "a", Value: "1"}},
+						chunks: [][]sample{{{0, 0}, {1, 1}, {2, 2}}}},
+					{lset: labels.Labels{{Name: "__name__", Value: "new_metric"}, {Name: "a", Value: "1"}},
+						chunks: [][]sample{{{1, 10}, {3, 30}}}},
+					{lset: labels.Labels{{Name: "__name__", Value: "new_metric"}, {Name: "a", Value: "2"}},
+						chunks: [][]sample{{{0, 0}, {1, 1}, {2, 2}}}},
+				},
+			},
+			modifiers: []Modifier{
+				WithDeletionModifier(metadata.DeletionRequest{
+					Matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "a", "2")},
+				}),
+				WithRelabelModifier(&relabel.Config{
+					Action:       relabel.Replace,
+					SourceLabels: model.LabelNames{"__name__"},
+					Regex:        relabel.MustNewRegexp("old_metric"),
+					TargetLabel:  "__name__",
+					Replacement:  "new_metric",
+				}),
+			},
+			expected: []seriesSamples{
+				{lset: labels.Labels{{Name: "__name__", Value: "new_metric"}, {Name: "a", Value: "1"}},
+					chunks: [][]sample{{{0, 0}, {1, 10}, {2, 2}, {3, 30}}}},
+			},
+			expectedChanges: "Deleted {__name__=\"new_metric\", a=\"2\"} [{0 2}]\n" +
+				"Relabelled {__name__=\"old_metric\", a=\"1\"} {__name__=\"new_metric\", a=\"1\"}\n",
+			expectedStats: tsdb.BlockStats{
+				NumSamples: 4,
+				NumSeries:  1,
+				NumChunks:  1,
+			},
+		},
 	} {
 		t.Run(tcase.name, func(t *testing.T) {
 			tmpDir, err := ioutil.TempDir("", "test-series-writer")
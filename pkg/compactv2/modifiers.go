@@ -4,7 +4,11 @@
 package compactv2
 
 import (
+	"sort"
+
 	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
@@ -61,13 +65,9 @@ SeriesLoop:
 	DeletionsLoop:
 		for _, deletions := range d.d.deletions {
 			for _, m := range deletions.Matchers {
-				v := lbls.Get(m.Name)
-				if v == "" {
-					continue
-				}
-
 				// Only if all matchers in the deletion request are matched can we proceed to deletion.
-				if !m.Matches(v) {
+				// Missing labels match as empty values, like in tsdb.PostingsForMatchers.
+				if !m.Matches(lbls.Get(m.Name)) {
 					continue DeletionsLoop
 				}
 			}
@@ -328,4 +328,114 @@ func (p *delChunkSeriesIterator) Next() bool {
 
 func (p *delChunkSeriesIterator) At() chunks.Meta { return p.curr }
 
-// TODO(bwplotka): Add relabelling.
+// RelabelModifier applies relabel configs to all series. Series dropped by relabelling are deleted and series which end up
+// with the same labels are merged together.
+type RelabelModifier struct {
+	relabels []*relabel.Config
+}
+
+func WithRelabelModifier(relabels ...*relabel.Config) *RelabelModifier {
+	return &RelabelModifier{relabels: relabels}
+}
+
+// Modify relabels all series. Since relabelling changes the order of series, all series are buffered and sorted again,
+// so memory usage grows with the number of series. Only labels and chunk references are buffered, chunk data is read
+// when the series are iterated. Symbols are rebuilt from the relabelled series.
+func (d *RelabelModifier) Modify(_ index.StringIter, set storage.ChunkSeriesSet, log ChangeLogger, p ProgressLogger) (index.StringIter, storage.ChunkSeriesSet) {
+	var (
+		symbols = map[string]struct{}{}
+		series  []storage.ChunkSeries
+	)
+	for set.Next() {
+		s := set.At()
+		lbls := s.Labels()
+
+		newLbls := relabel.Process(lbls, d.relabels...)
+		if len(newLbls) == 0 {
+			chksIter := s.Iterator()
+			var deleted tombstones.Intervals
+			for chksIter.Next() {
+				chk := chksIter.At()
+				deleted = deleted.Add(tombstones.Interval{Mint: chk.MinTime, Maxt: chk.MaxTime})
+			}
+			if err := chksIter.Err(); err != nil {
+				return index.NewStringListIter(nil), errSeriesSet{err: errors.Wrapf(err, "iterate chunks of %v", lbls)}
+			}
+			log.DeleteSeries(lbls, deleted)
+			p.SeriesProcessed()
+			continue
+		}
+
+		if !labels.Equal(lbls, newLbls) {
+			log.ModifySeries(lbls, newLbls)
+		}
+		for _, l := range newLbls {
+			symbols[l.Name] = struct{}{}
+			symbols[l.Value] = struct{}{}
+		}
+		series = append(series, &storage.ChunkSeriesEntry{Lset: newLbls, ChunkIteratorFn: s.Iterator})
+	}
+	if err := set.Err(); err != nil {
+		return index.NewStringListIter(nil), errSeriesSet{err: errors.Wrap(err, "iterate series to relabel")}
+	}
+
+	sort.SliceStable(series, func(i, j int) bool {
+		return labels.Compare(series[i].Labels(), series[j].Labels()) < 0
+	})
+
+	sortedSymbols := make([]string, 0, len(symbols))
+	for s := range symbols {
+		sortedSymbols = append(sortedSymbols, s)
+	}
+	sort.Strings(sortedSymbols)
+
+	return index.NewStringListIter(sortedSymbols), &relabelModifierSeriesSet{
+		series: series,
+		merge:  storage.NewCompactingChunkSeriesMerger(storage.ChainedSeriesMerge),
+		p:      p,
+	}
+}
+
+// relabelModifierSeriesSet iterates over sorted relabelled series, merging series with the same labels.
+type relabelModifierSeriesSet struct {
+	series []storage.ChunkSeries
+	merge  storage.VerticalChunkSeriesMergeFunc
+	p      ProgressLogger
+
+	curr storage.ChunkSeries
+}
+
+func (s *relabelModifierSeriesSet) Next() bool {
+	if len(s.series) == 0 {
+		return false
+	}
+
+	i := 1
+	for ; i < len(s.series); i++ {
+		if labels.Compare(s.series[0].Labels(), s.series[i].Labels()) != 0 {
+			break
+		}
+		// Merged series are processed together with the first one.
+		s.p.SeriesProcessed()
+	}
+	if i == 1 {
+		s.curr = s.series[0]
+	} else {
+		s.curr = s.merge(s.series[:i]...)
+	}
+	s.series = s.series[i:]
+	return true
+}
+
+func (s *relabelModifierSeriesSet) At() storage.ChunkSeries { return s.curr }
+
+func (s *relabelModifierSeriesSet) Err() error { return nil }
+
+func (s *relabelModifierSeriesSet) Warnings() storage.Warnings { return nil }
+
+type errSeriesSet struct{ err error }
+
+func (errSeriesSet) Next() bool                 { return false }
+func (errSeriesSet) At() storage.ChunkSeries    { return nil }
+func (e errSeriesSet) Err() error               { return e.err }
+func (errSeriesSet) Warnings() storage.Warnings { return nil }
@@ -0,0 +1,86 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+// Package snappy registers a snappy gRPC compressor. Servers importing it are able to receive requests
+// and send responses compressed with snappy, clients can request it with grpc.UseCompressor(snappy.Name).
+package snappy
+
+import (
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"google.golang.org/grpc/encoding"
+)
+
+// Name is the name registered for the snappy compressor.
+const Name = "snappy"
+
+func init() {
+	encoding.RegisterCompressor(newCompressor())
+}
+
+type compressor struct {
+	writersPool sync.Pool
+	readersPool sync.Pool
+}
+
+func newCompressor() *compressor {
+	c := &compressor{}
+	c.readersPool = sync.Pool{
+		New: func() interface{} {
+			return &reader{Reader: snappy.NewReader(nil), pool: &c.readersPool}
+		},
+	}
+	c.writersPool = sync.Pool{
+		New: func() interface{} {
+			return &writeCloser{Writer: snappy.NewBufferedWriter(nil), pool: &c.writersPool}
+		},
+	}
+	return c
+}
+
+func (c *compressor) Name() string {
+	return Name
+}
+
+func (c *compressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	wr := c.writersPool.Get().(*writeCloser)
+	wr.Reset(w)
+	return wr, nil
+}
+
+func (c *compressor) Decompress(r io.Reader) (io.Reader, error) {
+	dr := c.readersPool.Get().(*reader)
+	dr.Reset(r)
+	return dr, nil
+}
+
+type writeCloser struct {
+	*snappy.Writer
+	pool *sync.Pool
+}
+
+// Close flushes the compressed data and returns the writer to the pool.
+func (w *writeCloser) Close() error {
+	defer func() {
+		w.Writer.Reset(nil)
+		w.pool.Put(w)
+	}()
+	return w.Writer.Close()
+}
+
+type reader struct {
+	*snappy.Reader
+	pool *sync.Pool
+}
+
+// Read returns the reader to the pool once all data is read. gRPC always reads messages until io.EOF.
+func (r *reader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	if err == io.EOF {
+		r.Reader.Reset(nil)
+		r.pool.Put(r)
+	}
+	return n, err
+}
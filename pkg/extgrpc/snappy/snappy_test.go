@@ -0,0 +1,41 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package snappy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/encoding"
+
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func TestCompressor(t *testing.T) {
+	c := encoding.GetCompressor(Name)
+	testutil.Assert(t, c != nil, "expected snappy compressor to be registered")
+
+	for _, input := range []string{"", "short", strings.Repeat("series with many repeated labels ", 10000)} {
+		// Run twice to reuse pooled writers and readers.
+		for i := 0; i < 2; i++ {
+			var buf bytes.Buffer
+			w, err := c.Compress(&buf)
+			testutil.Ok(t, err)
+			_, err = w.Write([]byte(input))
+			testutil.Ok(t, err)
+			testutil.Ok(t, w.Close())
+			if len(input) > 1000 {
+				testutil.Assert(t, buf.Len() < len(input)/10, "expected compressed size %d to be small", buf.Len())
+			}
+
+			r, err := c.Decompress(&buf)
+			testutil.Ok(t, err)
+			out, err := ioutil.ReadAll(r)
+			testutil.Ok(t, err)
+			testutil.Equals(t, input, string(out))
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package parquet
+
+import "encoding/binary"
+
+// Types of the Thrift compact protocol used by Parquet metadata.
+// See https://github.com/apache/thrift/blob/master/doc/specs/thrift-compact-protocol.md.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes Thrift structs with the compact protocol, which is all Parquet needs to write its metadata.
+type thriftWriter struct {
+	buf []byte
+	// last holds the ID of the last written field of each struct being written, as field IDs are delta encoded.
+	last []int16
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf = append(w.buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.zigzag(v)
+}
+
+func (w *thriftWriter) string(id int16, v string) {
+	w.fieldHeader(id, thriftBinary)
+	w.varint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+// list writes the header of a list field with n elements of the given type.
+// Elements are written with listI32, listString or beginStruct(0) and endStruct.
+func (w *thriftWriter) list(id int16, typ byte, n int) {
+	w.fieldHeader(id, thriftList)
+	if n < 15 {
+		w.buf = append(w.buf, byte(n)<<4|typ)
+		return
+	}
+	w.buf = append(w.buf, 0xf0|typ)
+	w.varint(uint64(n))
+}
+
+func (w *thriftWriter) listI32(v int32) {
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) listString(v string) {
+	w.varint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+// beginStruct starts a struct field with the given ID, or a top level struct or list element if the ID is 0.
+func (w *thriftWriter) beginStruct(id int16) {
+	if id != 0 {
+		w.fieldHeader(id, thriftStruct)
+	}
+	w.last = append(w.last, 0)
+}
+
+func (w *thriftWriter) endStruct() {
+	w.buf = append(w.buf, 0)
+	w.last = w.last[:len(w.last)-1]
+}
@@ -0,0 +1,331 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+// Package parquet implements a minimal writer of Apache Parquet files with samples of series,
+// see https://github.com/apache/parquet-format.
+package parquet
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+const (
+	magic = "PAR1"
+
+	// DefaultRowGroupSize is the default number of rows of a row group.
+	DefaultRowGroupSize = 128 * 1024
+
+	// Parquet physical types.
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	// Parquet repetition types.
+	repetitionRequired = 0
+	repetitionRepeated = 2
+
+	// Parquet converted types.
+	convertedUTF8            = 0
+	convertedMap             = 1
+	convertedTimestampMillis = 9
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecSnappy = 1
+
+	pageTypeData = 0
+)
+
+// column is a leaf column of the schema.
+type column struct {
+	path []string
+	typ  int32
+	// repeated is true for the key and value columns of the labels map, which have repetition and definition levels.
+	repeated bool
+}
+
+// Leaf columns of written files: a map of label names to values, timestamp in milliseconds and value of each sample.
+// Buffered data of the columns is kept in Writer.data in the same order.
+var columns = []column{
+	{path: []string{"labels", "key_value", "key"}, typ: typeByteArray, repeated: true},
+	{path: []string{"labels", "key_value", "value"}, typ: typeByteArray, repeated: true},
+	{path: []string{"timestamp"}, typ: typeInt64},
+	{path: []string{"value"}, typ: typeDouble},
+}
+
+// columnChunk holds the metadata of a written column chunk.
+type columnChunk struct {
+	offset           int64
+	numValues        int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+type rowGroup struct {
+	columns []columnChunk
+	numRows int64
+}
+
+// Writer writes samples of series into a Parquet file. Rows are buffered and written in row groups,
+// with a single Snappy compressed data page in PLAIN encoding for each column.
+type Writer struct {
+	w            io.Writer
+	offset       int64
+	rowGroupSize int
+	rowGroups    []rowGroup
+	err          error
+
+	// Labels of the last appended sample in PLAIN encoding, reused while samples of the same series are appended.
+	lastLabels         labels.Labels
+	lastKeys, lastVals []byte
+	appended           bool
+
+	// Buffered data of the current row group, with values of each column in PLAIN encoding.
+	rows                 int
+	repLevels, defLevels []byte
+	data                 [4][]byte
+}
+
+// NewWriter returns a new Writer writing into w, buffering up to rowGroupSize rows in memory.
+func NewWriter(w io.Writer, rowGroupSize int) *Writer {
+	if rowGroupSize <= 0 {
+		rowGroupSize = DefaultRowGroupSize
+	}
+	return &Writer{w: w, rowGroupSize: rowGroupSize}
+}
+
+// Append appends a sample of the series with the given labels.
+func (w *Writer) Append(lset labels.Labels, t int64, v float64) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.offset == 0 {
+		w.write([]byte(magic))
+	}
+	if !w.appended || !labels.Equal(lset, w.lastLabels) {
+		w.lastLabels, w.appended = lset, true
+		w.lastKeys, w.lastVals = w.lastKeys[:0], w.lastVals[:0]
+		for _, l := range lset {
+			w.lastKeys = appendByteArray(w.lastKeys, l.Name)
+			w.lastVals = appendByteArray(w.lastVals, l.Value)
+		}
+	}
+
+	// The first label of a row starts a new map (repetition level 0), others are repeated within it (level 1).
+	// An empty map has a single entry with definition level 0 and no value.
+	if len(lset) == 0 {
+		w.repLevels = append(w.repLevels, 0)
+		w.defLevels = append(w.defLevels, 0)
+	}
+	for i := range lset {
+		if i == 0 {
+			w.repLevels = append(w.repLevels, 0)
+		} else {
+			w.repLevels = append(w.repLevels, 1)
+		}
+		w.defLevels = append(w.defLevels, 1)
+	}
+	w.data[0] = append(w.data[0], w.lastKeys...)
+	w.data[1] = append(w.data[1], w.lastVals...)
+
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(t))
+	w.data[2] = append(w.data[2], b[:]...)
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+	w.data[3] = append(w.data[3], b[:]...)
+
+	w.rows++
+	if w.rows >= w.rowGroupSize {
+		w.flush()
+	}
+	return w.err
+}
+
+// Close writes the buffered rows and the file metadata. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.offset == 0 {
+		w.write([]byte(magic))
+	}
+	w.flush()
+
+	meta := w.fileMetadata()
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(meta)))
+	w.write(meta)
+	w.write(size[:])
+	w.write([]byte(magic))
+	return w.err
+}
+
+func (w *Writer) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+	if err != nil {
+		w.err = errors.Wrap(err, "write")
+	}
+}
+
+// flush writes the buffered rows as a row group.
+func (w *Writer) flush() {
+	if w.rows == 0 || w.err != nil {
+		return
+	}
+	rg := rowGroup{numRows: int64(w.rows)}
+	for i, c := range columns {
+		numValues := w.rows
+		var page []byte
+		if c.repeated {
+			numValues = len(w.repLevels)
+			page = appendLevels(page, w.repLevels)
+			page = appendLevels(page, w.defLevels)
+		}
+		page = append(page, w.data[i]...)
+		rg.columns = append(rg.columns, w.writePage(page, numValues))
+		w.data[i] = w.data[i][:0]
+	}
+	w.rowGroups = append(w.rowGroups, rg)
+
+	w.rows = 0
+	w.repLevels, w.defLevels = w.repLevels[:0], w.defLevels[:0]
+}
+
+// writePage writes a column chunk made of a single data page with the given uncompressed data.
+func (w *Writer) writePage(data []byte, numValues int) columnChunk {
+	compressed := snappy.Encode(nil, data)
+
+	t := &thriftWriter{}
+	t.beginStruct(0)
+	t.i32(1, pageTypeData)
+	t.i32(2, int32(len(data)))
+	t.i32(3, int32(len(compressed)))
+	t.beginStruct(5)
+	t.i32(1, int32(numValues))
+	t.i32(2, encodingPlain)
+	t.i32(3, encodingRLE)
+	t.i32(4, encodingRLE)
+	t.endStruct()
+	t.endStruct()
+
+	c := columnChunk{
+		offset:           w.offset,
+		numValues:        int64(numValues),
+		uncompressedSize: int64(len(t.buf) + len(data)),
+		compressedSize:   int64(len(t.buf) + len(compressed)),
+	}
+	w.write(t.buf)
+	w.write(compressed)
+	return c
+}
+
+// fileMetadata returns the encoded FileMetaData of the file.
+func (w *Writer) fileMetadata() []byte {
+	var numRows int64
+	for _, rg := range w.rowGroups {
+		numRows += rg.numRows
+	}
+
+	t := &thriftWriter{}
+	t.beginStruct(0)
+	t.i32(1, 1)
+
+	t.list(2, thriftStruct, 7)
+	schemaElement(t, "schema", -1, -1, 3, -1)
+	schemaElement(t, "labels", -1, repetitionRequired, 1, convertedMap)
+	schemaElement(t, "key_value", -1, repetitionRepeated, 2, -1)
+	schemaElement(t, "key", typeByteArray, repetitionRequired, 0, convertedUTF8)
+	schemaElement(t, "value", typeByteArray, repetitionRequired, 0, convertedUTF8)
+	schemaElement(t, "timestamp", typeInt64, repetitionRequired, 0, convertedTimestampMillis)
+	schemaElement(t, "value", typeDouble, repetitionRequired, 0, -1)
+
+	t.i64(3, numRows)
+	t.list(4, thriftStruct, len(w.rowGroups))
+	for _, rg := range w.rowGroups {
+		t.beginStruct(0)
+		t.list(1, thriftStruct, len(rg.columns))
+		var size int64
+		for i, c := range rg.columns {
+			size += c.uncompressedSize
+			t.beginStruct(0)
+			t.i64(2, c.offset)
+			t.beginStruct(3)
+			t.i32(1, columns[i].typ)
+			t.list(2, thriftI32, 2)
+			t.listI32(encodingPlain)
+			t.listI32(encodingRLE)
+			t.list(3, thriftBinary, len(columns[i].path))
+			for _, p := range columns[i].path {
+				t.listString(p)
+			}
+			t.i32(4, codecSnappy)
+			t.i64(5, c.numValues)
+			t.i64(6, c.uncompressedSize)
+			t.i64(7, c.compressedSize)
+			t.i64(9, c.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, size)
+		t.i64(3, rg.numRows)
+		t.endStruct()
+	}
+	t.string(6, "thanos")
+	t.endStruct()
+	return t.buf
+}
+
+// schemaElement writes a SchemaElement, leaving out optional fields given as -1.
+func schemaElement(t *thriftWriter, name string, typ, repetition, numChildren, converted int32) {
+	t.beginStruct(0)
+	if typ >= 0 {
+		t.i32(1, typ)
+	}
+	if repetition >= 0 {
+		t.i32(3, repetition)
+	}
+	t.string(4, name)
+	if numChildren > 0 {
+		t.i32(5, numChildren)
+	}
+	if converted >= 0 {
+		t.i32(6, converted)
+	}
+	t.endStruct()
+}
+
+func appendByteArray(b []byte, s string) []byte {
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(s)))
+	return append(append(b, size[:]...), s...)
+}
+
+// appendLevels appends the levels of maximum level 1 in the RLE/bit-packing hybrid encoding, prefixed by their length.
+func appendLevels(b []byte, levels []byte) []byte {
+	start := len(b)
+	b = append(b, 0, 0, 0, 0)
+	var v [binary.MaxVarintLen64]byte
+	for i := 0; i < len(levels); {
+		j := i + 1
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		// RLE run: header with the run length shifted by one, then the value in one byte.
+		b = append(b, v[:binary.PutUvarint(v[:], uint64(j-i)<<1)]...)
+		b = append(b, levels[i])
+		i = j
+	}
+	binary.LittleEndian.PutUint32(b[start:], uint32(len(b)-start-4))
+	return b
+}
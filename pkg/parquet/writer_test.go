@@ -0,0 +1,190 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+type sample struct {
+	lset labels.Labels
+	t    int64
+	v    float64
+}
+
+func TestWriter(t *testing.T) {
+	samples := []sample{
+		{lset: labels.FromStrings(labels.MetricName, "up", "job", "a"), t: 1000, v: 1},
+		{lset: labels.FromStrings(labels.MetricName, "up", "job", "a"), t: 2000, v: 0},
+		{lset: labels.FromStrings(labels.MetricName, "up", "job", "b"), t: 1000, v: math.Inf(1)},
+		{lset: labels.FromStrings(labels.MetricName, "other"), t: -5, v: 0.5},
+		{lset: labels.Labels{}, t: 3000, v: 2},
+	}
+	for _, rowGroupSize := range []int{1, 2, 100} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, rowGroupSize)
+		for _, s := range samples {
+			testutil.Ok(t, w.Append(s.lset, s.t, s.v))
+		}
+		testutil.Ok(t, w.Close())
+		testutil.Equals(t, samples, readFile(t, buf.Bytes()))
+	}
+
+	var buf bytes.Buffer
+	testutil.Ok(t, NewWriter(&buf, 0).Close())
+	testutil.Equals(t, []sample(nil), readFile(t, buf.Bytes()))
+}
+
+// readFile reads back samples of a file written by Writer.
+func readFile(t *testing.T, b []byte) []sample {
+	testutil.Equals(t, magic, string(b[:4]))
+	testutil.Equals(t, magic, string(b[len(b)-4:]))
+	size := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	meta := readStruct(t, bytes.NewReader(b[len(b)-8-size:len(b)-8]))
+
+	var names []string
+	for _, e := range meta[2].([]interface{}) {
+		names = append(names, string(e.(map[int16]interface{})[4].([]byte)))
+	}
+	testutil.Equals(t, []string{"schema", "labels", "key_value", "key", "value", "timestamp", "value"}, names)
+
+	var res []sample
+	for _, rg := range meta[4].([]interface{}) {
+		rg := rg.(map[int16]interface{})
+		cols := rg[1].([]interface{})
+		testutil.Equals(t, len(columns), len(cols))
+
+		var values [4][]byte
+		var repLevels, defLevels []byte
+		for i, c := range cols {
+			cm := c.(map[int16]interface{})[3].(map[int16]interface{})
+			var path []string
+			for _, p := range cm[3].([]interface{}) {
+				path = append(path, string(p.([]byte)))
+			}
+			testutil.Equals(t, columns[i].path, path)
+
+			r := bytes.NewReader(b[cm[9].(int64):])
+			header := readStruct(t, r)
+			compressed := make([]byte, header[3].(int64))
+			_, err := r.Read(compressed)
+			testutil.Ok(t, err)
+			page, err := snappy.Decode(nil, compressed)
+			testutil.Ok(t, err)
+			testutil.Equals(t, header[2].(int64), int64(len(page)))
+			numValues := int(header[5].(map[int16]interface{})[1].(int64))
+			testutil.Equals(t, cm[5].(int64), int64(numValues))
+
+			if columns[i].repeated {
+				repLevels, page = readLevels(t, page, numValues)
+				defLevels, page = readLevels(t, page, numValues)
+			}
+			values[i] = page
+		}
+
+		for row := 0; row < int(rg[3].(int64)); row++ {
+			s := sample{lset: labels.Labels{}}
+			for len(defLevels) > 0 && (len(s.lset) == 0 || repLevels[0] == 1) {
+				if defLevels[0] == 0 {
+					repLevels, defLevels = repLevels[1:], defLevels[1:]
+					break
+				}
+				var l labels.Label
+				l.Name, values[0] = readByteArray(values[0])
+				l.Value, values[1] = readByteArray(values[1])
+				s.lset = append(s.lset, l)
+				repLevels, defLevels = repLevels[1:], defLevels[1:]
+			}
+			s.t = int64(binary.LittleEndian.Uint64(values[2]))
+			s.v = math.Float64frombits(binary.LittleEndian.Uint64(values[3]))
+			values[2], values[3] = values[2][8:], values[3][8:]
+			res = append(res, s)
+		}
+	}
+	testutil.Equals(t, int64(len(res)), meta[3].(int64))
+	return res
+}
+
+func readByteArray(b []byte) (string, []byte) {
+	n := binary.LittleEndian.Uint32(b)
+	return string(b[4 : 4+n]), b[4+n:]
+}
+
+// readLevels reads levels in the RLE/bit-packing hybrid encoding, supporting only RLE runs.
+func readLevels(t *testing.T, b []byte, n int) ([]byte, []byte) {
+	size := binary.LittleEndian.Uint32(b)
+	r := bytes.NewReader(b[4 : 4+size])
+	var levels []byte
+	for r.Len() > 0 {
+		header, err := binary.ReadUvarint(r)
+		testutil.Ok(t, err)
+		testutil.Equals(t, uint64(0), header&1)
+		v, err := r.ReadByte()
+		testutil.Ok(t, err)
+		levels = append(levels, bytes.Repeat([]byte{v}, int(header>>1))...)
+	}
+	testutil.Equals(t, n, len(levels))
+	return levels, b[4+size:]
+}
+
+// readStruct decodes a struct in the Thrift compact protocol into a map of field IDs to values.
+func readStruct(t *testing.T, r *bytes.Reader) map[int16]interface{} {
+	res := map[int16]interface{}{}
+	var id int16
+	for {
+		h, err := r.ReadByte()
+		testutil.Ok(t, err)
+		if h == 0 {
+			return res
+		}
+		if delta := int16(h >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, err := binary.ReadVarint(r)
+			testutil.Ok(t, err)
+			id = int16(v)
+		}
+		res[id] = readValue(t, r, h&0x0f)
+	}
+}
+
+func readValue(t *testing.T, r *bytes.Reader, typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		v, err := binary.ReadVarint(r)
+		testutil.Ok(t, err)
+		return v
+	case thriftBinary:
+		n, err := binary.ReadUvarint(r)
+		testutil.Ok(t, err)
+		b := make([]byte, n)
+		_, err = r.Read(b)
+		testutil.Ok(t, err)
+		return b
+	case thriftList:
+		h, err := r.ReadByte()
+		testutil.Ok(t, err)
+		n := uint64(h >> 4)
+		if n == 15 {
+			n, err = binary.ReadUvarint(r)
+			testutil.Ok(t, err)
+		}
+		var res []interface{}
+		for i := uint64(0); i < n; i++ {
+			res = append(res, readValue(t, r, h&0x0f))
+		}
+		return res
+	case thriftStruct:
+		return readStruct(t, r)
+	}
+	t.Fatalf("unexpected type %d", typ)
+	return nil
+}
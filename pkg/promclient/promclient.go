@@ -36,6 +36,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/runutil"
 	"github.com/thanos-io/thanos/pkg/store/storepb"
 	"github.com/thanos-io/thanos/pkg/tracing"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus/tsdbstatuspb"
 	"google.golang.org/grpc/codes"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -758,3 +759,24 @@ func (c *Client) MetadataInGRPC(ctx context.Context, base *url.URL, metric strin
 	}
 	return v.Data, c.get2xxResultWithGRPCErrors(ctx, "/metadata HTTP[client]", &u, &v)
 }
+
+// TSDBStatusInGRPC returns the cardinality statistics of the head block from Prometheus /api/v1/status/tsdb endpoint.
+// It uses gRPC errors.
+func (c *Client) TSDBStatusInGRPC(ctx context.Context, base *url.URL) (tsdbstatuspb.TSDBStatistics, error) {
+	u := *base
+	u.Path = path.Join(u.Path, "/api/v1/status/tsdb")
+
+	var v struct {
+		Data struct {
+			HeadStats struct {
+				NumSeries uint64 `json:"numSeries"`
+			} `json:"headStats"`
+			tsdbstatuspb.TSDBStatistics
+		} `json:"data"`
+	}
+	if err := c.get2xxResultWithGRPCErrors(ctx, "/status/tsdb HTTP[client]", &u, &v); err != nil {
+		return tsdbstatuspb.TSDBStatistics{}, err
+	}
+	v.Data.TSDBStatistics.NumSeries = v.Data.HeadStats.NumSeries
+	return v.Data.TSDBStatistics, nil
+}
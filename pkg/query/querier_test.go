@@ -44,7 +44,7 @@ func TestQueryableCreator_MaxResolution(t *testing.T) {
 	queryableCreator := NewQueryableCreator(nil, nil, testProxy, 2, 5*time.Second)
 
 	oneHourMillis := int64(1*time.Hour) / int64(time.Millisecond)
-	queryable := queryableCreator(false, nil, nil, oneHourMillis, false, false)
+	queryable := queryableCreator(false, nil, nil, oneHourMillis, false, false, false)
 
 	q, err := queryable.Querier(context.Background(), 0, 42)
 	testutil.Ok(t, err)
@@ -71,7 +71,7 @@ func TestQuerier_DownsampledData(t *testing.T) {
 	}
 
 	timeout := 10 * time.Second
-	q := NewQueryableCreator(nil, nil, testProxy, 2, timeout)(false, nil, nil, 9999999, false, false)
+	q := NewQueryableCreator(nil, nil, testProxy, 2, timeout)(false, nil, nil, 9999999, false, false, false)
 	engine := promql.NewEngine(
 		promql.EngineOpts{
 			MaxSamples: math.MaxInt32,
@@ -362,7 +362,7 @@ func TestQuerier_Select_AfterPromQL(t *testing.T) {
 						g := gate.New(2)
 						mq := &mockedQueryable{
 							Creator: func(mint, maxt int64) storage.Querier {
-								return newQuerier(context.Background(), nil, mint, maxt, tcase.replicaLabels, nil, tcase.storeAPI, sc.dedup, 0, true, false, g, timeout)
+								return newQuerier(context.Background(), nil, mint, maxt, tcase.replicaLabels, nil, tcase.storeAPI, sc.dedup, 0, true, false, false, g, timeout)
 							},
 						}
 						t.Cleanup(func() {
@@ -606,7 +606,7 @@ func TestQuerier_Select(t *testing.T) {
 				{dedup: true, expected: []series{tcase.expectedAfterDedup}},
 			} {
 				g := gate.New(2)
-				q := newQuerier(context.Background(), nil, tcase.mint, tcase.maxt, tcase.replicaLabels, nil, tcase.storeAPI, sc.dedup, 0, true, false, g, timeout)
+				q := newQuerier(context.Background(), nil, tcase.mint, tcase.maxt, tcase.replicaLabels, nil, tcase.storeAPI, sc.dedup, 0, true, false, false, g, timeout)
 				t.Cleanup(func() { testutil.Ok(t, q.Close()) })
 
 				t.Run(fmt.Sprintf("dedup=%v", sc.dedup), func(t *testing.T) {
@@ -853,7 +853,7 @@ func TestQuerierWithDedupUnderstoodByPromQL_Rate(t *testing.T) {
 
 		timeout := 100 * time.Second
 		g := gate.New(2)
-		q := newQuerier(context.Background(), logger, realSeriesWithStaleMarkerMint, realSeriesWithStaleMarkerMaxt, []string{"replica"}, nil, s, false, 0, true, false, g, timeout)
+		q := newQuerier(context.Background(), logger, realSeriesWithStaleMarkerMint, realSeriesWithStaleMarkerMaxt, []string{"replica"}, nil, s, false, 0, true, false, false, g, timeout)
 		t.Cleanup(func() {
 			testutil.Ok(t, q.Close())
 		})
@@ -923,7 +923,7 @@ func TestQuerierWithDedupUnderstoodByPromQL_Rate(t *testing.T) {
 
 		timeout := 5 * time.Second
 		g := gate.New(2)
-		q := newQuerier(context.Background(), logger, realSeriesWithStaleMarkerMint, realSeriesWithStaleMarkerMaxt, []string{"replica"}, nil, s, true, 0, true, false, g, timeout)
+		q := newQuerier(context.Background(), logger, realSeriesWithStaleMarkerMint, realSeriesWithStaleMarkerMaxt, []string{"replica"}, nil, s, true, 0, true, false, false, g, timeout)
 		t.Cleanup(func() {
 			testutil.Ok(t, q.Close())
 		})
@@ -979,6 +979,56 @@ func TestQuerierWithDedupUnderstoodByPromQL_Rate(t *testing.T) {
 	})
 }
 
+func TestQuerier_QueryHints(t *testing.T) {
+	for _, tcase := range []struct {
+		name          string
+		queryPushdown bool
+		dedup         bool
+		hints         *storage.SelectHints
+		expected      *storepb.QueryHints
+	}{
+		{
+			name:          "pushdown disabled",
+			queryPushdown: false,
+			hints:         &storage.SelectHints{Func: "max_over_time", Step: 60000, Range: 300000},
+		},
+		{
+			name:          "max_over_time",
+			queryPushdown: true,
+			hints:         &storage.SelectHints{Func: "max_over_time", Step: 60000, Range: 300000},
+			expected:      &storepb.QueryHints{StepMillis: 60000, Func: &storepb.Func{Name: "max_over_time"}, Range: &storepb.Range{Millis: 300000}},
+		},
+		{
+			name:          "max_over_time with deduplication",
+			queryPushdown: true,
+			dedup:         true,
+			hints:         &storage.SelectHints{Func: "max_over_time", Step: 60000, Range: 300000},
+		},
+		{
+			name:          "count_over_time with deduplication",
+			queryPushdown: true,
+			dedup:         true,
+			hints:         &storage.SelectHints{Func: "count_over_time", Step: 60000, Range: 300000},
+			expected:      &storepb.QueryHints{StepMillis: 60000, Func: &storepb.Func{Name: "count_over_time"}, Range: &storepb.Range{Millis: 300000}},
+		},
+		{
+			name:          "unsupported function",
+			queryPushdown: true,
+			hints:         &storage.SelectHints{Func: "rate", Step: 60000, Range: 300000},
+		},
+		{
+			name:          "no range",
+			queryPushdown: true,
+			hints:         &storage.SelectHints{Func: "max_over_time", Step: 60000},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			q := newQuerier(context.Background(), nil, 0, 0, []string{"replica"}, nil, nil, tcase.dedup, 0, true, false, tcase.queryPushdown, nil, 0)
+			testutil.Equals(t, tcase.expected, q.queryHints(tcase.hints))
+		})
+	}
+}
+
 func TestSortReplicaLabel(t *testing.T) {
 	tests := []struct {
 		input       []storepb.Series
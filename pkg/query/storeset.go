@@ -25,6 +25,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/store"
 	"github.com/thanos-io/thanos/pkg/store/labelpb"
 	"github.com/thanos-io/thanos/pkg/store/storepb"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus/tsdbstatuspb"
 )
 
 const (
@@ -34,12 +35,12 @@ const (
 type StoreSpec interface {
 	// Addr returns StoreAPI Address for the store spec. It is used as ID for store.
 	Addr() string
-	// Metadata returns current labels, store type and min, max ranges for store.
+	// Metadata returns current labels, store type, min, max ranges and compressions supported for Series calls for store.
 	// It can change for every call for this method.
 	// If metadata call fails we assume that store is no longer accessible and we should not use it.
 	// NOTE: It is implementation responsibility to retry until context timeout, but a caller responsibility to manage
 	// given store connection.
-	Metadata(ctx context.Context, client storepb.StoreClient) (labelSets []labels.Labels, mint int64, maxt int64, storeType component.StoreAPI, err error)
+	Metadata(ctx context.Context, client storepb.StoreClient) (labelSets []labels.Labels, mint int64, maxt int64, storeType component.StoreAPI, seriesCompressions []string, err error)
 
 	// StrictStatic returns true if the StoreAPI has been statically defined and it is under a strict mode.
 	StrictStatic() bool
@@ -104,10 +105,10 @@ func (s *grpcStoreSpec) Addr() string {
 
 // Metadata method for gRPC store API tries to reach host Info method until context timeout. If we are unable to get metadata after
 // that time, we assume that the host is unhealthy and return error.
-func (s *grpcStoreSpec) Metadata(ctx context.Context, client storepb.StoreClient) (labelSets []labels.Labels, mint int64, maxt int64, Type component.StoreAPI, err error) {
+func (s *grpcStoreSpec) Metadata(ctx context.Context, client storepb.StoreClient) (labelSets []labels.Labels, mint int64, maxt int64, Type component.StoreAPI, seriesCompressions []string, err error) {
 	resp, err := client.Info(ctx, &storepb.InfoRequest{}, grpc.WaitForReady(true))
 	if err != nil {
-		return nil, 0, 0, nil, errors.Wrapf(err, "fetching store info from %s", s.addr)
+		return nil, 0, 0, nil, nil, errors.Wrapf(err, "fetching store info from %s", s.addr)
 	}
 	if len(resp.LabelSets) == 0 && len(resp.Labels) > 0 {
 		resp.LabelSets = []labelpb.ZLabelSet{{Labels: resp.Labels}}
@@ -117,7 +118,7 @@ func (s *grpcStoreSpec) Metadata(ctx context.Context, client storepb.StoreClient
 	for _, ls := range resp.LabelSets {
 		labelSets = append(labelSets, ls.PromLabels())
 	}
-	return labelSets, resp.MinTime, resp.MaxTime, component.FromProto(resp.StoreType), nil
+	return labelSets, resp.MinTime, resp.MaxTime, component.FromProto(resp.StoreType), resp.SeriesCompressions, nil
 }
 
 // storeSetNodeCollector is a metric collector reporting the number of available storeAPIs for Querier.
@@ -190,6 +191,8 @@ type StoreSet struct {
 	metadataSpecs       func() []MetadataSpec
 	dialOpts            []grpc.DialOption
 	gRPCInfoCallTimeout time.Duration
+	// Compressions accepted for Series responses, in order of preference.
+	seriesCompressions []string
 
 	updateMtx         sync.Mutex
 	storesMtx         sync.RWMutex
@@ -212,6 +215,7 @@ func NewStoreSet(
 	ruleSpecs func() []RuleSpec,
 	metadataSpecs func() []MetadataSpec,
 	dialOpts []grpc.DialOption,
+	seriesCompressions []string,
 	unhealthyStoreTimeout time.Duration,
 ) *StoreSet {
 	storesMetric := newStoreSetNodeCollector()
@@ -238,6 +242,7 @@ func NewStoreSet(
 		ruleSpecs:             ruleSpecs,
 		metadataSpecs:         metadataSpecs,
 		dialOpts:              dialOpts,
+		seriesCompressions:    seriesCompressions,
 		storesMetric:          storesMetric,
 		gRPCInfoCallTimeout:   5 * time.Second,
 		stores:                make(map[string]*storeRef),
@@ -257,17 +262,21 @@ type storeRef struct {
 	// If rule is not nil, then this store also supports rules API.
 	rule     rulespb.RulesClient
 	metadata metadatapb.MetadataClient
+	// TSDBStatus API is requested from every store, as stores which do not support it respond with Unimplemented error.
+	tsdbStatus tsdbstatuspb.TSDBStatusClient
 
 	// Meta (can change during runtime).
 	labelSets []labels.Labels
 	storeType component.StoreAPI
 	minTime   int64
 	maxTime   int64
+	// seriesCompression is the gRPC compressor requested for Series calls, empty for none.
+	seriesCompression string
 
 	logger log.Logger
 }
 
-func (s *storeRef) Update(labelSets []labels.Labels, minTime int64, maxTime int64, storeType component.StoreAPI, rule rulespb.RulesClient, metadata metadatapb.MetadataClient) {
+func (s *storeRef) Update(labelSets []labels.Labels, minTime int64, maxTime int64, storeType component.StoreAPI, seriesCompression string, rule rulespb.RulesClient, metadata metadatapb.MetadataClient) {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
@@ -275,10 +284,24 @@ func (s *storeRef) Update(labelSets []labels.Labels, minTime int64, maxTime int6
 	s.labelSets = labelSets
 	s.minTime = minTime
 	s.maxTime = maxTime
+	s.seriesCompression = seriesCompression
 	s.rule = rule
 	s.metadata = metadata
 }
 
+// Series calls Series of the store, requesting the negotiated compression of the response stream.
+func (s *storeRef) Series(ctx context.Context, in *storepb.SeriesRequest, opts ...grpc.CallOption) (storepb.Store_SeriesClient, error) {
+	s.mtx.RLock()
+	compression := s.seriesCompression
+	s.mtx.RUnlock()
+
+	if compression != "" {
+		// Stores compress responses with the compressor of the request.
+		opts = append(opts, grpc.UseCompressor(compression))
+	}
+	return s.StoreClient.Series(ctx, in, opts...)
+}
+
 func (s *storeRef) StoreType() component.StoreAPI {
 	s.mtx.RLock()
 	defer s.mtx.RUnlock()
@@ -465,7 +488,7 @@ func (s *StoreSet) getActiveStores(ctx context.Context, stores map[string]*store
 					return
 				}
 
-				st = &storeRef{StoreClient: storepb.NewStoreClient(conn), storeType: component.UnknownStoreAPI, cc: conn, addr: addr, logger: s.logger}
+				st = &storeRef{StoreClient: storepb.NewStoreClient(conn), tsdbStatus: tsdbstatuspb.NewTSDBStatusClient(conn), storeType: component.UnknownStoreAPI, cc: conn, addr: addr, logger: s.logger}
 			}
 
 			var rule rulespb.RulesClient
@@ -479,7 +502,7 @@ func (s *StoreSet) getActiveStores(ctx context.Context, stores map[string]*store
 			}
 
 			// Check existing or new store. Is it healthy? What are current metadata?
-			labelSets, minTime, maxTime, storeType, err := spec.Metadata(ctx, st.StoreClient)
+			labelSets, minTime, maxTime, storeType, seriesCompressions, err := spec.Metadata(ctx, st.StoreClient)
 			if err != nil {
 				if !seenAlready && !spec.StrictStatic() {
 					// Close only if new and not a strict static node.
@@ -502,7 +525,7 @@ func (s *StoreSet) getActiveStores(ctx context.Context, stores map[string]*store
 			}
 
 			s.updateStoreStatus(st, nil)
-			st.Update(labelSets, minTime, maxTime, storeType, rule, metadata)
+			st.Update(labelSets, minTime, maxTime, storeType, negotiateCompression(s.seriesCompressions, seriesCompressions), rule, metadata)
 
 			mtx.Lock()
 			defer mtx.Unlock()
@@ -520,6 +543,18 @@ func (s *StoreSet) getActiveStores(ctx context.Context, stores map[string]*store
 	return activeStores
 }
 
+// negotiateCompression returns the first accepted compression supported by the store, or an empty string if there is none.
+func negotiateCompression(accepted, supported []string) string {
+	for _, a := range accepted {
+		for _, s := range supported {
+			if a == s {
+				return a
+			}
+		}
+	}
+	return ""
+}
+
 func (s *StoreSet) updateStoreStatus(store *storeRef, err error) {
 	s.storesStatusesMtx.Lock()
 	defer s.storesStatusesMtx.Unlock()
@@ -600,6 +635,20 @@ func (s *StoreSet) GetMetadataClients() []metadatapb.MetadataClient {
 	return metadataClients
 }
 
+// GetTSDBStatusClients returns a list of TSDBStatus clients of all active stores.
+func (s *StoreSet) GetTSDBStatusClients() []tsdbstatuspb.TSDBStatusClient {
+	s.storesMtx.RLock()
+	defer s.storesMtx.RUnlock()
+
+	tsdbStatusClients := make([]tsdbstatuspb.TSDBStatusClient, 0, len(s.stores))
+	for _, st := range s.stores {
+		if st.tsdbStatus != nil {
+			tsdbStatusClients = append(tsdbStatusClients, st.tsdbStatus)
+		}
+	}
+	return tsdbStatusClients
+}
+
 func (s *StoreSet) Close() {
 	s.storesMtx.Lock()
 	defer s.storesMtx.Unlock()
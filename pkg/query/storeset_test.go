@@ -18,6 +18,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/thanos-io/thanos/pkg/component"
+	"github.com/thanos-io/thanos/pkg/extgrpc/snappy"
 	"github.com/thanos-io/thanos/pkg/store"
 	"github.com/thanos-io/thanos/pkg/store/labelpb"
 	"github.com/thanos-io/thanos/pkg/store/storepb"
@@ -32,6 +33,7 @@ var testGRPCOpts = []grpc.DialOption{
 type mockedStore struct {
 	infoDelay time.Duration
 	info      storepb.InfoResponse
+	series    *storepb.Series
 }
 
 func (s *mockedStore) Info(ctx context.Context, r *storepb.InfoRequest) (*storepb.InfoResponse, error) {
@@ -42,7 +44,10 @@ func (s *mockedStore) Info(ctx context.Context, r *storepb.InfoRequest) (*storep
 }
 
 func (s *mockedStore) Series(r *storepb.SeriesRequest, srv storepb.Store_SeriesServer) error {
-	return status.Error(codes.Unimplemented, "not implemented")
+	if s.series == nil {
+		return status.Error(codes.Unimplemented, "not implemented")
+	}
+	return srv.Send(storepb.NewSeriesResponse(s.series))
 }
 
 func (s *mockedStore) LabelNames(ctx context.Context, r *storepb.LabelNamesRequest) (
@@ -58,10 +63,12 @@ func (s *mockedStore) LabelValues(ctx context.Context, r *storepb.LabelValuesReq
 }
 
 type testStoreMeta struct {
-	extlsetFn        func(addr string) []labelpb.ZLabelSet
-	storeType        component.StoreAPI
-	minTime, maxTime int64
-	infoDelay        time.Duration
+	extlsetFn          func(addr string) []labelpb.ZLabelSet
+	storeType          component.StoreAPI
+	minTime, maxTime   int64
+	infoDelay          time.Duration
+	seriesCompressions []string
+	series             *storepb.Series
 }
 
 type testStores struct {
@@ -86,11 +93,13 @@ func startTestStores(storeMetas []testStoreMeta) (*testStores, error) {
 
 		storeSrv := &mockedStore{
 			info: storepb.InfoResponse{
-				LabelSets: meta.extlsetFn(listener.Addr().String()),
-				MaxTime:   meta.maxTime,
-				MinTime:   meta.minTime,
+				LabelSets:          meta.extlsetFn(listener.Addr().String()),
+				MaxTime:            meta.maxTime,
+				MinTime:            meta.minTime,
+				SeriesCompressions: meta.seriesCompressions,
 			},
 			infoDelay: meta.infoDelay,
+			series:    meta.series,
 		}
 		if meta.storeType != nil {
 			storeSrv.info.StoreType = meta.storeType.ToProto()
@@ -199,7 +208,7 @@ func TestStoreSet_Update(t *testing.T) {
 		func() (specs []MetadataSpec) {
 			return nil
 		},
-		testGRPCOpts, time.Minute)
+		testGRPCOpts, nil, time.Minute)
 	storeSet.gRPCInfoCallTimeout = 2 * time.Second
 	defer storeSet.Close()
 
@@ -549,7 +558,7 @@ func TestStoreSet_Update_NoneAvailable(t *testing.T) {
 		},
 		func() (specs []RuleSpec) { return nil },
 		func() (specs []MetadataSpec) { return nil },
-		testGRPCOpts, time.Minute)
+		testGRPCOpts, nil, time.Minute)
 	storeSet.gRPCInfoCallTimeout = 2 * time.Second
 
 	// Should not matter how many of these we run.
@@ -635,7 +644,7 @@ func TestQuerierStrict(t *testing.T) {
 		return nil
 	}, func() (specs []MetadataSpec) {
 		return nil
-	}, testGRPCOpts, time.Minute)
+	}, testGRPCOpts, nil, time.Minute)
 	defer storeSet.Close()
 	storeSet.gRPCInfoCallTimeout = 1 * time.Second
 
@@ -666,6 +675,61 @@ func TestQuerierStrict(t *testing.T) {
 	testutil.NotOk(t, storeSet.storeStatuses[staticStoreAddr].LastError.originalErr)
 }
 
+func TestStoreSet_SeriesCompression(t *testing.T) {
+	series := &storepb.Series{Labels: []labelpb.ZLabel{{Name: "a", Value: "b"}}}
+	stores, err := startTestStores([]testStoreMeta{
+		{
+			extlsetFn:          func(addr string) []labelpb.ZLabelSet { return nil },
+			storeType:          component.Store,
+			seriesCompressions: store.SeriesCompressions,
+			series:             series,
+		},
+		{
+			extlsetFn: func(addr string) []labelpb.ZLabelSet { return nil },
+			storeType: component.Sidecar,
+			series:    series,
+		},
+	})
+	testutil.Ok(t, err)
+	defer stores.Close()
+
+	storeSet := NewStoreSet(nil, nil, func() (specs []StoreSpec) {
+		for _, addr := range stores.StoreAddresses() {
+			specs = append(specs, NewGRPCStoreSpec(addr, false))
+		}
+		return specs
+	}, nil, nil, testGRPCOpts, []string{snappy.Name}, time.Minute)
+	defer storeSet.Close()
+
+	storeSet.Update(context.Background())
+	testutil.Equals(t, 2, len(storeSet.stores))
+	testutil.Equals(t, snappy.Name, storeSet.stores[stores.StoreAddresses()[0]].seriesCompression)
+	testutil.Equals(t, "", storeSet.stores[stores.StoreAddresses()[1]].seriesCompression)
+
+	// Series calls succeed with and without compression.
+	for _, st := range storeSet.Get() {
+		cl, err := st.Series(context.Background(), &storepb.SeriesRequest{})
+		testutil.Ok(t, err)
+		resp, err := cl.Recv()
+		testutil.Ok(t, err)
+		testutil.Equals(t, series, resp.GetSeries())
+	}
+}
+
+func TestNegotiateCompression(t *testing.T) {
+	for _, tcase := range []struct {
+		accepted, supported []string
+		expected            string
+	}{
+		{accepted: nil, supported: []string{"snappy"}, expected: ""},
+		{accepted: []string{"snappy"}, supported: nil, expected: ""},
+		{accepted: []string{"snappy"}, supported: []string{"gzip", "snappy"}, expected: "snappy"},
+		{accepted: []string{"zstd", "snappy"}, supported: []string{"snappy", "zstd"}, expected: "zstd"},
+	} {
+		testutil.Equals(t, tcase.expected, negotiateCompression(tcase.accepted, tcase.supported))
+	}
+}
+
 func TestStoreSet_Update_Rules(t *testing.T) {
 	stores, err := startTestStores([]testStoreMeta{
 		{
@@ -765,7 +829,7 @@ func TestStoreSet_Update_Rules(t *testing.T) {
 			tc.storeSpecs,
 			tc.ruleSpecs,
 			func() []MetadataSpec { return nil },
-			testGRPCOpts, time.Minute)
+			testGRPCOpts, nil, time.Minute)
 
 		t.Run(tc.name, func(t *testing.T) {
 			defer storeSet.Close()
@@ -940,7 +1004,7 @@ func TestStoreSet_Rules_Discovery(t *testing.T) {
 				func() []MetadataSpec {
 					return nil
 				},
-				testGRPCOpts, time.Minute)
+				testGRPCOpts, nil, time.Minute)
 
 			defer storeSet.Close()
 
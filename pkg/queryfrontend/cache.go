@@ -5,23 +5,28 @@ package queryfrontend
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/querier/queryrange"
-
-	"github.com/thanos-io/thanos/pkg/compact/downsample"
 )
 
 // thanosCacheKeyGenerator is a utility for using split interval when determining cache keys.
 type thanosCacheKeyGenerator struct {
 	interval    time.Duration
-	resolutions []int64
+	resolutions []int64 // Available resolutions, high to low (in milliseconds).
 }
 
-func newThanosCacheKeyGenerator(interval time.Duration) thanosCacheKeyGenerator {
+// newThanosCacheKeyGenerator creates a key generator for the given split interval and downsampling
+// resolutions in increasing order.
+func newThanosCacheKeyGenerator(interval time.Duration, resolutions []int64) thanosCacheKeyGenerator {
+	res := make([]int64, len(resolutions))
+	copy(res, resolutions)
+	sort.Slice(res, func(i, j int) bool { return res[i] > res[j] })
+
 	return thanosCacheKeyGenerator{
 		interval:    interval,
-		resolutions: []int64{downsample.ResLevel2, downsample.ResLevel1, downsample.ResLevel0},
+		resolutions: res,
 	}
 }
 
@@ -8,11 +8,12 @@ import (
 
 	"github.com/cortexproject/cortex/pkg/querier/queryrange"
 
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
 	"github.com/thanos-io/thanos/pkg/testutil"
 )
 
 func TestGenerateCacheKey(t *testing.T) {
-	splitter := newThanosCacheKeyGenerator(hour)
+	splitter := newThanosCacheKeyGenerator(hour, downsample.DefaultLevels.Resolutions())
 
 	for _, tc := range []struct {
 		name     string
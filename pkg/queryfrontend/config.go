@@ -16,6 +16,7 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
 	cortexvalidation "github.com/cortexproject/cortex/pkg/util/validation"
 	"github.com/pkg/errors"
 )
@@ -25,6 +26,7 @@ type ResponseCacheProvider string
 const (
 	INMEMORY  ResponseCacheProvider = "IN-MEMORY"
 	MEMCACHED ResponseCacheProvider = "MEMCACHED"
+	REDIS     ResponseCacheProvider = "REDIS"
 )
 
 var (
@@ -40,6 +42,17 @@ var (
 		},
 		Expiration: 24 * time.Hour,
 	}
+
+	defaultRedisConfig = RedisResponseCacheConfig{
+		Redis: cacheutil.RedisClientConfig{
+			ReadTimeout:         500 * time.Millisecond,
+			PoolSize:            100,
+			IdleTimeout:         5 * time.Minute,
+			MaxAsyncConcurrency: 10,
+			MaxAsyncBufferSize:  10000,
+		},
+		Expiration: 24 * time.Hour,
+	}
 )
 
 // InMemoryResponseCacheConfig holds the configs for the in-memory cache provider.
@@ -59,6 +72,13 @@ type MemcachedResponseCacheConfig struct {
 	Expiration time.Duration `yaml:"expiration"`
 }
 
+// RedisResponseCacheConfig holds the configs for the redis cache provider.
+type RedisResponseCacheConfig struct {
+	Redis cacheutil.RedisClientConfig `yaml:",inline"`
+	// Expiration sets a global expiration limit for all cached items.
+	Expiration time.Duration `yaml:"expiration"`
+}
+
 // CacheProviderConfig is the initial CacheProviderConfig struct holder before parsing it into a specific cache provider.
 // Based on the config type the config is then parsed into a specific cache provider.
 type CacheProviderConfig struct {
@@ -135,6 +155,61 @@ func NewCacheConfig(logger log.Logger, confContentYaml []byte) (*cortexcache.Con
 				WriteBackGoroutines: config.Memcached.MaxAsyncConcurrency,
 			},
 		}, nil
+	case string(REDIS):
+		config := defaultRedisConfig
+		if err := yaml.UnmarshalStrict(backendConfig, &config); err != nil {
+			return nil, err
+		}
+		if len(config.Redis.Addresses) == 0 {
+			return nil, errors.New("no redis addresses provided")
+		}
+		// TODO: Add support for them in the cortex module.
+		if config.Redis.MaxItemSize > 0 {
+			level.Warn(logger).Log("msg", "MaxItemSize is not yet supported by the redis client")
+		}
+		if config.Redis.TLSConfig.CAFile != "" || config.Redis.TLSConfig.CertFile != "" || config.Redis.TLSConfig.KeyFile != "" || config.Redis.TLSConfig.ServerName != "" {
+			return nil, errors.New("only insecure_skip_verify TLS option is supported by the redis response cache")
+		}
+		if config.Redis.Username != "" {
+			return nil, errors.New("username is not supported by the redis response cache")
+		}
+		if config.Redis.SentinelPassword != "" {
+			return nil, errors.New("sentinel_password is not supported by the redis response cache")
+		}
+		// The cortex client connects to a Redis Cluster only if multiple addresses are given.
+		if config.Redis.Cluster && config.Redis.MasterName == "" && len(config.Redis.Addresses) == 1 {
+			return nil, errors.New("redis cluster mode requires multiple addresses in the redis response cache")
+		}
+
+		if config.Expiration == 0 {
+			level.Warn(logger).Log("msg", "redis cache valid time set to 0, so using a default of 24 hours expiration time")
+			config.Expiration = 24 * time.Hour
+		}
+
+		if config.Redis.MaxAsyncConcurrency <= 0 {
+			level.Warn(logger).Log("msg", "redis max async concurrency must be positive, defaulting to 10")
+			config.Redis.MaxAsyncConcurrency = 10
+		}
+
+		return &cortexcache.Config{
+			Redis: cortexcache.RedisConfig{
+				Endpoint:           strings.Join(config.Redis.Addresses, ","),
+				MasterName:         config.Redis.MasterName,
+				Timeout:            config.Redis.ReadTimeout,
+				Expiration:         config.Expiration,
+				DB:                 config.Redis.DB,
+				PoolSize:           config.Redis.PoolSize,
+				Password:           flagext.Secret{Value: config.Redis.Password},
+				EnableTLS:          config.Redis.TLSEnabled,
+				InsecureSkipVerify: config.Redis.TLSConfig.InsecureSkipVerify,
+				IdleTimeout:        config.Redis.IdleTimeout,
+				MaxConnAge:         config.Redis.MaxConnectionAge,
+			},
+			Background: cortexcache.BackgroundConfig{
+				WriteBackBuffer:     config.Redis.MaxAsyncBufferSize,
+				WriteBackGoroutines: config.Redis.MaxAsyncConcurrency,
+			},
+		}, nil
 	default:
 		return nil, errors.Errorf("response cache with type %s is not supported", cacheConfig.Type)
 	}
@@ -166,6 +241,10 @@ type QueryRangeConfig struct {
 	SplitQueriesByInterval time.Duration
 	MaxRetries             int
 	Limits                 *cortexvalidation.Limits
+
+	// DownsamplingResolutions are the resolutions (in milliseconds) of the configured downsampling levels
+	// in increasing order. Defaults to the standard Thanos levels if empty.
+	DownsamplingResolutions []int64
 }
 
 // LabelsConfig holds the config for labels tripperware.
@@ -0,0 +1,79 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package queryfrontend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func TestNewCacheConfig_Redis(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "valid",
+			config: `type: REDIS
+config:
+  addresses: [a:6379, b:6379]
+  tls_enabled: true
+  tls_config:
+    insecure_skip_verify: true`,
+		},
+		{
+			name: "tls ca file",
+			config: `type: REDIS
+config:
+  addresses: [a:6379]
+  tls_enabled: true
+  tls_config:
+    ca_file: /ca.pem`,
+			err: "only insecure_skip_verify TLS option is supported by the redis response cache",
+		},
+		{
+			name: "username",
+			config: `type: REDIS
+config:
+  addresses: [a:6379]
+  username: user`,
+			err: "username is not supported by the redis response cache",
+		},
+		{
+			name: "sentinel password",
+			config: `type: REDIS
+config:
+  addresses: [a:26379]
+  master_name: master
+  sentinel_password: secret`,
+			err: "sentinel_password is not supported by the redis response cache",
+		},
+		{
+			name: "cluster with single address",
+			config: `type: REDIS
+config:
+  addresses: [a:6379]
+  cluster: true`,
+			err: "redis cluster mode requires multiple addresses in the redis response cache",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := NewCacheConfig(log.NewNopLogger(), []byte(tc.config))
+			if tc.err != "" {
+				testutil.NotOk(t, err)
+				testutil.Equals(t, tc.err, err.Error())
+				return
+			}
+			testutil.Ok(t, err)
+			testutil.Equals(t, "a:6379,b:6379", config.Redis.Endpoint)
+			testutil.Equals(t, true, config.Redis.InsecureSkipVerify)
+			testutil.Equals(t, 24*time.Hour, config.Redis.Expiration)
+		})
+	}
+}
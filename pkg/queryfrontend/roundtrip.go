@@ -16,6 +16,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
 )
 
 const (
@@ -142,6 +144,11 @@ func newQueryRangeTripperware(
 	queryRangeMiddleware := []queryrange.Middleware{queryrange.NewLimitsMiddleware(limits)}
 	m := queryrange.NewInstrumentMiddlewareMetrics(reg)
 
+	resolutions := config.DownsamplingResolutions
+	if len(resolutions) == 0 {
+		resolutions = downsample.DefaultLevels.Resolutions()
+	}
+
 	// step align middleware.
 	if config.AlignRangeWithStep {
 		queryRangeMiddleware = append(
@@ -155,7 +162,7 @@ func newQueryRangeTripperware(
 		queryRangeMiddleware = append(
 			queryRangeMiddleware,
 			queryrange.InstrumentMiddleware("downsampled", m),
-			DownsampledMiddleware(codec, reg),
+			DownsampledMiddleware(codec, resolutions, reg),
 		)
 	}
 
@@ -175,7 +182,7 @@ func newQueryRangeTripperware(
 		queryCacheMiddleware, _, err := queryrange.NewResultsCacheMiddleware(
 			logger,
 			*config.ResultsCacheConfig,
-			newThanosCacheKeyGenerator(config.SplitQueriesByInterval),
+			newThanosCacheKeyGenerator(config.SplitQueriesByInterval, resolutions),
 			limits,
 			codec,
 			queryrange.PrometheusResponseExtractor{},
@@ -238,7 +245,7 @@ func newLabelsTripperware(
 		queryCacheMiddleware, _, err := queryrange.NewResultsCacheMiddleware(
 			logger,
 			*config.ResultsCacheConfig,
-			newThanosCacheKeyGenerator(config.SplitQueriesByInterval),
+			newThanosCacheKeyGenerator(config.SplitQueriesByInterval, downsample.DefaultLevels.Resolutions()),
 			limits,
 			codec,
 			ThanosResponseExtractor{},
@@ -0,0 +1,194 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package replicate
+
+import (
+	"context"
+	"path"
+	"sort"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	thanosblock "github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/runutil"
+)
+
+// FileDiffReason describes why an object of a block differs between buckets.
+type FileDiffReason string
+
+const (
+	// FileMissing means the object is not present in the target bucket.
+	FileMissing FileDiffReason = "missing"
+	// FileSizeMismatch means the object has a different size in the target bucket.
+	FileSizeMismatch FileDiffReason = "size mismatch"
+	// FileHashMismatch means the hash of the object in the target bucket differs from the hash in the origin meta file.
+	FileHashMismatch FileDiffReason = "hash mismatch"
+)
+
+// FileDiff is an object of a block which differs between buckets.
+type FileDiff struct {
+	Name   string         `json:"name"`
+	Reason FileDiffReason `json:"reason"`
+}
+
+// BlockDiff describes how a block differs between the origin and the target bucket.
+type BlockDiff struct {
+	ID ulid.ULID `json:"id"`
+	// MissingInTarget is true if none of the block objects is present in the target bucket.
+	MissingInTarget bool `json:"missingInTarget,omitempty"`
+	// MissingInOrigin is true if the block is present only in the target bucket.
+	MissingInOrigin bool `json:"missingInOrigin,omitempty"`
+	// Files are the objects which are missing or differ in the target bucket.
+	Files []FileDiff `json:"files,omitempty"`
+}
+
+// Empty returns true if the block is the same in both buckets.
+func (d BlockDiff) Empty() bool {
+	return !d.MissingInTarget && !d.MissingInOrigin && len(d.Files) == 0
+}
+
+// DiffBuckets compares the given blocks of the origin bucket with the given blocks of the target bucket and
+// returns the differing blocks ordered by ID. Only objects copied by replication, i.e. chunks, index and meta file, are compared.
+// If verifyHashes is true, objects of the target bucket with a hash in the origin meta file are downloaded and their hashes checked.
+func DiffBuckets(
+	ctx context.Context,
+	logger log.Logger,
+	from, to objstore.BucketReader,
+	fromMetas, toMetas map[ulid.ULID]*metadata.Meta,
+	verifyHashes bool,
+) ([]BlockDiff, error) {
+	var res []BlockDiff
+	for id, meta := range fromMetas {
+		d, err := DiffBlock(ctx, logger, from, to, meta, verifyHashes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "diff block %v", id)
+		}
+		if !d.Empty() {
+			res = append(res, d)
+		}
+	}
+	for id := range toMetas {
+		if _, ok := fromMetas[id]; !ok {
+			res = append(res, BlockDiff{ID: id, MissingInOrigin: true})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID.Compare(res[j].ID) < 0 })
+	return res, nil
+}
+
+// DiffBlock compares objects of the block described by the origin meta file between the origin and the target bucket.
+func DiffBlock(ctx context.Context, logger log.Logger, from, to objstore.BucketReader, meta *metadata.Meta, verifyHashes bool) (BlockDiff, error) {
+	d := BlockDiff{ID: meta.ULID}
+
+	var names []string
+	if err := from.Iter(ctx, path.Join(meta.ULID.String(), thanosblock.ChunksDirname), func(name string) error {
+		names = append(names, name)
+		return nil
+	}); err != nil {
+		return d, errors.Wrap(err, "list chunk files")
+	}
+	names = append(names, path.Join(meta.ULID.String(), thanosblock.IndexFilename), path.Join(meta.ULID.String(), thanosblock.MetaFilename))
+
+	hashes := map[string]*metadata.ObjectHash{}
+	for _, f := range meta.Thanos.Files {
+		if f.RelPath == thanosblock.BloomFilterFilename {
+			names = append(names, path.Join(meta.ULID.String(), f.RelPath))
+		}
+		if f.Hash != nil {
+			hashes[path.Join(meta.ULID.String(), f.RelPath)] = f.Hash
+		}
+	}
+
+	missing := 0
+	for _, name := range names {
+		fromAttrs, err := from.Attributes(ctx, name)
+		if err != nil {
+			return d, errors.Wrapf(err, "get attributes of %v from origin bucket", name)
+		}
+		toAttrs, err := to.Attributes(ctx, name)
+		if to.IsObjNotFoundErr(err) {
+			d.Files = append(d.Files, FileDiff{Name: name, Reason: FileMissing})
+			missing++
+			continue
+		}
+		if err != nil {
+			return d, errors.Wrapf(err, "get attributes of %v from target bucket", name)
+		}
+		if fromAttrs.Size != toAttrs.Size {
+			d.Files = append(d.Files, FileDiff{Name: name, Reason: FileSizeMismatch})
+			continue
+		}
+
+		hash, ok := hashes[name]
+		if !verifyHashes || !ok {
+			continue
+		}
+		match, err := hashMatches(ctx, logger, to, name, hash)
+		if err != nil {
+			return d, err
+		}
+		if !match {
+			d.Files = append(d.Files, FileDiff{Name: name, Reason: FileHashMismatch})
+		}
+	}
+	d.MissingInTarget = missing == len(names)
+	return d, nil
+}
+
+func hashMatches(ctx context.Context, logger log.Logger, bkt objstore.BucketReader, name string, expected *metadata.ObjectHash) (bool, error) {
+	r, err := bkt.Get(ctx, name)
+	if err != nil {
+		return false, errors.Wrapf(err, "get %v from target bucket", name)
+	}
+	defer runutil.CloseWithLogOnErr(logger, r, "close target object")
+
+	h, err := metadata.CalculateHashFromReader(r, expected.Func)
+	if err != nil {
+		return false, errors.Wrapf(err, "calculate hash of %v", name)
+	}
+	return h.Equal(expected), nil
+}
+
+// ReplicateDiff copies the objects which are missing or differ in the target bucket from the origin bucket.
+// The meta file is uploaded last, so an interrupted repair does not leave a block which looks complete.
+// Blocks present only in the target bucket are left untouched.
+func ReplicateDiff(ctx context.Context, logger log.Logger, from objstore.BucketReader, to objstore.Bucket, d BlockDiff) error {
+	if d.MissingInOrigin || len(d.Files) == 0 {
+		return nil
+	}
+
+	metaFile := path.Join(d.ID.String(), thanosblock.MetaFilename)
+	names := make([]string, 0, len(d.Files))
+	for _, f := range d.Files {
+		if f.Name != metaFile {
+			names = append(names, f.Name)
+		}
+	}
+	names = append(names, metaFile)
+
+	for _, name := range names {
+		if err := copyObject(ctx, logger, from, to, name); err != nil {
+			return err
+		}
+		level.Info(logger).Log("msg", "object replicated", "object", name)
+	}
+	return nil
+}
+
+func copyObject(ctx context.Context, logger log.Logger, from objstore.BucketReader, to objstore.Bucket, name string) error {
+	r, err := from.Get(ctx, name)
+	if err != nil {
+		return errors.Wrapf(err, "get %v from origin bucket", name)
+	}
+	defer runutil.CloseWithLogOnErr(logger, r, "close origin object")
+
+	if err := to.Upload(ctx, name, r); err != nil {
+		return errors.Wrapf(err, "upload %v to target bucket", name)
+	}
+	return nil
+}
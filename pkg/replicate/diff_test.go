@@ -0,0 +1,85 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package replicate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func uploadTestBlock(ctx context.Context, t *testing.T, bkt objstore.Bucket, meta *metadata.Meta, chunks, index string) {
+	testutil.Ok(t, bkt.Upload(ctx, path.Join(meta.ULID.String(), block.ChunksDirname, "000001"), strings.NewReader(chunks)))
+	testutil.Ok(t, bkt.Upload(ctx, path.Join(meta.ULID.String(), block.IndexFilename), strings.NewReader(index)))
+
+	var buf bytes.Buffer
+	testutil.Ok(t, json.NewEncoder(&buf).Encode(meta))
+	testutil.Ok(t, bkt.Upload(ctx, path.Join(meta.ULID.String(), block.MetaFilename), &buf))
+}
+
+func TestDiffBuckets(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	from, to := objstore.NewInMemBucket(), objstore.NewInMemBucket()
+
+	ids := []ulid.ULID{testULID(0), testULID(1), testULID(2), testULID(3), testULID(4)}
+	metas := make([]*metadata.Meta, len(ids))
+	for i, id := range ids {
+		metas[i] = testMeta(id)
+		chunkHash, err := metadata.CalculateHashFromReader(strings.NewReader("chunks"), metadata.SHA256Func)
+		testutil.Ok(t, err)
+		metas[i].Thanos.Files = []metadata.File{{RelPath: path.Join(block.ChunksDirname, "000001"), SizeBytes: 6, Hash: &chunkHash}}
+		if i < 4 {
+			uploadTestBlock(ctx, t, from, metas[i], "chunks", "index")
+		}
+	}
+	// Block 0 is the same, block 1 is missing, block 2 has a different index, block 3 has different chunks with the same size
+	// and block 4 is only in the target bucket.
+	uploadTestBlock(ctx, t, to, metas[0], "chunks", "index")
+	uploadTestBlock(ctx, t, to, metas[2], "chunks", "index2")
+	uploadTestBlock(ctx, t, to, metas[3], "chunkz", "index")
+	uploadTestBlock(ctx, t, to, metas[4], "chunks", "index")
+
+	fromMetas := map[ulid.ULID]*metadata.Meta{ids[0]: metas[0], ids[1]: metas[1], ids[2]: metas[2], ids[3]: metas[3]}
+	toMetas := map[ulid.ULID]*metadata.Meta{ids[0]: metas[0], ids[2]: metas[2], ids[3]: metas[3], ids[4]: metas[4]}
+
+	missingFiles := []FileDiff{
+		{Name: path.Join(ids[1].String(), block.ChunksDirname, "000001"), Reason: FileMissing},
+		{Name: path.Join(ids[1].String(), block.IndexFilename), Reason: FileMissing},
+		{Name: path.Join(ids[1].String(), block.MetaFilename), Reason: FileMissing},
+	}
+	diffs, err := DiffBuckets(ctx, logger, from, to, fromMetas, toMetas, false)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []BlockDiff{
+		{ID: ids[1], MissingInTarget: true, Files: missingFiles},
+		{ID: ids[2], Files: []FileDiff{{Name: path.Join(ids[2].String(), block.IndexFilename), Reason: FileSizeMismatch}}},
+		{ID: ids[4], MissingInOrigin: true},
+	}, diffs)
+
+	diffs, err = DiffBuckets(ctx, logger, from, to, fromMetas, toMetas, true)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []BlockDiff{
+		{ID: ids[1], MissingInTarget: true, Files: missingFiles},
+		{ID: ids[2], Files: []FileDiff{{Name: path.Join(ids[2].String(), block.IndexFilename), Reason: FileSizeMismatch}}},
+		{ID: ids[3], Files: []FileDiff{{Name: path.Join(ids[3].String(), block.ChunksDirname, "000001"), Reason: FileHashMismatch}}},
+		{ID: ids[4], MissingInOrigin: true},
+	}, diffs)
+
+	for _, d := range diffs {
+		testutil.Ok(t, ReplicateDiff(ctx, logger, from, to, d))
+	}
+	diffs, err = DiffBuckets(ctx, logger, from, to, fromMetas, toMetas, true)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []BlockDiff{{ID: ids[4], MissingInOrigin: true}}, diffs)
+}
@@ -82,8 +82,11 @@ func RunReplicate(
 	fromObjStoreConfig *extflag.PathOrContent,
 	toObjStoreConfig *extflag.PathOrContent,
 	singleRun bool,
+	interval time.Duration,
 	minTime, maxTime *thanosmodel.TimeOrDurationValue,
 	blockIDs []ulid.ULID,
+	propagateDeletions bool,
+	deleteDelay time.Duration,
 ) error {
 	logger = log.With(logger, "component", "replicate")
 
@@ -165,6 +168,11 @@ func RunReplicate(
 	replicationRunDuration.WithLabelValues(labelSuccess)
 	replicationRunDuration.WithLabelValues(labelError)
 
+	lastSuccessfulRun := promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+		Name: "thanos_replicate_last_successful_run_timestamp_seconds",
+		Help: "Timestamp of the last successful replication run.",
+	})
+
 	fetcher, err := thanosblock.NewMetaFetcher(
 		logger,
 		32,
@@ -178,6 +186,29 @@ func RunReplicate(
 		return errors.Wrapf(err, "create meta fetcher with bucket %v", fromBkt)
 	}
 
+	var deletion *deletionPropagation
+	if propagateDeletions {
+		// Target fetcher is not instrumented, as its metrics would conflict with the origin fetcher ones.
+		ignoreDeletionMarkFilter := thanosblock.NewIgnoreDeletionMarkFilter(logger, toBkt, 0, thanosblock.FetcherConcurrency)
+		toFetcher, err := thanosblock.NewMetaFetcher(
+			logger,
+			32,
+			toBkt,
+			"",
+			nil,
+			[]thanosblock.MetadataFilter{thanosblock.NewTimePartitionMetaFilter(*minTime, *maxTime), ignoreDeletionMarkFilter},
+			nil,
+		)
+		if err != nil {
+			return errors.Wrapf(err, "create meta fetcher with bucket %v", toBkt)
+		}
+		deletion = &deletionPropagation{
+			fetcher:                  toFetcher,
+			ignoreDeletionMarkFilter: ignoreDeletionMarkFilter,
+			deleteDelay:              deleteDelay,
+		}
+	}
+
 	blockFilter := NewBlockFilter(
 		logger,
 		labelSelector,
@@ -200,7 +231,7 @@ func RunReplicate(
 		logger := log.With(logger, "replication-run-id", runID.String())
 		level.Info(logger).Log("msg", "running replication attempt")
 
-		if err := newReplicationScheme(logger, metrics, blockFilter, fetcher, fromBkt, toBkt, reg).execute(ctx); err != nil {
+		if err := newReplicationScheme(logger, metrics, blockFilter, fetcher, fromBkt, toBkt, deletion, reg).execute(ctx); err != nil {
 			return errors.Wrap(err, "replication execute")
 		}
 
@@ -215,7 +246,7 @@ func RunReplicate(
 			return replicateFn()
 		}
 
-		return runutil.Repeat(time.Minute, ctx.Done(), func() error {
+		return runutil.Repeat(interval, ctx.Done(), func() error {
 			start := time.Now()
 			if err := replicateFn(); err != nil {
 				level.Error(logger).Log("msg", "running replication failed", "err", err)
@@ -227,6 +258,7 @@ func RunReplicate(
 			}
 			replicationRunCounter.WithLabelValues(labelSuccess).Inc()
 			replicationRunDuration.WithLabelValues(labelSuccess).Observe(time.Since(start).Seconds())
+			lastSuccessfulRun.SetToCurrentTime()
 			level.Info(logger).Log("msg", "ran replication successfully")
 
 			return nil
@@ -11,6 +11,7 @@ import (
 	"io/ioutil"
 	"path"
 	"sort"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -116,6 +117,15 @@ func (bf *BlockFilter) Filter(b *metadata.Meta) bool {
 
 type blockFilterFunc func(b *metadata.Meta) bool
 
+// deletionPropagation configures propagation of deletions from the origin to the target bucket.
+type deletionPropagation struct {
+	// fetcher fetches metas of blocks in the target bucket. It has to use ignoreDeletionMarkFilter with zero delay.
+	fetcher                  thanosblock.MetadataFetcher
+	ignoreDeletionMarkFilter *thanosblock.IgnoreDeletionMarkFilter
+	// deleteDelay is the time after which blocks marked for deletion are deleted from the target bucket.
+	deleteDelay time.Duration
+}
+
 // TODO: Add filters field.
 type replicationScheme struct {
 	fromBkt objstore.InstrumentedBucketReader
@@ -123,6 +133,8 @@ type replicationScheme struct {
 
 	blockFilter blockFilterFunc
 	fetcher     thanosblock.MetadataFetcher
+	// deletion is nil if deletions are not propagated.
+	deletion *deletionPropagation
 
 	logger  log.Logger
 	metrics *replicationMetrics
@@ -134,6 +146,11 @@ type replicationMetrics struct {
 	blocksAlreadyReplicated prometheus.Counter
 	blocksReplicated        prometheus.Counter
 	objectsReplicated       prometheus.Counter
+	blocksMarkedForDeletion prometheus.Counter
+	blocksCleaned           prometheus.Counter
+	blockCleanupFailures    prometheus.Counter
+	blocksPending           prometheus.Gauge
+	lag                     prometheus.Gauge
 }
 
 func newReplicationMetrics(reg prometheus.Registerer) *replicationMetrics {
@@ -150,6 +167,26 @@ func newReplicationMetrics(reg prometheus.Registerer) *replicationMetrics {
 			Name: "thanos_replicate_objects_replicated_total",
 			Help: "Total number of objects replicated.",
 		}),
+		blocksMarkedForDeletion: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "thanos_replicate_blocks_marked_for_deletion_total",
+			Help: "Total number of blocks marked for deletion in the target bucket because they were deleted in the origin bucket.",
+		}),
+		blocksCleaned: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "thanos_replicate_blocks_cleaned_total",
+			Help: "Total number of blocks deleted from the target bucket.",
+		}),
+		blockCleanupFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "thanos_replicate_block_cleanup_failures_total",
+			Help: "Failures encountered while deleting blocks from the target bucket.",
+		}),
+		blocksPending: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "thanos_replicate_blocks_pending",
+			Help: "Number of selected blocks of the origin bucket which were not replicated by the last replication run.",
+		}),
+		lag: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "thanos_replicate_lag_seconds",
+			Help: "Age of the oldest block of the origin bucket which was not replicated by the last replication run, based on the block ULID. Zero if all selected blocks are replicated.",
+		}),
 	}
 	return m
 }
@@ -161,6 +198,7 @@ func newReplicationScheme(
 	fetcher thanosblock.MetadataFetcher,
 	from objstore.InstrumentedBucketReader,
 	to objstore.Bucket,
+	deletion *deletionPropagation,
 	reg prometheus.Registerer,
 ) *replicationScheme {
 	if logger == nil {
@@ -173,6 +211,7 @@ func newReplicationScheme(
 		fetcher:     fetcher,
 		fromBkt:     from,
 		toBkt:       to,
+		deletion:    deletion,
 		metrics:     metrics,
 		reg:         reg,
 	}
@@ -203,15 +242,119 @@ func (rs *replicationScheme) execute(ctx context.Context) error {
 		return availableBlocks[i].BlockMeta.MinTime < availableBlocks[j].BlockMeta.MinTime
 	})
 
-	for _, b := range availableBlocks {
-		if err := rs.ensureBlockIsReplicated(ctx, b.BlockMeta.ULID); err != nil {
+	for i, b := range availableBlocks {
+		if err := rs.replicateBlock(ctx, b.BlockMeta.ULID); err != nil {
+			rs.updatePending(availableBlocks[i:])
 			return errors.Wrapf(err, "ensure block %v is replicated", b.BlockMeta.ULID.String())
 		}
 	}
+	rs.updatePending(nil)
+
+	if rs.deletion != nil {
+		if err := rs.propagateDeletions(ctx, metas, partials); err != nil {
+			return errors.Wrap(err, "propagate deletions")
+		}
+	}
 
 	return nil
 }
 
+// updatePending updates metrics of blocks which were not replicated.
+func (rs *replicationScheme) updatePending(pending []*metadata.Meta) {
+	var lag time.Duration
+	for _, b := range pending {
+		if l := time.Since(ulid.Time(b.ULID.Time())); l > lag {
+			lag = l
+		}
+	}
+	rs.metrics.blocksPending.Set(float64(len(pending)))
+	rs.metrics.lag.Set(lag.Seconds())
+}
+
+// replicateBlock ensures that a block and its no-compact mark present in the
+// origin bucket are present in the target bucket. If deletions are propagated,
+// blocks marked for deletion in the origin bucket are not replicated anymore,
+// only their deletion marks are.
+func (rs *replicationScheme) replicateBlock(ctx context.Context, id ulid.ULID) error {
+	if rs.deletion != nil {
+		marked, err := rs.fromBkt.Exists(ctx, path.Join(id.String(), metadata.DeletionMarkFilename))
+		if err != nil {
+			return errors.Wrap(err, "check if block is marked for deletion in origin bucket")
+		}
+		if marked {
+			replicated, err := rs.toBkt.Exists(ctx, path.Join(id.String(), thanosblock.MetaFilename))
+			if err != nil {
+				return errors.Wrap(err, "check if block exists in target bucket")
+			}
+			if !replicated {
+				level.Debug(rs.logger).Log("msg", "skipping block marked for deletion in origin bucket", "block_uuid", id.String())
+				return nil
+			}
+			return rs.ensureObjectReplicated(ctx, path.Join(id.String(), metadata.DeletionMarkFilename))
+		}
+	}
+
+	if err := rs.ensureBlockIsReplicated(ctx, id); err != nil {
+		return err
+	}
+
+	noCompactMarkFile := path.Join(id.String(), metadata.NoCompactMarkFilename)
+	marked, err := rs.fromBkt.Exists(ctx, noCompactMarkFile)
+	if err != nil {
+		return errors.Wrap(err, "check if block is marked for no compaction in origin bucket")
+	}
+	if !marked {
+		return nil
+	}
+	return rs.ensureObjectReplicated(ctx, noCompactMarkFile)
+}
+
+// propagateDeletions marks blocks of the target bucket which were replicated
+// from the origin bucket, are selected by the block filter but are not present
+// in the origin bucket anymore for deletion, and deletes blocks of the target
+// bucket marked for deletion before the delete delay. Blocks without a
+// replicated mark, e.g. produced by a compactor running on the target bucket,
+// are never marked.
+func (rs *replicationScheme) propagateDeletions(ctx context.Context, originMetas map[ulid.ULID]*metadata.Meta, originPartials map[ulid.ULID]error) error {
+	targetMetas, _, err := rs.deletion.fetcher.Fetch(ctx)
+	if err != nil {
+		return errors.Wrap(err, "fetch metas of target bucket")
+	}
+
+	for id, meta := range targetMetas {
+		if _, ok := originMetas[id]; ok {
+			continue
+		}
+		// Block might be being uploaded to origin bucket, e.g. by a backfill.
+		if _, ok := originPartials[id]; ok {
+			continue
+		}
+		if !rs.blockFilter(meta) {
+			continue
+		}
+		replicated, err := rs.toBkt.Exists(ctx, path.Join(id.String(), metadata.ReplicatedMarkFilename))
+		if err != nil {
+			return errors.Wrapf(err, "check if block %v is marked as replicated in target bucket", id)
+		}
+		if !replicated {
+			level.Debug(rs.logger).Log("msg", "skipping block not replicated from origin bucket", "block_uuid", id.String())
+			continue
+		}
+		if err := thanosblock.MarkForDeletion(ctx, rs.logger, rs.toBkt, id, "block deleted in origin bucket", rs.metrics.blocksMarkedForDeletion); err != nil {
+			return errors.Wrapf(err, "mark block %v for deletion in target bucket", id)
+		}
+	}
+
+	return compact.NewBlocksCleaner(
+		rs.logger,
+		rs.toBkt,
+		rs.deletion.ignoreDeletionMarkFilter,
+		rs.deletion.deleteDelay,
+		rs.metrics.blocksCleaned,
+		rs.metrics.blockCleanupFailures,
+	).DeleteMarkedBlocks(ctx)
+}
+
 // ensureBlockIsReplicated ensures that a block present in the origin bucket is
 // present in the target bucket.
 func (rs *replicationScheme) ensureBlockIsReplicated(ctx context.Context, id ulid.ULID) error {
@@ -219,6 +362,7 @@ func (rs *replicationScheme) ensureBlockIsReplicated(ctx context.Context, id uli
 	chunksDir := path.Join(blockID, thanosblock.ChunksDirname)
 	indexFile := path.Join(blockID, thanosblock.IndexFilename)
 	metaFile := path.Join(blockID, thanosblock.MetaFilename)
+	bloomFilterFile := path.Join(blockID, thanosblock.BloomFilterFilename)
 
 	level.Debug(rs.logger).Log("msg", "ensuring block is replicated", "block_uuid", blockID)
 
@@ -258,7 +402,18 @@ func (rs *replicationScheme) ensureBlockIsReplicated(ctx context.Context, id uli
 			level.Debug(rs.logger).Log("msg", "skipping block as already replicated", "block_uuid", blockID)
 			rs.metrics.blocksAlreadyReplicated.Inc()
 
-			return nil
+			// Blocks replicated by older versions or without deletion propagation have no replicated mark yet.
+			if rs.deletion == nil {
+				return nil
+			}
+			marked, err := rs.toBkt.Exists(ctx, path.Join(blockID, metadata.ReplicatedMarkFilename))
+			if err != nil {
+				return errors.Wrap(err, "check if block is marked as replicated in target bucket")
+			}
+			if marked {
+				return nil
+			}
+			return thanosblock.MarkReplicated(ctx, rs.logger, rs.toBkt, id)
 		}
 	}
 
@@ -277,6 +432,24 @@ func (rs *replicationScheme) ensureBlockIsReplicated(ctx context.Context, id uli
 		return errors.Wrap(err, "replicate index file")
 	}
 
+	// The bloom filter is optional, only blocks written with it enabled have one.
+	ok, err := rs.fromBkt.Exists(ctx, bloomFilterFile)
+	if err != nil {
+		return errors.Wrap(err, "check bloom filter file in origin bucket")
+	}
+	if ok {
+		if err := rs.ensureObjectReplicated(ctx, bloomFilterFile); err != nil {
+			return errors.Wrap(err, "replicate bloom filter file")
+		}
+	}
+
+	// The mark is uploaded before the meta file, so that complete blocks are always marked.
+	if rs.deletion != nil {
+		if err := thanosblock.MarkReplicated(ctx, rs.logger, rs.toBkt, id); err != nil {
+			return errors.Wrap(err, "upload replicated mark")
+		}
+	}
+
 	level.Debug(rs.logger).Log("msg", "replicating meta file", "object", metaFile)
 
 	if err := rs.toBkt.Upload(ctx, metaFile, bytes.NewBuffer(originMetaFileContent)); err != nil {
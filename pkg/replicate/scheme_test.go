@@ -115,6 +115,26 @@ func TestReplicationSchemeAll(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "FullBlockWithBloomFilter",
+			prepare: func(ctx context.Context, t *testing.T, originBucket, targetBucket *objstore.InMemBucket) {
+				ulid := testULID(0)
+				meta := testMeta(ulid)
+
+				b, err := json.Marshal(meta)
+				testutil.Ok(t, err)
+				_ = originBucket.Upload(ctx, path.Join(ulid.String(), "meta.json"), bytes.NewReader(b))
+				_ = originBucket.Upload(ctx, path.Join(ulid.String(), "chunks", "000001"), bytes.NewReader(nil))
+				_ = originBucket.Upload(ctx, path.Join(ulid.String(), "index"), bytes.NewReader(nil))
+				_ = originBucket.Upload(ctx, path.Join(ulid.String(), "bloom-filter"), bytes.NewReader([]byte("filter")))
+			},
+			assert: func(ctx context.Context, t *testing.T, originBucket, targetBucket *objstore.InMemBucket) {
+				if len(targetBucket.Objects()) != 4 {
+					t.Fatal("TargetBucket should have one block made up of four objects replicated.")
+				}
+				testutil.Equals(t, []byte("filter"), targetBucket.Objects()[path.Join(testULID(0).String(), "bloom-filter")])
+			},
+		},
 		{
 			name: "PreviousPartialUpload",
 			prepare: func(ctx context.Context, t *testing.T, originBucket, targetBucket *objstore.InMemBucket) {
@@ -346,7 +366,7 @@ func TestReplicationSchemeAll(t *testing.T) {
 		fetcher, err := block.NewMetaFetcher(logger, 32, objstore.WithNoopInstr(originBucket), "", nil, nil, nil)
 		testutil.Ok(t, err)
 
-		r := newReplicationScheme(logger, newReplicationMetrics(nil), filter, fetcher, objstore.WithNoopInstr(originBucket), targetBucket, nil)
+		r := newReplicationScheme(logger, newReplicationMetrics(nil), filter, fetcher, objstore.WithNoopInstr(originBucket), targetBucket, nil, nil)
 
 		err = r.execute(ctx)
 		testutil.Ok(t, err)
@@ -354,3 +374,70 @@ func TestReplicationSchemeAll(t *testing.T) {
 		c.assert(ctx, t, originBucket, targetBucket)
 	}
 }
+
+func TestReplicationSchemeDeletionPropagation(t *testing.T) {
+	ctx := context.Background()
+	logger := testLogger(t.Name())
+	originBucket := objstore.NewInMemBucket()
+	targetBucket := objstore.NewInMemBucket()
+
+	upload := func(bkt objstore.Bucket, id ulid.ULID, marks ...metadata.Marker) {
+		b, err := json.Marshal(testMeta(id))
+		testutil.Ok(t, err)
+		testutil.Ok(t, bkt.Upload(ctx, path.Join(id.String(), "meta.json"), bytes.NewReader(b)))
+		testutil.Ok(t, bkt.Upload(ctx, path.Join(id.String(), "chunks", "000001"), bytes.NewReader(nil)))
+		testutil.Ok(t, bkt.Upload(ctx, path.Join(id.String(), "index"), bytes.NewReader(nil)))
+		for _, m := range marks {
+			b, err := json.Marshal(m)
+			testutil.Ok(t, err)
+			name := metadata.DeletionMarkFilename
+			switch m.(type) {
+			case *metadata.NoCompactMark:
+				name = metadata.NoCompactMarkFilename
+			case *metadata.ReplicatedMark:
+				name = metadata.ReplicatedMarkFilename
+			}
+			testutil.Ok(t, bkt.Upload(ctx, path.Join(id.String(), name), bytes.NewReader(b)))
+		}
+	}
+
+	// Block 0 is new and marked for no compaction, block 1 is replicated and marked for deletion in origin,
+	// block 2 is marked for deletion in origin but not replicated, block 3 was deleted in origin,
+	// block 4 was deleted in origin and marked for deletion in target before the delete delay,
+	// block 5 was never replicated, e.g. compacted in target.
+	ids := []ulid.ULID{testULID(0), testULID(1), testULID(2), testULID(3), testULID(4), testULID(5)}
+	now := time.Now()
+	upload(originBucket, ids[0], &metadata.NoCompactMark{ID: ids[0], Version: metadata.NoCompactMarkVersion1, NoCompactTime: now.Unix(), Reason: metadata.ManualNoCompactReason})
+	upload(originBucket, ids[1], &metadata.DeletionMark{ID: ids[1], Version: metadata.DeletionMarkVersion1, DeletionTime: now.Unix()})
+	upload(targetBucket, ids[1])
+	upload(originBucket, ids[2], &metadata.DeletionMark{ID: ids[2], Version: metadata.DeletionMarkVersion1, DeletionTime: now.Unix()})
+	upload(targetBucket, ids[3], &metadata.ReplicatedMark{ID: ids[3], Version: metadata.ReplicatedMarkVersion1, ReplicateTime: now.Unix()})
+	upload(targetBucket, ids[4], &metadata.DeletionMark{ID: ids[4], Version: metadata.DeletionMarkVersion1, DeletionTime: now.Add(-2 * time.Hour).Unix()})
+	upload(targetBucket, ids[5])
+
+	matcher, err := labels.NewMatcher(labels.MatchEqual, "test-labelname", "test-labelvalue")
+	testutil.Ok(t, err)
+	filter := NewBlockFilter(logger, labels.Selector{matcher}, []compact.ResolutionLevel{compact.ResolutionLevelRaw}, []int{1}, nil).Filter
+	fetcher, err := block.NewMetaFetcher(logger, 32, objstore.WithNoopInstr(originBucket), "", nil, nil, nil)
+	testutil.Ok(t, err)
+
+	ignoreDeletionMarkFilter := block.NewIgnoreDeletionMarkFilter(logger, objstore.WithNoopInstr(targetBucket), 0, block.FetcherConcurrency)
+	toFetcher, err := block.NewMetaFetcher(logger, 32, objstore.WithNoopInstr(targetBucket), "", nil, []block.MetadataFilter{ignoreDeletionMarkFilter}, nil)
+	testutil.Ok(t, err)
+	deletion := &deletionPropagation{fetcher: toFetcher, ignoreDeletionMarkFilter: ignoreDeletionMarkFilter, deleteDelay: time.Hour}
+
+	r := newReplicationScheme(logger, newReplicationMetrics(nil), filter, fetcher, objstore.WithNoopInstr(originBucket), targetBucket, deletion, nil)
+	testutil.Ok(t, r.execute(ctx))
+
+	exists := func(id ulid.ULID, name string) bool {
+		_, ok := targetBucket.Objects()[path.Join(id.String(), name)]
+		return ok
+	}
+	testutil.Assert(t, exists(ids[0], "meta.json") && exists(ids[0], metadata.NoCompactMarkFilename), "block 0 should be replicated with its no-compact mark")
+	testutil.Assert(t, exists(ids[0], metadata.ReplicatedMarkFilename), "block 0 should be marked as replicated")
+	testutil.Assert(t, exists(ids[1], metadata.DeletionMarkFilename), "deletion mark of block 1 should be replicated")
+	testutil.Assert(t, !exists(ids[2], "meta.json"), "block 2 should not be replicated")
+	testutil.Assert(t, exists(ids[3], metadata.DeletionMarkFilename), "block 3 should be marked for deletion")
+	testutil.Assert(t, !exists(ids[4], "meta.json"), "block 4 should be deleted")
+	testutil.Assert(t, !exists(ids[5], metadata.DeletionMarkFilename), "block 5 should not be marked for deletion")
+}
@@ -35,6 +35,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/bloom"
 	"github.com/thanos-io/thanos/pkg/block/indexheader"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
 	"github.com/thanos-io/thanos/pkg/compact/downsample"
@@ -51,6 +52,8 @@ import (
 	"github.com/thanos-io/thanos/pkg/store/storepb"
 	"github.com/thanos-io/thanos/pkg/strutil"
 	"github.com/thanos-io/thanos/pkg/tracing"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus/tsdbstatuspb"
 )
 
 const (
@@ -84,6 +87,10 @@ const (
 
 	PartitionerMaxGapSize = 512 * 1024
 
+	// DefaultSeriesBatchSize represents default value for --store.grpc.series-batch-size.
+	// Most queries match fewer series per block, so they are served in a single batch without copying chunks.
+	DefaultSeriesBatchSize = 10000
+
 	// Labels for metrics.
 	labelEncode = "encode"
 	labelDecode = "decode"
@@ -106,6 +113,9 @@ type bucketStoreMetrics struct {
 	chunkSizeBytes        prometheus.Histogram
 	queriesDropped        *prometheus.CounterVec
 	seriesRefetches       prometheus.Counter
+	bloomSkippedBlocks    prometheus.Counter
+	blockWarmupDuration   prometheus.Histogram
+	blockWarmupFailures   prometheus.Counter
 
 	cachedPostingsCompressions           *prometheus.CounterVec
 	cachedPostingsCompressionErrors      *prometheus.CounterVec
@@ -194,6 +204,19 @@ func newBucketStoreMetrics(reg prometheus.Registerer) *bucketStoreMetrics {
 		Name: "thanos_bucket_store_series_refetches_total",
 		Help: fmt.Sprintf("Total number of cases where %v bytes was not enough was to fetch series from index, resulting in refetch.", maxSeriesSize),
 	})
+	m.blockWarmupDuration = promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+		Name:    "thanos_bucket_store_block_warmup_duration_seconds",
+		Help:    "Time it takes to warm up a block before it is queryable.",
+		Buckets: []float64{0.01, 0.1, 0.3, 0.6, 1, 3, 6, 9, 20, 30, 60, 90, 120},
+	})
+	m.blockWarmupFailures = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "thanos_bucket_store_block_warmup_failures_total",
+		Help: "Total number of failed block warm ups. Blocks are queryable even if their warm up failed.",
+	})
+	m.bloomSkippedBlocks = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "thanos_bucket_store_bloom_filter_skipped_blocks_total",
+		Help: "Total number of blocks skipped by series requests because their bloom filter did not match the equality matchers.",
+	})
 
 	m.cachedPostingsCompressions = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 		Name: "thanos_bucket_store_cached_postings_compressions_total",
@@ -287,8 +310,78 @@ type BucketStore struct {
 	// Every how many posting offset entry we pool in heap memory. Default in Prometheus is 32.
 	postingOffsetsInMemSampling int
 
+	// Lazy loading of index-headers also delays loading of bloom filters until the block is queried.
+	lazyIndexReaderEnabled bool
+
 	// Enables hints in the Series() response.
 	enableSeriesResponseHints bool
+
+	// Available downsampling resolutions, high to low (in milliseconds).
+	resolutions []int64
+
+	// Number of series loaded at once per block by Series calls.
+	seriesBatchSize int
+
+	// Factories of limiters of postings, series and chunk bytes fetched by a single Series call.
+	postingsBytesLimiterFactory BytesLimiterFactory
+	seriesBytesLimiterFactory   BytesLimiterFactory
+	chunksBytesLimiterFactory   BytesLimiterFactory
+
+	// mergeSmallChunks enables re-encoding consecutive small chunks of series into bigger ones before sending them.
+	mergeSmallChunks bool
+
+	// warmupConfig and warmupLog are set if new blocks are warmed up before they are queryable.
+	warmupConfig *WarmupConfig
+	warmupLog    *warmupQueryLog
+}
+
+// BucketStoreOption configures optional parameters of the BucketStore.
+type BucketStoreOption func(s *BucketStore)
+
+// WithDownsamplingResolutions sets the resolutions (in milliseconds) of the downsampling levels
+// the store can serve blocks of. Defaults to the standard Thanos levels.
+func WithDownsamplingResolutions(resolutions []int64) BucketStoreOption {
+	return func(s *BucketStore) {
+		res := make([]int64, len(resolutions))
+		copy(res, resolutions)
+		sort.Slice(res, func(i, j int) bool { return res[i] > res[j] })
+		s.resolutions = res
+	}
+}
+
+// WithSeriesBatchSize sets the number of series, with their chunks, loaded at once per block while streaming
+// a Series response. 0 means all matching series of a block are loaded at once.
+func WithSeriesBatchSize(n int) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.seriesBatchSize = n
+	}
+}
+
+// WithBytesLimiterFactories sets factories of limiters of postings, series and chunk bytes fetched by a single Series call.
+// All of them are unlimited by default.
+func WithBytesLimiterFactories(postings, series, chunks BytesLimiterFactory) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.postingsBytesLimiterFactory = postings
+		s.seriesBytesLimiterFactory = series
+		s.chunksBytesLimiterFactory = chunks
+	}
+}
+
+// WithSmallChunksMerging enables re-encoding consecutive small raw chunks of a series into bigger ones before
+// sending them, trading CPU for less data on the wire.
+func WithSmallChunksMerging(enabled bool) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.mergeSmallChunks = enabled
+	}
+}
+
+// WithWarmup enables warming up new blocks with the given config before they are queryable. The label pairs
+// most frequently used by queries are recorded in a query log file in the store directory, so that they
+// survive restarts.
+func WithWarmup(config WarmupConfig) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.warmupConfig = &config
+	}
 }
 
 type noopCache struct{}
@@ -303,6 +396,11 @@ func (noopCache) FetchMultiSeries(_ context.Context, _ ulid.ULID, ids []uint64)
 	return map[uint64][]byte{}, ids
 }
 
+func (noopCache) StoreExpandedPostings(context.Context, ulid.ULID, []*labels.Matcher, []byte) {}
+func (noopCache) FetchExpandedPostings(context.Context, ulid.ULID, []*labels.Matcher) ([]byte, bool) {
+	return nil, false
+}
+
 type noopGate struct{}
 
 func (noopGate) Start(context.Context) error { return nil }
@@ -331,6 +429,7 @@ func NewBucketStore(
 	enableSeriesResponseHints bool, // TODO(pracucci) Thanos 0.12 and below doesn't gracefully handle new fields in SeriesResponse. Drop this flag and always enable hints once we can drop backward compatibility.
 	lazyIndexReaderEnabled bool,
 	lazyIndexReaderIdleTimeout time.Duration,
+	options ...BucketStoreOption,
 ) (*BucketStore, error) {
 	if logger == nil {
 		logger = log.NewNopLogger()
@@ -365,14 +464,32 @@ func NewBucketStore(
 		partitioner:                 partitioner,
 		enableCompatibilityLabel:    enableCompatibilityLabel,
 		postingOffsetsInMemSampling: postingOffsetsInMemSampling,
+		lazyIndexReaderEnabled:      lazyIndexReaderEnabled,
 		enableSeriesResponseHints:   enableSeriesResponseHints,
 		metrics:                     newBucketStoreMetrics(reg),
+		resolutions:                 []int64{downsample.ResLevel2, downsample.ResLevel1, downsample.ResLevel0},
+		seriesBatchSize:             DefaultSeriesBatchSize,
+		postingsBytesLimiterFactory: NewBytesLimiterFactory(0),
+		seriesBytesLimiterFactory:   NewBytesLimiterFactory(0),
+		chunksBytesLimiterFactory:   NewBytesLimiterFactory(0),
+	}
+
+	for _, option := range options {
+		option(s)
 	}
 
 	if err := os.MkdirAll(dir, 0777); err != nil {
 		return nil, errors.Wrap(err, "create dir")
 	}
 
+	if s.warmupConfig != nil {
+		l, err := newWarmupQueryLog(logger, filepath.Join(dir, WarmupQueryLogFilename), *s.warmupConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "create warm up query log")
+		}
+		s.warmupLog = l
+	}
+
 	return s, nil
 }
 
@@ -386,6 +503,9 @@ func (s *BucketStore) Close() (err error) {
 	}
 
 	s.indexReaderPool.Close()
+	if s.warmupLog != nil {
+		runutil.CloseWithErrCapture(&err, s.warmupLog, "warm up query log")
+	}
 	return err
 }
 
@@ -455,6 +575,12 @@ func (s *BucketStore) SyncBlocks(ctx context.Context) error {
 		return strings.Compare(s.advLabelSets[i].String(), s.advLabelSets[j].String()) < 0
 	})
 	s.mtx.Unlock()
+
+	if s.warmupLog != nil {
+		if err := s.warmupLog.save(); err != nil {
+			level.Warn(s.logger).Log("msg", "failed to save warm up query log", "err", err)
+		}
+	}
 	return nil
 }
 
@@ -556,6 +682,14 @@ func (s *BucketStore) addBlock(ctx context.Context, meta *metadata.Meta) (err er
 		}
 	}()
 
+	if !s.lazyIndexReaderEnabled {
+		b.loadBloomFilter(ctx)
+	}
+
+	if s.warmupLog != nil {
+		s.warmupBlock(ctx, b)
+	}
+
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
@@ -563,7 +697,7 @@ func (s *BucketStore) addBlock(ctx context.Context, meta *metadata.Meta) (err er
 
 	set, ok := s.blockSets[h]
 	if !ok {
-		set = newBucketBlockSet(lset)
+		set = newBucketBlockSet(lset, s.resolutions)
 		s.blockSets[h] = set
 	}
 
@@ -625,9 +759,11 @@ func (s *BucketStore) TimeRange() (mint, maxt int64) {
 func (s *BucketStore) Info(context.Context, *storepb.InfoRequest) (*storepb.InfoResponse, error) {
 	mint, maxt := s.TimeRange()
 	res := &storepb.InfoResponse{
-		StoreType: component.Store.ToProto(),
-		MinTime:   mint,
-		MaxTime:   maxt,
+		StoreType:          component.Store.ToProto(),
+		MinTime:            mint,
+		MaxTime:            maxt,
+		QueryPushdownFuncs: QueryPushdownFuncs,
+		SeriesCompressions: SeriesCompressions,
 	}
 
 	s.mtx.RLock()
@@ -676,36 +812,43 @@ type seriesEntry struct {
 	chks []storepb.AggrChunk
 }
 
-type bucketSeriesSet struct {
-	set []seriesEntry
-	i   int
-	err error
-}
+// blockSeriesSet is a storepb.SeriesSet over series of a single block matching the request. Series and their
+// chunks are loaded lazily in batches of postings, so memory used by a block is bounded by the batch size rather
+// than by the number of series matching the request. Batches after the first one are prefetched in the background,
+// one batch ahead of the merge, so blocks keep fetching from object storage concurrently.
+type blockSeriesSet struct {
+	extLset       labels.Labels
+	indexr        *bucketIndexReader
+	chunkr        *bucketChunkReader
+	req           *storepb.SeriesRequest
+	chunksLimiter ChunksLimiter
+	batchSize     int
 
-func newBucketSeriesSet(set []seriesEntry) *bucketSeriesSet {
-	return &bucketSeriesSet{
-		set: set,
-		i:   -1,
-	}
-}
+	// Postings of series which are not loaded yet. Owned by the prefetching goroutine once it is started.
+	ps             []uint64
+	symbolizedLset []symbolizedLabel
+	chks           []chunks.Meta
 
-func (s *bucketSeriesSet) Next() bool {
-	if s.i >= len(s.set)-1 {
-		return false
-	}
-	s.i++
-	return true
-}
+	// Batches loaded by the prefetching goroutine, closed when it is done. Nil if all series were loaded at once.
+	batches chan seriesBatch
+	cancel  context.CancelFunc
+	done    chan struct{}
 
-func (s *bucketSeriesSet) At() (labels.Labels, []storepb.AggrChunk) {
-	return s.set[s.i].lset, s.set[s.i].chks
+	entries []seriesEntry
+	i       int
+	err     error
 }
 
-func (s *bucketSeriesSet) Err() error {
-	return s.err
+type seriesBatch struct {
+	entries []seriesEntry
+	err     error
 }
 
-func blockSeries(
+// newBlockSeriesSet expands postings of the matchers, reserves the matching series through seriesLimiter and loads the
+// first batch of series, so errors of the block are returned before any series is sent. Further batches are prefetched
+// using the given context until close is called. Batch size of 0 means all matching series are loaded at once.
+func newBlockSeriesSet(
+	ctx context.Context,
 	extLset labels.Labels,
 	indexr *bucketIndexReader,
 	chunkr *bucketChunkReader,
@@ -713,97 +856,213 @@ func blockSeries(
 	req *storepb.SeriesRequest,
 	chunksLimiter ChunksLimiter,
 	seriesLimiter SeriesLimiter,
-) (storepb.SeriesSet, *queryStats, error) {
+	batchSize int,
+) (*blockSeriesSet, error) {
 	ps, err := indexr.ExpandedPostings(matchers)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "expanded matching posting")
+		return nil, errors.Wrap(err, "expanded matching posting")
 	}
 
+	// Reserve series seriesLimiter
+	if err := seriesLimiter.Reserve(uint64(len(ps))); err != nil {
+		return nil, errors.Wrap(err, "exceeded series limit")
+	}
+
+	if batchSize <= 0 {
+		batchSize = len(ps)
+	}
+	s := &blockSeriesSet{
+		extLset:       extLset,
+		indexr:        indexr,
+		chunkr:        chunkr,
+		req:           req,
+		chunksLimiter: chunksLimiter,
+		batchSize:     batchSize,
+		ps:            ps,
+		i:             -1,
+	}
 	if len(ps) == 0 {
-		return storepb.EmptySeriesSet(), indexr.stats, nil
+		return s, nil
+	}
+	if s.entries, err = s.loadBatch(); err != nil {
+		return nil, err
 	}
+	if len(s.ps) > 0 {
+		ctx, s.cancel = context.WithCancel(ctx)
+		// Unbuffered, so that at most the merged batch and the one being prefetched are held.
+		s.batches = make(chan seriesBatch)
+		s.done = make(chan struct{})
+		go s.prefetch(ctx)
+	}
+	return s, nil
+}
 
-	// Reserve series seriesLimiter
-	if err := seriesLimiter.Reserve(uint64(len(ps))); err != nil {
-		return nil, nil, errors.Wrap(err, "exceeded series limit")
+// prefetch loads the remaining batches until all are loaded, loading fails or the context is canceled.
+func (s *blockSeriesSet) prefetch(ctx context.Context) {
+	defer close(s.done)
+	defer close(s.batches)
+
+	for len(s.ps) > 0 {
+		entries, err := s.loadBatch()
+		select {
+		case s.batches <- seriesBatch{entries: entries, err: err}:
+		case <-ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *blockSeriesSet) Next() bool {
+	for {
+		if s.i < len(s.entries)-1 {
+			s.i++
+			return true
+		}
+		if s.err != nil || s.batches == nil {
+			return false
+		}
+		b, ok := <-s.batches
+		if !ok {
+			s.batches = nil
+			return false
+		}
+		if b.err != nil {
+			s.err = errors.Wrapf(b.err, "fetch series for block %s", s.indexr.block.meta.ULID)
+			return false
+		}
+		s.entries, s.i = b.entries, -1
+	}
+}
+
+func (s *blockSeriesSet) At() (labels.Labels, []storepb.AggrChunk) {
+	return s.entries[s.i].lset, s.entries[s.i].chks
+}
+
+func (s *blockSeriesSet) Err() error {
+	return s.err
+}
+
+// close stops prefetching and waits until it is stopped, so the readers of the set can be closed.
+func (s *blockSeriesSet) close() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+// stats returns statistics of all batches loaded so far. It must be called after close.
+func (s *blockSeriesSet) stats() *queryStats {
+	if s.chunkr == nil {
+		return s.indexr.stats
+	}
+	return s.indexr.stats.merge(s.chunkr.stats)
+}
+
+// loadBatch returns series and chunks of the next batch of postings.
+func (s *blockSeriesSet) loadBatch() ([]seriesEntry, error) {
+	n := s.batchSize
+	if n > len(s.ps) {
+		n = len(s.ps)
+	}
+	ps := s.ps[:n]
+	s.ps = s.ps[n:]
+
+	// Release data of the previous batch. Chunks of its entries were copied if a further batch was pending.
+	s.indexr.reset()
+	if s.chunkr != nil {
+		s.chunkr.reset()
 	}
 
-	// Preload all series index data.
-	// TODO(bwplotka): Consider not keeping all series in memory all the time.
-	// TODO(bwplotka): Do lazy loading in one step as `ExpandingPostings` method.
-	if err := indexr.PreloadSeries(ps); err != nil {
-		return nil, nil, errors.Wrap(err, "preload series")
+	if err := s.indexr.PreloadSeries(ps); err != nil {
+		return nil, errors.Wrap(err, "preload series")
 	}
 
 	// Transform all series into the response types and mark their relevant chunks
 	// for preloading.
-	var (
-		res            []seriesEntry
-		symbolizedLset []symbolizedLabel
-		lset           labels.Labels
-		chks           []chunks.Meta
-	)
+	entries := make([]seriesEntry, 0, len(ps))
 	for _, id := range ps {
-		ok, err := indexr.LoadSeriesForTime(id, &symbolizedLset, &chks, req.SkipChunks, req.MinTime, req.MaxTime)
+		ok, err := s.indexr.LoadSeriesForTime(id, &s.symbolizedLset, &s.chks, s.req.SkipChunks, s.req.MinTime, s.req.MaxTime)
 		if err != nil {
-			return nil, nil, errors.Wrap(err, "read series")
+			return nil, errors.Wrap(err, "read series")
 		}
 		if !ok {
 			// No matching chunks for this time duration, skip series.
 			continue
 		}
 
-		s := seriesEntry{}
-		if !req.SkipChunks {
+		e := seriesEntry{}
+		if !s.req.SkipChunks {
 			// Schedule loading chunks.
-			s.refs = make([]uint64, 0, len(chks))
-			s.chks = make([]storepb.AggrChunk, 0, len(chks))
-			for _, meta := range chks {
-				if err := chunkr.addPreload(meta.Ref); err != nil {
-					return nil, nil, errors.Wrap(err, "add chunk preload")
+			e.refs = make([]uint64, 0, len(s.chks))
+			e.chks = make([]storepb.AggrChunk, 0, len(s.chks))
+			for _, meta := range s.chks {
+				if err := s.chunkr.addPreload(meta.Ref); err != nil {
+					return nil, errors.Wrap(err, "add chunk preload")
 				}
-				s.chks = append(s.chks, storepb.AggrChunk{
+				e.chks = append(e.chks, storepb.AggrChunk{
 					MinTime: meta.MinTime,
 					MaxTime: meta.MaxTime,
 				})
-				s.refs = append(s.refs, meta.Ref)
+				e.refs = append(e.refs, meta.Ref)
 			}
 
 			// Ensure sample limit through chunksLimiter if we return chunks.
-			if err := chunksLimiter.Reserve(uint64(len(s.chks))); err != nil {
-				return nil, nil, errors.Wrap(err, "exceeded chunks limit")
+			if err := s.chunksLimiter.Reserve(uint64(len(e.chks))); err != nil {
+				return nil, errors.Wrap(err, "exceeded chunks limit")
 			}
 		}
-		if err := indexr.LookupLabelsSymbols(symbolizedLset, &lset); err != nil {
-			return nil, nil, errors.Wrap(err, "Lookup labels symbols")
+		var lset labels.Labels
+		if err := s.indexr.LookupLabelsSymbols(s.symbolizedLset, &lset); err != nil {
+			return nil, errors.Wrap(err, "Lookup labels symbols")
 		}
 
-		s.lset = labelpb.ExtendSortedLabels(lset, extLset)
-		res = append(res, s)
+		e.lset = labelpb.ExtendSortedLabels(lset, s.extLset)
+		entries = append(entries, e)
 	}
 
-	if req.SkipChunks {
-		return newBucketSeriesSet(res), indexr.stats, nil
+	if s.req.SkipChunks {
+		return entries, nil
 	}
 
 	// Preload all chunks that were marked in the previous stage.
-	if err := chunkr.preload(); err != nil {
-		return nil, nil, errors.Wrap(err, "preload chunks")
+	if err := s.chunkr.preload(); err != nil {
+		return nil, errors.Wrap(err, "preload chunks")
 	}
 
+	// Chunks of the last batch can reference the pooled buffers, which are released only when the reader is closed.
+	// Chunks of other batches are copied, because they are still used by the merge of series sets when the next batch
+	// is loaded and the buffers are returned to the pool.
+	copyChunks := len(s.ps) > 0
+
 	// Transform all chunks into the response format.
-	for _, s := range res {
-		for i, ref := range s.refs {
-			chk, err := chunkr.Chunk(ref)
+	for _, e := range entries {
+		for i, ref := range e.refs {
+			chk, err := s.chunkr.Chunk(ref)
 			if err != nil {
-				return nil, nil, errors.Wrap(err, "get chunk")
+				return nil, errors.Wrap(err, "get chunk")
+			}
+			if err := populateChunk(&e.chks[i], chk, s.req.Aggregates); err != nil {
+				return nil, errors.Wrap(err, "populate chunk")
 			}
-			if err := populateChunk(&s.chks[i], chk, req.Aggregates); err != nil {
-				return nil, nil, errors.Wrap(err, "populate chunk")
+			if copyChunks {
+				copyAggrChunkData(&e.chks[i])
 			}
 		}
 	}
-	return newBucketSeriesSet(res), indexr.stats.merge(chunkr.stats), nil
+	return entries, nil
+}
+
+// copyAggrChunkData replaces data of all chunks of the aggregated chunk with copies.
+func copyAggrChunkData(c *storepb.AggrChunk) {
+	for _, chk := range []*storepb.Chunk{c.Raw, c.Count, c.Sum, c.Min, c.Max, c.Counter} {
+		if chk != nil {
+			chk.Data = append([]byte(nil), chk.Data...)
+		}
+	}
 }
 
 func populateChunk(out *storepb.AggrChunk, in chunkenc.Chunk, aggrs []storepb.Aggr) error {
@@ -906,19 +1165,28 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 	if err != nil {
 		return status.Error(codes.InvalidArgument, err.Error())
 	}
+	if s.warmupLog != nil {
+		s.warmupLog.record(matchers)
+	}
+	// Windows of pushed down functions are aligned to the requested min time, not the limited one.
+	queryMinTime := req.MinTime
 	req.MinTime = s.limitMinTime(req.MinTime)
 	req.MaxTime = s.limitMaxTime(req.MaxTime)
 
 	var (
 		ctx              = srv.Context()
 		stats            = &queryStats{}
-		res              []storepb.SeriesSet
+		res              []*blockSeriesSet
 		mtx              sync.Mutex
 		g, gctx          = errgroup.WithContext(ctx)
 		resHints         = &hintspb.SeriesResponseHints{}
 		reqBlockMatchers []*labels.Matcher
 		chunksLimiter    = s.chunksLimiterFactory(s.metrics.queriesDropped.WithLabelValues("chunks"))
 		seriesLimiter    = s.seriesLimiterFactory(s.metrics.queriesDropped.WithLabelValues("series"))
+
+		postingsBytesLimiter = s.postingsBytesLimiterFactory(s.metrics.queriesDropped.WithLabelValues("postings_bytes"))
+		seriesBytesLimiter   = s.seriesBytesLimiterFactory(s.metrics.queriesDropped.WithLabelValues("series_bytes"))
+		chunksBytesLimiter   = s.chunksBytesLimiterFactory(s.metrics.queriesDropped.WithLabelValues("chunks_bytes"))
 	)
 
 	if req.Hints != nil {
@@ -933,66 +1201,103 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 		}
 	}
 
+	// Readers of the queried blocks are created with the lock held, so that the blocks are not closed while being read.
+	// Bloom filters are loaded from object storage only once it is released.
+	type blockReaders struct {
+		b             *bucketBlock
+		blockMatchers []*labels.Matcher
+		indexr        *bucketIndexReader
+		chunkr        *bucketChunkReader
+	}
+	var (
+		queried []blockReaders
+		blocks  []*bucketBlock
+	)
 	s.mtx.RLock()
-
 	for _, bs := range s.blockSets {
 		blockMatchers, ok := bs.labelMatchers(matchers...)
 		if !ok {
 			continue
 		}
 
-		blocks := bs.getFor(req.MinTime, req.MaxTime, req.MaxResolutionWindow, reqBlockMatchers)
+		setBlocks := bs.getFor(req.MinTime, req.MaxTime, req.MaxResolutionWindow, reqBlockMatchers)
 
 		if s.debugLogging {
-			debugFoundBlockSetOverview(s.logger, req.MinTime, req.MaxTime, req.MaxResolutionWindow, bs.labels, blocks)
+			debugFoundBlockSetOverview(s.logger, req.MinTime, req.MaxTime, req.MaxResolutionWindow, bs.labels, setBlocks)
 		}
 
-		for _, b := range blocks {
-			b := b
-
-			if s.enableSeriesResponseHints {
-				// Keep track of queried blocks.
-				resHints.AddQueriedBlock(b.meta.ULID)
-			}
-
-			var chunkr *bucketChunkReader
-			// We must keep the readers open until all their data has been sent.
-			indexr := b.indexReader(gctx)
+		for _, b := range setBlocks {
+			r := blockReaders{b: b, blockMatchers: blockMatchers}
+			// We must keep the readers open until all their data has been sent. Readers use the request context,
+			// because batches of series after the first one are loaded while merging, after the errgroup is done.
+			r.indexr = b.indexReader(ctx)
+			r.indexr.postingsBytesLimiter, r.indexr.seriesBytesLimiter = postingsBytesLimiter, seriesBytesLimiter
 			if !req.SkipChunks {
-				chunkr = b.chunkReader(gctx)
-				defer runutil.CloseWithLogOnErr(s.logger, chunkr, "series block")
+				r.chunkr = b.chunkReader(ctx)
+				r.chunkr.chunksBytesLimiter = chunksBytesLimiter
+				defer runutil.CloseWithLogOnErr(s.logger, r.chunkr, "series block")
 			}
 
 			// Defer all closes to the end of Series method.
-			defer runutil.CloseWithLogOnErr(s.logger, indexr, "series block")
+			defer runutil.CloseWithLogOnErr(s.logger, r.indexr, "series block")
 
-			g.Go(func() error {
-				part, pstats, err := blockSeries(
-					b.extLset,
-					indexr,
-					chunkr,
-					blockMatchers,
-					req,
-					chunksLimiter,
-					seriesLimiter,
-				)
-				if err != nil {
-					return errors.Wrapf(err, "fetch series for block %s", b.meta.ULID)
-				}
+			queried = append(queried, r)
+			blocks = append(blocks, b)
+		}
+	}
+	s.mtx.RUnlock()
 
-				mtx.Lock()
-				res = append(res, part)
-				stats = stats.merge(pstats)
-				mtx.Unlock()
+	loadBloomFilters(ctx, blocks)
 
-				return nil
-			})
+	for _, r := range queried {
+		r := r
+
+		if !r.b.mayMatch(ctx, r.blockMatchers) {
+			s.metrics.bloomSkippedBlocks.Inc()
+			continue
 		}
-	}
 
-	s.mtx.RUnlock()
+		if s.enableSeriesResponseHints {
+			// Keep track of queried blocks.
+			resHints.AddQueriedBlock(r.b.meta.ULID)
+		}
+
+		g.Go(func() error {
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+			// Further batches are prefetched with the request context, as the errgroup context is canceled
+			// once all first batches are loaded.
+			part, err := newBlockSeriesSet(
+				ctx,
+				r.b.extLset,
+				r.indexr,
+				r.chunkr,
+				r.blockMatchers,
+				req,
+				chunksLimiter,
+				seriesLimiter,
+				s.seriesBatchSize,
+			)
+			if err != nil {
+				return errors.Wrapf(err, "fetch series for block %s", r.b.meta.ULID)
+			}
+
+			mtx.Lock()
+			res = append(res, part)
+			mtx.Unlock()
+
+			return nil
+		})
+	}
 
 	defer func() {
+		// Series sets keep loading batches while merging, so their stats are complete only once they are closed.
+		// This runs before the readers are closed.
+		for _, part := range res {
+			part.close()
+			stats = stats.merge(part.stats())
+		}
 		s.metrics.seriesDataTouched.WithLabelValues("postings").Observe(float64(stats.postingsTouched))
 		s.metrics.seriesDataFetched.WithLabelValues("postings").Observe(float64(stats.postingsFetched))
 		s.metrics.seriesDataSizeTouched.WithLabelValues("postings").Observe(float64(stats.postingsTouchedSizeSum))
@@ -1043,7 +1348,17 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 
 		// NOTE: We "carefully" assume series and chunks are sorted within each SeriesSet. This should be guaranteed by
 		// blockSeries method. In worst case deduplication logic won't deduplicate correctly, which will be accounted later.
-		set := storepb.MergeSeriesSets(res...)
+		sets := make([]storepb.SeriesSet, 0, len(res))
+		for _, part := range res {
+			sets = append(sets, part)
+		}
+		set := storepb.MergeSeriesSets(sets...)
+		if !req.SkipChunks {
+			set = newPushdownSeriesSet(set, req.QueryHints, queryMinTime)
+			if s.mergeSmallChunks {
+				set = newMergeSmallChunksSeriesSet(set)
+			}
+		}
 		for set.Next() {
 			var series storepb.Series
 
@@ -1065,7 +1380,12 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 			}
 		}
 		if set.Err() != nil {
-			err = status.Error(codes.Unknown, errors.Wrap(set.Err(), "expand series set").Error())
+			// Batches of series after the first one are loaded while merging, so keep the code of limit errors.
+			code := codes.Unknown
+			if s, ok := status.FromError(errors.Cause(set.Err())); ok {
+				code = s.Code()
+			}
+			err = status.Error(code, errors.Wrap(set.Err(), "expand series set").Error())
 			return
 		}
 		stats.mergeDuration = time.Since(begin)
@@ -1073,6 +1393,9 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 
 		err = nil
 	})
+	if err != nil {
+		return err
+	}
 
 	if s.enableSeriesResponseHints {
 		var anyHints *types.Any
@@ -1259,6 +1582,93 @@ func (s *BucketStore) LabelValues(ctx context.Context, req *storepb.LabelValuesR
 	}, nil
 }
 
+// TSDBStatus returns cardinality statistics of raw blocks overlapping with the requested time range, calculated from their index-headers.
+// Series present in multiple blocks are counted once per block. Series counts of label pairs are exact, computed from the sizes of their postings lists.
+func (s *BucketStore) TSDBStatus(ctx context.Context, req *tsdbstatuspb.TSDBStatusRequest) (*tsdbstatuspb.TSDBStatusResponse, error) {
+	var indexReaders []*bucketIndexReader
+	s.mtx.RLock()
+	for _, b := range s.blocks {
+		if b.meta.Thanos.Downsample.Resolution != downsample.ResLevel0 || !b.overlapsClosedInterval(req.MinTime, req.MaxTime) {
+			continue
+		}
+		indexReaders = append(indexReaders, b.indexReader(ctx))
+	}
+	s.mtx.RUnlock()
+
+	defer func() {
+		for _, indexr := range indexReaders {
+			runutil.CloseWithLogOnErr(s.logger, indexr, "tsdb status")
+		}
+	}()
+
+	builder := tsdbstatus.NewStatisticsBuilder()
+	for _, indexr := range indexReaders {
+		if err := ctx.Err(); err != nil {
+			return nil, status.Error(codes.Canceled, err.Error())
+		}
+		if err := addIndexHeaderStatistics(builder, indexr); err != nil {
+			return nil, status.Error(codes.Internal, errors.Wrapf(err, "block %s", indexr.block.meta.ULID).Error())
+		}
+	}
+	return &tsdbstatuspb.TSDBStatusResponse{Statistics: builder.Build(tsdbstatus.RequestLimit(req))}, nil
+}
+
+// addIndexHeaderStatistics adds label pairs of the block to the builder. The number of series of each label pair is
+// computed from the size of its postings list. The postings range returned by the index-header excludes the length
+// and the checksum, so it is made of 4 bytes of number of entries and 4 bytes per entry. The range of the last postings
+// list in the index also spans the following label indices table, so that list is fetched and counted instead.
+func addIndexHeaderStatistics(builder *tsdbstatus.StatisticsBuilder, indexr *bucketIndexReader) error {
+	b := indexr.block
+	builder.AddSeries(b.meta.Stats.NumSeries)
+
+	names, err := b.indexHeaderReader.LabelNames()
+	if err != nil {
+		return errors.Wrap(err, "label names")
+	}
+	for i, name := range names {
+		values, err := b.indexHeaderReader.LabelValues(name)
+		if err != nil {
+			return errors.Wrapf(err, "label values of %s", name)
+		}
+		for j, value := range values {
+			if i == len(names)-1 && j == len(values)-1 {
+				series, err := countFetchedPostings(indexr, labels.Label{Name: name, Value: value})
+				if err != nil {
+					return err
+				}
+				builder.AddLabelPair(name, value, series)
+				continue
+			}
+
+			rng, err := b.indexHeaderReader.PostingsOffset(name, value)
+			if err == indexheader.NotFoundRangeErr {
+				continue
+			}
+			if err != nil {
+				return errors.Wrapf(err, "postings offset of %s=%q", name, value)
+			}
+			var series uint64
+			if size := rng.End - rng.Start - 4; size > 0 {
+				series = uint64(size / 4)
+			}
+			builder.AddLabelPair(name, value, series)
+		}
+	}
+	return nil
+}
+
+func countFetchedPostings(indexr *bucketIndexReader, key labels.Label) (uint64, error) {
+	postings, err := indexr.fetchPostings([]labels.Label{key})
+	if err != nil {
+		return 0, errors.Wrapf(err, "fetch postings of %s=%q", key.Name, key.Value)
+	}
+	var n uint64
+	for postings[0].Next() {
+		n++
+	}
+	return n, errors.Wrapf(postings[0].Err(), "iterate postings of %s=%q", key.Name, key.Value)
+}
+
 // bucketBlockSet holds all blocks of an equal label set. It internally splits
 // them up by downsampling resolution and allows querying.
 type bucketBlockSet struct {
@@ -1268,13 +1678,12 @@ type bucketBlockSet struct {
 	blocks      [][]*bucketBlock // Ordered buckets for the existing resolutions.
 }
 
-// newBucketBlockSet initializes a new set with the known downsampling windows hard-configured.
-// The set currently does not support arbitrary ranges.
-func newBucketBlockSet(lset labels.Labels) *bucketBlockSet {
+// newBucketBlockSet initializes a new set with the given downsampling resolutions ordered from high to low.
+func newBucketBlockSet(lset labels.Labels, resolutions []int64) *bucketBlockSet {
 	return &bucketBlockSet{
 		labels:      lset,
-		resolutions: []int64{downsample.ResLevel2, downsample.ResLevel1, downsample.ResLevel0},
-		blocks:      make([][]*bucketBlock, 3),
+		resolutions: resolutions,
+		blocks:      make([][]*bucketBlock, len(resolutions)),
 	}
 }
 
@@ -1417,6 +1826,13 @@ type bucketBlock struct {
 	// Block's labels used by block-level matchers to filter blocks to query. These are used to select blocks using
 	// request hints' BlockMatchers.
 	relabelLabels labels.Labels
+
+	// Optional bloom filter of the label pairs of the block. It is nil until it is loaded, or if the block
+	// has none or it can't be decoded.
+	hasBloomFilter    bool
+	bloomFilterMtx    sync.Mutex
+	bloomFilterLoaded bool
+	bloomFilter       *bloom.Filter
 }
 
 func newBucketBlock(
@@ -1452,6 +1868,13 @@ func newBucketBlock(
 	sort.Sort(b.extLset)
 	sort.Sort(b.relabelLabels)
 
+	for _, f := range meta.Thanos.Files {
+		if f.RelPath == block.BloomFilterFilename {
+			b.hasBloomFilter = true
+			break
+		}
+	}
+
 	// Get object handles for all chunk files (segment files) from meta.json, if available.
 	if len(meta.Thanos.SegmentFiles) > 0 {
 		b.chunkObjs = make([]string, 0, len(meta.Thanos.SegmentFiles))
@@ -1530,6 +1953,75 @@ func (b *bucketBlock) chunkReader(ctx context.Context) *bucketChunkReader {
 	return newBucketChunkReader(ctx, b)
 }
 
+// loadBloomFilter returns the bloom filter of the block, loading it on the first call if the block was uploaded
+// with one. Blocks whose filter can't be loaded are still queried, just without skipping them by their filter.
+// Loading is retried by the next call if the filter can't be fetched, e.g. because the query was canceled.
+func (b *bucketBlock) loadBloomFilter(ctx context.Context) *bloom.Filter {
+	if !b.hasBloomFilter {
+		return nil
+	}
+
+	b.bloomFilterMtx.Lock()
+	defer b.bloomFilterMtx.Unlock()
+
+	if b.bloomFilterLoaded {
+		return b.bloomFilter
+	}
+
+	r, err := b.bkt.Get(ctx, path.Join(b.meta.ULID.String(), block.BloomFilterFilename))
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get bloom filter", "err", err)
+		return nil
+	}
+	defer runutil.CloseWithLogOnErr(b.logger, r, "bloom filter reader")
+
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to read bloom filter", "err", err)
+		return nil
+	}
+	b.bloomFilterLoaded = true
+
+	f, err := bloom.Decode(buf)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to decode bloom filter", "err", err)
+		return nil
+	}
+	b.bloomFilter = f
+	return f
+}
+
+// mayMatch returns false if the bloom filter of the block guarantees that no series of the block match
+// the given matchers.
+func (b *bucketBlock) mayMatch(ctx context.Context, matchers []*labels.Matcher) bool {
+	f := b.loadBloomFilter(ctx)
+	return f == nil || f.MayMatch(matchers)
+}
+
+// loadBloomFilters loads the bloom filters of the given blocks which are not loaded yet concurrently, so blocks
+// queried for the first time with lazy index-header loading don't fetch their filters one by one.
+func loadBloomFilters(ctx context.Context, blocks []*bucketBlock) {
+	var wg sync.WaitGroup
+	for _, b := range blocks {
+		if !b.hasBloomFilter {
+			continue
+		}
+		b.bloomFilterMtx.Lock()
+		loaded := b.bloomFilterLoaded
+		b.bloomFilterMtx.Unlock()
+		if loaded {
+			continue
+		}
+
+		wg.Add(1)
+		go func(b *bucketBlock) {
+			defer wg.Done()
+			b.loadBloomFilter(ctx)
+		}(b)
+	}
+	wg.Wait()
+}
+
 // matchRelabelLabels verifies whether the block matches the given matchers.
 func (b *bucketBlock) matchRelabelLabels(matchers []*labels.Matcher) bool {
 	for _, m := range matchers {
@@ -1561,6 +2053,10 @@ type bucketIndexReader struct {
 	dec   *index.Decoder
 	stats *queryStats
 
+	// Limiters of postings and series bytes fetched by the reader. Unlimited by default.
+	postingsBytesLimiter BytesLimiter
+	seriesBytesLimiter   BytesLimiter
+
 	mtx          sync.Mutex
 	loadedSeries map[uint64][]byte
 }
@@ -1572,8 +2068,10 @@ func newBucketIndexReader(ctx context.Context, block *bucketBlock) *bucketIndexR
 		dec: &index.Decoder{
 			LookupSymbol: block.indexHeaderReader.LookupSymbol,
 		},
-		stats:        &queryStats{},
-		loadedSeries: map[uint64][]byte{},
+		stats:                &queryStats{},
+		loadedSeries:         map[uint64][]byte{},
+		postingsBytesLimiter: NewBytesLimiterFactory(0)(nil),
+		seriesBytesLimiter:   NewBytesLimiterFactory(0)(nil),
 	}
 	return r
 }
@@ -1595,6 +2093,10 @@ func (r *bucketIndexReader) ExpandedPostings(ms []*labels.Matcher) ([]uint64, er
 		keys          []labels.Label
 	)
 
+	if ps, ok := r.fetchExpandedPostingsFromCache(ms); ok {
+		return r.seriesRefs(ps)
+	}
+
 	// NOTE: Derived from tsdb.PostingsForMatchers.
 	for _, m := range ms {
 		// Each group is separate to tell later what postings are intersecting with what.
@@ -1671,7 +2173,42 @@ func (r *bucketIndexReader) ExpandedPostings(ms []*labels.Matcher) ([]uint64, er
 	if err != nil {
 		return nil, errors.Wrap(err, "expand")
 	}
+	r.storeExpandedPostingsToCache(ms, ps)
 
+	return r.seriesRefs(ps)
+}
+
+// fetchExpandedPostingsFromCache returns the postings matching all matchers if they are cached.
+func (r *bucketIndexReader) fetchExpandedPostingsFromCache(ms []*labels.Matcher) ([]uint64, bool) {
+	data, ok := r.block.indexCache.FetchExpandedPostings(r.ctx, r.block.meta.ULID, ms)
+	if !ok {
+		return nil, false
+	}
+
+	p, err := diffVarintSnappyDecode(data)
+	if err == nil {
+		var ps []uint64
+		ps, err = index.ExpandPostings(p)
+		if err == nil {
+			return ps, true
+		}
+	}
+	level.Warn(r.block.logger).Log("msg", "failed to decode cached expanded postings, ignoring", "block", r.block.meta.ULID, "err", err)
+	return nil, false
+}
+
+// storeExpandedPostingsToCache caches the postings matching all matchers.
+func (r *bucketIndexReader) storeExpandedPostingsToCache(ms []*labels.Matcher, ps []uint64) {
+	data, err := diffVarintSnappyEncode(index.NewListPostings(ps), len(ps))
+	if err != nil {
+		level.Warn(r.block.logger).Log("msg", "failed to encode expanded postings for cache", "block", r.block.meta.ULID, "err", err)
+		return
+	}
+	r.block.indexCache.StoreExpandedPostings(r.ctx, r.block.meta.ULID, ms, data)
+}
+
+// seriesRefs converts postings into references of series in the index.
+func (r *bucketIndexReader) seriesRefs(ps []uint64) ([]uint64, error) {
 	// As of version two all series entries are 16 byte padded. All references
 	// we get have to account for that to get the correct offset.
 	version, err := r.block.indexHeaderReader.IndexVersion()
@@ -1788,6 +2325,9 @@ func (r *bucketIndexReader) fetchPostings(keys []labels.Label) ([]index.Postings
 	for ix, key := range keys {
 		// Get postings for the given key from cache first.
 		if b, ok := fromCache[key]; ok {
+			if err := reserveBytes(r.postingsBytesLimiter, uint64(len(b)), "postings"); err != nil {
+				return nil, err
+			}
 			r.stats.postingsTouched++
 			r.stats.postingsTouchedSizeSum += len(b)
 
@@ -1853,6 +2393,9 @@ func (r *bucketIndexReader) fetchPostings(keys []labels.Label) ([]index.Postings
 
 		// Fetch from object storage concurrently and update stats and posting list.
 		g.Go(func() error {
+			if err := reserveBytes(r.postingsBytesLimiter, uint64(length), "postings"); err != nil {
+				return err
+			}
 			begin := time.Now()
 
 			b, err := r.block.readIndexRange(ctx, start, length)
@@ -1989,6 +2532,13 @@ func (it *bigEndianPostings) length() int {
 	return len(it.list) / 4
 }
 
+// reset releases series loaded so far, so the reader can be reused for the next batch of series.
+func (r *bucketIndexReader) reset() {
+	r.mtx.Lock()
+	r.loadedSeries = map[uint64][]byte{}
+	r.mtx.Unlock()
+}
+
 func (r *bucketIndexReader) PreloadSeries(ids []uint64) error {
 	timer := prometheus.NewTimer(r.block.metrics.seriesFetchDuration)
 	defer timer.ObserveDuration()
@@ -1997,6 +2547,9 @@ func (r *bucketIndexReader) PreloadSeries(ids []uint64) error {
 	// with the missing ones.
 	fromCache, ids := r.block.indexCache.FetchMultiSeries(r.ctx, r.block.meta.ULID, ids)
 	for id, b := range fromCache {
+		if err := reserveBytes(r.seriesBytesLimiter, uint64(len(b)), "series"); err != nil {
+			return err
+		}
 		r.loadedSeries[id] = b
 	}
 
@@ -2016,6 +2569,9 @@ func (r *bucketIndexReader) PreloadSeries(ids []uint64) error {
 }
 
 func (r *bucketIndexReader) loadSeries(ctx context.Context, ids []uint64, refetch bool, start, end uint64) error {
+	if err := reserveBytes(r.seriesBytesLimiter, end-start, "series"); err != nil {
+		return err
+	}
 	begin := time.Now()
 
 	b, err := r.block.readIndexRange(ctx, int64(start), int64(end-start))
@@ -2228,15 +2784,32 @@ type bucketChunkReader struct {
 	chunks     map[uint64]chunkenc.Chunk
 	stats      *queryStats
 	chunkBytes []*[]byte // Byte slice to return to the chunk pool on close.
+
+	// Limiter of chunk bytes fetched by the reader. Unlimited by default.
+	chunksBytesLimiter BytesLimiter
 }
 
 func newBucketChunkReader(ctx context.Context, block *bucketBlock) *bucketChunkReader {
 	return &bucketChunkReader{
-		ctx:      ctx,
-		block:    block,
-		stats:    &queryStats{},
-		preloads: make([][]uint32, len(block.chunkObjs)),
-		chunks:   map[uint64]chunkenc.Chunk{},
+		ctx:                ctx,
+		block:              block,
+		stats:              &queryStats{},
+		preloads:           make([][]uint32, len(block.chunkObjs)),
+		chunks:             map[uint64]chunkenc.Chunk{},
+		chunksBytesLimiter: NewBytesLimiterFactory(0)(nil),
+	}
+}
+
+// reset releases chunks loaded so far and returns their bytes to the chunk pool, so the reader can be reused
+// for the next batch of chunks. Chunks returned by Chunk before must not be used anymore.
+func (r *bucketChunkReader) reset() {
+	for _, b := range r.chunkBytes {
+		r.block.chunkPool.Put(b)
+	}
+	r.chunkBytes = nil
+	r.chunks = map[uint64]chunkenc.Chunk{}
+	for i := range r.preloads {
+		r.preloads[i] = r.preloads[i][:0]
 	}
 }
 
@@ -2283,6 +2856,9 @@ func (r *bucketChunkReader) preload() error {
 // loadChunks will read range [start, end] from the segment file with sequence number seq.
 // This data range covers chunks starting at supplied offsets.
 func (r *bucketChunkReader) loadChunks(ctx context.Context, offs []uint32, seq int, start, end uint32) error {
+	if err := reserveBytes(r.chunksBytesLimiter, uint64(end-start), "chunks"); err != nil {
+		return err
+	}
 	fetchBegin := time.Now()
 
 	// Compute the byte ranges of chunks we actually need. The total read data may be bigger
@@ -2336,6 +2912,9 @@ func (r *bucketChunkReader) loadChunks(ctx context.Context, offs []uint32, seq i
 		r.mtx.Unlock()
 		locked = false
 
+		if err := reserveBytes(r.chunksBytesLimiter, uint64(chLen), "chunks"); err != nil {
+			return err
+		}
 		fetchBegin = time.Now()
 
 		// Read entire chunk into new buffer.
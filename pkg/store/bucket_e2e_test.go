@@ -13,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alecthomas/units"
 	"github.com/go-kit/kit/log"
 	"github.com/gogo/status"
 	"github.com/oklog/ulid"
@@ -84,6 +85,14 @@ func (c *swappableCache) FetchMultiSeries(ctx context.Context, blockID ulid.ULID
 	return c.ptr.FetchMultiSeries(ctx, blockID, ids)
 }
 
+func (c *swappableCache) StoreExpandedPostings(ctx context.Context, blockID ulid.ULID, matchers []*labels.Matcher, v []byte) {
+	c.ptr.StoreExpandedPostings(ctx, blockID, matchers, v)
+}
+
+func (c *swappableCache) FetchExpandedPostings(ctx context.Context, blockID ulid.ULID, matchers []*labels.Matcher) ([]byte, bool) {
+	return c.ptr.FetchExpandedPostings(ctx, blockID, matchers)
+}
+
 type storeSuite struct {
 	store            *BucketStore
 	minTime, maxTime int64
@@ -150,7 +159,7 @@ func newCustomSeriesLimiterFactory(limit uint64, code codes.Code) SeriesLimiterF
 	}
 }
 
-func prepareStoreWithTestBlocks(t testing.TB, dir string, bkt objstore.Bucket, manyParts bool, chunksLimiterFactory ChunksLimiterFactory, seriesLimiterFactory SeriesLimiterFactory, relabelConfig []*relabel.Config, filterConf *FilterConfig) *storeSuite {
+func prepareStoreWithTestBlocks(t testing.TB, dir string, bkt objstore.Bucket, manyParts bool, chunksLimiterFactory ChunksLimiterFactory, seriesLimiterFactory SeriesLimiterFactory, relabelConfig []*relabel.Config, filterConf *FilterConfig, options ...BucketStoreOption) *storeSuite {
 	series := []labels.Labels{
 		labels.FromStrings("a", "1", "b", "1"),
 		labels.FromStrings("a", "1", "b", "2"),
@@ -199,6 +208,7 @@ func prepareStoreWithTestBlocks(t testing.TB, dir string, bkt objstore.Bucket, m
 		true,
 		true,
 		time.Minute,
+		options...,
 	)
 	testutil.Ok(t, err)
 	defer func() { testutil.Ok(t, store.Close()) }()
@@ -654,6 +664,74 @@ func TestBucketStore_Series_ChunksLimiter_e2e(t *testing.T) {
 	}
 }
 
+func TestBucketStore_Series_BytesLimiter_e2e(t *testing.T) {
+	cases := map[string]struct {
+		postingsLimit, seriesLimit, chunksLimit units.Base2Bytes
+		expectedErr                             string
+	}{
+		"should succeed if the bytes limits are not exceeded": {
+			postingsLimit: units.MiB,
+			seriesLimit:   units.MiB,
+			chunksLimit:   units.MiB,
+		},
+		"should fail if the postings bytes limit is exceeded": {
+			postingsLimit: 1,
+			expectedErr:   "exceeded postings bytes limit",
+		},
+		"should fail if the series bytes limit is exceeded": {
+			seriesLimit: 1,
+			expectedErr: "exceeded series bytes limit",
+		},
+		"should fail if the chunks bytes limit is exceeded": {
+			chunksLimit: 1,
+			expectedErr: "exceeded chunks bytes limit",
+		},
+	}
+
+	for testName, testData := range cases {
+		t.Run(testName, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			bkt := objstore.NewInMemBucket()
+
+			dir, err := ioutil.TempDir("", "test_bucket_bytes_limiter_e2e")
+			testutil.Ok(t, err)
+			defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+			s := prepareStoreWithTestBlocks(t, dir, bkt, false, NewChunksLimiterFactory(0), NewSeriesLimiterFactory(0), emptyRelabelConfig, allowAllFilterConf,
+				WithBytesLimiterFactories(
+					NewBytesLimiterFactory(testData.postingsLimit),
+					NewBytesLimiterFactory(testData.seriesLimit),
+					NewBytesLimiterFactory(testData.chunksLimit),
+				))
+			testutil.Ok(t, s.store.SyncBlocks(ctx))
+
+			req := &storepb.SeriesRequest{
+				Matchers: []storepb.LabelMatcher{
+					{Type: storepb.LabelMatcher_EQ, Name: "a", Value: "1"},
+				},
+				MinTime: minTimeDuration.PrometheusTimestamp(),
+				MaxTime: maxTimeDuration.PrometheusTimestamp(),
+			}
+
+			s.cache.SwapWith(noopCache{})
+			srv := newStoreSeriesServer(ctx)
+			err = s.store.Series(req, srv)
+
+			if testData.expectedErr == "" {
+				testutil.Ok(t, err)
+				testutil.Equals(t, 4, len(srv.SeriesSet))
+			} else {
+				testutil.NotOk(t, err)
+				testutil.Assert(t, strings.Contains(err.Error(), testData.expectedErr), "unexpected error: %v", err)
+				status, ok := status.FromError(err)
+				testutil.Equals(t, true, ok)
+				testutil.Equals(t, codes.ResourceExhausted, status.Code())
+			}
+		})
+	}
+}
+
 func TestBucketStore_LabelNames_e2e(t *testing.T) {
 	objtesting.ForeachStore(t, func(t *testing.T, bkt objstore.Bucket) {
 		ctx, cancel := context.WithCancel(context.Background())
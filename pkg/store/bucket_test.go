@@ -20,6 +20,7 @@ import (
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/gogo/protobuf/proto"
@@ -38,6 +39,7 @@ import (
 	"go.uber.org/atomic"
 
 	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/bloom"
 	"github.com/thanos-io/thanos/pkg/block/indexheader"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
 	"github.com/thanos-io/thanos/pkg/compact/downsample"
@@ -51,6 +53,7 @@ import (
 	storetestutil "github.com/thanos-io/thanos/pkg/store/storepb/testutil"
 	"github.com/thanos-io/thanos/pkg/testutil"
 	"github.com/thanos-io/thanos/pkg/testutil/e2eutil"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus/tsdbstatuspb"
 )
 
 var emptyRelabelConfig = make([]*relabel.Config, 0)
@@ -61,7 +64,7 @@ func TestBucketBlock_Property(t *testing.T) {
 	parameters.MinSuccessfulTests = 20000
 	properties := gopter.NewProperties(parameters)
 
-	set := newBucketBlockSet(labels.Labels{})
+	set := newBucketBlockSet(labels.Labels{}, []int64{downsample.ResLevel2, downsample.ResLevel1, downsample.ResLevel0})
 
 	type resBlock struct {
 		mint, maxt int64
@@ -286,7 +289,7 @@ func TestBucketBlock_matchLabels(t *testing.T) {
 func TestBucketBlockSet_addGet(t *testing.T) {
 	defer testutil.TolerantVerifyLeak(t)
 
-	set := newBucketBlockSet(labels.Labels{})
+	set := newBucketBlockSet(labels.Labels{}, []int64{downsample.ResLevel2, downsample.ResLevel1, downsample.ResLevel0})
 
 	type resBlock struct {
 		mint, maxt int64
@@ -397,7 +400,7 @@ func TestBucketBlockSet_addGet(t *testing.T) {
 func TestBucketBlockSet_remove(t *testing.T) {
 	defer testutil.TolerantVerifyLeak(t)
 
-	set := newBucketBlockSet(labels.Labels{})
+	set := newBucketBlockSet(labels.Labels{}, []int64{downsample.ResLevel2, downsample.ResLevel1, downsample.ResLevel0})
 
 	type resBlock struct {
 		id         ulid.ULID
@@ -424,10 +427,64 @@ func TestBucketBlockSet_remove(t *testing.T) {
 	testutil.Equals(t, input[2].id, res[1].meta.ULID)
 }
 
+func TestBucketBlockSet_customResolutions(t *testing.T) {
+	defer testutil.TolerantVerifyLeak(t)
+
+	var (
+		res1m  = time.Minute.Milliseconds()
+		res15m = 15 * time.Minute.Milliseconds()
+		res1d  = 24 * time.Hour.Milliseconds()
+	)
+	set := newBucketBlockSet(labels.Labels{}, []int64{res1d, res15m, res1m, downsample.ResLevel0})
+
+	type resBlock struct {
+		id         ulid.ULID
+		window     int64
+		mint, maxt int64
+	}
+	input := []resBlock{
+		{id: ulid.MustNew(1, nil), window: downsample.ResLevel0, mint: 200, maxt: 300},
+		{id: ulid.MustNew(2, nil), window: res1m, mint: 100, maxt: 300},
+		{id: ulid.MustNew(3, nil), window: res15m, mint: 0, maxt: 300},
+		{id: ulid.MustNew(4, nil), window: res1d, mint: 0, maxt: 100},
+	}
+	for _, in := range input {
+		var m metadata.Meta
+		m.ULID = in.id
+		m.Thanos.Downsample.Resolution = in.window
+		m.MinTime = in.mint
+		m.MaxTime = in.maxt
+		testutil.Ok(t, set.add(&bucketBlock{meta: &m}))
+	}
+
+	// Resolution which is not configured cannot be added.
+	var m metadata.Meta
+	m.Thanos.Downsample.Resolution = downsample.ResLevel1
+	testutil.NotOk(t, set.add(&bucketBlock{meta: &m}))
+
+	for _, c := range []struct {
+		mint, maxt    int64
+		maxResolution int64
+		expected      []ulid.ULID
+	}{
+		{mint: 200, maxt: 299, maxResolution: 0, expected: []ulid.ULID{input[0].id}},
+		{mint: 200, maxt: 299, maxResolution: res1m, expected: []ulid.ULID{input[1].id}},
+		{mint: 200, maxt: 299, maxResolution: 5 * res1m, expected: []ulid.ULID{input[1].id}},
+		{mint: 200, maxt: 299, maxResolution: res15m, expected: []ulid.ULID{input[2].id}},
+		{mint: 0, maxt: 299, maxResolution: res1d, expected: []ulid.ULID{input[3].id, input[2].id}},
+	} {
+		var ids []ulid.ULID
+		for _, b := range set.getFor(c.mint, c.maxt, c.maxResolution, nil) {
+			ids = append(ids, b.meta.ULID)
+		}
+		testutil.Equals(t, c.expected, ids)
+	}
+}
+
 func TestBucketBlockSet_labelMatchers(t *testing.T) {
 	defer testutil.TolerantVerifyLeak(t)
 
-	set := newBucketBlockSet(labels.FromStrings("a", "b", "c", "d"))
+	set := newBucketBlockSet(labels.FromStrings("a", "b", "c", "d"), []int64{downsample.ResLevel2, downsample.ResLevel1, downsample.ResLevel0})
 
 	cases := []struct {
 		in    []*labels.Matcher
@@ -948,9 +1005,10 @@ func TestReadIndexCache_LoadSeries(t *testing.T) {
 	testutil.Ok(t, bkt.Upload(context.Background(), filepath.Join(b.meta.ULID.String(), block.IndexFilename), bytes.NewReader(buf.Get())))
 
 	r := bucketIndexReader{
-		block:        b,
-		stats:        &queryStats{},
-		loadedSeries: map[uint64][]byte{},
+		block:              b,
+		stats:              &queryStats{},
+		loadedSeries:       map[uint64][]byte{},
+		seriesBytesLimiter: NewBytesLimiterFactory(0)(nil),
 	}
 
 	// Success with no refetches.
@@ -1010,6 +1068,133 @@ func TestBucketIndexReader_ExpandedPostings(t *testing.T) {
 	benchmarkExpandedPostings(tb, bkt, id, r, 500)
 }
 
+func TestBucketIndexReader_ExpandedPostings_Cached(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test-expanded-postings-cached")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(tmpDir)) }()
+
+	bkt, err := filesystem.NewBucket(filepath.Join(tmpDir, "bkt"))
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, bkt.Close()) }()
+
+	id := uploadTestBlock(t, tmpDir, bkt, 500)
+
+	r, err := indexheader.NewBinaryReader(context.Background(), log.NewNopLogger(), bkt, tmpDir, id, DefaultPostingOffsetInMemorySampling)
+	testutil.Ok(t, err)
+
+	indexCache, err := storecache.NewInMemoryIndexCacheWithConfig(log.NewNopLogger(), nil, storecache.DefaultInMemoryIndexCacheConfig)
+	testutil.Ok(t, err)
+
+	b := &bucketBlock{
+		logger:            log.NewNopLogger(),
+		metrics:           newBucketStoreMetrics(nil),
+		indexHeaderReader: r,
+		indexCache:        indexCache,
+		bkt:               bkt,
+		meta:              &metadata.Meta{BlockMeta: tsdb.BlockMeta{ULID: id}},
+		partitioner:       NewGapBasedPartitioner(PartitionerMaxGapSize),
+	}
+
+	n1 := labels.MustNewMatcher(labels.MatchEqual, "n", "1"+storetestutil.LabelLongSuffix)
+	jFoo := labels.MustNewMatcher(labels.MatchEqual, "j", "foo")
+
+	indexr := newBucketIndexReader(context.Background(), b)
+	expected, err := indexr.ExpandedPostings([]*labels.Matcher{n1, jFoo})
+	testutil.Ok(t, err)
+	testutil.Equals(t, 10, len(expected))
+	testutil.Assert(t, indexr.stats.postingsTouched > 0, "expected postings to be touched on cache miss")
+
+	// The same matchers in a different order should be served from the expanded postings cache.
+	indexr = newBucketIndexReader(context.Background(), b)
+	actual, err := indexr.ExpandedPostings([]*labels.Matcher{jFoo, n1})
+	testutil.Ok(t, err)
+	testutil.Equals(t, expected, actual)
+	testutil.Equals(t, 0, indexr.stats.postingsTouched)
+}
+
+// poisonedBytesPool is a pool.Bytes which overwrites byte slices returned to it, to detect uses after release.
+type poisonedBytesPool struct{}
+
+func (poisonedBytesPool) Get(sz int) (*[]byte, error) {
+	b := make([]byte, 0, sz)
+	return &b, nil
+}
+
+func (poisonedBytesPool) Put(b *[]byte) {
+	for i := range *b {
+		(*b)[i] = 0xff
+	}
+}
+
+func TestBlockSeriesSet_Batches(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	tmpDir, err := ioutil.TempDir("", "test-block-series-set")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(tmpDir)) }()
+
+	bkt, err := filesystem.NewBucket(filepath.Join(tmpDir, "bkt"))
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, bkt.Close()) }()
+
+	id := uploadTestBlock(t, tmpDir, bkt, 500)
+	meta, err := block.DownloadMeta(ctx, logger, bkt, id)
+	testutil.Ok(t, err)
+
+	r, err := indexheader.NewBinaryReader(ctx, logger, bkt, tmpDir, id, DefaultPostingOffsetInMemorySampling)
+	testutil.Ok(t, err)
+	b, err := newBucketBlock(ctx, logger, newBucketStoreMetrics(nil), &meta, bkt, tmpDir, noopCache{}, poisonedBytesPool{}, r, NewGapBasedPartitioner(PartitionerMaxGapSize))
+	testutil.Ok(t, err)
+
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "j", "foo")}
+	for _, skipChunks := range []bool{false, true} {
+		t.Run(fmt.Sprintf("skipChunks=%v", skipChunks), func(t *testing.T) {
+			req := &storepb.SeriesRequest{MinTime: meta.MinTime, MaxTime: meta.MaxTime, SkipChunks: skipChunks}
+
+			expand := func(batchSize int) ([]seriesEntry, *queryStats, func()) {
+				indexr, chunkr := b.indexReader(ctx), b.chunkReader(ctx)
+				set, err := newBlockSeriesSet(ctx, nil, indexr, chunkr, matchers, req, NewChunksLimiterFactory(0)(nil), NewSeriesLimiterFactory(0)(nil), batchSize)
+				testutil.Ok(t, err)
+
+				var res []seriesEntry
+				for set.Next() {
+					lset, chks := set.At()
+					res = append(res, seriesEntry{lset: lset, chks: chks})
+				}
+				testutil.Ok(t, set.Err())
+				set.close()
+				return res, set.stats(), func() {
+					testutil.Ok(t, indexr.Close())
+					testutil.Ok(t, chunkr.Close())
+				}
+			}
+
+			expected, expectedStats, closeExpected := expand(0)
+			defer closeExpected()
+			testutil.Equals(t, 200, len(expected))
+
+			for _, batchSize := range []int{1, 33, 200, 1000} {
+				actual, stats, closeActual := expand(batchSize)
+				// Chunks of all batches but the last one must have been copied before their bytes were released.
+				testutil.Equals(t, expected, actual)
+				testutil.Equals(t, expectedStats.seriesTouched, stats.seriesTouched)
+				testutil.Equals(t, expectedStats.chunksTouched, stats.chunksTouched)
+				closeActual()
+			}
+
+			// Prefetching is stopped when the set is closed before all series are consumed.
+			indexr, chunkr := b.indexReader(ctx), b.chunkReader(ctx)
+			set, err := newBlockSeriesSet(ctx, nil, indexr, chunkr, matchers, req, NewChunksLimiterFactory(0)(nil), NewSeriesLimiterFactory(0)(nil), 10)
+			testutil.Ok(t, err)
+			testutil.Assert(t, set.Next(), "expected series")
+			set.close()
+			testutil.Ok(t, indexr.Close())
+			testutil.Ok(t, chunkr.Close())
+		})
+	}
+}
+
 func BenchmarkBucketIndexReader_ExpandedPostings(b *testing.B) {
 	tb := testutil.NewTB(b)
 
@@ -1318,8 +1503,10 @@ func benchBucketSeries(t testutil.TB, skipChunk bool, samplesPerSeries, totalSer
 
 	if !t.IsBenchmark() {
 		if !skipChunk {
-			// Make sure the pool is correctly used. This is expected for 200k numbers.
-			testutil.Equals(t, numOfBlocks, int(st.chunkPool.(*mockedPool).gets.Load()))
+			// Make sure the pool is correctly used. This is expected for 200k numbers, with chunks of each batch of series
+			// loaded at once.
+			batchesPerBlock := (seriesPerBlock + DefaultSeriesBatchSize - 1) / DefaultSeriesBatchSize
+			testutil.Equals(t, numOfBlocks*batchesPerBlock, int(st.chunkPool.(*mockedPool).gets.Load()))
 			// TODO(bwplotka): This is wrong negative for large number of samples (1mln). Investigate.
 			testutil.Equals(t, 0, int(st.chunkPool.(*mockedPool).balance.Load()))
 			st.chunkPool.(*mockedPool).gets.Store(0)
@@ -1492,9 +1679,12 @@ func TestBucketSeries_OneBlock_InMemIndexCacheSegfault(t *testing.T) {
 			b1.meta.ULID: b1,
 			b2.meta.ULID: b2,
 		},
-		queryGate:            noopGate{},
-		chunksLimiterFactory: NewChunksLimiterFactory(0),
-		seriesLimiterFactory: NewSeriesLimiterFactory(0),
+		queryGate:                   noopGate{},
+		chunksLimiterFactory:        NewChunksLimiterFactory(0),
+		seriesLimiterFactory:        NewSeriesLimiterFactory(0),
+		postingsBytesLimiterFactory: NewBytesLimiterFactory(0),
+		seriesBytesLimiterFactory:   NewBytesLimiterFactory(0),
+		chunksBytesLimiterFactory:   NewBytesLimiterFactory(0),
 	}
 
 	t.Run("invoke series for one block. Fill the cache on the way.", func(t *testing.T) {
@@ -1680,6 +1870,105 @@ func TestSeries_ErrorUnmarshallingRequestHints(t *testing.T) {
 	testutil.Equals(t, true, regexp.MustCompile(".*unmarshal series request hints.*").MatchString(err.Error()))
 }
 
+func TestSeries_BloomFilter(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test-series-bloom-filter")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(tmpDir)) }()
+
+	var (
+		ctx    = context.Background()
+		logger = log.NewNopLogger()
+		bkt    = objstore.NewInMemBucket()
+		blocks []ulid.ULID
+	)
+
+	// Each block contains a single pod, only the first one has no bloom filter.
+	for i, pod := range []string{"a", "b", "c"} {
+		id, err := e2eutil.CreateBlock(ctx, tmpDir, []labels.Labels{
+			labels.FromStrings(labels.MetricName, "up", "pod", pod),
+		}, 10, int64(i)*1000, int64(i+1)*1000, labels.FromStrings("ext1", "1"), 0, metadata.NoneFunc)
+		testutil.Ok(t, err)
+
+		bdir := filepath.Join(tmpDir, id.String())
+		if i > 0 {
+			testutil.Ok(t, bloom.WriteBlockFilter(logger, bdir, bloom.DefaultFalsePositiveRate))
+		}
+		testutil.Ok(t, block.Upload(ctx, logger, bkt, bdir, metadata.NoneFunc))
+		blocks = append(blocks, id)
+	}
+
+	for _, lazy := range []bool{false, true} {
+		t.Run(fmt.Sprintf("lazy=%v", lazy), func(t *testing.T) {
+			instrBkt := objstore.WithNoopInstr(bkt)
+			fetcher, err := block.NewMetaFetcher(logger, 10, instrBkt, filepath.Join(tmpDir, fmt.Sprintf("fetcher-%v", lazy)), nil, nil, nil)
+			testutil.Ok(t, err)
+
+			store, err := NewBucketStore(
+				logger,
+				nil,
+				instrBkt,
+				fetcher,
+				filepath.Join(tmpDir, fmt.Sprintf("store-%v", lazy)),
+				nil,
+				nil,
+				nil,
+				NewChunksLimiterFactory(0),
+				NewSeriesLimiterFactory(0),
+				NewGapBasedPartitioner(PartitionerMaxGapSize),
+				false,
+				10,
+				nil,
+				false,
+				DefaultPostingOffsetInMemorySampling,
+				true,
+				lazy,
+				0,
+			)
+			testutil.Ok(t, err)
+			defer func() { testutil.Ok(t, store.Close()) }()
+			testutil.Ok(t, store.SyncBlocks(ctx))
+
+			// With lazy index-header loading, filters are loaded by the first query of the block.
+			testutil.Equals(t, false, store.blocks[blocks[0]].bloomFilterLoaded)
+			testutil.Equals(t, !lazy, store.blocks[blocks[1]].bloomFilterLoaded)
+
+			for _, tcase := range []struct {
+				pod            string
+				expectedSeries int
+				expectedBlocks []hintspb.Block
+				expectedSkips  float64
+			}{
+				// The block without bloom filter is always queried.
+				{pod: "a", expectedSeries: 1, expectedBlocks: []hintspb.Block{{Id: blocks[0].String()}}, expectedSkips: 2},
+				{pod: "b", expectedSeries: 1, expectedBlocks: []hintspb.Block{{Id: blocks[0].String()}, {Id: blocks[1].String()}}, expectedSkips: 3},
+				{pod: "d", expectedSeries: 0, expectedBlocks: []hintspb.Block{{Id: blocks[0].String()}}, expectedSkips: 5},
+			} {
+				t.Run(tcase.pod, func(t *testing.T) {
+					srv := newStoreSeriesServer(ctx)
+					testutil.Ok(t, store.Series(&storepb.SeriesRequest{
+						MinTime: 0,
+						MaxTime: 3000,
+						Matchers: []storepb.LabelMatcher{
+							{Type: storepb.LabelMatcher_EQ, Name: labels.MetricName, Value: "up"},
+							{Type: storepb.LabelMatcher_EQ, Name: "pod", Value: tcase.pod},
+						},
+					}, srv))
+					testutil.Equals(t, tcase.expectedSeries, len(srv.SeriesSet))
+
+					testutil.Equals(t, 1, len(srv.HintsSet))
+					hints := hintspb.SeriesResponseHints{}
+					testutil.Ok(t, types.UnmarshalAny(srv.HintsSet[0], &hints))
+					sort.Slice(hints.QueriedBlocks, func(i, j int) bool { return hints.QueriedBlocks[i].Id < hints.QueriedBlocks[j].Id })
+					sort.Slice(tcase.expectedBlocks, func(i, j int) bool { return tcase.expectedBlocks[i].Id < tcase.expectedBlocks[j].Id })
+					testutil.Equals(t, tcase.expectedBlocks, hints.QueriedBlocks)
+					testutil.Equals(t, tcase.expectedSkips, promtest.ToFloat64(store.metrics.bloomSkippedBlocks))
+				})
+			}
+			testutil.Equals(t, true, store.blocks[blocks[1]].bloomFilterLoaded)
+		})
+	}
+}
+
 func TestSeries_BlockWithMultipleChunks(t *testing.T) {
 	tb := testutil.NewTB(t)
 
@@ -2400,11 +2689,12 @@ func benchmarkBlockSeriesWithConcurrency(b *testing.B, concurrency int, blockMet
 				indexReader := blk.indexReader(ctx)
 				chunkReader := blk.chunkReader(ctx)
 
-				seriesSet, _, err := blockSeries(nil, indexReader, chunkReader, matchers, req, chunksLimiter, seriesLimiter)
+				seriesSet, err := newBlockSeriesSet(ctx, nil, indexReader, chunkReader, matchers, req, chunksLimiter, seriesLimiter, DefaultSeriesBatchSize)
 				testutil.Ok(b, err)
 
 				// Ensure at least 1 series has been returned (as expected).
 				testutil.Equals(b, true, seriesSet.Next())
+				seriesSet.close()
 
 				testutil.Ok(b, indexReader.Close())
 				testutil.Ok(b, chunkReader.Close())
@@ -2453,3 +2743,95 @@ func TestChunkOffsetsToByteRanges(t *testing.T) {
 		})
 	}
 }
+
+func TestBucketStore_TSDBStatus(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test-bucket-store-tsdb-status")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(tmpDir)) }()
+
+	var (
+		ctx    = context.Background()
+		logger = log.NewNopLogger()
+		bkt    = objstore.WithNoopInstr(objstore.NewInMemBucket())
+	)
+
+	for i, series := range [][]labels.Labels{
+		{
+			labels.FromStrings(labels.MetricName, "up", "job", "a"),
+			labels.FromStrings(labels.MetricName, "up", "job", "b"),
+			labels.FromStrings(labels.MetricName, "scrape_duration_seconds", "job", "a"),
+			labels.FromStrings(labels.MetricName, "scrape_duration_seconds", "job", "a", "pod", "x"),
+		},
+		{
+			labels.FromStrings(labels.MetricName, "up", "job", "a"),
+		},
+	} {
+		id, err := e2eutil.CreateBlock(ctx, tmpDir, series, 10, int64(i)*1000, int64(i+1)*1000, labels.FromStrings("ext1", "1"), 0, metadata.NoneFunc)
+		testutil.Ok(t, err)
+		testutil.Ok(t, block.Upload(ctx, logger, bkt, filepath.Join(tmpDir, id.String()), metadata.NoneFunc))
+	}
+
+	fetcher, err := block.NewMetaFetcher(logger, 10, bkt, tmpDir, nil, nil, nil)
+	testutil.Ok(t, err)
+
+	store, err := NewBucketStore(
+		logger,
+		nil,
+		bkt,
+		fetcher,
+		filepath.Join(tmpDir, "store"),
+		nil,
+		nil,
+		nil,
+		NewChunksLimiterFactory(0),
+		NewSeriesLimiterFactory(0),
+		NewGapBasedPartitioner(PartitionerMaxGapSize),
+		false,
+		10,
+		nil,
+		false,
+		DefaultPostingOffsetInMemorySampling,
+		true,
+		false,
+		0,
+	)
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, store.Close()) }()
+	testutil.Ok(t, store.SyncBlocks(ctx))
+
+	resp, err := store.TSDBStatus(ctx, &tsdbstatuspb.TSDBStatusRequest{MinTime: 0, MaxTime: 2000, Limit: 10})
+	testutil.Ok(t, err)
+	testutil.Equals(t, tsdbstatuspb.TSDBStatistics{
+		NumSeries: 5,
+		SeriesCountByMetricName: []tsdbstatuspb.Statistic{
+			{Name: "up", Value: 3},
+			{Name: "scrape_duration_seconds", Value: 2},
+		},
+		LabelValueCountByLabelName: []tsdbstatuspb.Statistic{
+			{Name: "__name__", Value: 2},
+			{Name: "job", Value: 2},
+			{Name: "pod", Value: 1},
+		},
+		MemoryInBytesByLabelName: []tsdbstatuspb.Statistic{
+			{Name: "__name__", Value: 25},
+			{Name: "job", Value: 2},
+			{Name: "pod", Value: 1},
+		},
+		SeriesCountByLabelValuePair: []tsdbstatuspb.Statistic{
+			{Name: "job=a", Value: 4},
+			{Name: "__name__=up", Value: 3},
+			{Name: "__name__=scrape_duration_seconds", Value: 2},
+			{Name: "job=b", Value: 1},
+			{Name: "pod=x", Value: 1},
+		},
+	}, resp.Statistics)
+
+	// Only the second block overlaps the requested time range.
+	resp, err = store.TSDBStatus(ctx, &tsdbstatuspb.TSDBStatusRequest{MinTime: 1500, MaxTime: 3000, Limit: 10})
+	testutil.Ok(t, err)
+	testutil.Equals(t, uint64(1), resp.Statistics.NumSeries)
+	testutil.Equals(t, []tsdbstatuspb.Statistic{
+		{Name: "__name__=up", Value: 1},
+		{Name: "job=a", Value: 1},
+	}, resp.Statistics.SeriesCountByLabelValuePair)
+}
@@ -0,0 +1,150 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package storecache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/thanos-io/thanos/pkg/cache"
+)
+
+const (
+	backendDefaultTTL = 24 * time.Hour
+)
+
+// BackendIndexCache is an index cache storing items into a generic cache.Cache backend,
+// e.g. a redis, disk or multi-level cache.
+type BackendIndexCache struct {
+	logger log.Logger
+	cache  cache.Cache
+
+	// Metrics.
+	requests *prometheus.CounterVec
+	hits     *prometheus.CounterVec
+}
+
+// NewBackendIndexCache makes a new BackendIndexCache.
+func NewBackendIndexCache(logger log.Logger, c cache.Cache, reg prometheus.Registerer) (*BackendIndexCache, error) {
+	ic := &BackendIndexCache{
+		logger: logger,
+		cache:  c,
+	}
+
+	ic.requests = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_store_index_cache_requests_total",
+		Help: "Total number of items requests to the cache.",
+	}, []string{"item_type"})
+	ic.requests.WithLabelValues(cacheTypePostings)
+	ic.requests.WithLabelValues(cacheTypeSeries)
+	ic.requests.WithLabelValues(cacheTypeExpandedPostings)
+
+	ic.hits = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_store_index_cache_hits_total",
+		Help: "Total number of items requests to the cache that were a hit.",
+	}, []string{"item_type"})
+	ic.hits.WithLabelValues(cacheTypePostings)
+	ic.hits.WithLabelValues(cacheTypeSeries)
+	ic.hits.WithLabelValues(cacheTypeExpandedPostings)
+
+	level.Info(logger).Log("msg", "created backend index cache")
+
+	return ic, nil
+}
+
+// StorePostings sets the postings identified by the ulid and label to the value v.
+func (c *BackendIndexCache) StorePostings(ctx context.Context, blockID ulid.ULID, l labels.Label, v []byte) {
+	key := cacheKey{blockID, cacheKeyPostings(l)}.string()
+	c.cache.Store(ctx, map[string][]byte{key: v}, backendDefaultTTL)
+}
+
+// FetchMultiPostings fetches multiple postings - each identified by a label -
+// and returns a map containing cache hits, along with a list of missing keys.
+func (c *BackendIndexCache) FetchMultiPostings(ctx context.Context, blockID ulid.ULID, lbls []labels.Label) (hits map[labels.Label][]byte, misses []labels.Label) {
+	keys := make([]string, 0, len(lbls))
+	for _, lbl := range lbls {
+		keys = append(keys, cacheKey{blockID, cacheKeyPostings(lbl)}.string())
+	}
+
+	c.requests.WithLabelValues(cacheTypePostings).Add(float64(len(keys)))
+	results := c.cache.Fetch(ctx, keys)
+	if len(results) == 0 {
+		return nil, lbls
+	}
+
+	hits = map[labels.Label][]byte{}
+	for i, lbl := range lbls {
+		value, ok := results[keys[i]]
+		if !ok {
+			misses = append(misses, lbl)
+			continue
+		}
+		hits[lbl] = value
+	}
+
+	c.hits.WithLabelValues(cacheTypePostings).Add(float64(len(hits)))
+	return hits, misses
+}
+
+// StoreSeries sets the series identified by the ulid and id to the value v.
+func (c *BackendIndexCache) StoreSeries(ctx context.Context, blockID ulid.ULID, id uint64, v []byte) {
+	key := cacheKey{blockID, cacheKeySeries(id)}.string()
+	c.cache.Store(ctx, map[string][]byte{key: v}, backendDefaultTTL)
+}
+
+// FetchMultiSeries fetches multiple series - each identified by ID - from the cache
+// and returns a map containing cache hits, along with a list of missing IDs.
+func (c *BackendIndexCache) FetchMultiSeries(ctx context.Context, blockID ulid.ULID, ids []uint64) (hits map[uint64][]byte, misses []uint64) {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, cacheKey{blockID, cacheKeySeries(id)}.string())
+	}
+
+	c.requests.WithLabelValues(cacheTypeSeries).Add(float64(len(ids)))
+	results := c.cache.Fetch(ctx, keys)
+	if len(results) == 0 {
+		return nil, ids
+	}
+
+	hits = map[uint64][]byte{}
+	for i, id := range ids {
+		value, ok := results[keys[i]]
+		if !ok {
+			misses = append(misses, id)
+			continue
+		}
+		hits[id] = value
+	}
+
+	c.hits.WithLabelValues(cacheTypeSeries).Add(float64(len(hits)))
+	return hits, misses
+}
+
+// StoreExpandedPostings sets the postings matching all given matchers to the value v.
+func (c *BackendIndexCache) StoreExpandedPostings(ctx context.Context, blockID ulid.ULID, matchers []*labels.Matcher, v []byte) {
+	key := cacheKey{blockID, newCacheKeyExpandedPostings(matchers)}.string()
+	c.cache.Store(ctx, map[string][]byte{key: v}, backendDefaultTTL)
+}
+
+// FetchExpandedPostings fetches the postings matching all given matchers and returns
+// them along with a boolean telling whether it was a cache hit.
+func (c *BackendIndexCache) FetchExpandedPostings(ctx context.Context, blockID ulid.ULID, matchers []*labels.Matcher) ([]byte, bool) {
+	key := cacheKey{blockID, newCacheKeyExpandedPostings(matchers)}.string()
+
+	c.requests.WithLabelValues(cacheTypeExpandedPostings).Inc()
+	value, ok := c.cache.Fetch(ctx, []string{key})[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.hits.WithLabelValues(cacheTypeExpandedPostings).Inc()
+	return value, true
+}
@@ -0,0 +1,127 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package storecache
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/thanos-io/thanos/pkg/runutil"
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func TestBackendIndexCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_backend_index_cache")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	conf := []byte(fmt.Sprintf(`
+type: MULTI-LEVEL
+config:
+  levels:
+  - type: IN-MEMORY
+    config:
+      max_size: 1MB
+      max_item_size: 1KB
+  - type: DISK
+    config:
+      directory: %s
+      max_size: 10MB
+      max_item_size: 1MB
+`, dir))
+
+	ctx := context.Background()
+	block := ulid.MustNew(1, nil)
+	lbl := labels.Label{Name: "instance", Value: "a"}
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "job", "test")}
+
+	ic, err := NewIndexCache(log.NewNopLogger(), conf, prometheus.NewRegistry())
+	testutil.Ok(t, err)
+
+	ic.StorePostings(ctx, block, lbl, []byte{1})
+	ic.StoreSeries(ctx, block, 1, []byte{2})
+	ic.StoreExpandedPostings(ctx, block, matchers, []byte{3})
+
+	// Items are written to disk asynchronously.
+	testutil.Ok(t, runutil.Retry(10*time.Millisecond, ctx.Done(), func() error {
+		files := 0
+		if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && filepath.Ext(path) == "" {
+				files++
+			}
+			return err
+		}); err != nil {
+			return err
+		}
+		if files != 3 {
+			return errors.Errorf("expected 3 items written, got %d", files)
+		}
+		return nil
+	}))
+
+	// Items are persisted by the disk level, so they are found after a restart.
+	ic, err = NewIndexCache(log.NewNopLogger(), conf, prometheus.NewRegistry())
+	testutil.Ok(t, err)
+
+	postings, missingLabels := ic.FetchMultiPostings(ctx, block, []labels.Label{lbl, {Name: "instance", Value: "b"}})
+	testutil.Equals(t, map[labels.Label][]byte{lbl: {1}}, postings)
+	testutil.Equals(t, []labels.Label{{Name: "instance", Value: "b"}}, missingLabels)
+
+	series, missingIDs := ic.FetchMultiSeries(ctx, block, []uint64{1, 2})
+	testutil.Equals(t, map[uint64][]byte{1: {2}}, series)
+	testutil.Equals(t, []uint64{2}, missingIDs)
+
+	expandedPostings, ok := ic.FetchExpandedPostings(ctx, block, matchers)
+	testutil.Assert(t, ok, "expected expanded postings cache hit")
+	testutil.Equals(t, []byte{3}, expandedPostings)
+
+	_, ok = ic.FetchExpandedPostings(ctx, ulid.MustNew(2, nil), matchers)
+	testutil.Assert(t, !ok, "expected expanded postings cache miss")
+
+	bic := ic.(*BackendIndexCache)
+	testutil.Equals(t, 2.0, prom_testutil.ToFloat64(bic.requests.WithLabelValues(cacheTypePostings)))
+	testutil.Equals(t, 1.0, prom_testutil.ToFloat64(bic.hits.WithLabelValues(cacheTypePostings)))
+	testutil.Equals(t, 2.0, prom_testutil.ToFloat64(bic.requests.WithLabelValues(cacheTypeSeries)))
+	testutil.Equals(t, 1.0, prom_testutil.ToFloat64(bic.hits.WithLabelValues(cacheTypeSeries)))
+	testutil.Equals(t, 2.0, prom_testutil.ToFloat64(bic.requests.WithLabelValues(cacheTypeExpandedPostings)))
+	testutil.Equals(t, 1.0, prom_testutil.ToFloat64(bic.hits.WithLabelValues(cacheTypeExpandedPostings)))
+}
+
+func TestNewIndexCache_MultiLevelValidation(t *testing.T) {
+	for _, conf := range []string{
+		`
+type: MULTI-LEVEL
+config:
+  levels: []
+`,
+		`
+type: MULTI-LEVEL
+config:
+  levels:
+  - type: IN-MEMORY
+  - type: IN-MEMORY
+`,
+		`
+type: MULTI-LEVEL
+config:
+  levels:
+  - type: MULTI-LEVEL
+`,
+	} {
+		_, err := NewIndexCache(log.NewNopLogger(), []byte(conf), prometheus.NewRegistry())
+		testutil.NotOk(t, err)
+	}
+}
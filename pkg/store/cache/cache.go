@@ -6,7 +6,9 @@ package storecache
 import (
 	"context"
 	"encoding/base64"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/oklog/ulid"
 	"github.com/prometheus/prometheus/pkg/labels"
@@ -14,8 +16,9 @@ import (
 )
 
 const (
-	cacheTypePostings string = "Postings"
-	cacheTypeSeries   string = "Series"
+	cacheTypePostings         string = "Postings"
+	cacheTypeSeries           string = "Series"
+	cacheTypeExpandedPostings string = "ExpandedPostings"
 
 	sliceHeaderSize = 16
 )
@@ -39,6 +42,13 @@ type IndexCache interface {
 	// FetchMultiSeries fetches multiple series - each identified by ID - from the cache
 	// and returns a map containing cache hits, along with a list of missing IDs.
 	FetchMultiSeries(ctx context.Context, blockID ulid.ULID, ids []uint64) (hits map[uint64][]byte, misses []uint64)
+
+	// StoreExpandedPostings stores the postings matching all given matchers.
+	StoreExpandedPostings(ctx context.Context, blockID ulid.ULID, matchers []*labels.Matcher, v []byte)
+
+	// FetchExpandedPostings fetches the postings matching all given matchers and returns
+	// them along with a boolean telling whether it was a cache hit.
+	FetchExpandedPostings(ctx context.Context, blockID ulid.ULID, matchers []*labels.Matcher) ([]byte, bool)
 }
 
 type cacheKey struct {
@@ -52,6 +62,8 @@ func (c cacheKey) keyType() string {
 		return cacheTypePostings
 	case cacheKeySeries:
 		return cacheTypeSeries
+	case cacheKeyExpandedPostings:
+		return cacheTypeExpandedPostings
 	}
 	return "<unknown>"
 }
@@ -63,6 +75,9 @@ func (c cacheKey) size() uint64 {
 		return ulidSize + 2*sliceHeaderSize + uint64(len(k.Value)+len(k.Name))
 	case cacheKeySeries:
 		return ulidSize + 8 // ULID + uint64.
+	case cacheKeyExpandedPostings:
+		// ULID + slice header + number of chars in the matchers hash.
+		return ulidSize + sliceHeaderSize + uint64(len(k))
 	}
 	return 0
 }
@@ -77,6 +92,8 @@ func (c cacheKey) string() string {
 		return "P:" + c.block.String() + ":" + base64.RawURLEncoding.EncodeToString(lblHash[0:])
 	case cacheKeySeries:
 		return "S:" + c.block.String() + ":" + strconv.FormatUint(uint64(c.key.(cacheKeySeries)), 10)
+	case cacheKeyExpandedPostings:
+		return "E:" + c.block.String() + ":" + string(c.key.(cacheKeyExpandedPostings))
 	default:
 		return ""
 	}
@@ -84,3 +101,26 @@ func (c cacheKey) string() string {
 
 type cacheKeyPostings labels.Label
 type cacheKeySeries uint64
+type cacheKeyExpandedPostings string // Hash of the canonical matchers set.
+
+// newCacheKeyExpandedPostings returns the key of the postings matching all given matchers.
+// Matchers are sorted and deduplicated, so the same set of matchers in any order has the same key.
+func newCacheKeyExpandedPostings(matchers []*labels.Matcher) cacheKeyExpandedPostings {
+	strs := make([]string, 0, len(matchers))
+	for _, m := range matchers {
+		strs = append(strs, m.String())
+	}
+	sort.Strings(strs)
+
+	uniq := strs[:0]
+	for i, s := range strs {
+		if i == 0 || s != strs[i-1] {
+			uniq = append(uniq, s)
+		}
+	}
+
+	// Use cryptographically hash functions to avoid hash collisions
+	// which would end up in wrong query results.
+	hash := blake2b.Sum256([]byte(strings.Join(uniq, ",")))
+	return cacheKeyExpandedPostings(base64.RawURLEncoding.EncodeToString(hash[0:]))
+}
@@ -43,6 +43,15 @@ func TestCacheKey_string(t *testing.T) {
 			key:      cacheKey{uid, cacheKeySeries(12345)},
 			expected: fmt.Sprintf("S:%s:12345", uid.String()),
 		},
+		"should stringify expanded postings cache key": {
+			key: cacheKey{uid, newCacheKeyExpandedPostings([]*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "foo", "bar")})},
+			expected: func() string {
+				hash := blake2b.Sum256([]byte(`foo="bar"`))
+				encodedHash := base64.RawURLEncoding.EncodeToString(hash[0:])
+
+				return fmt.Sprintf("E:%s:%s", uid.String(), encodedHash)
+			}(),
+		},
 	}
 
 	for testName, testData := range tests {
@@ -75,6 +84,16 @@ func TestCacheKey_string_ShouldGuaranteeReasonablyShortKeyLength(t *testing.T) {
 				{uid, cacheKeySeries(math.MaxUint64)},
 			},
 		},
+		"should guarantee reasonably short key length for expanded postings": {
+			expectedLen: 72,
+			keys: []cacheKey{
+				{uid, newCacheKeyExpandedPostings(nil)},
+				{uid, newCacheKeyExpandedPostings([]*labels.Matcher{
+					labels.MustNewMatcher(labels.MatchRegexp, strings.Repeat("a", 100), strings.Repeat("a", 1000)),
+					labels.MustNewMatcher(labels.MatchNotEqual, "b", "c"),
+				})},
+			},
+		},
 	}
 
 	for testName, testData := range tests {
@@ -86,6 +105,29 @@ func TestCacheKey_string_ShouldGuaranteeReasonablyShortKeyLength(t *testing.T) {
 	}
 }
 
+func TestNewCacheKeyExpandedPostings(t *testing.T) {
+	t.Parallel()
+
+	a := labels.MustNewMatcher(labels.MatchEqual, "a", "1")
+	b := labels.MustNewMatcher(labels.MatchRegexp, "b", "2|3")
+	c := labels.MustNewMatcher(labels.MatchNotEqual, "c", "")
+
+	key := newCacheKeyExpandedPostings([]*labels.Matcher{a, b, c})
+	testutil.Equals(t, key, newCacheKeyExpandedPostings([]*labels.Matcher{c, a, b}))
+	testutil.Equals(t, key, newCacheKeyExpandedPostings([]*labels.Matcher{b, c, a, b}))
+	testutil.Equals(t, key, newCacheKeyExpandedPostings([]*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchNotEqual, "c", ""),
+		labels.MustNewMatcher(labels.MatchEqual, "a", "1"),
+		labels.MustNewMatcher(labels.MatchRegexp, "b", "2|3"),
+	}))
+
+	testutil.Assert(t, key != newCacheKeyExpandedPostings([]*labels.Matcher{a, b}), "subset of matchers should have a different key")
+	testutil.Assert(t, key != newCacheKeyExpandedPostings([]*labels.Matcher{a, b, labels.MustNewMatcher(labels.MatchEqual, "c", "")}), "different matcher type should have a different key")
+	testutil.Assert(t, newCacheKeyExpandedPostings([]*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "a", "b,c")}) !=
+		newCacheKeyExpandedPostings([]*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "a", "b"), labels.MustNewMatcher(labels.MatchEqual, "c", "")}),
+		"separator in label value should not collide with multiple matchers")
+}
+
 func BenchmarkCacheKey_string_Postings(b *testing.B) {
 	uid := ulid.MustNew(1, nil)
 	key := cacheKey{uid, cacheKeyPostings(labels.Label{Name: strings.Repeat("a", 100), Value: strings.Repeat("a", 1000)})}
@@ -25,10 +25,24 @@ import (
 type BucketCacheProvider string
 
 const (
-	InMemoryBucketCacheProvider  BucketCacheProvider = "IN-MEMORY" // In-memory cache-provider for caching bucket.
-	MemcachedBucketCacheProvider BucketCacheProvider = "MEMCACHED" // Memcached cache-provider for caching bucket.
+	InMemoryBucketCacheProvider   BucketCacheProvider = "IN-MEMORY"   // In-memory cache-provider for caching bucket.
+	MemcachedBucketCacheProvider  BucketCacheProvider = "MEMCACHED"   // Memcached cache-provider for caching bucket.
+	RedisBucketCacheProvider      BucketCacheProvider = "REDIS"       // Redis cache-provider for caching bucket.
+	DiskBucketCacheProvider       BucketCacheProvider = "DISK"        // Local disk cache-provider for caching bucket.
+	MultiLevelBucketCacheProvider BucketCacheProvider = "MULTI-LEVEL" // Chain of cache-providers for caching bucket, e.g. disk in front of memcached.
 )
 
+// MultiLevelCacheConfig is a configuration of a cache made of multiple levels, ordered from the fastest to the slowest.
+type MultiLevelCacheConfig struct {
+	Levels []CacheLevelConfig `yaml:"levels"`
+}
+
+// CacheLevelConfig is a configuration of a single level of a multi-level cache.
+type CacheLevelConfig struct {
+	Type          BucketCacheProvider `yaml:"type"`
+	BackendConfig interface{}         `yaml:"config"`
+}
+
 // CachingWithBackendConfig is a configuration of caching bucket used by Store component.
 type CachingWithBackendConfig struct {
 	Type          BucketCacheProvider `yaml:"type"`
@@ -82,36 +96,33 @@ func NewCachingBucketFromYaml(yamlContent []byte, bucket objstore.Bucket, logger
 		return nil, errors.Wrap(err, "marshal content of cache backend configuration")
 	}
 
-	var c cache.Cache
+	levels, err := newCacheLevels("caching-bucket", config.Type, backendConfig, logger, reg)
+	if err != nil {
+		return nil, err
+	}
 
-	switch strings.ToUpper(string(config.Type)) {
-	case string(MemcachedBucketCacheProvider):
-		var memcached cacheutil.MemcachedClient
-		memcached, err := cacheutil.NewMemcachedClient(logger, "caching-bucket", backendConfig, reg)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to create memcached client")
-		}
-		c = cache.NewMemcachedCache("caching-bucket", logger, memcached, reg)
-	case string(InMemoryBucketCacheProvider):
-		c, err = cache.NewInMemoryCache("caching-bucket", logger, reg, backendConfig)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to create inmemory cache")
+	// Items found in a slower level are back-filled into the faster ones using the shortest
+	// TTL of the items cached by the given configuration, since the original TTL is unknown.
+	newCache := func(backfillTTL time.Duration) cache.Cache {
+		c := levels[0]
+		if len(levels) > 1 {
+			c = cache.NewMultiLevelCache(backfillTTL, levels...)
 		}
-	default:
-		return nil, errors.Errorf("unsupported cache type: %s", config.Type)
+		// Include interactions with cache in the traces.
+		return cache.NewTracingCache(c)
 	}
-
-	// Include interactions with cache in the traces.
-	c = cache.NewTracingCache(c)
 	cfg := NewCachingBucketConfig()
 
 	// Configure cache.
-	cfg.CacheGetRange("chunks", c, isTSDBChunkFile, config.ChunkSubrangeSize, config.ChunkObjectAttrsTTL, config.ChunkSubrangeTTL, config.MaxChunksGetRangeRequests)
-	cfg.CacheExists("meta.jsons", c, isMetaFile, config.MetafileExistsTTL, config.MetafileDoesntExistTTL)
-	cfg.CacheGet("meta.jsons", c, isMetaFile, int(config.MetafileMaxSize), config.MetafileContentTTL, config.MetafileExistsTTL, config.MetafileDoesntExistTTL)
+	chunksCache := newCache(minDuration(config.ChunkObjectAttrsTTL, config.ChunkSubrangeTTL))
+	cfg.CacheGetRange("chunks", chunksCache, isTSDBChunkFile, config.ChunkSubrangeSize, config.ChunkObjectAttrsTTL, config.ChunkSubrangeTTL, config.MaxChunksGetRangeRequests)
+
+	metaCache := newCache(minDuration(config.MetafileExistsTTL, config.MetafileDoesntExistTTL, config.MetafileContentTTL))
+	cfg.CacheExists("meta.jsons", metaCache, isMetaFile, config.MetafileExistsTTL, config.MetafileDoesntExistTTL)
+	cfg.CacheGet("meta.jsons", metaCache, isMetaFile, int(config.MetafileMaxSize), config.MetafileContentTTL, config.MetafileExistsTTL, config.MetafileDoesntExistTTL)
 
 	// Cache Iter requests for root.
-	cfg.CacheIter("blocks-iter", c, isBlocksRootDir, config.BlocksIterTTL, JSONIterCodec{})
+	cfg.CacheIter("blocks-iter", newCache(config.BlocksIterTTL), isBlocksRootDir, config.BlocksIterTTL, JSONIterCodec{})
 
 	cb, err := NewCachingBucket(bucket, cfg, logger, reg)
 	if err != nil {
@@ -121,6 +132,92 @@ func NewCachingBucketFromYaml(yamlContent []byte, bucket objstore.Bucket, logger
 	return cb, nil
 }
 
+// newCacheLevels creates the caches configured for the given provider. Only the multi-level
+// provider returns more than one cache, ordered from the fastest to the slowest.
+func newCacheLevels(name string, provider BucketCacheProvider, backendConfig []byte, logger log.Logger, reg prometheus.Registerer) ([]cache.Cache, error) {
+	if strings.ToUpper(string(provider)) != string(MultiLevelBucketCacheProvider) {
+		c, err := newCache(name, provider, backendConfig, logger, reg)
+		if err != nil {
+			return nil, err
+		}
+		return []cache.Cache{c}, nil
+	}
+
+	config := &MultiLevelCacheConfig{}
+	if err := yaml.UnmarshalStrict(backendConfig, config); err != nil {
+		return nil, errors.Wrap(err, "parsing multi-level cache config")
+	}
+	if len(config.Levels) == 0 {
+		return nil, errors.New("no levels configured for multi-level cache")
+	}
+
+	// Metrics are registered per cache type, so a type can be used only once.
+	seen := map[string]struct{}{}
+	levels := make([]cache.Cache, 0, len(config.Levels))
+	for _, l := range config.Levels {
+		typ := strings.ToUpper(string(l.Type))
+		if typ == string(MultiLevelBucketCacheProvider) {
+			return nil, errors.New("multi-level cache cannot be nested")
+		}
+		if _, ok := seen[typ]; ok {
+			return nil, errors.Errorf("cache type %s used by more than one level", l.Type)
+		}
+		seen[typ] = struct{}{}
+
+		levelConfig, err := yaml.Marshal(l.BackendConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal content of cache level configuration")
+		}
+		c, err := newCache(name, l.Type, levelConfig, logger, reg)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, c)
+	}
+	return levels, nil
+}
+
+func newCache(name string, provider BucketCacheProvider, backendConfig []byte, logger log.Logger, reg prometheus.Registerer) (cache.Cache, error) {
+	switch strings.ToUpper(string(provider)) {
+	case string(MemcachedBucketCacheProvider):
+		memcached, err := cacheutil.NewMemcachedClient(logger, name, backendConfig, reg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create memcached client")
+		}
+		return cache.NewMemcachedCache(name, logger, memcached, reg), nil
+	case string(RedisBucketCacheProvider):
+		redis, err := cacheutil.NewRedisClient(logger, name, backendConfig, reg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create redis client")
+		}
+		return cache.NewRedisCache(name, logger, redis, reg), nil
+	case string(InMemoryBucketCacheProvider):
+		c, err := cache.NewInMemoryCache(name, logger, reg, backendConfig)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create inmemory cache")
+		}
+		return c, nil
+	case string(DiskBucketCacheProvider):
+		c, err := cache.NewDiskCache(name, logger, reg, backendConfig)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create disk cache")
+		}
+		return c, nil
+	default:
+		return nil, errors.Errorf("unsupported cache type: %s", provider)
+	}
+}
+
+func minDuration(ds ...time.Duration) time.Duration {
+	min := ds[0]
+	for _, d := range ds[1:] {
+		if d < min {
+			min = d
+		}
+	}
+	return min
+}
+
 var chunksMatcher = regexp.MustCompile(`^.*/chunks/\d+$`)
 
 func isTSDBChunkFile(name string) bool { return chunksMatcher.MatchString(name) }
@@ -11,6 +11,7 @@ import (
 	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/thanos/pkg/cache"
 	"github.com/thanos-io/thanos/pkg/cacheutil"
 	"gopkg.in/yaml.v2"
 )
@@ -18,8 +19,11 @@ import (
 type IndexCacheProvider string
 
 const (
-	INMEMORY  IndexCacheProvider = "IN-MEMORY"
-	MEMCACHED IndexCacheProvider = "MEMCACHED"
+	INMEMORY   IndexCacheProvider = "IN-MEMORY"
+	MEMCACHED  IndexCacheProvider = "MEMCACHED"
+	REDIS      IndexCacheProvider = "REDIS"
+	DISK       IndexCacheProvider = "DISK"
+	MULTILEVEL IndexCacheProvider = "MULTI-LEVEL"
 )
 
 // IndexCacheConfig specifies the index cache config.
@@ -41,15 +45,25 @@ func NewIndexCache(logger log.Logger, confContentYaml []byte, reg prometheus.Reg
 		return nil, errors.Wrap(err, "marshal content of cache backend configuration")
 	}
 
-	var cache IndexCache
+	var indexCache IndexCache
 	switch strings.ToUpper(string(cacheConfig.Type)) {
 	case string(INMEMORY):
-		cache, err = NewInMemoryIndexCache(logger, reg, backendConfig)
+		indexCache, err = NewInMemoryIndexCache(logger, reg, backendConfig)
 	case string(MEMCACHED):
 		var memcached cacheutil.MemcachedClient
 		memcached, err = cacheutil.NewMemcachedClient(logger, "index-cache", backendConfig, reg)
 		if err == nil {
-			cache, err = NewMemcachedIndexCache(logger, memcached, reg)
+			indexCache, err = NewMemcachedIndexCache(logger, memcached, reg)
+		}
+	case string(REDIS), string(DISK), string(MULTILEVEL):
+		var levels []cache.Cache
+		levels, err = newCacheLevels("index-cache", BucketCacheProvider(cacheConfig.Type), backendConfig, logger, reg)
+		if err == nil {
+			c := levels[0]
+			if len(levels) > 1 {
+				c = cache.NewMultiLevelCache(backendDefaultTTL, levels...)
+			}
+			indexCache, err = NewBackendIndexCache(logger, cache.NewTracingCache(c), reg)
 		}
 	default:
 		return nil, errors.Errorf("index cache with type %s is not supported", cacheConfig.Type)
@@ -57,5 +71,5 @@ func NewIndexCache(logger log.Logger, confContentYaml []byte, reg prometheus.Reg
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("create %s index cache", cacheConfig.Type))
 	}
-	return cache, nil
+	return indexCache, nil
 }
@@ -98,6 +98,7 @@ func NewInMemoryIndexCacheWithConfig(logger log.Logger, reg prometheus.Registere
 	}, []string{"item_type"})
 	c.evicted.WithLabelValues(cacheTypePostings)
 	c.evicted.WithLabelValues(cacheTypeSeries)
+	c.evicted.WithLabelValues(cacheTypeExpandedPostings)
 
 	c.added = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 		Name: "thanos_store_index_cache_items_added_total",
@@ -105,6 +106,7 @@ func NewInMemoryIndexCacheWithConfig(logger log.Logger, reg prometheus.Registere
 	}, []string{"item_type"})
 	c.added.WithLabelValues(cacheTypePostings)
 	c.added.WithLabelValues(cacheTypeSeries)
+	c.added.WithLabelValues(cacheTypeExpandedPostings)
 
 	c.requests = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 		Name: "thanos_store_index_cache_requests_total",
@@ -112,6 +114,7 @@ func NewInMemoryIndexCacheWithConfig(logger log.Logger, reg prometheus.Registere
 	}, []string{"item_type"})
 	c.requests.WithLabelValues(cacheTypePostings)
 	c.requests.WithLabelValues(cacheTypeSeries)
+	c.requests.WithLabelValues(cacheTypeExpandedPostings)
 
 	c.overflow = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 		Name: "thanos_store_index_cache_items_overflowed_total",
@@ -119,6 +122,7 @@ func NewInMemoryIndexCacheWithConfig(logger log.Logger, reg prometheus.Registere
 	}, []string{"item_type"})
 	c.overflow.WithLabelValues(cacheTypePostings)
 	c.overflow.WithLabelValues(cacheTypeSeries)
+	c.overflow.WithLabelValues(cacheTypeExpandedPostings)
 
 	c.hits = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 		Name: "thanos_store_index_cache_hits_total",
@@ -126,6 +130,7 @@ func NewInMemoryIndexCacheWithConfig(logger log.Logger, reg prometheus.Registere
 	}, []string{"item_type"})
 	c.hits.WithLabelValues(cacheTypePostings)
 	c.hits.WithLabelValues(cacheTypeSeries)
+	c.hits.WithLabelValues(cacheTypeExpandedPostings)
 
 	c.current = promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
 		Name: "thanos_store_index_cache_items",
@@ -133,6 +138,7 @@ func NewInMemoryIndexCacheWithConfig(logger log.Logger, reg prometheus.Registere
 	}, []string{"item_type"})
 	c.current.WithLabelValues(cacheTypePostings)
 	c.current.WithLabelValues(cacheTypeSeries)
+	c.current.WithLabelValues(cacheTypeExpandedPostings)
 
 	c.currentSize = promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
 		Name: "thanos_store_index_cache_items_size_bytes",
@@ -140,6 +146,7 @@ func NewInMemoryIndexCacheWithConfig(logger log.Logger, reg prometheus.Registere
 	}, []string{"item_type"})
 	c.currentSize.WithLabelValues(cacheTypePostings)
 	c.currentSize.WithLabelValues(cacheTypeSeries)
+	c.currentSize.WithLabelValues(cacheTypeExpandedPostings)
 
 	c.totalCurrentSize = promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
 		Name: "thanos_store_index_cache_total_size_bytes",
@@ -147,6 +154,7 @@ func NewInMemoryIndexCacheWithConfig(logger log.Logger, reg prometheus.Registere
 	}, []string{"item_type"})
 	c.totalCurrentSize.WithLabelValues(cacheTypePostings)
 	c.totalCurrentSize.WithLabelValues(cacheTypeSeries)
+	c.totalCurrentSize.WithLabelValues(cacheTypeExpandedPostings)
 
 	_ = promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
 		Name: "thanos_store_index_cache_max_size_bytes",
@@ -330,3 +338,15 @@ func (c *InMemoryIndexCache) FetchMultiSeries(_ context.Context, blockID ulid.UL
 
 	return hits, misses
 }
+
+// StoreExpandedPostings sets the postings matching all given matchers to the value v,
+// if the postings already exist in the cache they are not mutated.
+func (c *InMemoryIndexCache) StoreExpandedPostings(_ context.Context, blockID ulid.ULID, matchers []*labels.Matcher, v []byte) {
+	c.set(cacheTypeExpandedPostings, cacheKey{block: blockID, key: newCacheKeyExpandedPostings(matchers)}, v)
+}
+
+// FetchExpandedPostings fetches the postings matching all given matchers and returns
+// them along with a boolean telling whether it was a cache hit.
+func (c *InMemoryIndexCache) FetchExpandedPostings(_ context.Context, blockID ulid.ULID, matchers []*labels.Matcher) ([]byte, bool) {
+	return c.get(cacheTypeExpandedPostings, cacheKey{blockID, newCacheKeyExpandedPostings(matchers)})
+}
@@ -121,6 +121,7 @@ func TestInMemoryIndexCache_UpdateItem(t *testing.T) {
 
 	uid := func(id uint64) ulid.ULID { return ulid.MustNew(id, nil) }
 	lbl := labels.Label{Name: "foo", Value: "bar"}
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "foo", "bar")}
 	ctx := context.Background()
 
 	for _, tt := range []struct {
@@ -148,6 +149,13 @@ func TestInMemoryIndexCache_UpdateItem(t *testing.T) {
 				return b, ok
 			},
 		},
+		{
+			typ: cacheTypeExpandedPostings,
+			set: func(id uint64, b []byte) { cache.StoreExpandedPostings(ctx, uid(id), matchers, b) },
+			get: func(id uint64) ([]byte, bool) {
+				return cache.FetchExpandedPostings(ctx, uid(id), matchers)
+			},
+		},
 	} {
 		t.Run(tt.typ, func(t *testing.T) {
 			defer func() { errorLogs = nil }()
@@ -44,6 +44,7 @@ func NewMemcachedIndexCache(logger log.Logger, memcached cacheutil.MemcachedClie
 	}, []string{"item_type"})
 	c.requests.WithLabelValues(cacheTypePostings)
 	c.requests.WithLabelValues(cacheTypeSeries)
+	c.requests.WithLabelValues(cacheTypeExpandedPostings)
 
 	c.hits = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 		Name: "thanos_store_index_cache_hits_total",
@@ -51,6 +52,7 @@ func NewMemcachedIndexCache(logger log.Logger, memcached cacheutil.MemcachedClie
 	}, []string{"item_type"})
 	c.hits.WithLabelValues(cacheTypePostings)
 	c.hits.WithLabelValues(cacheTypeSeries)
+	c.hits.WithLabelValues(cacheTypeExpandedPostings)
 
 	level.Info(logger).Log("msg", "created memcached index cache")
 
@@ -176,3 +178,31 @@ func (c *MemcachedIndexCache) FetchMultiSeries(ctx context.Context, blockID ulid
 	c.hits.WithLabelValues(cacheTypeSeries).Add(float64(len(hits)))
 	return hits, misses
 }
+
+// StoreExpandedPostings sets the postings matching all given matchers to the value v.
+// The function enqueues the request and returns immediately: the entry will be
+// asynchronously stored in the cache.
+func (c *MemcachedIndexCache) StoreExpandedPostings(ctx context.Context, blockID ulid.ULID, matchers []*labels.Matcher, v []byte) {
+	key := cacheKey{blockID, newCacheKeyExpandedPostings(matchers)}.string()
+
+	if err := c.memcached.SetAsync(ctx, key, v, memcachedDefaultTTL); err != nil {
+		level.Error(c.logger).Log("msg", "failed to cache expanded postings in memcached", "err", err)
+	}
+}
+
+// FetchExpandedPostings fetches the postings matching all given matchers and returns
+// them along with a boolean telling whether it was a cache hit.
+// In case of error, it logs and returns a cache miss.
+func (c *MemcachedIndexCache) FetchExpandedPostings(ctx context.Context, blockID ulid.ULID, matchers []*labels.Matcher) ([]byte, bool) {
+	key := cacheKey{blockID, newCacheKeyExpandedPostings(matchers)}.string()
+
+	c.requests.WithLabelValues(cacheTypeExpandedPostings).Inc()
+	results := c.memcached.GetMulti(ctx, []string{key})
+	value, ok := results[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.hits.WithLabelValues(cacheTypeExpandedPostings).Inc()
+	return value, true
+}
@@ -196,6 +196,91 @@ func TestMemcachedIndexCache_FetchMultiSeries(t *testing.T) {
 	}
 }
 
+func TestMemcachedIndexCache_FetchExpandedPostings(t *testing.T) {
+	t.Parallel()
+
+	// Init some data to conveniently define test cases later one.
+	block1 := ulid.MustNew(1, nil)
+	block2 := ulid.MustNew(2, nil)
+	matchers1 := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "foo", "bar"), labels.MustNewMatcher(labels.MatchRegexp, "baz", "q.*")}
+	matchers2 := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "foo", "bar")}
+	value1 := []byte{1}
+	value2 := []byte{2}
+
+	tests := map[string]struct {
+		setup         []mockedExpandedPostings
+		mockedErr     error
+		fetchBlockID  ulid.ULID
+		fetchMatchers []*labels.Matcher
+		expectedValue []byte
+		expectedHit   bool
+	}{
+		"should return no hit on empty cache": {
+			fetchBlockID:  block1,
+			fetchMatchers: matchers1,
+		},
+		"should return hit for the same matchers in different order": {
+			setup: []mockedExpandedPostings{
+				{block: block1, matchers: matchers1, value: value1},
+				{block: block1, matchers: matchers2, value: value2},
+			},
+			fetchBlockID:  block1,
+			fetchMatchers: []*labels.Matcher{matchers1[1], matchers1[0]},
+			expectedValue: value1,
+			expectedHit:   true,
+		},
+		"should return no hit for another block": {
+			setup: []mockedExpandedPostings{
+				{block: block2, matchers: matchers1, value: value1},
+			},
+			fetchBlockID:  block1,
+			fetchMatchers: matchers1,
+		},
+		"should return no hit on memcached error": {
+			setup: []mockedExpandedPostings{
+				{block: block1, matchers: matchers1, value: value1},
+			},
+			mockedErr:     errors.New("mocked error"),
+			fetchBlockID:  block1,
+			fetchMatchers: matchers1,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			memcached := newMockedMemcachedClient(testData.mockedErr)
+			c, err := NewMemcachedIndexCache(log.NewNopLogger(), memcached, nil)
+			testutil.Ok(t, err)
+
+			// Store the expanded postings expected before running the test.
+			ctx := context.Background()
+			for _, p := range testData.setup {
+				c.StoreExpandedPostings(ctx, p.block, p.matchers, p.value)
+			}
+
+			// Fetch expanded postings from cached and assert on it.
+			value, hit := c.FetchExpandedPostings(ctx, testData.fetchBlockID, testData.fetchMatchers)
+			testutil.Equals(t, testData.expectedValue, value)
+			testutil.Equals(t, testData.expectedHit, hit)
+
+			// Assert on metrics.
+			expectedHits := 0.0
+			if testData.expectedHit {
+				expectedHits = 1
+			}
+			testutil.Equals(t, 1.0, prom_testutil.ToFloat64(c.requests.WithLabelValues(cacheTypeExpandedPostings)))
+			testutil.Equals(t, expectedHits, prom_testutil.ToFloat64(c.hits.WithLabelValues(cacheTypeExpandedPostings)))
+			testutil.Equals(t, 0.0, prom_testutil.ToFloat64(c.requests.WithLabelValues(cacheTypePostings)))
+		})
+	}
+}
+
+type mockedExpandedPostings struct {
+	block    ulid.ULID
+	matchers []*labels.Matcher
+	value    []byte
+}
+
 type mockedPostings struct {
 	block ulid.ULID
 	label labels.Label
@@ -6,9 +6,12 @@ package store
 import (
 	"sync"
 
+	"github.com/alecthomas/units"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/atomic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type ChunksLimiter interface {
@@ -25,6 +28,13 @@ type SeriesLimiter interface {
 	Reserve(num uint64) error
 }
 
+type BytesLimiter interface {
+	// Reserve num bytes out of the total number of bytes enforced by the limiter.
+	// Returns an error if the limit has been exceeded. This function must be
+	// goroutine safe.
+	Reserve(num uint64) error
+}
+
 // ChunksLimiterFactory is used to create a new ChunksLimiter. The factory is useful for
 // projects depending on Thanos (eg. Cortex) which have dynamic limits.
 type ChunksLimiterFactory func(failedCounter prometheus.Counter) ChunksLimiter
@@ -32,6 +42,9 @@ type ChunksLimiterFactory func(failedCounter prometheus.Counter) ChunksLimiter
 // SeriesLimiterFactory is used to create a new SeriesLimiter.
 type SeriesLimiterFactory func(failedCounter prometheus.Counter) SeriesLimiter
 
+// BytesLimiterFactory is used to create a new BytesLimiter.
+type BytesLimiterFactory func(failedCounter prometheus.Counter) BytesLimiter
+
 // Limiter is a simple mechanism for checking if something has passed a certain threshold.
 type Limiter struct {
 	limit    uint64
@@ -74,3 +87,19 @@ func NewSeriesLimiterFactory(limit uint64) SeriesLimiterFactory {
 		return NewLimiter(limit, failedCounter)
 	}
 }
+
+// NewBytesLimiterFactory makes a new BytesLimiterFactory with a static limit.
+func NewBytesLimiterFactory(limit units.Base2Bytes) BytesLimiterFactory {
+	return func(failedCounter prometheus.Counter) BytesLimiter {
+		return NewLimiter(uint64(limit), failedCounter)
+	}
+}
+
+// reserveBytes reserves num bytes of the given kind of data through the limiter and returns
+// a ResourceExhausted error if the limit has been exceeded.
+func reserveBytes(l BytesLimiter, num uint64, kind string) error {
+	if err := l.Reserve(num); err != nil {
+		return status.Error(codes.ResourceExhausted, errors.Wrapf(err, "exceeded %s bytes limit", kind).Error())
+	}
+	return nil
+}
@@ -6,10 +6,13 @@ package store
 import (
 	"testing"
 
+	"github.com/alecthomas/units"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/thanos-io/thanos/pkg/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestLimiter(t *testing.T) {
@@ -28,3 +31,18 @@ func TestLimiter(t *testing.T) {
 	testutil.NotOk(t, l.Reserve(2))
 	testutil.Equals(t, float64(1), prom_testutil.ToFloat64(c))
 }
+
+func TestBytesLimiter(t *testing.T) {
+	c := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+	l := NewBytesLimiterFactory(units.KiB)(c)
+
+	testutil.Ok(t, reserveBytes(l, 1000, "chunks"))
+	testutil.Ok(t, reserveBytes(l, 24, "chunks"))
+	testutil.Equals(t, float64(0), prom_testutil.ToFloat64(c))
+
+	err := reserveBytes(l, 1, "chunks")
+	testutil.NotOk(t, err)
+	testutil.Equals(t, codes.ResourceExhausted, status.Code(err))
+	testutil.Equals(t, "exceeded chunks bytes limit: limit 1024 violated (got 1025)", status.Convert(err).Message())
+	testutil.Equals(t, float64(1), prom_testutil.ToFloat64(c))
+}
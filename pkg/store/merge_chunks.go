@@ -0,0 +1,121 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package store
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+)
+
+// mergeSmallChunksSeriesSet re-encodes consecutive small raw chunks of series into bigger ones, which saves
+// the per chunk overhead on the wire. Small chunks are common for series spanning many uncompacted blocks.
+type mergeSmallChunksSeriesSet struct {
+	set storepb.SeriesSet
+
+	lset labels.Labels
+	chks []storepb.AggrChunk
+	err  error
+}
+
+func newMergeSmallChunksSeriesSet(set storepb.SeriesSet) storepb.SeriesSet {
+	return &mergeSmallChunksSeriesSet{set: set}
+}
+
+func (s *mergeSmallChunksSeriesSet) Next() bool {
+	if !s.set.Next() {
+		return false
+	}
+	lset, chks := s.set.At()
+	merged, err := mergeSmallChunks(chks, MaxSamplesPerChunk)
+	if err != nil {
+		s.err = errors.Wrapf(err, "merge chunks of series %s", lset)
+		return false
+	}
+	s.lset, s.chks = lset, merged
+	return true
+}
+
+func (s *mergeSmallChunksSeriesSet) At() (labels.Labels, []storepb.AggrChunk) {
+	return s.lset, s.chks
+}
+
+func (s *mergeSmallChunksSeriesSet) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.set.Err()
+}
+
+// mergeSmallChunks merges runs of consecutive, non overlapping raw XOR chunks with at most maxSamples samples
+// in total into single chunks. Chunks which are not merged with any other chunk are returned as they are.
+func mergeSmallChunks(chks []storepb.AggrChunk, maxSamples int) ([]storepb.AggrChunk, error) {
+	if len(chks) < 2 {
+		return chks, nil
+	}
+
+	res := make([]storepb.AggrChunk, 0, len(chks))
+	// Run of chunks to merge and their decoded chunks.
+	run := make([]storepb.AggrChunk, 0, 8)
+	runChks := make([]chunkenc.Chunk, 0, 8)
+	runSamples := 0
+
+	flush := func() error {
+		defer func() {
+			run, runChks, runSamples = run[:0], runChks[:0], 0
+		}()
+		if len(run) < 2 {
+			res = append(res, run...)
+			return nil
+		}
+		out := chunkenc.NewXORChunk()
+		app, err := out.Appender()
+		if err != nil {
+			return err
+		}
+		for _, c := range runChks {
+			it := c.Iterator(nil)
+			for it.Next() {
+				app.Append(it.At())
+			}
+			if err := it.Err(); err != nil {
+				return errors.Wrap(err, "iterate chunk")
+			}
+		}
+		res = append(res, storepb.AggrChunk{
+			MinTime: run[0].MinTime,
+			MaxTime: run[len(run)-1].MaxTime,
+			Raw:     &storepb.Chunk{Type: storepb.Chunk_XOR, Data: out.Bytes()},
+		})
+		return nil
+	}
+
+	for _, c := range chks {
+		if c.Raw == nil || c.Raw.Type != storepb.Chunk_XOR {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			res = append(res, c)
+			continue
+		}
+		chk, err := chunkenc.FromData(chunkenc.EncXOR, c.Raw.Data)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode chunk")
+		}
+		n := chk.NumSamples()
+		if len(run) > 0 && (runSamples+n > maxSamples || c.MinTime <= run[len(run)-1].MaxTime) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		run, runChks = append(run, c), append(runChks, chk)
+		runSamples += n
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,88 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package store
+
+import (
+	"testing"
+
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func TestMergeSmallChunks(t *testing.T) {
+	samples := func(mint, maxt int64) (res []sample) {
+		for ts := mint; ts <= maxt; ts++ {
+			res = append(res, sample{t: ts, v: float64(ts)})
+		}
+		return res
+	}
+	aggr := storepb.AggrChunk{MinTime: 100, MaxTime: 109, Count: &storepb.Chunk{Type: storepb.Chunk_XOR}}
+
+	for _, tcase := range []struct {
+		name     string
+		chks     []storepb.AggrChunk
+		expected []int
+	}{
+		{
+			name:     "single chunk",
+			chks:     encodeSamples(t, samples(0, 9), 10),
+			expected: []int{10},
+		},
+		{
+			name:     "small chunks",
+			chks:     encodeSamples(t, samples(0, 99), 10),
+			expected: []int{100},
+		},
+		{
+			name:     "chunks exceeding max samples",
+			chks:     encodeSamples(t, samples(0, 299), 50),
+			expected: []int{100, 100, 100},
+		},
+		{
+			name:     "full chunks",
+			chks:     encodeSamples(t, samples(0, 299), 100),
+			expected: []int{100, 100, 100},
+		},
+		{
+			name:     "overlapping chunks",
+			chks:     append(encodeSamples(t, samples(0, 19), 10), encodeSamples(t, samples(10, 29), 10)...),
+			expected: []int{20, 20},
+		},
+		{
+			name:     "aggregated chunks",
+			chks:     append(append(encodeSamples(t, samples(0, 19), 10), aggr), encodeSamples(t, samples(110, 129), 10)...),
+			expected: []int{20, 0, 20},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			merged, err := mergeSmallChunks(tcase.chks, 100)
+			testutil.Ok(t, err)
+
+			var got []int
+			for _, c := range merged {
+				if c.Raw == nil {
+					got = append(got, 0)
+					continue
+				}
+				got = append(got, len(decodeSamples(t, []storepb.AggrChunk{c})))
+			}
+			testutil.Equals(t, tcase.expected, got)
+
+			var raw []storepb.AggrChunk
+			for _, c := range tcase.chks {
+				if c.Raw != nil {
+					raw = append(raw, c)
+				}
+			}
+			var mergedRaw []storepb.AggrChunk
+			for _, c := range merged {
+				if c.Raw != nil {
+					mergedRaw = append(mergedRaw, c)
+					testutil.Equals(t, decodeSamples(t, []storepb.AggrChunk{c})[0].t, c.MinTime)
+				}
+			}
+			testutil.Equals(t, decodeSamples(t, raw), decodeSamples(t, mergedRaw))
+		})
+	}
+}
@@ -53,7 +53,8 @@ func (s *MultiTSDBStore) Info(ctx context.Context, req *storepb.InfoRequest) (*s
 	stores := s.tsdbStores()
 
 	resp := &storepb.InfoResponse{
-		StoreType: s.component.ToProto(),
+		StoreType:          s.component.ToProto(),
+		SeriesCompressions: SeriesCompressions,
 	}
 	if len(stores) == 0 {
 		return resp, nil
@@ -104,10 +104,11 @@ func (p *PrometheusStore) Info(_ context.Context, _ *storepb.InfoRequest) (*stor
 	mint, maxt := p.timestamps()
 
 	res := &storepb.InfoResponse{
-		Labels:    make([]labelpb.ZLabel, 0, len(lset)),
-		StoreType: p.component.ToProto(),
-		MinTime:   mint,
-		MaxTime:   maxt,
+		Labels:             make([]labelpb.ZLabel, 0, len(lset)),
+		StoreType:          p.component.ToProto(),
+		MinTime:            mint,
+		MaxTime:            maxt,
+		SeriesCompressions: SeriesCompressions,
 	}
 	res.Labels = append(res.Labels, labelpb.ZLabelsFromPromLabels(lset)...)
 
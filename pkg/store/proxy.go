@@ -21,6 +21,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/thanos-io/thanos/pkg/component"
+	"github.com/thanos-io/thanos/pkg/extgrpc/snappy"
 	"github.com/thanos-io/thanos/pkg/store/labelpb"
 	"github.com/thanos-io/thanos/pkg/store/storepb"
 	"github.com/thanos-io/thanos/pkg/strutil"
@@ -36,6 +37,9 @@ type ctxKey int
 // StoreMatcherKey is the context key for the store's allow list.
 const StoreMatcherKey = ctxKey(0)
 
+// SeriesCompressions are the gRPC compressors StoreAPIs of this package advertise for Series calls.
+var SeriesCompressions = []string{snappy.Name}
+
 // Client holds meta information about a store.
 type Client interface {
 	// Client to access the store.
@@ -113,8 +117,9 @@ func NewProxyStore(
 // Info returns store information about the external labels this store have.
 func (s *ProxyStore) Info(_ context.Context, _ *storepb.InfoRequest) (*storepb.InfoResponse, error) {
 	res := &storepb.InfoResponse{
-		StoreType: s.component.ToProto(),
-		Labels:    labelpb.ZLabelsFromPromLabels(s.selectorLabels),
+		StoreType:          s.component.ToProto(),
+		Labels:             labelpb.ZLabelsFromPromLabels(s.selectorLabels),
+		SeriesCompressions: SeriesCompressions,
 	}
 
 	minTime := int64(math.MaxInt64)
@@ -225,6 +230,7 @@ func (s *ProxyStore) Series(r *storepb.SeriesRequest, srv storepb.Store_SeriesSe
 				MaxResolutionWindow:     r.MaxResolutionWindow,
 				SkipChunks:              r.SkipChunks,
 				PartialResponseDisabled: r.PartialResponseDisabled,
+				QueryHints:              r.QueryHints,
 			}
 			wg = &sync.WaitGroup{}
 		)
@@ -0,0 +1,242 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package store
+
+import (
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/value"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+)
+
+const (
+	pushdownCountOverTime = "count_over_time"
+	pushdownMaxOverTime   = "max_over_time"
+	pushdownMinOverTime   = "min_over_time"
+)
+
+// QueryPushdownFuncs are the functions of SeriesRequest query hints pushed down by the bucket store.
+var QueryPushdownFuncs = []string{pushdownCountOverTime, pushdownMaxOverTime, pushdownMinOverTime}
+
+// sampleReducer reduces the samples of a series, passed in order, to the samples to send instead.
+type sampleReducer interface {
+	add(t int64, v float64, emit func(t int64, v float64))
+	// flush emits samples still pending after the last sample of a series.
+	flush(emit func(t int64, v float64))
+}
+
+// newSampleReducerFunc returns the constructor of reducers pushing down the function in the given hints, or nil
+// if the function can't be pushed down. Windows of queries are aligned to the given min time of the request.
+//
+// Reduced samples give the same function result as all samples for every window the PromQL engine evaluates.
+// count_over_time only depends on the number of samples, so all samples are kept with a constant value,
+// which compresses much better. max_over_time and min_over_time only depend on the extreme sample of a window.
+// Windows start and end on a grid with a step dividing both query step and range, so only samples exactly on
+// the grid and the extreme sample between each two grid points are kept. Extremes don't change when these
+// samples are merged with overlapping data of other stores.
+func newSampleReducerFunc(hints *storepb.QueryHints, mint int64) func() sampleReducer {
+	if hints == nil || hints.Func == nil || hints.Range == nil || hints.Range.Millis <= 0 || hints.StepMillis < 0 {
+		return nil
+	}
+	width := gcd(hints.StepMillis, hints.Range.Millis)
+
+	switch hints.Func.Name {
+	case pushdownCountOverTime:
+		return func() sampleReducer { return countSampleReducer{} }
+	case pushdownMaxOverTime:
+		return func() sampleReducer {
+			return &extremeSampleReducer{mint: mint, width: width, replaces: func(cur, v float64) bool {
+				// The same comparison as in max_over_time of the PromQL engine.
+				return v > cur || math.IsNaN(cur)
+			}}
+		}
+	case pushdownMinOverTime:
+		return func() sampleReducer {
+			return &extremeSampleReducer{mint: mint, width: width, replaces: func(cur, v float64) bool {
+				return v < cur || math.IsNaN(cur)
+			}}
+		}
+	}
+	return nil
+}
+
+type countSampleReducer struct{}
+
+func (countSampleReducer) add(t int64, _ float64, emit func(int64, float64)) { emit(t, 0) }
+
+func (countSampleReducer) flush(func(int64, float64)) {}
+
+type extremeSampleReducer struct {
+	mint, width int64
+	replaces    func(cur, v float64) bool
+
+	pending       bool
+	pendingBucket int64
+	pendingT      int64
+	pendingV      float64
+}
+
+func (r *extremeSampleReducer) add(t int64, v float64, emit func(int64, float64)) {
+	// Bucket i holds samples after the grid point mint + i*width and before the next one.
+	bucket, offset := (t-r.mint)/r.width, (t-r.mint)%r.width
+	if offset < 0 {
+		bucket, offset = bucket-1, offset+r.width
+	}
+	if r.pending && (offset == 0 || bucket != r.pendingBucket) {
+		r.flush(emit)
+	}
+
+	switch {
+	case offset == 0:
+		// Samples on the grid start or end a window, so they are always kept.
+		emit(t, v)
+	case !r.pending:
+		r.pending, r.pendingBucket, r.pendingT, r.pendingV = true, bucket, t, v
+	case r.replaces(r.pendingV, v):
+		r.pendingT, r.pendingV = t, v
+	}
+}
+
+func (r *extremeSampleReducer) flush(emit func(int64, float64)) {
+	if r.pending {
+		emit(r.pendingT, r.pendingV)
+		r.pending = false
+	}
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// pushdownSeriesSet reduces the samples of raw chunks of series with a sampleReducer. Aggregated chunks of
+// downsampled blocks are passed through.
+type pushdownSeriesSet struct {
+	set       storepb.SeriesSet
+	newReduce func() sampleReducer
+
+	lset labels.Labels
+	chks []storepb.AggrChunk
+	err  error
+}
+
+// newPushdownSeriesSet returns a series set pushing down the function in the given hints, or the given
+// set if the function can't be pushed down.
+func newPushdownSeriesSet(set storepb.SeriesSet, hints *storepb.QueryHints, mint int64) storepb.SeriesSet {
+	newReduce := newSampleReducerFunc(hints, mint)
+	if newReduce == nil {
+		return set
+	}
+	return &pushdownSeriesSet{set: set, newReduce: newReduce}
+}
+
+func (s *pushdownSeriesSet) Next() bool {
+	for s.set.Next() {
+		lset, chks := s.set.At()
+		reduced, err := reduceChunks(chks, s.newReduce())
+		if err != nil {
+			s.err = errors.Wrapf(err, "push down query hints for series %s", lset)
+			return false
+		}
+		if len(reduced) == 0 {
+			// Series with only stale markers have no samples for functions over time.
+			continue
+		}
+		s.lset, s.chks = lset, reduced
+		return true
+	}
+	return false
+}
+
+func (s *pushdownSeriesSet) At() (labels.Labels, []storepb.AggrChunk) {
+	return s.lset, s.chks
+}
+
+func (s *pushdownSeriesSet) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.set.Err()
+}
+
+// reduceChunks re-encodes samples of the raw chunks reduced by the given reducer into new raw chunks. Chunks of
+// overlapping blocks are merged sample by sample before reducing them, keeping one sample per timestamp.
+func reduceChunks(chks []storepb.AggrChunk, reduce sampleReducer) ([]storepb.AggrChunk, error) {
+	var (
+		res    = make([]storepb.AggrChunk, 0, len(chks))
+		series = make([]storage.Series, 0, len(chks))
+		out    *chunkenc.XORChunk
+		app    chunkenc.Appender
+		mint   int64
+		maxt   int64
+	)
+	cut := func() {
+		if out == nil {
+			return
+		}
+		res = append(res, storepb.AggrChunk{
+			MinTime: mint,
+			MaxTime: maxt,
+			Raw:     &storepb.Chunk{Type: storepb.Chunk_XOR, Data: out.Bytes()},
+		})
+		out = nil
+	}
+	emit := func(t int64, v float64) {
+		if out == nil || out.NumSamples() >= MaxSamplesPerChunk {
+			cut()
+			out = chunkenc.NewXORChunk()
+			// Appender of a new chunk never fails.
+			app, _ = out.Appender()
+			mint = t
+		}
+		app.Append(t, v)
+		maxt = t
+	}
+
+	for _, c := range chks {
+		if c.Raw == nil {
+			res = append(res, c)
+			continue
+		}
+		if c.Raw.Type != storepb.Chunk_XOR {
+			return nil, errors.Errorf("unsupported chunk encoding %d", c.Raw.Type)
+		}
+		chk, err := chunkenc.FromData(chunkenc.EncXOR, c.Raw.Data)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode chunk")
+		}
+		series = append(series, &storage.SeriesEntry{SampleIteratorFn: func() chunkenc.Iterator { return chk.Iterator(nil) }})
+	}
+	if len(series) == 0 {
+		return res, nil
+	}
+
+	it := storage.ChainedSeriesMerge(series...).Iterator()
+	for it.Next() {
+		t, v := it.At()
+		// Stale markers are ignored by functions over time.
+		if value.IsStaleNaN(v) {
+			continue
+		}
+		reduce.add(t, v, emit)
+	}
+	if err := it.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate chunks")
+	}
+	reduce.flush(emit)
+	cut()
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].MinTime < res[j].MinTime
+	})
+	return res, nil
+}
@@ -0,0 +1,199 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package store
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/value"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+	"github.com/thanos-io/thanos/pkg/testutil"
+)
+
+func encodeSamples(t *testing.T, samples []sample, samplesPerChunk int) []storepb.AggrChunk {
+	var chks []storepb.AggrChunk
+	for len(samples) > 0 {
+		n := samplesPerChunk
+		if n > len(samples) {
+			n = len(samples)
+		}
+		c := chunkenc.NewXORChunk()
+		app, err := c.Appender()
+		testutil.Ok(t, err)
+		for _, s := range samples[:n] {
+			app.Append(s.t, s.v)
+		}
+		chks = append(chks, storepb.AggrChunk{
+			MinTime: samples[0].t,
+			MaxTime: samples[n-1].t,
+			Raw:     &storepb.Chunk{Type: storepb.Chunk_XOR, Data: c.Bytes()},
+		})
+		samples = samples[n:]
+	}
+	return chks
+}
+
+func decodeSamples(t *testing.T, chks []storepb.AggrChunk) []sample {
+	var samples []sample
+	for _, c := range chks {
+		chk, err := chunkenc.FromData(chunkenc.EncXOR, c.Raw.Data)
+		testutil.Ok(t, err)
+		it := chk.Iterator(nil)
+		for it.Next() {
+			ts, v := it.At()
+			samples = append(samples, sample{t: ts, v: v})
+		}
+		testutil.Ok(t, it.Err())
+	}
+	return samples
+}
+
+// evalOverTime evaluates the given function over the window [maxt-rng, maxt] like the PromQL engine.
+func evalOverTime(fn string, samples []sample, maxt, rng int64) (float64, bool) {
+	var (
+		res float64
+		n   int
+	)
+	for _, s := range samples {
+		if s.t < maxt-rng || s.t > maxt || value.IsStaleNaN(s.v) {
+			continue
+		}
+		n++
+		switch fn {
+		case pushdownCountOverTime:
+			res = float64(n)
+		case pushdownMaxOverTime:
+			if n == 1 || s.v > res || math.IsNaN(res) {
+				res = s.v
+			}
+		case pushdownMinOverTime:
+			if n == 1 || s.v < res || math.IsNaN(res) {
+				res = s.v
+			}
+		}
+	}
+	return res, n > 0
+}
+
+func TestReduceChunks(t *testing.T) {
+	random := rand.New(rand.NewSource(120))
+
+	// Irregular samples with duplicates of overlapping blocks, NaNs and stale markers.
+	var raw []sample
+	for ts := int64(0); ts < 3*60*60*1000; ts += 1000 + random.Int63n(30000) {
+		v := random.Float64() * 100
+		switch random.Intn(50) {
+		case 0:
+			v = math.NaN()
+		case 1:
+			v = math.Float64frombits(value.StaleNaN)
+		}
+		raw = append(raw, sample{t: ts, v: v})
+	}
+	sortChunks := func(chks []storepb.AggrChunk) []storepb.AggrChunk {
+		sort.Slice(chks, func(i, j int) bool { return chks[i].MinTime < chks[j].MinTime })
+		return chks
+	}
+	// Chunks of overlapping blocks with samples at different timestamps, e.g. written by two receivers.
+	var even, odd []sample
+	for i, s := range raw {
+		if i%2 == 0 {
+			even = append(even, s)
+		} else {
+			odd = append(odd, s)
+		}
+	}
+
+	for name, chks := range map[string][]storepb.AggrChunk{
+		"single block": encodeSamples(t, raw, 120),
+		// Chunks of an overlapping block with the same samples.
+		"overlapping blocks with duplicates": sortChunks(append(encodeSamples(t, raw, 120), encodeSamples(t, raw[100:400], 50)...)),
+		"overlapping blocks":                 sortChunks(append(encodeSamples(t, even, 120), encodeSamples(t, odd, 70)...)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			testReduceChunks(t, raw, chks)
+		})
+	}
+}
+
+func testReduceChunks(t *testing.T, raw []sample, chks []storepb.AggrChunk) {
+	for _, fn := range QueryPushdownFuncs {
+		for _, tcase := range []struct {
+			start, step, rng int64
+		}{
+			{start: 7 * 60 * 1000, step: 0, rng: 5 * 60 * 1000},
+			{start: 5 * 60 * 1000, step: 60 * 1000, rng: 5 * 60 * 1000},
+			{start: 12345, step: 90 * 1000, rng: 5 * 60 * 1000},
+			{start: 60 * 60 * 1000, step: 7 * 60 * 1000, rng: 60 * 60 * 1000},
+			{start: 1000, step: 15 * 1000, rng: 1000},
+		} {
+			t.Run(fmt.Sprintf("%s/start=%d,step=%d,range=%d", fn, tcase.start, tcase.step, tcase.rng), func(t *testing.T) {
+				hints := &storepb.QueryHints{
+					StepMillis: tcase.step,
+					Func:       &storepb.Func{Name: fn},
+					Range:      &storepb.Range{Millis: tcase.rng},
+				}
+				// The engine selects samples from the first window start on.
+				mint := tcase.start - tcase.rng
+				reduced, err := reduceChunks(chks, newSampleReducerFunc(hints, mint)())
+				testutil.Ok(t, err)
+				reducedSamples := decodeSamples(t, reduced)
+				testutil.Assert(t, len(reducedSamples) <= len(raw), "expected at most %d samples, got %d", len(raw), len(reducedSamples))
+
+				step := tcase.step
+				if step == 0 {
+					step = 60 * 1000
+				}
+				for ts := tcase.start; ts < 3*60*60*1000; ts += step {
+					exp, expOk := evalOverTime(fn, raw, ts, tcase.rng)
+					got, gotOk := evalOverTime(fn, reducedSamples, ts, tcase.rng)
+					testutil.Equals(t, expOk, gotOk, "at %d", ts)
+					if math.IsNaN(exp) {
+						testutil.Assert(t, math.IsNaN(got), "at %d: expected NaN, got %v", ts, got)
+						continue
+					}
+					testutil.Equals(t, exp, got, "at %d", ts)
+					if tcase.step == 0 {
+						// Only a single window is aligned for instant queries.
+						break
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestReduceChunks_ReducesExtremes(t *testing.T) {
+	var raw []sample
+	for ts := int64(0); ts < 60*60*1000; ts += 15 * 1000 {
+		raw = append(raw, sample{t: ts, v: float64(ts % 7)})
+	}
+	hints := &storepb.QueryHints{
+		StepMillis: 60 * 1000,
+		Func:       &storepb.Func{Name: pushdownMaxOverTime},
+		Range:      &storepb.Range{Millis: 5 * 60 * 1000},
+	}
+	reduced, err := reduceChunks(encodeSamples(t, raw, 120), newSampleReducerFunc(hints, 0)())
+	testutil.Ok(t, err)
+	// One sample on each minute and the maximum of the three samples in between.
+	testutil.Equals(t, 2*60, len(decodeSamples(t, reduced)))
+}
+
+func TestNewSampleReducerFunc(t *testing.T) {
+	for _, hints := range []*storepb.QueryHints{
+		nil,
+		{StepMillis: 1000, Func: &storepb.Func{Name: pushdownMaxOverTime}},
+		{StepMillis: 1000, Func: &storepb.Func{Name: pushdownMaxOverTime}, Range: &storepb.Range{Millis: 0}},
+		{StepMillis: 1000, Func: &storepb.Func{Name: "rate"}, Range: &storepb.Range{Millis: 1000}},
+		{StepMillis: 1000, Range: &storepb.Range{Millis: 1000}},
+	} {
+		testutil.Assert(t, newSampleReducerFunc(hints, 0) == nil, "expected %v not to be pushed down", hints)
+	}
+}
@@ -229,6 +229,11 @@ type InfoResponse struct {
 	StoreType StoreType                                              `protobuf:"varint,4,opt,name=storeType,proto3,enum=thanos.StoreType" json:"storeType,omitempty"`
 	// label_sets is an unsorted list of `ZLabelSet`s.
 	LabelSets []labelpb.ZLabelSet `protobuf:"bytes,5,rep,name=label_sets,json=labelSets,proto3" json:"label_sets"`
+	// query_pushdown_funcs is the list of functions in query_hints of SeriesRequest the store can push down.
+	QueryPushdownFuncs []string `protobuf:"bytes,6,rep,name=query_pushdown_funcs,json=queryPushdownFuncs,proto3" json:"query_pushdown_funcs,omitempty"`
+	// series_compressions is the list of gRPC compressors the store can receive Series requests and send
+	// Series responses with. Clients can request one of them with the grpc-encoding header.
+	SeriesCompressions []string `protobuf:"bytes,7,rep,name=series_compressions,json=seriesCompressions,proto3" json:"series_compressions,omitempty"`
 }
 
 func (m *InfoResponse) Reset()         { *m = InfoResponse{} }
@@ -280,6 +285,10 @@ type SeriesRequest struct {
 	// The content of this field and whether it's supported depends on the
 	// implementation of a specific store.
 	Hints *types.Any `protobuf:"bytes,9,opt,name=hints,proto3" json:"hints,omitempty"`
+	// query_hints describe the PromQL function the requested series are evaluated with. Stores supporting
+	// the function (see query_pushdown_funcs of InfoResponse) may reduce the samples of the returned series,
+	// as long as evaluating the function on them gives the same result as on all samples.
+	QueryHints *QueryHints `protobuf:"bytes,10,opt,name=query_hints,json=queryHints,proto3" json:"query_hints,omitempty"`
 }
 
 func (m *SeriesRequest) Reset()         { *m = SeriesRequest{} }
@@ -315,6 +324,123 @@ func (m *SeriesRequest) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_SeriesRequest proto.InternalMessageInfo
 
+type QueryHints struct {
+	// step_millis is the query resolution step width in milliseconds, 0 for instant queries.
+	StepMillis int64 `protobuf:"varint,1,opt,name=step_millis,json=stepMillis,proto3" json:"step_millis,omitempty"`
+	// func is the function surrounding the series selector.
+	Func *Func `protobuf:"bytes,2,opt,name=func,proto3" json:"func,omitempty"`
+	// range is the range of the range vector selector.
+	Range *Range `protobuf:"bytes,3,opt,name=range,proto3" json:"range,omitempty"`
+}
+
+func (m *QueryHints) Reset()         { *m = QueryHints{} }
+func (m *QueryHints) String() string { return proto.CompactTextString(m) }
+func (*QueryHints) ProtoMessage()    {}
+func (*QueryHints) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a938d55a388af629, []int{5}
+}
+func (m *QueryHints) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *QueryHints) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_QueryHints.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *QueryHints) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryHints.Merge(m, src)
+}
+func (m *QueryHints) XXX_Size() int {
+	return m.Size()
+}
+func (m *QueryHints) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryHints.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_QueryHints proto.InternalMessageInfo
+
+type Func struct {
+	// name is the name of the function, e.g. max_over_time.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (m *Func) Reset()         { *m = Func{} }
+func (m *Func) String() string { return proto.CompactTextString(m) }
+func (*Func) ProtoMessage()    {}
+func (*Func) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a938d55a388af629, []int{6}
+}
+func (m *Func) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Func) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Func.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Func) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Func.Merge(m, src)
+}
+func (m *Func) XXX_Size() int {
+	return m.Size()
+}
+func (m *Func) XXX_DiscardUnknown() {
+	xxx_messageInfo_Func.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Func proto.InternalMessageInfo
+
+type Range struct {
+	Millis int64 `protobuf:"varint,1,opt,name=millis,proto3" json:"millis,omitempty"`
+}
+
+func (m *Range) Reset()         { *m = Range{} }
+func (m *Range) String() string { return proto.CompactTextString(m) }
+func (*Range) ProtoMessage()    {}
+func (*Range) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a938d55a388af629, []int{7}
+}
+func (m *Range) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Range) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Range.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Range) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Range.Merge(m, src)
+}
+func (m *Range) XXX_Size() int {
+	return m.Size()
+}
+func (m *Range) XXX_DiscardUnknown() {
+	xxx_messageInfo_Range.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Range proto.InternalMessageInfo
+
 type SeriesResponse struct {
 	// Types that are valid to be assigned to Result:
 	//	*SeriesResponse_Series
@@ -327,7 +453,7 @@ func (m *SeriesResponse) Reset()         { *m = SeriesResponse{} }
 func (m *SeriesResponse) String() string { return proto.CompactTextString(m) }
 func (*SeriesResponse) ProtoMessage()    {}
 func (*SeriesResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{5}
+	return fileDescriptor_a938d55a388af629, []int{8}
 }
 func (m *SeriesResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -429,7 +555,7 @@ func (m *LabelNamesRequest) Reset()         { *m = LabelNamesRequest{} }
 func (m *LabelNamesRequest) String() string { return proto.CompactTextString(m) }
 func (*LabelNamesRequest) ProtoMessage()    {}
 func (*LabelNamesRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{6}
+	return fileDescriptor_a938d55a388af629, []int{9}
 }
 func (m *LabelNamesRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -471,7 +597,7 @@ func (m *LabelNamesResponse) Reset()         { *m = LabelNamesResponse{} }
 func (m *LabelNamesResponse) String() string { return proto.CompactTextString(m) }
 func (*LabelNamesResponse) ProtoMessage()    {}
 func (*LabelNamesResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{7}
+	return fileDescriptor_a938d55a388af629, []int{10}
 }
 func (m *LabelNamesResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -518,7 +644,7 @@ func (m *LabelValuesRequest) Reset()         { *m = LabelValuesRequest{} }
 func (m *LabelValuesRequest) String() string { return proto.CompactTextString(m) }
 func (*LabelValuesRequest) ProtoMessage()    {}
 func (*LabelValuesRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{8}
+	return fileDescriptor_a938d55a388af629, []int{11}
 }
 func (m *LabelValuesRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -560,7 +686,7 @@ func (m *LabelValuesResponse) Reset()         { *m = LabelValuesResponse{} }
 func (m *LabelValuesResponse) String() string { return proto.CompactTextString(m) }
 func (*LabelValuesResponse) ProtoMessage()    {}
 func (*LabelValuesResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{9}
+	return fileDescriptor_a938d55a388af629, []int{12}
 }
 func (m *LabelValuesResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -597,6 +723,9 @@ func init() {
 	proto.RegisterType((*InfoRequest)(nil), "thanos.InfoRequest")
 	proto.RegisterType((*InfoResponse)(nil), "thanos.InfoResponse")
 	proto.RegisterType((*SeriesRequest)(nil), "thanos.SeriesRequest")
+	proto.RegisterType((*QueryHints)(nil), "thanos.QueryHints")
+	proto.RegisterType((*Func)(nil), "thanos.Func")
+	proto.RegisterType((*Range)(nil), "thanos.Range")
 	proto.RegisterType((*SeriesResponse)(nil), "thanos.SeriesResponse")
 	proto.RegisterType((*LabelNamesRequest)(nil), "thanos.LabelNamesRequest")
 	proto.RegisterType((*LabelNamesResponse)(nil), "thanos.LabelNamesResponse")
@@ -607,73 +736,83 @@ func init() {
 func init() { proto.RegisterFile("store/storepb/rpc.proto", fileDescriptor_a938d55a388af629) }
 
 var fileDescriptor_a938d55a388af629 = []byte{
-	// 1054 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0x5d, 0x6f, 0x1b, 0x45,
-	0x17, 0xf6, 0x7a, 0xfd, 0x79, 0x9c, 0xe4, 0xdd, 0x4e, 0x9c, 0x74, 0xe3, 0x4a, 0x8e, 0x65, 0xe9,
-	0x95, 0xac, 0xa8, 0xd8, 0x60, 0x50, 0x25, 0x50, 0x6f, 0xec, 0xc4, 0x90, 0x88, 0xc6, 0x81, 0x71,
-	0xdc, 0x40, 0x11, 0xb2, 0xd6, 0xce, 0x74, 0xbd, 0x8a, 0xf7, 0x83, 0x9d, 0x31, 0x89, 0xef, 0x10,
-	0xdc, 0x23, 0xc4, 0x7f, 0x42, 0xca, 0x1d, 0xbd, 0x44, 0x5c, 0x54, 0x90, 0xfc, 0x11, 0x34, 0x1f,
-	0x6b, 0x7b, 0x43, 0xda, 0x20, 0xa5, 0x37, 0xd6, 0x9c, 0xf3, 0x9c, 0x33, 0xe7, 0x99, 0xf3, 0xcc,
-	0x19, 0x2f, 0x3c, 0xa4, 0xcc, 0x0f, 0x49, 0x43, 0xfc, 0x06, 0xc3, 0x46, 0x18, 0x8c, 0xea, 0x41,
-	0xe8, 0x33, 0x1f, 0x65, 0xd8, 0xd8, 0xf2, 0x7c, 0x5a, 0xda, 0x8a, 0x07, 0xb0, 0x59, 0x40, 0xa8,
-	0x0c, 0x29, 0x15, 0x6d, 0xdf, 0xf6, 0xc5, 0xb2, 0xc1, 0x57, 0xca, 0x5b, 0x89, 0x27, 0x04, 0xa1,
-	0xef, 0xde, 0xc8, 0x53, 0x5b, 0x4e, 0xac, 0x21, 0x99, 0xdc, 0x84, 0x6c, 0xdf, 0xb7, 0x27, 0xa4,
-	0x21, 0xac, 0xe1, 0xf4, 0x65, 0xc3, 0xf2, 0x66, 0x12, 0xaa, 0xfe, 0x0f, 0x56, 0x4f, 0x42, 0x87,
-	0x11, 0x4c, 0x68, 0xe0, 0x7b, 0x94, 0x54, 0x7f, 0xd2, 0x60, 0x45, 0x79, 0xbe, 0x9b, 0x12, 0xca,
-	0x50, 0x0b, 0x80, 0x39, 0x2e, 0xa1, 0x24, 0x74, 0x08, 0x35, 0xb5, 0x8a, 0x5e, 0x2b, 0x34, 0x1f,
-	0xf1, 0x6c, 0x97, 0xb0, 0x31, 0x99, 0xd2, 0xc1, 0xc8, 0x0f, 0x66, 0xf5, 0x63, 0xc7, 0x25, 0x3d,
-	0x11, 0xd2, 0x4e, 0x5d, 0xbe, 0xde, 0x4e, 0xe0, 0xa5, 0x24, 0xb4, 0x09, 0x19, 0x46, 0x3c, 0xcb,
-	0x63, 0x66, 0xb2, 0xa2, 0xd5, 0xf2, 0x58, 0x59, 0xc8, 0x84, 0x6c, 0x48, 0x82, 0x89, 0x33, 0xb2,
-	0x4c, 0xbd, 0xa2, 0xd5, 0x74, 0x1c, 0x99, 0xd5, 0x55, 0x28, 0x1c, 0x78, 0x2f, 0x7d, 0xc5, 0xa1,
-	0xfa, 0x6b, 0x12, 0x56, 0xa4, 0x2d, 0x59, 0xa2, 0x11, 0x64, 0xc4, 0x41, 0x23, 0x42, 0xab, 0x75,
-	0xd9, 0xd8, 0xfa, 0x33, 0xee, 0x6d, 0x3f, 0xe5, 0x14, 0xfe, 0x7c, 0xbd, 0xfd, 0x91, 0xed, 0xb0,
-	0xf1, 0x74, 0x58, 0x1f, 0xf9, 0x6e, 0x43, 0x06, 0xbc, 0xe7, 0xf8, 0x6a, 0xd5, 0x08, 0xce, 0xec,
-	0x46, 0xac, 0x67, 0xf5, 0x17, 0x22, 0x1b, 0xab, 0xad, 0xd1, 0x16, 0xe4, 0x5c, 0xc7, 0x1b, 0xf0,
-	0x83, 0x08, 0xe2, 0x3a, 0xce, 0xba, 0x8e, 0xc7, 0x4f, 0x2a, 0x20, 0xeb, 0x42, 0x42, 0x8a, 0xba,
-	0x6b, 0x5d, 0x08, 0xa8, 0x01, 0x79, 0xb1, 0xeb, 0xf1, 0x2c, 0x20, 0x66, 0xaa, 0xa2, 0xd5, 0xd6,
-	0x9a, 0x0f, 0x22, 0x76, 0xbd, 0x08, 0xc0, 0x8b, 0x18, 0xf4, 0x04, 0x40, 0x14, 0x1c, 0x50, 0xc2,
-	0xa8, 0x99, 0x16, 0xe7, 0x99, 0x67, 0x48, 0x4a, 0x3d, 0xc2, 0x54, 0x5b, 0xf3, 0x13, 0x65, 0xd3,
-	0xea, 0x6f, 0x3a, 0xac, 0xca, 0x96, 0x47, 0x52, 0x2d, 0x13, 0xd6, 0xde, 0x4c, 0x38, 0x19, 0x27,
-	0xfc, 0x84, 0x43, 0x6c, 0x34, 0x26, 0x21, 0x35, 0x75, 0x51, 0xbd, 0x18, 0xeb, 0xe6, 0xa1, 0x04,
-	0x15, 0x81, 0x79, 0x2c, 0x6a, 0xc2, 0x06, 0xdf, 0x32, 0x24, 0xd4, 0x9f, 0x4c, 0x99, 0xe3, 0x7b,
-	0x83, 0x73, 0xc7, 0x3b, 0xf5, 0xcf, 0xc5, 0xa1, 0x75, 0xbc, 0xee, 0x5a, 0x17, 0x78, 0x8e, 0x9d,
-	0x08, 0x08, 0x3d, 0x06, 0xb0, 0x6c, 0x3b, 0x24, 0xb6, 0xc5, 0x88, 0x3c, 0xeb, 0x5a, 0x73, 0x25,
-	0xaa, 0xd6, 0xb2, 0xed, 0x10, 0x2f, 0xe1, 0xe8, 0x13, 0xd8, 0x0a, 0xac, 0x90, 0x39, 0xd6, 0x84,
-	0x57, 0x11, 0xca, 0x0f, 0x4e, 0x1d, 0x6a, 0x0d, 0x27, 0xe4, 0xd4, 0xcc, 0x54, 0xb4, 0x5a, 0x0e,
-	0x3f, 0x54, 0x01, 0xd1, 0xcd, 0xd8, 0x53, 0x30, 0xfa, 0xe6, 0x96, 0x5c, 0xca, 0x42, 0x8b, 0x11,
-	0x7b, 0x66, 0x66, 0x85, 0x2c, 0xdb, 0x51, 0xe1, 0x2f, 0xe2, 0x7b, 0xf4, 0x54, 0xd8, 0xbf, 0x36,
-	0x8f, 0x00, 0xb4, 0x0d, 0x05, 0x7a, 0xe6, 0x04, 0x83, 0xd1, 0x78, 0xea, 0x9d, 0x51, 0x33, 0x27,
-	0xa8, 0x00, 0x77, 0xed, 0x0a, 0x0f, 0xda, 0x81, 0xf4, 0xd8, 0xf1, 0x18, 0x35, 0xf3, 0x15, 0x4d,
-	0x34, 0x54, 0x4e, 0x60, 0x3d, 0x9a, 0xc0, 0x7a, 0xcb, 0x9b, 0x61, 0x19, 0x52, 0xfd, 0x59, 0x83,
-	0xb5, 0x48, 0x47, 0x75, 0xbd, 0x6b, 0x90, 0x99, 0xcf, 0x1b, 0xcf, 0x5f, 0x9b, 0x5f, 0x20, 0xe1,
-	0xdd, 0x4f, 0x60, 0x85, 0xa3, 0x12, 0x64, 0xcf, 0xad, 0xd0, 0x73, 0x3c, 0x5b, 0xce, 0xd6, 0x7e,
-	0x02, 0x47, 0x0e, 0xf4, 0x38, 0x22, 0xa1, 0xbf, 0x99, 0xc4, 0x7e, 0x42, 0xd1, 0x68, 0xe7, 0x20,
-	0x13, 0x12, 0x3a, 0x9d, 0xb0, 0xea, 0x0f, 0x49, 0x78, 0x20, 0x94, 0xef, 0x5a, 0xee, 0xe2, 0x72,
-	0xbd, 0x55, 0x0c, 0xed, 0x1e, 0x62, 0x24, 0xef, 0x29, 0x46, 0x11, 0xd2, 0x94, 0x59, 0x21, 0x53,
-	0x83, 0x28, 0x0d, 0x64, 0x80, 0x4e, 0xbc, 0x53, 0x75, 0x17, 0xf9, 0x72, 0xa1, 0x49, 0xfa, 0x6e,
-	0x4d, 0x42, 0x40, 0xcb, 0x1d, 0x50, 0xb2, 0x14, 0x21, 0xed, 0x71, 0x87, 0x78, 0x74, 0xf2, 0x58,
-	0x1a, 0xa8, 0x04, 0x39, 0xd5, 0x71, 0x6a, 0x26, 0x05, 0x30, 0xb7, 0x17, 0x35, 0xf5, 0xbb, 0x6b,
-	0xfe, 0x9e, 0x54, 0x45, 0x9f, 0x5b, 0x93, 0xe9, 0xa2, 0xef, 0x45, 0x48, 0x8b, 0x99, 0x17, 0x3d,
-	0xce, 0x63, 0x69, 0xbc, 0x5d, 0x8d, 0xe4, 0x3d, 0xd4, 0xd0, 0xdf, 0x95, 0x1a, 0xa9, 0x5b, 0xd4,
-	0x48, 0xdf, 0xa2, 0x46, 0xe6, 0xce, 0xce, 0xc4, 0x5e, 0xa8, 0xec, 0x7f, 0x7f, 0xa1, 0xaa, 0x53,
-	0x58, 0x8f, 0x35, 0x54, 0xc9, 0xb8, 0x09, 0x99, 0xef, 0x85, 0x47, 0xe9, 0xa8, 0xac, 0x77, 0x25,
-	0xe4, 0xce, 0xb7, 0x90, 0x9f, 0x3f, 0xf4, 0xa8, 0x00, 0xd9, 0x7e, 0xf7, 0xf3, 0xee, 0xd1, 0x49,
-	0xd7, 0x48, 0xa0, 0x3c, 0xa4, 0xbf, 0xec, 0x77, 0xf0, 0xd7, 0x86, 0x86, 0x72, 0x90, 0xc2, 0xfd,
-	0x67, 0x1d, 0x23, 0xc9, 0x23, 0x7a, 0x07, 0x7b, 0x9d, 0xdd, 0x16, 0x36, 0x74, 0x1e, 0xd1, 0x3b,
-	0x3e, 0xc2, 0x1d, 0x23, 0xc5, 0xfd, 0xb8, 0xb3, 0xdb, 0x39, 0x78, 0xde, 0x31, 0xd2, 0xdc, 0xbf,
-	0xd7, 0x69, 0xf7, 0x3f, 0x33, 0x32, 0x3b, 0x6d, 0x48, 0xf1, 0x97, 0x12, 0x65, 0x41, 0xc7, 0xad,
-	0x13, 0xb9, 0xeb, 0xee, 0x51, 0xbf, 0x7b, 0x6c, 0x68, 0xdc, 0xd7, 0xeb, 0x1f, 0x1a, 0x49, 0xbe,
-	0x38, 0x3c, 0xe8, 0x1a, 0xba, 0x58, 0xb4, 0xbe, 0x92, 0xdb, 0x89, 0xa8, 0x0e, 0x36, 0xd2, 0xcd,
-	0x1f, 0x93, 0x90, 0x16, 0x1c, 0xd1, 0x07, 0x90, 0xe2, 0xff, 0xac, 0x68, 0x3d, 0xea, 0xe8, 0xd2,
-	0xff, 0x6e, 0xa9, 0x18, 0x77, 0xaa, 0xfe, 0x7d, 0x0c, 0x19, 0xf9, 0x0e, 0xa1, 0x8d, 0xf8, 0xbb,
-	0x14, 0xa5, 0x6d, 0xde, 0x74, 0xcb, 0xc4, 0xf7, 0x35, 0xb4, 0x0b, 0xb0, 0x98, 0x2b, 0xb4, 0x15,
-	0x53, 0x71, 0xf9, 0xb5, 0x29, 0x95, 0x6e, 0x83, 0x54, 0xfd, 0x4f, 0xa1, 0xb0, 0x24, 0x2b, 0x8a,
-	0x87, 0xc6, 0x86, 0xa7, 0xf4, 0xe8, 0x56, 0x4c, 0xee, 0xd3, 0xec, 0xc2, 0x9a, 0xf8, 0xd2, 0xe1,
-	0x53, 0x21, 0x9b, 0xf1, 0x14, 0x0a, 0x98, 0xb8, 0x3e, 0x23, 0xc2, 0x8f, 0xe6, 0xc7, 0x5f, 0xfe,
-	0x20, 0x2a, 0x6d, 0xdc, 0xf0, 0xaa, 0x0f, 0xa7, 0x44, 0xfb, 0xff, 0x97, 0x7f, 0x97, 0x13, 0x97,
-	0x57, 0x65, 0xed, 0xd5, 0x55, 0x59, 0xfb, 0xeb, 0xaa, 0xac, 0xfd, 0x72, 0x5d, 0x4e, 0xbc, 0xba,
-	0x2e, 0x27, 0xfe, 0xb8, 0x2e, 0x27, 0x5e, 0x64, 0xd5, 0xb7, 0xdb, 0x30, 0x23, 0xee, 0xcc, 0x87,
-	0xff, 0x04, 0x00, 0x00, 0xff, 0xff, 0xfd, 0xe5, 0x37, 0xcd, 0x25, 0x0a, 0x00, 0x00,
+	// 1204 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0xcd, 0x6f, 0x13, 0x47,
+	0x14, 0xf7, 0x7a, 0xbd, 0xeb, 0xf8, 0x99, 0xa4, 0xcb, 0xc4, 0xc0, 0xc6, 0x48, 0x8e, 0xb5, 0x55,
+	0x25, 0x0b, 0x51, 0x9b, 0x86, 0x0a, 0xa9, 0x15, 0x97, 0x24, 0x98, 0x26, 0x2a, 0x31, 0x30, 0x4e,
+	0x48, 0x4b, 0x55, 0x59, 0x1b, 0x67, 0x58, 0xaf, 0xd8, 0x2f, 0x76, 0x66, 0x1b, 0x7c, 0xab, 0xda,
+	0x7b, 0xd5, 0x3f, 0x8b, 0x5b, 0x39, 0x56, 0x3d, 0xa0, 0x16, 0xfe, 0x86, 0x5e, 0x7a, 0xaa, 0xe6,
+	0x63, 0x6d, 0x6f, 0x1a, 0xa0, 0x52, 0xb8, 0x58, 0xf3, 0xde, 0xef, 0xbd, 0x37, 0x6f, 0x7e, 0xef,
+	0xc3, 0x0b, 0x57, 0x28, 0x8b, 0x53, 0xd2, 0x13, 0xbf, 0xc9, 0x51, 0x2f, 0x4d, 0xc6, 0xdd, 0x24,
+	0x8d, 0x59, 0x8c, 0x4c, 0x36, 0x71, 0xa3, 0x98, 0x36, 0xd7, 0x8a, 0x06, 0x6c, 0x9a, 0x10, 0x2a,
+	0x4d, 0x9a, 0x0d, 0x2f, 0xf6, 0x62, 0x71, 0xec, 0xf1, 0x93, 0xd2, 0xb6, 0x8b, 0x0e, 0x49, 0x1a,
+	0x87, 0xa7, 0xfc, 0x54, 0xc8, 0xc0, 0x3d, 0x22, 0xc1, 0x69, 0xc8, 0x8b, 0x63, 0x2f, 0x20, 0x3d,
+	0x21, 0x1d, 0x65, 0x4f, 0x7a, 0x6e, 0x34, 0x95, 0x90, 0xf3, 0x11, 0x2c, 0x1f, 0xa6, 0x3e, 0x23,
+	0x98, 0xd0, 0x24, 0x8e, 0x28, 0x71, 0x7e, 0xd6, 0xe0, 0x82, 0xd2, 0x3c, 0xcb, 0x08, 0x65, 0x68,
+	0x13, 0x80, 0xf9, 0x21, 0xa1, 0x24, 0xf5, 0x09, 0xb5, 0xb5, 0xb6, 0xde, 0xa9, 0x6f, 0x5c, 0xe5,
+	0xde, 0x21, 0x61, 0x13, 0x92, 0xd1, 0xd1, 0x38, 0x4e, 0xa6, 0xdd, 0x7d, 0x3f, 0x24, 0x43, 0x61,
+	0xb2, 0x55, 0x79, 0xf1, 0x6a, 0xbd, 0x84, 0x17, 0x9c, 0xd0, 0x65, 0x30, 0x19, 0x89, 0xdc, 0x88,
+	0xd9, 0xe5, 0xb6, 0xd6, 0xa9, 0x61, 0x25, 0x21, 0x1b, 0xaa, 0x29, 0x49, 0x02, 0x7f, 0xec, 0xda,
+	0x7a, 0x5b, 0xeb, 0xe8, 0x38, 0x17, 0x9d, 0x65, 0xa8, 0xef, 0x46, 0x4f, 0x62, 0x95, 0x83, 0xf3,
+	0x77, 0x19, 0x2e, 0x48, 0x59, 0x66, 0x89, 0xc6, 0x60, 0x8a, 0x87, 0xe6, 0x09, 0x2d, 0x77, 0x25,
+	0xb1, 0xdd, 0x7b, 0x5c, 0xbb, 0x75, 0x9b, 0xa7, 0xf0, 0xc7, 0xab, 0xf5, 0xcf, 0x3d, 0x9f, 0x4d,
+	0xb2, 0xa3, 0xee, 0x38, 0x0e, 0x7b, 0xd2, 0xe0, 0x53, 0x3f, 0x56, 0xa7, 0x5e, 0xf2, 0xd4, 0xeb,
+	0x15, 0x38, 0xeb, 0x3e, 0x16, 0xde, 0x58, 0x85, 0x46, 0x6b, 0xb0, 0x14, 0xfa, 0xd1, 0x88, 0x3f,
+	0x44, 0x24, 0xae, 0xe3, 0x6a, 0xe8, 0x47, 0xfc, 0xa5, 0x02, 0x72, 0x9f, 0x4b, 0x48, 0xa5, 0x1e,
+	0xba, 0xcf, 0x05, 0xd4, 0x83, 0x9a, 0x88, 0xba, 0x3f, 0x4d, 0x88, 0x5d, 0x69, 0x6b, 0x9d, 0x95,
+	0x8d, 0x8b, 0x79, 0x76, 0xc3, 0x1c, 0xc0, 0x73, 0x1b, 0x74, 0x0b, 0x40, 0x5c, 0x38, 0xa2, 0x84,
+	0x51, 0xdb, 0x10, 0xef, 0x99, 0x79, 0xc8, 0x94, 0x86, 0x84, 0x29, 0x5a, 0x6b, 0x81, 0x92, 0x29,
+	0xba, 0x01, 0x8d, 0x67, 0x19, 0x49, 0xa7, 0xa3, 0x24, 0xa3, 0x93, 0xe3, 0xf8, 0x24, 0x1a, 0x3d,
+	0xc9, 0xa2, 0x31, 0xb5, 0xcd, 0xb6, 0xde, 0xa9, 0x61, 0x24, 0xb0, 0x07, 0x0a, 0xba, 0xcb, 0x11,
+	0xd4, 0x83, 0x55, 0x59, 0x91, 0xd1, 0x38, 0x0e, 0x93, 0x94, 0x50, 0xea, 0xc7, 0x11, 0xb5, 0xab,
+	0xd2, 0x41, 0x42, 0xdb, 0x0b, 0x88, 0xf3, 0x8f, 0x0e, 0xcb, 0xb2, 0xaa, 0x79, 0x37, 0x2c, 0x72,
+	0xa2, 0xbd, 0x9d, 0x93, 0x72, 0x91, 0x93, 0x5b, 0x1c, 0x62, 0xe3, 0x09, 0x49, 0xa9, 0xad, 0x8b,
+	0x07, 0x36, 0x0a, 0x05, 0xdb, 0x93, 0xa0, 0x7a, 0xe3, 0xcc, 0x16, 0x6d, 0xc0, 0x25, 0x1e, 0x32,
+	0x25, 0x34, 0x0e, 0x32, 0xe6, 0xc7, 0xd1, 0xe8, 0xc4, 0x8f, 0x8e, 0xe3, 0x13, 0xc1, 0xab, 0x8e,
+	0x57, 0x43, 0xf7, 0x39, 0x9e, 0x61, 0x87, 0x02, 0x42, 0xd7, 0x01, 0x5c, 0xcf, 0x4b, 0x89, 0xe7,
+	0x32, 0x22, 0xe9, 0x5c, 0xd9, 0xb8, 0x90, 0xdf, 0xb6, 0xe9, 0x79, 0x29, 0x5e, 0xc0, 0xd1, 0x97,
+	0xb0, 0x96, 0xb8, 0x29, 0xf3, 0xdd, 0x60, 0x94, 0xaa, 0xe6, 0x1a, 0x1d, 0xfb, 0xd4, 0x3d, 0x0a,
+	0xc8, 0xb1, 0x6d, 0xb6, 0xb5, 0xce, 0x12, 0xbe, 0xa2, 0x0c, 0xf2, 0xe6, 0xbb, 0xa3, 0x60, 0xf4,
+	0xdd, 0x19, 0xbe, 0x94, 0xa5, 0x2e, 0x23, 0xde, 0xd4, 0xae, 0x8a, 0xca, 0xaf, 0xe7, 0x17, 0x3f,
+	0x28, 0xc6, 0x18, 0x2a, 0xb3, 0xff, 0x04, 0xcf, 0x01, 0xb4, 0x0e, 0x75, 0xfa, 0xd4, 0x4f, 0x46,
+	0xe3, 0x49, 0x16, 0x3d, 0xa5, 0xf6, 0x92, 0x48, 0x05, 0xb8, 0x6a, 0x5b, 0x68, 0xd0, 0x35, 0x30,
+	0x26, 0x7e, 0xc4, 0xa8, 0x5d, 0x6b, 0x6b, 0x82, 0x50, 0x39, 0xe4, 0xdd, 0x7c, 0xc8, 0xbb, 0x9b,
+	0xd1, 0x14, 0x4b, 0x13, 0x74, 0x13, 0xea, 0xb2, 0x55, 0xa4, 0x07, 0x08, 0x0f, 0x94, 0xe7, 0xf6,
+	0x90, 0x43, 0x3b, 0x1c, 0xc1, 0xf0, 0x6c, 0x76, 0x76, 0x18, 0xc0, 0x1c, 0x11, 0xf9, 0x30, 0x92,
+	0x8c, 0x42, 0x3f, 0x08, 0x7c, 0xaa, 0x6a, 0x0f, 0x5c, 0xb5, 0x27, 0x34, 0xa8, 0x0d, 0x15, 0xde,
+	0x7f, 0xa2, 0xf4, 0xf5, 0x39, 0xe3, 0xbc, 0xf3, 0xb0, 0x40, 0xd0, 0xc7, 0x60, 0xa4, 0x6e, 0xe4,
+	0xc9, 0x89, 0x59, 0x98, 0x59, 0xcc, 0x95, 0x58, 0x62, 0x4e, 0x13, 0x2a, 0xdc, 0x05, 0x21, 0xa8,
+	0x44, 0xae, 0x6a, 0xb2, 0x1a, 0x16, 0x67, 0x67, 0x1d, 0x0c, 0x61, 0xcb, 0x17, 0x4a, 0x21, 0x0f,
+	0x25, 0x39, 0xbf, 0x68, 0xb0, 0x92, 0xf7, 0xab, 0xda, 0x14, 0x1d, 0x30, 0x67, 0xab, 0x8b, 0xdf,
+	0xba, 0x32, 0x9b, 0x45, 0xa1, 0xdd, 0x29, 0x61, 0x85, 0xa3, 0x26, 0x54, 0x4f, 0xdc, 0x34, 0xf2,
+	0x23, 0x4f, 0xae, 0xa9, 0x9d, 0x12, 0xce, 0x15, 0xe8, 0x7a, 0x4e, 0xb6, 0xfe, 0x76, 0xb2, 0x77,
+	0x4a, 0x8a, 0xee, 0xad, 0x25, 0x30, 0x53, 0x42, 0xb3, 0x80, 0x39, 0x3f, 0x96, 0xe1, 0xa2, 0xe8,
+	0xf0, 0x81, 0x1b, 0xce, 0x87, 0xe8, 0x9d, 0x4d, 0xa7, 0x9d, 0xa3, 0xe9, 0xca, 0xe7, 0x6c, 0xba,
+	0x06, 0x18, 0x94, 0xb9, 0x29, 0x53, 0x3b, 0x4d, 0x0a, 0xc8, 0x02, 0x9d, 0x44, 0xc7, 0x6a, 0xe6,
+	0xf8, 0x71, 0xde, 0x7b, 0xc6, 0x7b, 0x7b, 0xcf, 0x49, 0x01, 0x2d, 0x32, 0xa0, 0xca, 0xd2, 0x00,
+	0x83, 0x97, 0x54, 0xee, 0xef, 0x1a, 0x96, 0x02, 0x6a, 0xc2, 0x92, 0x62, 0x9c, 0xda, 0x65, 0x01,
+	0xcc, 0xe4, 0xf9, 0x9d, 0xfa, 0xfb, 0xef, 0xfc, 0xad, 0xac, 0x2e, 0x7d, 0xe4, 0x06, 0xd9, 0x9c,
+	0xf7, 0x06, 0x18, 0x62, 0x7d, 0xaa, 0xa6, 0x92, 0xc2, 0xbb, 0xab, 0x51, 0x3e, 0x47, 0x35, 0xf4,
+	0x0f, 0x55, 0x8d, 0xca, 0x19, 0xd5, 0x30, 0xce, 0xa8, 0x86, 0xf9, 0xfe, 0x4d, 0xb0, 0xb8, 0x89,
+	0xab, 0xff, 0x7f, 0x13, 0x3b, 0x19, 0xac, 0x16, 0x08, 0x55, 0x65, 0xbc, 0x0c, 0xe6, 0x0f, 0x42,
+	0xa3, 0xea, 0xa8, 0xa4, 0x0f, 0x55, 0xc8, 0x6b, 0xdf, 0x43, 0x6d, 0xf6, 0x9f, 0x89, 0xea, 0x50,
+	0x3d, 0x18, 0x7c, 0x3d, 0xb8, 0x7f, 0x38, 0xb0, 0x4a, 0xa8, 0x06, 0xc6, 0xc3, 0x83, 0x3e, 0xfe,
+	0xd6, 0xd2, 0xd0, 0x12, 0x54, 0xf0, 0xc1, 0xbd, 0xbe, 0x55, 0xe6, 0x16, 0xc3, 0xdd, 0x3b, 0xfd,
+	0xed, 0x4d, 0x6c, 0xe9, 0xdc, 0x62, 0xb8, 0x7f, 0x1f, 0xf7, 0xad, 0x0a, 0xd7, 0xe3, 0xfe, 0x76,
+	0x7f, 0xf7, 0x51, 0xdf, 0x32, 0xb8, 0xfe, 0x4e, 0x7f, 0xeb, 0xe0, 0x2b, 0xcb, 0xbc, 0xb6, 0x05,
+	0x15, 0xfe, 0x8f, 0x80, 0xaa, 0xa0, 0xe3, 0xcd, 0x43, 0x19, 0x75, 0xfb, 0xfe, 0xc1, 0x60, 0xdf,
+	0xd2, 0xb8, 0x6e, 0x78, 0xb0, 0x67, 0x95, 0xf9, 0x61, 0x6f, 0x77, 0x60, 0xe9, 0xe2, 0xb0, 0xf9,
+	0x8d, 0x0c, 0x27, 0xac, 0xfa, 0xd8, 0x32, 0x36, 0x7e, 0x2a, 0x83, 0x21, 0x72, 0x44, 0x9f, 0x41,
+	0x85, 0x7f, 0xa4, 0xa0, 0xd5, 0x9c, 0xd1, 0x85, 0x4f, 0x98, 0x66, 0xa3, 0xa8, 0x54, 0xfc, 0x7d,
+	0x01, 0xa6, 0xdc, 0x43, 0xe8, 0x52, 0x71, 0x2f, 0xe5, 0x6e, 0x97, 0x4f, 0xab, 0xa5, 0xe3, 0x0d,
+	0x0d, 0x6d, 0x03, 0xcc, 0xe7, 0x0a, 0xad, 0x15, 0xaa, 0xb8, 0xb8, 0x6d, 0x9a, 0xcd, 0xb3, 0x20,
+	0x75, 0xff, 0x5d, 0xa8, 0x2f, 0x94, 0x15, 0x15, 0x4d, 0x0b, 0xc3, 0xd3, 0xbc, 0x7a, 0x26, 0x26,
+	0xe3, 0x6c, 0x0c, 0x60, 0x45, 0x7c, 0x34, 0xf2, 0xa9, 0x90, 0x64, 0xdc, 0x86, 0x3a, 0x26, 0x61,
+	0xcc, 0x88, 0xd0, 0xa3, 0xd9, 0xf3, 0x17, 0xbf, 0x2d, 0x9b, 0x97, 0x4e, 0x69, 0xd5, 0x37, 0x68,
+	0x69, 0xeb, 0x93, 0x17, 0x7f, 0xb5, 0x4a, 0x2f, 0x5e, 0xb7, 0xb4, 0x97, 0xaf, 0x5b, 0xda, 0x9f,
+	0xaf, 0x5b, 0xda, 0xaf, 0x6f, 0x5a, 0xa5, 0x97, 0x6f, 0x5a, 0xa5, 0xdf, 0xdf, 0xb4, 0x4a, 0x8f,
+	0xab, 0xea, 0x33, 0xf8, 0xc8, 0x14, 0x3d, 0x73, 0xf3, 0xdf, 0x01, 0x00, 0x3b, 0x47, 0xb5, 0x77,
+	0x70, 0x0b, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -1109,6 +1248,24 @@ func (m *InfoResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.SeriesCompressions) > 0 {
+		for iNdEx := len(m.SeriesCompressions) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.SeriesCompressions[iNdEx])
+			copy(dAtA[i:], m.SeriesCompressions[iNdEx])
+			i = encodeVarintRpc(dAtA, i, uint64(len(m.SeriesCompressions[iNdEx])))
+			i--
+			dAtA[i] = 0x3a
+		}
+	}
+	if len(m.QueryPushdownFuncs) > 0 {
+		for iNdEx := len(m.QueryPushdownFuncs) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.QueryPushdownFuncs[iNdEx])
+			copy(dAtA[i:], m.QueryPushdownFuncs[iNdEx])
+			i = encodeVarintRpc(dAtA, i, uint64(len(m.QueryPushdownFuncs[iNdEx])))
+			i--
+			dAtA[i] = 0x32
+		}
+	}
 	if len(m.LabelSets) > 0 {
 		for iNdEx := len(m.LabelSets) - 1; iNdEx >= 0; iNdEx-- {
 			{
@@ -1175,6 +1332,18 @@ func (m *SeriesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.QueryHints != nil {
+		{
+			size, err := m.QueryHints.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintRpc(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x52
+	}
 	if m.Hints != nil {
 		{
 			size, err := m.Hints.MarshalToSizedBuffer(dAtA[:i])
@@ -1213,20 +1382,20 @@ func (m *SeriesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		dAtA[i] = 0x30
 	}
 	if len(m.Aggregates) > 0 {
-		dAtA3 := make([]byte, len(m.Aggregates)*10)
-		var j2 int
+		dAtA4 := make([]byte, len(m.Aggregates)*10)
+		var j3 int
 		for _, num := range m.Aggregates {
 			for num >= 1<<7 {
-				dAtA3[j2] = uint8(uint64(num)&0x7f | 0x80)
+				dAtA4[j3] = uint8(uint64(num)&0x7f | 0x80)
 				num >>= 7
-				j2++
+				j3++
 			}
-			dAtA3[j2] = uint8(num)
-			j2++
+			dAtA4[j3] = uint8(num)
+			j3++
 		}
-		i -= j2
-		copy(dAtA[i:], dAtA3[:j2])
-		i = encodeVarintRpc(dAtA, i, uint64(j2))
+		i -= j3
+		copy(dAtA[i:], dAtA4[:j3])
+		i = encodeVarintRpc(dAtA, i, uint64(j3))
 		i--
 		dAtA[i] = 0x2a
 	}
@@ -1262,6 +1431,116 @@ func (m *SeriesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *QueryHints) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryHints) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryHints) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Range != nil {
+		{
+			size, err := m.Range.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintRpc(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.Func != nil {
+		{
+			size, err := m.Func.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintRpc(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.StepMillis != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.StepMillis))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *Func) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Func) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Func) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Name) > 0 {
+		i -= len(m.Name)
+		copy(dAtA[i:], m.Name)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.Name)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *Range) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Range) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Range) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Millis != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.Millis))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
 func (m *SeriesResponse) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -1675,6 +1954,18 @@ func (m *InfoResponse) Size() (n int) {
 			n += 1 + l + sovRpc(uint64(l))
 		}
 	}
+	if len(m.QueryPushdownFuncs) > 0 {
+		for _, s := range m.QueryPushdownFuncs {
+			l = len(s)
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
+	if len(m.SeriesCompressions) > 0 {
+		for _, s := range m.SeriesCompressions {
+			l = len(s)
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -1719,6 +2010,55 @@ func (m *SeriesRequest) Size() (n int) {
 		l = m.Hints.Size()
 		n += 1 + l + sovRpc(uint64(l))
 	}
+	if m.QueryHints != nil {
+		l = m.QueryHints.Size()
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	return n
+}
+
+func (m *QueryHints) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.StepMillis != 0 {
+		n += 1 + sovRpc(uint64(m.StepMillis))
+	}
+	if m.Func != nil {
+		l = m.Func.Size()
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	if m.Range != nil {
+		l = m.Range.Size()
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	return n
+}
+
+func (m *Func) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	return n
+}
+
+func (m *Range) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Millis != 0 {
+		n += 1 + sovRpc(uint64(m.Millis))
+	}
 	return n
 }
 
@@ -2273,22 +2613,86 @@ func (m *InfoResponse) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRpc(dAtA[iNdEx:])
-			if err != nil {
-				return err
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryPushdownFuncs", wireType)
 			}
-			if (skippy < 0) || (iNdEx+skippy) < 0 {
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthRpc
 			}
-			if (iNdEx + skippy) > l {
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			iNdEx += skippy
-		}
-	}
-
+			m.QueryPushdownFuncs = append(m.QueryPushdownFuncs, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SeriesCompressions", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SeriesCompressions = append(m.SeriesCompressions, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
 	if iNdEx > l {
 		return io.ErrUnexpectedEOF
 	}
@@ -2578,6 +2982,334 @@ func (m *SeriesRequest) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryHints", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.QueryHints == nil {
+				m.QueryHints = &QueryHints{}
+			}
+			if err := m.QueryHints.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *QueryHints) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: QueryHints: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: QueryHints: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StepMillis", wireType)
+			}
+			m.StepMillis = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StepMillis |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Func", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Func == nil {
+				m.Func = &Func{}
+			}
+			if err := m.Func.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Range", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Range == nil {
+				m.Range = &Range{}
+			}
+			if err := m.Range.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Func) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Func: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Func: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Range) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Range: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Range: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Millis", wireType)
+			}
+			m.Millis = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Millis |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
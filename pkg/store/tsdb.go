@@ -12,6 +12,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
 	"github.com/thanos-io/thanos/pkg/store/labelpb"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -20,6 +21,8 @@ import (
 	"github.com/thanos-io/thanos/pkg/component"
 	"github.com/thanos-io/thanos/pkg/runutil"
 	"github.com/thanos-io/thanos/pkg/store/storepb"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus/tsdbstatuspb"
 )
 
 const RemoteReadFrameLimit = 1048576
@@ -75,10 +78,11 @@ func (s *TSDBStore) Info(_ context.Context, _ *storepb.InfoRequest) (*storepb.In
 	}
 
 	res := &storepb.InfoResponse{
-		Labels:    labelpb.ZLabelsFromPromLabels(s.extLset),
-		StoreType: s.component.ToProto(),
-		MinTime:   minTime,
-		MaxTime:   math.MaxInt64,
+		Labels:             labelpb.ZLabelsFromPromLabels(s.extLset),
+		StoreType:          s.component.ToProto(),
+		MinTime:            minTime,
+		MaxTime:            math.MaxInt64,
+		SeriesCompressions: SeriesCompressions,
 	}
 
 	// Until we deprecate the single labels in the reply, we just duplicate
@@ -231,3 +235,26 @@ func (s *TSDBStore) LabelValues(ctx context.Context, r *storepb.LabelValuesReque
 	}
 	return &storepb.LabelValuesResponse{Values: res}, nil
 }
+
+// TSDBStatus returns cardinality statistics of the head block, if it overlaps with the requested time range.
+func (s *TSDBStore) TSDBStatus(_ context.Context, r *tsdbstatuspb.TSDBStatusRequest) (*tsdbstatuspb.TSDBStatusResponse, error) {
+	db, ok := s.db.(interface{ Head() *tsdb.Head })
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "TSDB status is not supported by the underlying TSDB")
+	}
+
+	b := tsdbstatus.NewStatisticsBuilder()
+	head := db.Head()
+	if head.MinTime() <= r.MaxTime && r.MinTime <= head.MaxTime() {
+		ir, err := head.Index()
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		defer runutil.CloseWithLogOnErr(s.logger, ir, "close head index reader")
+
+		if err := b.AddIndex(ir); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	return &tsdbstatuspb.TSDBStatusResponse{Statistics: b.Build(tsdbstatus.RequestLimit(r))}, nil
+}
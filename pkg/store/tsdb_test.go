@@ -25,6 +25,7 @@ import (
 	storetestutil "github.com/thanos-io/thanos/pkg/store/storepb/testutil"
 	"github.com/thanos-io/thanos/pkg/testutil"
 	"github.com/thanos-io/thanos/pkg/testutil/e2eutil"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus/tsdbstatuspb"
 )
 
 func TestTSDBStore_Info(t *testing.T) {
@@ -422,6 +423,43 @@ func TestTSDBStore_LabelValues(t *testing.T) {
 	}
 }
 
+func TestTSDBStore_TSDBStatus(t *testing.T) {
+	defer testutil.TolerantVerifyLeak(t)
+
+	db, err := e2eutil.NewTSDB()
+	defer func() { testutil.Ok(t, db.Close()) }()
+	testutil.Ok(t, err)
+
+	app := db.Appender(context.Background())
+	for _, lset := range []labels.Labels{
+		labels.FromStrings(labels.MetricName, "up", "job", "a"),
+		labels.FromStrings(labels.MetricName, "up", "job", "b"),
+		labels.FromStrings(labels.MetricName, "scrape_duration_seconds", "job", "a"),
+	} {
+		_, err := app.Add(lset, 1000, 1)
+		testutil.Ok(t, err)
+	}
+	testutil.Ok(t, app.Commit())
+
+	tsdbStore := NewTSDBStore(nil, db, component.Rule, labels.FromStrings("region", "eu-west"))
+
+	resp, err := tsdbStore.TSDBStatus(context.Background(), &tsdbstatuspb.TSDBStatusRequest{MinTime: 0, MaxTime: 2000, Limit: 1})
+	testutil.Ok(t, err)
+	testutil.Equals(t, tsdbstatuspb.TSDBStatistics{
+		NumSeries:                   3,
+		SeriesCountByMetricName:     []tsdbstatuspb.Statistic{{Name: "up", Value: 2}},
+		LabelValueCountByLabelName:  []tsdbstatuspb.Statistic{{Name: "__name__", Value: 2}},
+		MemoryInBytesByLabelName:    []tsdbstatuspb.Statistic{{Name: "__name__", Value: 25}},
+		SeriesCountByLabelValuePair: []tsdbstatuspb.Statistic{{Name: "__name__=up", Value: 2}},
+	}, resp.Statistics)
+
+	// Head outside of the requested time range.
+	resp, err = tsdbStore.TSDBStatus(context.Background(), &tsdbstatuspb.TSDBStatusRequest{MinTime: 5000, MaxTime: 6000})
+	testutil.Ok(t, err)
+	testutil.Equals(t, uint64(0), resp.Statistics.NumSeries)
+	testutil.Equals(t, 0, len(resp.Statistics.SeriesCountByMetricName))
+}
+
 // Regression test for https://github.com/thanos-io/thanos/issues/1038.
 func TestTSDBStore_Series_SplitSamplesIntoChunksWithMaxSizeOf120(t *testing.T) {
 	defer testutil.TolerantVerifyLeak(t)
@@ -0,0 +1,224 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/thanos-io/thanos/pkg/runutil"
+)
+
+const (
+	// WarmupQueryLogFilename is the name of the query log file in the store directory.
+	WarmupQueryLogFilename = "warmup-query-log.json"
+
+	warmupQueryLogVersion1 = 1
+)
+
+// WarmupConfig configures warming up of new blocks before they are queryable.
+type WarmupConfig struct {
+	// LabelNames are the names of labels whose equality matchers in Series requests are recorded in the query log.
+	LabelNames []string
+	// MaxLabelPairs is the maximum number of the most frequently used label pairs whose postings are fetched.
+	MaxLabelPairs int
+}
+
+// warmupQueryLog counts how often label pairs are used by equality matchers of Series requests.
+type warmupQueryLog struct {
+	logger     log.Logger
+	path       string
+	labelNames map[string]struct{}
+	maxPairs   int
+
+	mtx    sync.Mutex
+	counts map[labels.Label]uint64
+	dirty  bool
+}
+
+type warmupQueryLogFile struct {
+	Version int                   `json:"version"`
+	Entries []warmupQueryLogEntry `json:"entries"`
+}
+
+type warmupQueryLogEntry struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Count uint64 `json:"count"`
+}
+
+// newWarmupQueryLog returns a query log persisted in the file at the given path. Entries already in the file are
+// loaded, a file which can't be decoded is ignored.
+func newWarmupQueryLog(logger log.Logger, path string, config WarmupConfig) (*warmupQueryLog, error) {
+	l := &warmupQueryLog{
+		logger:     logger,
+		path:       path,
+		labelNames: make(map[string]struct{}, len(config.LabelNames)),
+		maxPairs:   config.MaxLabelPairs,
+		counts:     map[labels.Label]uint64{},
+	}
+	for _, n := range config.LabelNames {
+		l.labelNames[n] = struct{}{}
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", path)
+	}
+
+	var f warmupQueryLogFile
+	if err := json.Unmarshal(b, &f); err != nil || f.Version != warmupQueryLogVersion1 {
+		level.Warn(logger).Log("msg", "ignoring warm up query log which cannot be decoded", "path", path, "version", f.Version, "err", err)
+		return l, nil
+	}
+	for _, e := range f.Entries {
+		if _, ok := l.labelNames[e.Name]; !ok {
+			continue
+		}
+		l.counts[labels.Label{Name: e.Name, Value: e.Value}] = e.Count
+	}
+	l.prune()
+	return l, nil
+}
+
+// record counts the label pairs of the given matchers.
+func (l *warmupQueryLog) record(matchers []*labels.Matcher) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	for _, m := range matchers {
+		if m.Type != labels.MatchEqual || m.Value == "" {
+			continue
+		}
+		if _, ok := l.labelNames[m.Name]; !ok {
+			continue
+		}
+		l.counts[labels.Label{Name: m.Name, Value: m.Value}]++
+		l.dirty = true
+	}
+	// Keep some more pairs than needed, so that new pairs have a chance to become frequent.
+	if len(l.counts) > 10*l.maxPairs {
+		l.prune()
+	}
+}
+
+// top returns the most frequently used label pairs, most frequent first.
+func (l *warmupQueryLog) top() []labels.Label {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.sorted(l.maxPairs)
+}
+
+// sorted returns up to n label pairs sorted by decreasing count. It must be called with the lock held.
+func (l *warmupQueryLog) sorted(n int) []labels.Label {
+	res := make([]labels.Label, 0, len(l.counts))
+	for lbl := range l.counts {
+		res = append(res, lbl)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if ci, cj := l.counts[res[i]], l.counts[res[j]]; ci != cj {
+			return ci > cj
+		}
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].Value < res[j].Value
+	})
+	if len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+// prune drops all but the most frequently used label pairs. It must be called with the lock held.
+func (l *warmupQueryLog) prune() {
+	if len(l.counts) <= l.maxPairs {
+		return
+	}
+	counts := make(map[labels.Label]uint64, l.maxPairs)
+	for _, lbl := range l.sorted(l.maxPairs) {
+		counts[lbl] = l.counts[lbl]
+	}
+	l.counts = counts
+}
+
+// save writes the query log to its file if it changed since it was last saved.
+func (l *warmupQueryLog) save() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if !l.dirty {
+		return nil
+	}
+
+	f := warmupQueryLogFile{Version: warmupQueryLogVersion1}
+	for _, lbl := range l.sorted(l.maxPairs) {
+		f.Entries = append(f.Entries, warmupQueryLogEntry{Name: lbl.Name, Value: lbl.Value, Count: l.counts[lbl]})
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return errors.Wrap(err, "encode warm up query log")
+	}
+
+	tmp := l.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0666); err != nil {
+		return errors.Wrapf(err, "write %s", tmp)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return errors.Wrapf(err, "rename %s", tmp)
+	}
+	l.dirty = false
+	return nil
+}
+
+// Close saves the query log.
+func (l *warmupQueryLog) Close() error {
+	return l.save()
+}
+
+// warmupBlock loads the index-header of the given block and fetches the postings of the label pairs most frequently
+// used by queries into the index cache. Failures are only logged, as the block is queryable without warm up.
+func (s *BucketStore) warmupBlock(ctx context.Context, b *bucketBlock) {
+	start := time.Now()
+	if err := b.warmup(ctx, s.warmupLog.top()); err != nil {
+		s.metrics.blockWarmupFailures.Inc()
+		level.Warn(b.logger).Log("msg", "warm up of block failed", "err", err)
+		return
+	}
+	s.metrics.blockWarmupDuration.Observe(time.Since(start).Seconds())
+	level.Debug(b.logger).Log("msg", "warmed up block", "elapsed", time.Since(start))
+}
+
+func (b *bucketBlock) warmup(ctx context.Context, keys []labels.Label) error {
+	// Lazy index-headers are loaded on their first use.
+	if _, err := b.indexHeaderReader.IndexVersion(); err != nil {
+		return errors.Wrap(err, "load index-header")
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	indexr := b.indexReader(ctx)
+	defer runutil.CloseWithLogOnErr(b.logger, indexr, "close warm up index reader")
+
+	// Fetched postings are stored in the index cache.
+	if _, err := indexr.fetchPostings(keys); err != nil {
+		return errors.Wrap(err, "fetch postings")
+	}
+	return nil
+}
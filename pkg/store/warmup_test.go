@@ -0,0 +1,163 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+	"github.com/thanos-io/thanos/pkg/testutil"
+	"github.com/thanos-io/thanos/pkg/testutil/e2eutil"
+)
+
+func TestWarmupQueryLog(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test-warmup-query-log")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(tmpDir)) }()
+
+	var (
+		logger = log.NewNopLogger()
+		path   = filepath.Join(tmpDir, WarmupQueryLogFilename)
+		config = WarmupConfig{LabelNames: []string{labels.MetricName, "job"}, MaxLabelPairs: 2}
+	)
+
+	l, err := newWarmupQueryLog(logger, path, config)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []labels.Label{}, l.top())
+
+	for i := 0; i < 3; i++ {
+		l.record([]*labels.Matcher{
+			labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "up"),
+			labels.MustNewMatcher(labels.MatchEqual, "job", fmt.Sprintf("job-%d", i)),
+			// Only equality matchers of configured label names are recorded.
+			labels.MustNewMatcher(labels.MatchEqual, "pod", "a"),
+			labels.MustNewMatcher(labels.MatchRegexp, "job", "api"),
+			labels.MustNewMatcher(labels.MatchEqual, "job", ""),
+		})
+	}
+	l.record([]*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "job", "job-1")})
+	testutil.Equals(t, []labels.Label{{Name: labels.MetricName, Value: "up"}, {Name: "job", Value: "job-1"}}, l.top())
+
+	// The log is restored from its file.
+	testutil.Ok(t, l.Close())
+	l, err = newWarmupQueryLog(logger, path, config)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []labels.Label{{Name: labels.MetricName, Value: "up"}, {Name: "job", Value: "job-1"}}, l.top())
+
+	// Label names which are not configured anymore are dropped.
+	l, err = newWarmupQueryLog(logger, path, WarmupConfig{LabelNames: []string{"job"}, MaxLabelPairs: 2})
+	testutil.Ok(t, err)
+	testutil.Equals(t, []labels.Label{{Name: "job", Value: "job-1"}}, l.top())
+
+	// Corrupted files are ignored.
+	testutil.Ok(t, ioutil.WriteFile(path, []byte("{"), 0666))
+	l, err = newWarmupQueryLog(logger, path, config)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []labels.Label{}, l.top())
+}
+
+type postingsRecordingCache struct {
+	noopCache
+
+	mtx      sync.Mutex
+	postings map[ulid.ULID][]labels.Label
+}
+
+func (c *postingsRecordingCache) StorePostings(_ context.Context, id ulid.ULID, l labels.Label, _ []byte) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.postings[id] = append(c.postings[id], l)
+}
+
+func TestBucketStore_Warmup(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test-bucket-store-warmup")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(tmpDir)) }()
+
+	var (
+		ctx    = context.Background()
+		logger = log.NewNopLogger()
+		bkt    = objstore.WithNoopInstr(objstore.NewInMemBucket())
+		config = WarmupConfig{LabelNames: []string{labels.MetricName, "job"}, MaxLabelPairs: 10}
+	)
+
+	id, err := e2eutil.CreateBlock(ctx, tmpDir, []labels.Labels{
+		labels.FromStrings(labels.MetricName, "up", "job", "api", "pod", "a"),
+		labels.FromStrings(labels.MetricName, "up", "job", "db", "pod", "b"),
+	}, 10, 0, 1000, labels.FromStrings("ext1", "1"), 0, metadata.NoneFunc)
+	testutil.Ok(t, err)
+	testutil.Ok(t, block.Upload(ctx, logger, bkt, filepath.Join(tmpDir, id.String()), metadata.NoneFunc))
+
+	newStore := func(cache *postingsRecordingCache) *BucketStore {
+		fetcher, err := block.NewMetaFetcher(logger, 10, bkt, tmpDir, nil, nil, nil)
+		testutil.Ok(t, err)
+
+		store, err := NewBucketStore(
+			logger,
+			nil,
+			bkt,
+			fetcher,
+			filepath.Join(tmpDir, "store"),
+			cache,
+			nil,
+			nil,
+			NewChunksLimiterFactory(0),
+			NewSeriesLimiterFactory(0),
+			NewGapBasedPartitioner(PartitionerMaxGapSize),
+			false,
+			10,
+			nil,
+			false,
+			DefaultPostingOffsetInMemorySampling,
+			true,
+			true,
+			0,
+			WithWarmup(config),
+		)
+		testutil.Ok(t, err)
+		return store
+	}
+
+	// Without a query log, only the index-header is loaded.
+	cache := &postingsRecordingCache{postings: map[ulid.ULID][]labels.Label{}}
+	store := newStore(cache)
+	testutil.Ok(t, store.InitialSync(ctx))
+	testutil.Equals(t, 0, len(cache.postings))
+	testutil.Equals(t, 1, promtest.CollectAndCount(store.metrics.blockWarmupDuration))
+	testutil.Equals(t, 0.0, promtest.ToFloat64(store.metrics.blockWarmupFailures))
+
+	testutil.Ok(t, store.Series(&storepb.SeriesRequest{
+		MinTime: 0,
+		MaxTime: 1000,
+		Matchers: []storepb.LabelMatcher{
+			{Type: storepb.LabelMatcher_EQ, Name: labels.MetricName, Value: "up"},
+			{Type: storepb.LabelMatcher_EQ, Name: "job", Value: "api"},
+			{Type: storepb.LabelMatcher_EQ, Name: "pod", Value: "a"},
+		},
+	}, newStoreSeriesServer(ctx)))
+	testutil.Ok(t, store.Close())
+
+	// After a restart, postings of the recorded label pairs are fetched before the block is queryable.
+	cache = &postingsRecordingCache{postings: map[ulid.ULID][]labels.Label{}}
+	store = newStore(cache)
+	defer func() { testutil.Ok(t, store.Close()) }()
+	testutil.Ok(t, store.InitialSync(ctx))
+	testutil.Equals(t, map[ulid.ULID][]labels.Label{
+		id: {{Name: labels.MetricName, Value: "up"}, {Name: "job", Value: "api"}},
+	}, cache.postings)
+}
@@ -0,0 +1,36 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package tsdbstatus
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/thanos-io/thanos/pkg/promclient"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus/tsdbstatuspb"
+)
+
+// Prometheus implements tsdbstatuspb.TSDBStatus gRPC that allows to fetch cardinality statistics from Prometheus HTTP /api/v1/status/tsdb endpoint.
+type Prometheus struct {
+	base   *url.URL
+	client *promclient.Client
+}
+
+// NewPrometheus creates a new tsdbstatus.Prometheus.
+func NewPrometheus(base *url.URL, client *promclient.Client) *Prometheus {
+	return &Prometheus{
+		base:   base,
+		client: client,
+	}
+}
+
+// TSDBStatus returns cardinality statistics of the Prometheus head block. The time range of the request is not applied,
+// and Prometheus returns at most top 10 entries of each list.
+func (p *Prometheus) TSDBStatus(ctx context.Context, r *tsdbstatuspb.TSDBStatusRequest) (*tsdbstatuspb.TSDBStatusResponse, error) {
+	stats, err := p.client.TSDBStatusInGRPC(ctx, p.base)
+	if err != nil {
+		return nil, err
+	}
+	return &tsdbstatuspb.TSDBStatusResponse{Statistics: Merge(RequestLimit(r), stats)}, nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package tsdbstatus
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus/tsdbstatuspb"
+)
+
+// Proxy implements tsdbstatuspb.TSDBStatus gRPC that fanouts requests to given tsdbstatuspb.TSDBStatus and merges the statistics on the way.
+type Proxy struct {
+	logger     log.Logger
+	tsdbStatus func() []tsdbstatuspb.TSDBStatusClient
+}
+
+// NewProxy returns a new tsdbstatus.Proxy.
+func NewProxy(logger log.Logger, tsdbStatus func() []tsdbstatuspb.TSDBStatusClient) *Proxy {
+	return &Proxy{
+		logger:     logger,
+		tsdbStatus: tsdbStatus,
+	}
+}
+
+// TSDBStatus returns merged cardinality statistics of all clients. Clients which do not implement the TSDBStatus API are skipped.
+func (s *Proxy) TSDBStatus(ctx context.Context, req *tsdbstatuspb.TSDBStatusRequest) (*tsdbstatuspb.TSDBStatusResponse, error) {
+	var (
+		g, gctx = errgroup.WithContext(ctx)
+		mtx     sync.Mutex
+		stats   []tsdbstatuspb.TSDBStatistics
+		resp    = &tsdbstatuspb.TSDBStatusResponse{}
+	)
+
+	for _, tsdbStatusClient := range s.tsdbStatus() {
+		client := tsdbStatusClient
+		g.Go(func() error {
+			r, err := client.TSDBStatus(gctx, req)
+			if err != nil {
+				if status.Code(err) == codes.Unimplemented {
+					level.Debug(s.logger).Log("msg", "skipping client without TSDBStatus API", "client", client)
+					return nil
+				}
+				err = errors.Wrapf(err, "fetching tsdb status from client %v", client)
+				if req.PartialResponseStrategy == storepb.PartialResponseStrategy_ABORT {
+					return err
+				}
+
+				mtx.Lock()
+				resp.Warnings = append(resp.Warnings, err.Error())
+				mtx.Unlock()
+				return nil
+			}
+
+			mtx.Lock()
+			stats = append(stats, r.Statistics)
+			resp.Warnings = append(resp.Warnings, r.Warnings...)
+			mtx.Unlock()
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		level.Error(s.logger).Log("err", err)
+		return nil, err
+	}
+
+	resp.Statistics = Merge(RequestLimit(req), stats...)
+	return resp, nil
+}
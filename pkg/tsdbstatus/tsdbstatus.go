@@ -0,0 +1,180 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package tsdbstatus
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"google.golang.org/grpc"
+
+	"github.com/thanos-io/thanos/pkg/tsdbstatus/tsdbstatuspb"
+)
+
+// DefaultLimit is the number of entries in each list of statistics when the request does not specify any, as in Prometheus.
+const DefaultLimit = 10
+
+func RegisterTSDBStatusServer(tsdbStatusSrv tsdbstatuspb.TSDBStatusServer) func(*grpc.Server) {
+	return func(s *grpc.Server) {
+		tsdbstatuspb.RegisterTSDBStatusServer(s, tsdbStatusSrv)
+	}
+}
+
+// RequestLimit returns the number of entries in each list of statistics requested by r.
+func RequestLimit(r *tsdbstatuspb.TSDBStatusRequest) int {
+	if r.Limit <= 0 {
+		return DefaultLimit
+	}
+	return int(r.Limit)
+}
+
+// StatisticsBuilder builds cardinality statistics from label pairs and the number of their series.
+type StatisticsBuilder struct {
+	numSeries uint64
+	pairs     map[string]map[string]uint64
+}
+
+// NewStatisticsBuilder returns a new StatisticsBuilder.
+func NewStatisticsBuilder() *StatisticsBuilder {
+	return &StatisticsBuilder{pairs: map[string]map[string]uint64{}}
+}
+
+// AddSeries adds n series to the total number of series.
+func (b *StatisticsBuilder) AddSeries(n uint64) {
+	b.numSeries += n
+}
+
+// AddLabelPair adds the number of series with the given label pair.
+// Label name and value are copied, so they can point to memory which is released afterwards, e.g. mmaped index.
+func (b *StatisticsBuilder) AddLabelPair(name, value string, series uint64) {
+	values, ok := b.pairs[name]
+	if !ok {
+		values = map[string]uint64{}
+		b.pairs[copyString(name)] = values
+	}
+	if _, ok := values[value]; !ok {
+		value = copyString(value)
+	}
+	values[value] += series
+}
+
+// AddIndex adds all series and label pairs of the index.
+func (b *StatisticsBuilder) AddIndex(ir tsdb.IndexReader) error {
+	allName, allValue := index.AllPostingsKey()
+	n, err := countPostings(ir, allName, allValue)
+	if err != nil {
+		return err
+	}
+	b.AddSeries(n)
+
+	names, err := ir.LabelNames()
+	if err != nil {
+		return errors.Wrap(err, "label names")
+	}
+	for _, name := range names {
+		values, err := ir.LabelValues(name)
+		if err != nil {
+			return errors.Wrapf(err, "label values of %s", name)
+		}
+		for _, value := range values {
+			n, err := countPostings(ir, name, value)
+			if err != nil {
+				return err
+			}
+			b.AddLabelPair(name, value, n)
+		}
+	}
+	return nil
+}
+
+func countPostings(ir tsdb.IndexReader, name, value string) (uint64, error) {
+	p, err := ir.Postings(name, value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "postings of %s=%q", name, value)
+	}
+	var n uint64
+	for p.Next() {
+		n++
+	}
+	return n, errors.Wrapf(p.Err(), "iterate postings of %s=%q", name, value)
+}
+
+// Build returns statistics with up to limit entries in each list.
+func (b *StatisticsBuilder) Build(limit int) tsdbstatuspb.TSDBStatistics {
+	var (
+		metrics, valueCounts, memory, pairs []tsdbstatuspb.Statistic
+	)
+	for name, values := range b.pairs {
+		var size uint64
+		for value, series := range values {
+			if name == labels.MetricName {
+				metrics = append(metrics, tsdbstatuspb.Statistic{Name: value, Value: series})
+			}
+			pairs = append(pairs, tsdbstatuspb.Statistic{Name: name + "=" + value, Value: series})
+			size += uint64(len(value))
+		}
+		valueCounts = append(valueCounts, tsdbstatuspb.Statistic{Name: name, Value: uint64(len(values))})
+		memory = append(memory, tsdbstatuspb.Statistic{Name: name, Value: size})
+	}
+	return tsdbstatuspb.TSDBStatistics{
+		NumSeries:                   b.numSeries,
+		SeriesCountByMetricName:     top(metrics, limit),
+		LabelValueCountByLabelName:  top(valueCounts, limit),
+		MemoryInBytesByLabelName:    top(memory, limit),
+		SeriesCountByLabelValuePair: top(pairs, limit),
+	}
+}
+
+// Merge merges statistics of multiple sources into statistics with up to limit entries in each list.
+// Values of the same entries are added up, so label value counts are upper bounds if sources share label values.
+// As sources return only their top entries, merged entries missing in some sources are approximations.
+func Merge(limit int, stats ...tsdbstatuspb.TSDBStatistics) tsdbstatuspb.TSDBStatistics {
+	var res tsdbstatuspb.TSDBStatistics
+	for _, s := range stats {
+		res.NumSeries += s.NumSeries
+	}
+	res.SeriesCountByMetricName = mergeStatistics(limit, stats, func(s tsdbstatuspb.TSDBStatistics) []tsdbstatuspb.Statistic { return s.SeriesCountByMetricName })
+	res.LabelValueCountByLabelName = mergeStatistics(limit, stats, func(s tsdbstatuspb.TSDBStatistics) []tsdbstatuspb.Statistic { return s.LabelValueCountByLabelName })
+	res.MemoryInBytesByLabelName = mergeStatistics(limit, stats, func(s tsdbstatuspb.TSDBStatistics) []tsdbstatuspb.Statistic { return s.MemoryInBytesByLabelName })
+	res.SeriesCountByLabelValuePair = mergeStatistics(limit, stats, func(s tsdbstatuspb.TSDBStatistics) []tsdbstatuspb.Statistic { return s.SeriesCountByLabelValuePair })
+	return res
+}
+
+func mergeStatistics(limit int, stats []tsdbstatuspb.TSDBStatistics, list func(tsdbstatuspb.TSDBStatistics) []tsdbstatuspb.Statistic) []tsdbstatuspb.Statistic {
+	values := map[string]uint64{}
+	for _, s := range stats {
+		for _, e := range list(s) {
+			values[e.Name] += e.Value
+		}
+	}
+	res := make([]tsdbstatuspb.Statistic, 0, len(values))
+	for name, value := range values {
+		res = append(res, tsdbstatuspb.Statistic{Name: name, Value: value})
+	}
+	return top(res, limit)
+}
+
+// top sorts the statistics by value in descending order and returns up to limit of them.
+func top(stats []tsdbstatuspb.Statistic, limit int) []tsdbstatuspb.Statistic {
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Value != stats[j].Value {
+			return stats[i].Value > stats[j].Value
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	if limit > 0 && len(stats) > limit {
+		stats = stats[:limit]
+	}
+	if stats == nil {
+		return []tsdbstatuspb.Statistic{}
+	}
+	return stats
+}
+
+func copyString(s string) string {
+	return string([]byte(s))
+}
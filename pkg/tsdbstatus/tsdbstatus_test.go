@@ -0,0 +1,137 @@
+// Copyright (c) The Thanos Authors.
+// Licensed under the Apache License 2.0.
+
+package tsdbstatus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+	"github.com/thanos-io/thanos/pkg/testutil"
+	"github.com/thanos-io/thanos/pkg/tsdbstatus/tsdbstatuspb"
+)
+
+func TestStatisticsBuilder(t *testing.T) {
+	b := NewStatisticsBuilder()
+	b.AddSeries(3)
+	b.AddLabelPair("__name__", "up", 2)
+	b.AddLabelPair("__name__", "scrape_duration_seconds", 1)
+	b.AddLabelPair("job", "a", 2)
+	b.AddLabelPair("job", "b", 1)
+	b.AddLabelPair("job", "a", 1)
+
+	testutil.Equals(t, tsdbstatuspb.TSDBStatistics{
+		NumSeries:                   3,
+		SeriesCountByMetricName:     []tsdbstatuspb.Statistic{{Name: "up", Value: 2}, {Name: "scrape_duration_seconds", Value: 1}},
+		LabelValueCountByLabelName:  []tsdbstatuspb.Statistic{{Name: "__name__", Value: 2}, {Name: "job", Value: 2}},
+		MemoryInBytesByLabelName:    []tsdbstatuspb.Statistic{{Name: "__name__", Value: 25}, {Name: "job", Value: 2}},
+		SeriesCountByLabelValuePair: []tsdbstatuspb.Statistic{{Name: "job=a", Value: 3}, {Name: "__name__=up", Value: 2}},
+	}, b.Build(2))
+}
+
+func TestMerge(t *testing.T) {
+	a := tsdbstatuspb.TSDBStatistics{
+		NumSeries:                   3,
+		SeriesCountByMetricName:     []tsdbstatuspb.Statistic{{Name: "up", Value: 2}, {Name: "scrape_duration_seconds", Value: 1}},
+		LabelValueCountByLabelName:  []tsdbstatuspb.Statistic{{Name: "job", Value: 2}},
+		MemoryInBytesByLabelName:    []tsdbstatuspb.Statistic{{Name: "job", Value: 2}},
+		SeriesCountByLabelValuePair: []tsdbstatuspb.Statistic{{Name: "job=a", Value: 2}},
+	}
+	b := tsdbstatuspb.TSDBStatistics{
+		NumSeries:                   5,
+		SeriesCountByMetricName:     []tsdbstatuspb.Statistic{{Name: "scrape_duration_seconds", Value: 4}, {Name: "up", Value: 1}},
+		LabelValueCountByLabelName:  []tsdbstatuspb.Statistic{{Name: "instance", Value: 5}},
+		MemoryInBytesByLabelName:    []tsdbstatuspb.Statistic{{Name: "instance", Value: 50}},
+		SeriesCountByLabelValuePair: []tsdbstatuspb.Statistic{{Name: "job=a", Value: 5}},
+	}
+
+	testutil.Equals(t, tsdbstatuspb.TSDBStatistics{
+		NumSeries:                   8,
+		SeriesCountByMetricName:     []tsdbstatuspb.Statistic{{Name: "scrape_duration_seconds", Value: 5}},
+		LabelValueCountByLabelName:  []tsdbstatuspb.Statistic{{Name: "instance", Value: 5}},
+		MemoryInBytesByLabelName:    []tsdbstatuspb.Statistic{{Name: "instance", Value: 50}},
+		SeriesCountByLabelValuePair: []tsdbstatuspb.Statistic{{Name: "job=a", Value: 7}},
+	}, Merge(1, a, b))
+
+	testutil.Equals(t, tsdbstatuspb.TSDBStatistics{
+		SeriesCountByMetricName:     []tsdbstatuspb.Statistic{},
+		LabelValueCountByLabelName:  []tsdbstatuspb.Statistic{},
+		MemoryInBytesByLabelName:    []tsdbstatuspb.Statistic{},
+		SeriesCountByLabelValuePair: []tsdbstatuspb.Statistic{},
+	}, Merge(1))
+}
+
+type testTSDBStatusClient struct {
+	response *tsdbstatuspb.TSDBStatusResponse
+	err      error
+}
+
+func (c *testTSDBStatusClient) String() string {
+	return "test"
+}
+
+func (c *testTSDBStatusClient) TSDBStatus(context.Context, *tsdbstatuspb.TSDBStatusRequest, ...grpc.CallOption) (*tsdbstatuspb.TSDBStatusResponse, error) {
+	return c.response, c.err
+}
+
+var _ tsdbstatuspb.TSDBStatusClient = &testTSDBStatusClient{}
+
+func TestProxy_TSDBStatus(t *testing.T) {
+	ok := &testTSDBStatusClient{response: &tsdbstatuspb.TSDBStatusResponse{
+		Statistics: tsdbstatuspb.TSDBStatistics{
+			NumSeries:               2,
+			SeriesCountByMetricName: []tsdbstatuspb.Statistic{{Name: "up", Value: 2}},
+		},
+		Warnings: []string{"partial"},
+	}}
+	unimplemented := &testTSDBStatusClient{err: status.Error(codes.Unimplemented, "unimplemented")}
+	failing := &testTSDBStatusClient{err: errors.New("failure")}
+
+	for _, tc := range []struct {
+		name             string
+		clients          []tsdbstatuspb.TSDBStatusClient
+		strategy         storepb.PartialResponseStrategy
+		expectedErr      bool
+		expectedSeries   uint64
+		expectedWarnings int
+	}{
+		{
+			name:             "merges responses and skips unimplemented clients",
+			clients:          []tsdbstatuspb.TSDBStatusClient{ok, ok, unimplemented},
+			expectedSeries:   4,
+			expectedWarnings: 2,
+		},
+		{
+			name:        "failing client with abort strategy",
+			clients:     []tsdbstatuspb.TSDBStatusClient{ok, failing},
+			strategy:    storepb.PartialResponseStrategy_ABORT,
+			expectedErr: true,
+		},
+		{
+			name:             "failing client with warn strategy",
+			clients:          []tsdbstatuspb.TSDBStatusClient{ok, failing},
+			strategy:         storepb.PartialResponseStrategy_WARN,
+			expectedSeries:   2,
+			expectedWarnings: 2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewProxy(log.NewNopLogger(), func() []tsdbstatuspb.TSDBStatusClient { return tc.clients })
+			resp, err := p.TSDBStatus(context.Background(), &tsdbstatuspb.TSDBStatusRequest{PartialResponseStrategy: tc.strategy})
+			if tc.expectedErr {
+				testutil.NotOk(t, err)
+				return
+			}
+			testutil.Ok(t, err)
+			testutil.Equals(t, tc.expectedSeries, resp.Statistics.NumSeries)
+			testutil.Equals(t, tc.expectedWarnings, len(resp.Warnings))
+		})
+	}
+}